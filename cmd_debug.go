@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/kasworld/nonkey/interpreter/debugger"
+	"github.com/kasworld/nonkey/interpreter/object"
+	"github.com/kasworld/nonkey/interpreter/runmon"
+)
+
+// runDebug implements `nonkey debug [-autoload file] [-break lines]
+// script`, which runs the script under the debugger, stopped before
+// its first statement.
+func runDebug(args []string) int {
+	flags := flag.NewFlagSet("debug", flag.ExitOnError)
+	autoload := flags.String("autoload", "", "autoload filename")
+	breaks := flags.String("break", "", "comma-separated lines to set breakpoints on")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: nonkey debug [-autoload file] [-break lines] script\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	name := flags.Arg(0)
+	src, err := ioutil.ReadFile(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "debug: %s\n", err)
+		return 2
+	}
+
+	d := debugger.New(os.Stdin, os.Stdout)
+	if *breaks != "" {
+		for _, s := range strings.Split(*breaks, ",") {
+			line, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "debug: invalid line %q\n", s)
+				return 2
+			}
+			d.Break(line)
+		}
+	}
+
+	env := object.NewEnvironment()
+	if *autoload != "" {
+		env = runmon.RunFile(*autoload, env)
+	}
+	if err := d.Run(name, string(src), env); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/kasworld/nonkey/interpreter/format"
+)
+
+// runFmt implements `nonkey fmt [-w] files...`, which prints each file
+// in the canonical layout, or with -w rewrites those not already in
+// it.  With no files it formats standard input.
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := flags.Bool("w", false, "write the result to each file, rather than standard output")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: nonkey fmt [-w] [files...]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		if *write {
+			fmt.Fprintf(os.Stderr, "fmt: -w needs files to write\n")
+			return 2
+		}
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "fmt: %s\n", err)
+			return 1
+		}
+		out, err := format.Source(string(src))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "<stdin>:%s\n", err)
+			return 1
+		}
+		os.Stdout.WriteString(out)
+		return 0
+	}
+
+	status := 0
+	for _, name := range flags.Args() {
+		if err := formatFile(name, *write); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// formatFile formats the file name, rewriting it if write is set and
+// printing it otherwise.
+func formatFile(name string, write bool) error {
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	src, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	out, err := format.Source(string(src))
+	if err != nil {
+		return fmt.Errorf("%s:%s", name, err)
+	}
+	if !write {
+		_, err = os.Stdout.WriteString(out)
+		return err
+	}
+	if out == string(src) {
+		return nil
+	}
+	return ioutil.WriteFile(name, []byte(out), info.Mode().Perm())
+}
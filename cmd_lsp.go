@@ -0,0 +1,30 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/kasworld/nonkey/interpreter/lsp"
+)
+
+// runLsp implements `nonkey lsp`, which serves the Language Server
+// Protocol on stdin and stdout for editors.
+func runLsp(args []string) int {
+	flags := flag.NewFlagSet("lsp", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: nonkey lsp\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "lsp: %s\n", err)
+		return 1
+	}
+	return 0
+}
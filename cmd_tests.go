@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/kasworld/nonkey/interpreter/object"
+	"github.com/kasworld/nonkey/interpreter/runmon"
+	"github.com/kasworld/nonkey/interpreter/tester"
+)
+
+// runTest implements `nonkey test [-format text|tap|junit] [-autoload
+// file] [dirs or files...]`, which runs the tests in the files named
+// *_test.mon in each directory, or the current one, and below, and in
+// each file given.  It exits non-zero if any fail.
+func runTest(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	format := flags.String("format", "text", "how to report the results: text, tap or junit")
+	autoload := flags.String("autoload", "", "file to run before each test")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: nonkey test [-format text|tap|junit] [-autoload file] [dirs or files...]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	write := map[string]func(w io.Writer, results []tester.Result) error{
+		"text":  tester.WriteText,
+		"tap":   tester.WriteTAP,
+		"junit": tester.WriteJUnit,
+	}[*format]
+	if write == nil {
+		flags.Usage()
+		return 2
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "test: %s\n", err)
+			return 2
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		found, err := tester.Find(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "test: %s\n", err)
+			return 2
+		}
+		files = append(files, found...)
+	}
+
+	var setup func(env *object.Environment)
+	if *autoload != "" {
+		setup = func(env *object.Environment) {
+			runmon.RunFile(*autoload, env)
+		}
+	}
+
+	var results []tester.Result
+	for _, name := range files {
+		src, err := ioutil.ReadFile(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "test: %s\n", err)
+			return 2
+		}
+		results = append(results, tester.Run(name, string(src), setup)...)
+	}
+
+	if err := write(os.Stdout, results); err != nil {
+		fmt.Fprintf(os.Stderr, "test: %s\n", err)
+		return 1
+	}
+	for _, r := range results {
+		if !r.Passed() {
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/kasworld/nonkey/interpreter/ast"
+	"github.com/kasworld/nonkey/interpreter/lexer"
+	"github.com/kasworld/nonkey/interpreter/parser"
+	"github.com/kasworld/nonkey/interpreter/vet"
+)
+
+// runVet implements `nonkey vet [-json] [-autoload file] files...`,
+// which reports suspicious code in each file as file:line:col, or as
+// a JSON array of diagnostics.  It exits non-zero if any are found.
+func runVet(args []string) int {
+	flags := flag.NewFlagSet("vet", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the diagnostics as JSON")
+	autoload := flags.String("autoload", "", "file whose definitions the files may use")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: nonkey vet [-json] [-autoload file] files...\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	var preludes []*ast.Program
+	if *autoload != "" {
+		src, err := ioutil.ReadFile(*autoload)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "vet: %s\n", err)
+			return 2
+		}
+		p := parser.New(lexer.New(string(src)))
+		preludes = append(preludes, p.ParseProgram())
+	}
+
+	diags := []vet.Diagnostic{}
+	for _, name := range flags.Args() {
+		src, err := ioutil.ReadFile(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "vet: %s\n", err)
+			return 2
+		}
+		diags = append(diags, vet.Source(name, string(src), preludes...)...)
+	}
+
+	if *asJSON {
+		out, _ := json.MarshalIndent(diags, "", "  ")
+		fmt.Printf("%s\n", out)
+	} else {
+		for _, d := range diags {
+			fmt.Println(d)
+		}
+	}
+	if len(diags) > 0 {
+		return 1
+	}
+	return 0
+}
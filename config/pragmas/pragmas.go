@@ -1,3 +1,46 @@
 package pragmas
 
-var PRAGMAS = make(map[string]int)
+import (
+	"sort"
+	"sync"
+)
+
+// pragmas holds the enabled pragmas; it's guarded by mu since spawned
+// functions may call pragma() concurrently.
+var (
+	mu      sync.RWMutex
+	pragmas = make(map[string]int)
+)
+
+// Enable turns the named pragma on.
+func Enable(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	pragmas[name] = 1
+}
+
+// Disable turns the named pragma off.
+func Disable(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(pragmas, name)
+}
+
+// Enabled reports whether the named pragma is on.
+func Enabled(name string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return pragmas[name] == 1
+}
+
+// Names returns the enabled pragmas, sorted.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(pragmas))
+	for name := range pragmas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kasworld/nonkey/interpreter/cover"
+	"github.com/kasworld/nonkey/interpreter/object"
+	"github.com/kasworld/nonkey/interpreter/runmon"
+)
+
+// runCovered implements `nonkey -cover out.cov script`, which runs the
+// script in env writing which of its statements and branches ran to
+// out, and the source marked with them to an HTML file beside it.
+func runCovered(name, out string, env *object.Environment) int {
+	src, err := ioutil.ReadFile(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cover: %s\n", err)
+		return 2
+	}
+
+	program := runmon.Parse(name, string(src))
+	c := cover.New(name, string(src), program)
+	if program != nil {
+		c.Start(env)
+		runmon.RunProgram(name, string(src), program, env)
+		c.Stop(env)
+	}
+
+	statements, branches := c.Percent()
+	fmt.Fprintf(os.Stderr, "coverage: %.1f%% of statements, %.1f%% of branches\n", statements, branches)
+
+	page := strings.TrimSuffix(out, filepath.Ext(out)) + ".html"
+	if page == out {
+		page += ".html"
+	}
+	if err := writeFile(out, c.WriteProfile); err != nil {
+		fmt.Fprintf(os.Stderr, "cover: %s\n", err)
+		return 1
+	}
+	if err := writeFile(page, c.WriteHTML); err != nil {
+		fmt.Fprintf(os.Stderr, "cover: %s\n", err)
+		return 1
+	}
+	return 0
+}
@@ -20,6 +20,14 @@ const (
 	HASH                           // HASH
 	FILE                           // FILE
 	REGEXP                         // REGEXP
+	SET                            // SET
+	ITERATOR                       // ITERATOR
+	RANGE                          // RANGE
+	CHANNEL                        // CHANNEL
+	BYTES                          // BYTES
+	PROCESS                        // PROCESS
+	TIME                           // TIME
+	DURATION                       // DURATION
 	//
 
 	ObjectType_Count int = iota
@@ -39,6 +47,14 @@ var _ObjectType2string = [ObjectType_Count][2]string{
 	HASH:         {"HASH", "HASH"},
 	FILE:         {"FILE", "FILE"},
 	REGEXP:       {"REGEXP", "REGEXP"},
+	SET:          {"SET", "SET"},
+	ITERATOR:     {"ITERATOR", "ITERATOR"},
+	RANGE:        {"RANGE", "RANGE"},
+	CHANNEL:      {"CHANNEL", "CHANNEL"},
+	BYTES:        {"BYTES", "BYTES"},
+	PROCESS:      {"PROCESS", "PROCESS"},
+	TIME:         {"TIME", "TIME"},
+	DURATION:     {"DURATION", "DURATION"},
 }
 
 func (e ObjectType) String() string {
@@ -69,6 +85,14 @@ var _string2ObjectType = map[string]ObjectType{
 	"HASH":         HASH,
 	"FILE":         FILE,
 	"REGEXP":       REGEXP,
+	"SET":          SET,
+	"ITERATOR":     ITERATOR,
+	"RANGE":        RANGE,
+	"CHANNEL":      CHANNEL,
+	"BYTES":        BYTES,
+	"PROCESS":      PROCESS,
+	"TIME":         TIME,
+	"DURATION":     DURATION,
 }
 
 func String2ObjectType(s string) (ObjectType, bool) {
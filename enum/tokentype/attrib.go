@@ -16,29 +16,36 @@ var attrib = [TokenType_Count]struct {
 }{
 	ILLEGAL: {false, "ILLEGAL"},
 	REGEXP:  {false, "REGEXP"},
+	COMMENT: {false, "COMMENT"},
 	EOF:     {false, "EOF"},
 	EOL:     {false, "EOL"},
 	IDENT:   {false, "IDENT"},
 
+	// literals, whose names are also those of the builtins which
+	// convert to them, so they aren't keywords
+	FLOAT:  {false, "float"},
+	INT:    {false, "int"},
+	STRING: {false, "string"},
+
 	// keyword
 	CASE:            {true, "case"},
 	CONST:           {true, "const"},
 	DEFAULT:         {true, "default"},
 	DEFINE_FUNCTION: {true, "function"},
 	ELSE:            {true, "else"},
-	STRING:          {true, "string"},
 	SWITCH:          {true, "switch"},
 	TRUE:            {true, "true"},
 	FALSE:           {true, "false"},
-	FLOAT:           {true, "float"},
 	FOR:             {true, "for"},
 	FOREACH:         {true, "foreach"},
 	FUNCTION:        {true, "fn"},
 	IF:              {true, "if"},
 	IN:              {true, "in"},
-	INT:             {true, "int"},
 	LET:             {true, "let"},
 	RETURN:          {true, "return"},
+	SELECT:          {true, "select"},
+	SPAWN:           {true, "spawn"},
+	YIELD:           {true, "yield"},
 
 	BACKTICK:    {false, "`"},
 	BANG:        {false, "!"},
@@ -76,6 +83,8 @@ var attrib = [TokenType_Count]struct {
 	MOD:             {false, "%"},
 	AND:             {false, "&&"},
 	OR:              {false, "||"},
+	AMPERSAND:       {false, "&"},
+	PIPE:            {false, "|"},
 	LPAREN:          {false, "("},
 	PERIOD:          {false, "."},
 	LBRACKET:        {false, "["},
@@ -123,6 +132,8 @@ var Token2Precedences = [TokenType_Count]precedence.Precedence{
 	SLASH_EQUALS:    precedence.PRODUCT,
 	ASTERISK:        precedence.PRODUCT,
 	ASTERISK_EQUALS: precedence.PRODUCT,
+	AMPERSAND:       precedence.PRODUCT,
+	PIPE:            precedence.SUM,
 	POW:             precedence.POWER,
 	MOD:             precedence.MOD,
 	AND:             precedence.COND,
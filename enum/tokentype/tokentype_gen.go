@@ -12,6 +12,7 @@ const (
 	EOL                      // EOL
 	IDENT                    // IDENT
 	REGEXP                   // REGEXP
+	COMMENT                  // COMMENT
 	//
 	CASE            // case
 	CONST           // const
@@ -28,11 +29,15 @@ const (
 	INT             // int
 	LET             // let
 	RETURN          // return
+	SELECT          // select
+	SPAWN           // spawn
 	STRING          // string
 	SWITCH          // switch
 	TRUE            // true
+	YIELD           // yield
 	//
 	AND             // &&
+	AMPERSAND       // &
 	ASSIGN          // =
 	ASTERISK        // *
 	ASTERISK_EQUALS // *=
@@ -58,6 +63,7 @@ const (
 	NOT_EQ          // !=
 	OR              // ||
 	PERIOD          // .
+	PIPE            // |
 	PLUS            // +
 	PLUS_EQUALS     // +=
 	PLUS_PLUS       // ++
@@ -80,6 +86,7 @@ var _TokenType2string = [TokenType_Count][2]string{
 	EOL:             {"EOL", "EOL"},
 	IDENT:           {"IDENT", "IDENT"},
 	REGEXP:          {"REGEXP", "REGEXP"},
+	COMMENT:         {"COMMENT", "COMMENT"},
 	CASE:            {"CASE", "case"},
 	CONST:           {"CONST", "const"},
 	DEFAULT:         {"DEFAULT", "default"},
@@ -95,10 +102,14 @@ var _TokenType2string = [TokenType_Count][2]string{
 	INT:             {"INT", "int"},
 	LET:             {"LET", "let"},
 	RETURN:          {"RETURN", "return"},
+	SELECT:          {"SELECT", "select"},
+	SPAWN:           {"SPAWN", "spawn"},
 	STRING:          {"STRING", "string"},
 	SWITCH:          {"SWITCH", "switch"},
 	TRUE:            {"TRUE", "true"},
+	YIELD:           {"YIELD", "yield"},
 	AND:             {"AND", "&&"},
+	AMPERSAND:       {"AMPERSAND", "&"},
 	ASSIGN:          {"ASSIGN", "="},
 	ASTERISK:        {"ASTERISK", "*"},
 	ASTERISK_EQUALS: {"ASTERISK_EQUALS", "*="},
@@ -124,6 +135,7 @@ var _TokenType2string = [TokenType_Count][2]string{
 	NOT_EQ:          {"NOT_EQ", "!="},
 	OR:              {"OR", "||"},
 	PERIOD:          {"PERIOD", "."},
+	PIPE:            {"PIPE", "|"},
 	PLUS:            {"PLUS", "+"},
 	PLUS_EQUALS:     {"PLUS_EQUALS", "+="},
 	PLUS_PLUS:       {"PLUS_PLUS", "++"},
@@ -157,6 +169,7 @@ var _string2TokenType = map[string]TokenType{
 	"EOL":             EOL,
 	"IDENT":           IDENT,
 	"REGEXP":          REGEXP,
+	"COMMENT":         COMMENT,
 	"CASE":            CASE,
 	"CONST":           CONST,
 	"DEFAULT":         DEFAULT,
@@ -172,10 +185,14 @@ var _string2TokenType = map[string]TokenType{
 	"INT":             INT,
 	"LET":             LET,
 	"RETURN":          RETURN,
+	"SELECT":          SELECT,
+	"SPAWN":           SPAWN,
 	"STRING":          STRING,
 	"SWITCH":          SWITCH,
 	"TRUE":            TRUE,
+	"YIELD":           YIELD,
 	"AND":             AND,
+	"AMPERSAND":       AMPERSAND,
 	"ASSIGN":          ASSIGN,
 	"ASTERISK":        ASTERISK,
 	"ASTERISK_EQUALS": ASTERISK_EQUALS,
@@ -201,6 +218,7 @@ var _string2TokenType = map[string]TokenType{
 	"NOT_EQ":          NOT_EQ,
 	"OR":              OR,
 	"PERIOD":          PERIOD,
+	"PIPE":            PIPE,
 	"PLUS":            PLUS,
 	"PLUS_EQUALS":     PLUS_EQUALS,
 	"PLUS_PLUS":       PLUS_PLUS,
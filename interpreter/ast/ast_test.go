@@ -28,3 +28,20 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestReturnString(t *testing.T) {
+	stmt := &ReturnStatement{
+		Token: token.Token{Type: tokentype.RETURN, Literal: "return"},
+		ReturnValue: &PrefixExpression{
+			Token:    token.Token{Type: tokentype.MINUS, Literal: "-"},
+			Operator: tokentype.MINUS,
+			Right: &Identifier{
+				Token: token.Token{Type: tokentype.IDENT, Literal: "x"},
+				Value: "x",
+			},
+		},
+	}
+	if stmt.String() != "return (-x);" {
+		t.Errorf("stmt.String() wrong. got=%q", stmt.String())
+	}
+}
@@ -265,6 +265,9 @@ type FunctionLiteral struct {
 
 	// Body contains the set of statements within the function.
 	Body *BlockStatement
+
+	// Generator is set when the body contains a yield-statement.
+	Generator bool
 }
 
 func (fl *FunctionLiteral) ExpressionNode() {}
@@ -299,6 +302,9 @@ type FunctionDefineLiteral struct {
 
 	// Body holds the set of statements in the functions' body.
 	Body *BlockStatement
+
+	// Generator is set when the body contains a yield-statement.
+	Generator bool
 }
 
 func (fl *FunctionDefineLiteral) ExpressionNode() {}
@@ -476,6 +482,47 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+// SliceExpression holds a slice-expression, such as `a[1:3]` or `s[::-1]`.
+type SliceExpression struct {
+	// Token is the actual token
+	Token token.Token
+
+	// Left is the thing being sliced.
+	Left asti.ExpressionI
+
+	// Start is the first index of the slice (optional).
+	Start asti.ExpressionI
+
+	// End is the index the slice stops before (optional).
+	End asti.ExpressionI
+
+	// Step is the distance between selected items (optional).
+	Step asti.ExpressionI
+}
+
+func (se *SliceExpression) ExpressionNode() {}
+
+// GetToken returns the token.
+func (se *SliceExpression) GetToken() token.Token { return se.Token }
+
+// String returns this object as a string.
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "(%v[", se.Left)
+	if se.Start != nil {
+		out.WriteString(se.Start.String())
+	}
+	out.WriteString(":")
+	if se.End != nil {
+		out.WriteString(se.End.String())
+	}
+	if se.Step != nil {
+		fmt.Fprintf(&out, ":%v", se.Step)
+	}
+	out.WriteString("])")
+	return out.String()
+}
+
 // HashLiteral holds a hash definition
 type HashLiteral struct {
 	// Token holds the token
@@ -483,6 +530,9 @@ type HashLiteral struct {
 
 	// Pairs stores the name/value sets of the hash-content
 	Pairs map[asti.ExpressionI]asti.ExpressionI
+
+	// Keys holds the keys of Pairs in the order they were written.
+	Keys []asti.ExpressionI
 }
 
 func (hl *HashLiteral) ExpressionNode() {}
@@ -494,13 +544,38 @@ func (hl *HashLiteral) GetToken() token.Token { return hl.Token }
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 	pairs := make([]string, 0)
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, key := range hl.Keys {
+		pairs = append(pairs, key.String()+":"+hl.Pairs[key].String())
 	}
 	fmt.Fprintf(&out, "{%v}", strings.Join(pairs, ", "))
 	return out.String()
 }
 
+// SetLiteral holds an inline set, e.g. {1, 2, 3}
+type SetLiteral struct {
+	// Token holds the token
+	Token token.Token // the '{' token
+
+	// Elements holds the members of the set.
+	Elements []asti.ExpressionI
+}
+
+func (sl *SetLiteral) ExpressionNode() {}
+
+// GetToken returns the token.
+func (sl *SetLiteral) GetToken() token.Token { return sl.Token }
+
+// String returns this object as a string.
+func (sl *SetLiteral) String() string {
+	var out bytes.Buffer
+	elements := make([]string, 0)
+	for _, el := range sl.Elements {
+		elements = append(elements, el.String())
+	}
+	fmt.Fprintf(&out, "{%v}", strings.Join(elements, ", "))
+	return out.String()
+}
+
 // CaseExpression handles the case within a switch statement
 type CaseExpression struct {
 	// Token is the actual token
@@ -572,6 +647,90 @@ func (se *SwitchExpression) String() string {
 	return out.String()
 }
 
+// SpawnExpression runs a function call in a goroutine.
+type SpawnExpression struct {
+	// Token is the actual token
+	Token token.Token
+
+	// Call is the call to run, a CallExpression or an
+	// ObjectCallExpression.
+	Call asti.ExpressionI
+}
+
+func (se *SpawnExpression) ExpressionNode() {}
+
+// GetToken returns the token.
+func (se *SpawnExpression) GetToken() token.Token { return se.Token }
+
+// String returns this object as a string.
+func (se *SpawnExpression) String() string {
+	return "spawn " + se.Call.String()
+}
+
+// SelectCase is one branch of a select statement.
+type SelectCase struct {
+	// Token is the actual token
+	Token token.Token
+
+	// Default branch?
+	Default bool
+
+	// Ident is the variable set to the received value, if any.
+	Ident string
+
+	// Channel is the channel we receive from.
+	Channel asti.ExpressionI
+
+	// The code to execute once the receive happens
+	Block *BlockStatement
+}
+
+func (sc *SelectCase) ExpressionNode() {}
+
+// GetToken returns the token.
+func (sc *SelectCase) GetToken() token.Token { return sc.Token }
+
+// String returns this object as a string.
+func (sc *SelectCase) String() string {
+	var out bytes.Buffer
+	switch {
+	case sc.Default:
+		out.WriteString("default ")
+	case sc.Ident != "":
+		fmt.Fprintf(&out, "case %s in %s ", sc.Ident, sc.Channel)
+	default:
+		fmt.Fprintf(&out, "case %s ", sc.Channel)
+	}
+	out.WriteString(sc.Block.String())
+	return out.String()
+}
+
+// SelectExpression waits for the first of several channels to be
+// ready.
+type SelectExpression struct {
+	// Token is the actual token
+	Token token.Token
+
+	// The branches we handle
+	Cases []*SelectCase
+}
+
+func (se *SelectExpression) ExpressionNode() {}
+
+// GetToken returns the token.
+func (se *SelectExpression) GetToken() token.Token { return se.Token }
+
+// String returns this object as a string.
+func (se *SelectExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("\nselect\n{\n")
+	for _, tmp := range se.Cases {
+		out.WriteString(tmp.String())
+	}
+	out.WriteString("}\n")
+	return out.String()
+}
+
 // ForeachStatement holds a foreach-statement.
 type ForeachStatement struct {
 	// Token is the actual token
@@ -611,9 +770,13 @@ func (fes *ForeachStatement) String() string {
 // Specifically "x += y" is defined as an assignment-statement with
 // the operator set to "+=".  The same applies for "+=", "-=", "*=", and
 // "/=".
+//
+// Assigning to an element, such as "x[1] = y" or "x.name = y", leaves
+// Name empty and stores the index-expression in Target instead.
 type AssignStatement struct {
 	Token    token.Token
 	Name     *Identifier
+	Target   *IndexExpression
 	Operator tokentype.TokenType
 	Value    asti.ExpressionI
 }
@@ -626,6 +789,10 @@ func (as *AssignStatement) GetToken() token.Token { return as.Token }
 // String returns this object as a string.
 func (as *AssignStatement) String() string {
 	var out bytes.Buffer
-	fmt.Fprintf(&out, "%v%v%v", as.Name, as.Operator.Literal(), as.Value)
+	if as.Target != nil {
+		fmt.Fprintf(&out, "%v%v%v", as.Target, as.Operator.Literal(), as.Value)
+	} else {
+		fmt.Fprintf(&out, "%v%v%v", as.Name, as.Operator.Literal(), as.Value)
+	}
 	return out.String()
 }
@@ -85,12 +85,34 @@ func (rs *ReturnStatement) GetToken() token.Token { return rs.Token }
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
 	fmt.Fprintf(&out, "%v %v;",
-		rs.GetToken(),
-		rs.ReturnValue.GetToken(),
+		rs.GetToken().Literal,
+		rs.ReturnValue,
 	)
 	return out.String()
 }
 
+// YieldStatement stores a yield-statement inside a generator.
+type YieldStatement struct {
+	// Token contains the literal token.
+	Token token.Token
+
+	// Value is the value which is to be yielded, nil for null.
+	Value asti.ExpressionI
+}
+
+func (ys *YieldStatement) StatementNode() {}
+
+// GetToken returns the token.
+func (ys *YieldStatement) GetToken() token.Token { return ys.Token }
+
+// String returns this object as a string.
+func (ys *YieldStatement) String() string {
+	if ys.Value == nil {
+		return "yield;"
+	}
+	return "yield " + ys.Value.String() + ";"
+}
+
 // BlockStatement holds a group of statements, which are treated
 // as a block.  (For example the body of an `if` expression.)
 type BlockStatement struct {
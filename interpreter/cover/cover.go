@@ -0,0 +1,325 @@
+// Package cover records which parts of monkey programs run: each
+// statement, and each branch an `if` or `switch` can take.
+//
+// It finds the statements and branches of a parsed program before it
+// runs, then counts them through the trace hooks of the environment
+// the same program runs in.  What it found can be written as a
+// profile, or as an HTML page of the source marked with what ran.
+package cover
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kasworld/nonkey/interpreter/ast"
+	"github.com/kasworld/nonkey/interpreter/asti"
+	"github.com/kasworld/nonkey/interpreter/object"
+)
+
+// The kinds of the parts of a program which are counted.
+const (
+	Statement = "statement"
+	Then      = "then"    // the block of an `if` run when its condition holds
+	Else      = "else"    // what an `if` does otherwise, even without an `else`
+	Case      = "case"    // the block of a `case` of a `switch`
+	Default   = "default" // what a `switch` does when no case matches
+)
+
+// Block is a part of a program which is counted.
+type Block struct {
+	Kind  string
+	Line  int // counted from one
+	Pos   int // of the first token, counted from one
+	Count int
+
+	node asti.NodeI
+}
+
+// Coverage counts the parts of a program as it runs.
+type Coverage struct {
+	name  string
+	lines []string
+
+	mu       sync.Mutex
+	blocks   []*Block
+	stmts    map[asti.NodeI]*Block
+	branches map[asti.NodeI]*Block
+}
+
+// New returns the coverage of program, parsed from src from the file
+// name, with nothing run yet.  Only what program itself runs is
+// counted, so the program run must be this one, not the source parsed
+// again.  A nil program, for source which doesn't parse, has no parts.
+func New(name, src string, program *ast.Program) *Coverage {
+	c := &Coverage{
+		name:     name,
+		lines:    strings.Split(src, "\n"),
+		stmts:    make(map[asti.NodeI]*Block),
+		branches: make(map[asti.NodeI]*Block),
+	}
+	if program != nil {
+		c.statements(program.Statements)
+	}
+	sort.SliceStable(c.blocks, func(i, j int) bool {
+		if c.blocks[i].Line != c.blocks[j].Line {
+			return c.blocks[i].Line < c.blocks[j].Line
+		}
+		return c.blocks[i].Pos < c.blocks[j].Pos
+	})
+	return c
+}
+
+// add adds a part of the program.
+func (c *Coverage) add(m map[asti.NodeI]*Block, kind string, node asti.NodeI) {
+	if _, ok := m[node]; ok {
+		return
+	}
+	tok := node.GetToken()
+	b := &Block{Kind: kind, Line: tok.Line + 1, Pos: tok.Pos, node: node}
+	m[node] = b
+	c.blocks = append(c.blocks, b)
+}
+
+// Statements returns the statements of program, including those
+// within its blocks and functions, in the order they appear.  These
+// are the statements the trace hook of an environment is told of as
+// the program runs.
+func Statements(program *ast.Program) []asti.StatementI {
+	c := New("", "", program)
+	var list []asti.StatementI
+	for _, b := range c.blocks {
+		if b.Kind == Statement {
+			list = append(list, b.node.(asti.StatementI))
+		}
+	}
+	return list
+}
+
+func (c *Coverage) statements(list []asti.StatementI) {
+	for _, stmt := range list {
+		c.add(c.stmts, Statement, stmt)
+		switch n := stmt.(type) {
+		case *ast.LetStatement:
+			c.expr(n.Value)
+		case *ast.ConstStatement:
+			c.expr(n.Value)
+		case *ast.ReturnStatement:
+			c.expr(n.ReturnValue)
+		case *ast.YieldStatement:
+			c.expr(n.Value)
+		case *ast.ExpressionStatement:
+			c.expr(n.Expression)
+		case *ast.BlockStatement:
+			c.statements(n.Statements)
+		}
+	}
+}
+
+func (c *Coverage) block(b *ast.BlockStatement) {
+	if b != nil {
+		c.statements(b.Statements)
+	}
+}
+
+func (c *Coverage) exprs(list []asti.ExpressionI) {
+	for _, e := range list {
+		c.expr(e)
+	}
+}
+
+func (c *Coverage) defaults(m map[string]asti.ExpressionI) {
+	for _, e := range m {
+		c.expr(e)
+	}
+}
+
+// expr finds the statements and branches within an expression.
+func (c *Coverage) expr(e asti.ExpressionI) {
+	switch n := e.(type) {
+	case nil:
+	case *ast.PrefixExpression:
+		c.expr(n.Right)
+	case *ast.InfixExpression:
+		c.expr(n.Left)
+		c.expr(n.Right)
+	case *ast.TernaryExpression:
+		c.expr(n.Condition)
+		c.expr(n.IfTrue)
+		c.expr(n.IfFalse)
+	case *ast.IfExpression:
+		c.expr(n.Condition)
+		if n.Consequence != nil {
+			c.add(c.branches, Then, n.Consequence)
+		}
+		if n.Alternative != nil {
+			c.add(c.branches, Else, n.Alternative)
+		} else {
+			c.add(c.branches, Else, n)
+		}
+		c.block(n.Consequence)
+		c.block(n.Alternative)
+	case *ast.ForLoopExpression:
+		c.expr(n.Condition)
+		c.block(n.Consequence)
+	case *ast.ForeachStatement:
+		c.expr(n.Value)
+		c.block(n.Body)
+	case *ast.FunctionLiteral:
+		c.defaults(n.Defaults)
+		c.block(n.Body)
+	case *ast.FunctionDefineLiteral:
+		c.defaults(n.Defaults)
+		c.block(n.Body)
+	case *ast.CallExpression:
+		c.expr(n.Function)
+		c.exprs(n.Arguments)
+	case *ast.ObjectCallExpression:
+		c.expr(n.Object)
+		if call, ok := n.Call.(*ast.CallExpression); ok {
+			c.exprs(call.Arguments)
+		}
+	case *ast.IndexExpression:
+		c.expr(n.Left)
+		c.expr(n.Index)
+	case *ast.SliceExpression:
+		c.expr(n.Left)
+		c.expr(n.Start)
+		c.expr(n.End)
+		c.expr(n.Step)
+	case *ast.ArrayLiteral:
+		c.exprs(n.Elements)
+	case *ast.SetLiteral:
+		c.exprs(n.Elements)
+	case *ast.HashLiteral:
+		for _, key := range n.Keys {
+			c.expr(key)
+			c.expr(n.Pairs[key])
+		}
+	case *ast.SwitchExpression:
+		c.expr(n.Value)
+		hasDefault := false
+		for _, choice := range n.Choices {
+			if choice.Block == nil {
+				continue
+			}
+			if choice.Default {
+				hasDefault = true
+				c.add(c.branches, Default, choice.Block)
+			} else {
+				c.add(c.branches, Case, choice.Block)
+			}
+		}
+		if !hasDefault {
+			c.add(c.branches, Default, n)
+		}
+		for _, choice := range n.Choices {
+			c.exprs(choice.Expr)
+			c.block(choice.Block)
+		}
+	case *ast.SelectExpression:
+		for _, sc := range n.Cases {
+			c.expr(sc.Channel)
+			c.block(sc.Block)
+		}
+	case *ast.SpawnExpression:
+		c.expr(n.Call)
+	case *ast.AssignStatement:
+		if n.Target != nil {
+			c.expr(n.Target)
+		}
+		c.expr(n.Value)
+	}
+}
+
+// Start starts counting the program as it runs in env.
+func (c *Coverage) Start(env *object.Environment) {
+	env.SetTrace(c.statement)
+	env.SetBranchTrace(c.branch)
+}
+
+// Stop stops counting the program as it runs in env.
+func (c *Coverage) Stop(env *object.Environment) {
+	env.SetTrace(nil)
+	env.SetBranchTrace(nil)
+}
+
+// statement counts a statement run.  Those of other programs, such
+// as code given to eval or loaded before the program, aren't known,
+// and aren't counted.
+func (c *Coverage) statement(stmt asti.StatementI, env *object.Environment) {
+	c.count(c.stmts, stmt)
+}
+
+// branch counts a branch taken.
+func (c *Coverage) branch(branch asti.NodeI, env *object.Environment) {
+	c.count(c.branches, branch)
+}
+
+func (c *Coverage) count(m map[asti.NodeI]*Block, node asti.NodeI) {
+	c.mu.Lock()
+	if b, ok := m[node]; ok {
+		b.Count++
+	}
+	c.mu.Unlock()
+}
+
+// Blocks returns the parts of the program, in the order they appear,
+// with how often each ran.
+func (c *Coverage) Blocks() []Block {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	list := make([]Block, len(c.blocks))
+	for i, b := range c.blocks {
+		list[i] = *b
+	}
+	return list
+}
+
+// Percent returns how many of the statements, and how many of the
+// branches, ran, as percentages.  It's 100 when there are none.
+func (c *Coverage) Percent() (statements, branches float64) {
+	var stmts, stmtsRun, branchesAll, branchesRun int
+	for _, b := range c.Blocks() {
+		if b.Kind == Statement {
+			stmts++
+			if b.Count > 0 {
+				stmtsRun++
+			}
+		} else {
+			branchesAll++
+			if b.Count > 0 {
+				branchesRun++
+			}
+		}
+	}
+	return percent(stmtsRun, stmts), percent(branchesRun, branchesAll)
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return 100 * float64(n) / float64(total)
+}
+
+// WriteProfile writes how often each part of the program ran, as
+// lines giving the file name, its line and position, its kind, and
+// the count:
+//
+//	mode: count
+//	demo.mon:2.5 statement 9
+//	demo.mon:2.16 then 6
+//	demo.mon:2.5 else 3
+func (c *Coverage) WriteProfile(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "mode: count\n")
+	for _, b := range c.Blocks() {
+		fmt.Fprintf(out, "%s:%d.%d %s %d\n", c.name, b.Line, b.Pos, b.Kind, b.Count)
+	}
+	return out.Flush()
+}
@@ -0,0 +1,145 @@
+package cover
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/kasworld/nonkey/interpreter/evaluator"
+	"github.com/kasworld/nonkey/interpreter/lexer"
+	"github.com/kasworld/nonkey/interpreter/object"
+	"github.com/kasworld/nonkey/interpreter/parser"
+)
+
+const script = `function sign(n) {
+    if (n < 0) {
+        return -1;
+    } else {
+        return 1;
+    }
+}
+function name(n) {
+    switch (n) {
+        case 1 {
+            return "one";
+        }
+        case 2 {
+            return "two";
+        }
+    }
+    if (n > 100) { return "big"; }
+    return "many";
+}
+let results = [sign(3), name(1), name(5)];
+`
+
+// cover runs the script, returning its coverage.
+func cover(t *testing.T) *Coverage {
+	program := parser.New(lexer.New(script)).ParseProgram()
+	c := New("t.mon", script, program)
+	env := object.NewEnvironment()
+	c.Start(env)
+	evaluator.Eval(program, env)
+	c.Stop(env)
+
+	if results, _ := env.Get("results"); results == nil || results.Inspect() != "[1, one, many]" {
+		t.Fatalf("unexpected results %v", results)
+	}
+	return c
+}
+
+func TestProfile(t *testing.T) {
+	var out bytes.Buffer
+	if err := cover(t).WriteProfile(&out); err != nil {
+		t.Fatalf("%s", err)
+	}
+	expected := `mode: count
+t.mon:1.1 statement 1
+t.mon:2.5 statement 1
+t.mon:2.16 then 0
+t.mon:3.9 statement 0
+t.mon:4.12 else 1
+t.mon:5.9 statement 1
+t.mon:8.1 statement 1
+t.mon:9.5 statement 2
+t.mon:9.5 default 1
+t.mon:10.16 case 1
+t.mon:11.13 statement 1
+t.mon:13.16 case 0
+t.mon:14.13 statement 0
+t.mon:17.5 statement 1
+t.mon:17.5 else 1
+t.mon:17.18 then 0
+t.mon:17.20 statement 0
+t.mon:18.5 statement 1
+t.mon:20.1 statement 1
+`
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestPercent(t *testing.T) {
+	statements, branches := cover(t).Percent()
+	if statements != 75 {
+		t.Errorf("expected 9 of 12 statements run, got %v%%", statements)
+	}
+	if branches != 100*4.0/7 {
+		t.Errorf("expected 4 of 7 branches taken, got %v%%", branches)
+	}
+
+	statements, branches = New("t.mon", "let x = (;", nil).Percent()
+	if statements != 100 || branches != 100 {
+		t.Errorf("expected full coverage of nothing, got %v%% and %v%%", statements, branches)
+	}
+}
+
+func TestOtherPrograms(t *testing.T) {
+	// The function loaded first, and the code given to eval, have
+	// statements at the same places as some which never run.
+	lib := `let helper = fn() {
+    let a = 1;
+    return a;
+};
+`
+	main := `let r = helper();
+if (r > 5) {
+    puts(r);
+}
+r = r + 1;
+eval("1;\n    2;");
+`
+	env := object.NewEnvironment()
+	evaluator.Eval(parser.New(lexer.New(lib)).ParseProgram(), env)
+
+	program := parser.New(lexer.New(main)).ParseProgram()
+	c := New("t.mon", main, program)
+	c.Start(env)
+	evaluator.Eval(program, env)
+	c.Stop(env)
+
+	if statements, _ := c.Percent(); statements != 80 {
+		t.Errorf("expected 4 of 5 statements run, got %v%%", statements)
+	}
+	if got := len(Statements(program)); got != 5 {
+		t.Errorf("expected 5 statements, got %d", got)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	var out bytes.Buffer
+	if err := cover(t).WriteHTML(&out); err != nil {
+		t.Fatalf("%s", err)
+	}
+	for _, expected := range []string{
+		`<p>75.0% of statements and 57.1% of branches ran.</p>`,
+		`<tr class="part"><td class="number">2</td><td class="count">1</td><td class="source">    if (n &lt; 0) {</td><td class="note">then never taken</td></tr>`,
+		`<tr class="none"><td class="number">3</td><td class="count">0</td>`,
+		`<tr class="run"><td class="number">4</td><td class="count"></td>`,
+		`<tr class="none"><td class="number">13</td><td class="count"></td><td class="source">        case 2 {</td><td class="note">case never taken</td></tr>`,
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in:\n%s", expected, out.String())
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package cover
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+var page = template.Must(template.New("cover").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}} coverage</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; font-family: monospace; }
+td { padding: 0 0.5em; white-space: pre; vertical-align: top; }
+td.number, td.count { text-align: right; color: #888; }
+td.note { color: #a00; font-family: sans-serif; font-size: smaller; }
+tr.run td.source { background: #cfc; }
+tr.part td.source { background: #ffc; }
+tr.none td.source { background: #fcc; }
+</style>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+<p>{{printf "%.1f" .Statements}}% of statements and {{printf "%.1f" .Branches}}% of branches ran.</p>
+<table>
+{{range .Lines}}<tr class="{{.Class}}"><td class="number">{{.Number}}</td><td class="count">{{.Count}}</td><td class="source">{{.Source}}</td><td class="note">{{.Note}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// line is a line of the source, as the page shows it.
+type line struct {
+	Number int
+	Count  string // the times its first statement ran
+	Source string
+	Class  string // run, part or none, as all its parts ran, some or none
+	Note   string // the branches on it which never ran
+}
+
+// WriteHTML writes the source of the program as a page, marking each
+// line by whether what's on it ran, with how often, and noting the
+// branches which never did.
+func (c *Coverage) WriteHTML(w io.Writer) error {
+	byLine := make(map[int][]Block)
+	for _, b := range c.Blocks() {
+		byLine[b.Line] = append(byLine[b.Line], b)
+	}
+
+	var lines []line
+	for i, src := range c.lines {
+		l := line{Number: i + 1, Source: strings.TrimRight(src, "\r")}
+		run, all := 0, 0
+		var missed []string
+		for _, b := range byLine[l.Number] {
+			all++
+			if b.Count > 0 {
+				run++
+			} else if b.Kind != Statement {
+				missed = append(missed, b.Kind)
+			}
+			if b.Kind == Statement && l.Count == "" {
+				l.Count = fmt.Sprint(b.Count)
+			}
+		}
+		switch {
+		case all == 0:
+		case run == all:
+			l.Class = "run"
+		case run == 0:
+			l.Class = "none"
+		default:
+			l.Class = "part"
+		}
+		if len(missed) > 0 {
+			l.Note = strings.Join(missed, ", ") + " never taken"
+		}
+		lines = append(lines, l)
+	}
+
+	statements, branches := c.Percent()
+	return page.Execute(w, struct {
+		Name                 string
+		Statements, Branches float64
+		Lines                []line
+	}{c.name, statements, branches, lines})
+}
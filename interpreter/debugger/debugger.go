@@ -0,0 +1,370 @@
+// Package debugger runs monkey programs under the control of a user,
+// who can stop them at breakpoints, step through them a statement at
+// a time, and look at the call stack and variables as they go.
+//
+// It follows the program through the trace hook of the environment
+// it runs in, which the evaluator calls before each statement.
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kasworld/nonkey/interpreter/asti"
+	"github.com/kasworld/nonkey/interpreter/evaluator"
+	"github.com/kasworld/nonkey/interpreter/lexer"
+	"github.com/kasworld/nonkey/interpreter/object"
+	"github.com/kasworld/nonkey/interpreter/parser"
+)
+
+// How the program runs until it next stops.
+const (
+	modeContinue = iota // until a breakpoint
+	modeStep            // until the next statement
+	modeNext            // until the next statement in this function, or its caller
+	modeFinish          // until the next statement in the caller
+)
+
+const help = `commands:
+  break [line]    set a breakpoint, or list them   (b)
+  delete line     remove a breakpoint              (d)
+  continue        run until a breakpoint           (c)
+  step            run to the next statement        (s)
+  next            run to the next statement here   (n)
+  finish          run until this function returns  (f)
+  where           show the call stack              (bt)
+  print expr      show the value of an expression  (p)
+  vars            show the variables in scope      (v)
+  list            show the source around here      (l)
+  quit            stop the program                 (q)
+An empty line repeats the last command.
+`
+
+// quitting is panicked with to stop the program on quit.
+type quitting struct{}
+
+// Debugger runs a program, reading commands from one stream and
+// writing what they show to another.
+type Debugger struct {
+	in  *bufio.Scanner
+	out io.Writer
+
+	// name and lines are the name and source of the program.
+	name  string
+	lines []string
+
+	// mu is held while the program is stopped, so spawned functions
+	// wait for it.
+	mu sync.Mutex
+
+	// breaks holds the lines with breakpoints, counted from one.
+	breaks map[int]bool
+
+	mode  int
+	depth int // of the stack where stepping began
+
+	// stmt, line and frame locate the last statement run.
+	stmt  asti.StatementI
+	line  int
+	frame *object.Frame
+
+	// last is the last command, repeated by an empty line.
+	last string
+
+	// evaluating is set while print runs code, whose statements
+	// mustn't stop.
+	evaluating int32
+
+	// detached is set once input runs out, after which the program
+	// runs to its end.
+	detached bool
+}
+
+// New returns a debugger reading commands from in, and writing to out.
+func New(in io.Reader, out io.Writer) *Debugger {
+	return &Debugger{
+		in:     bufio.NewScanner(in),
+		out:    out,
+		breaks: make(map[int]bool),
+		mode:   modeStep,
+	}
+}
+
+// Break sets a breakpoint on a line, counted from one.
+func (d *Debugger) Break(line int) {
+	d.breaks[line] = true
+}
+
+// Run runs the program src, named name, in env, stopping before its
+// first statement.  It returns an error if the program doesn't parse.
+func (d *Debugger) Run(name, src string, env *object.Environment) error {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return fmt.Errorf("%s", strings.TrimSuffix(parser.Render(name, src, errs), "\n"))
+	}
+
+	d.name = name
+	d.lines = strings.Split(src, "\n")
+	env.SetTrace(d.trace)
+	defer env.SetTrace(nil)
+
+	result, quit := d.eval(program, env)
+	if quit {
+		return nil
+	}
+	if err, ok := result.(*object.Error); ok {
+		fmt.Fprintf(d.out, "error: %s\n", err.Message)
+	}
+	fmt.Fprintf(d.out, "program finished\n")
+	return nil
+}
+
+// eval runs a program, reporting whether the user quit it.
+func (d *Debugger) eval(program asti.NodeI, env *object.Environment) (result object.ObjectI, quit bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(quitting); !ok {
+				panic(r)
+			}
+			quit = true
+		}
+	}()
+	return evaluator.Eval(program, env), false
+}
+
+// trace is called before each statement runs, and stops the program
+// there if it should.
+func (d *Debugger) trace(stmt asti.StatementI, env *object.Environment) {
+	if atomic.LoadInt32(&d.evaluating) != 0 {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	line := stmt.GetToken().Line + 1
+	frame := env.Frame()
+	depth := len(env.Stack())
+
+	// A breakpoint stops the program once per visit to its line,
+	// but a loop coming round again is a new visit.
+	newLine := line != d.line || frame != d.frame || stmt == d.stmt
+	d.stmt, d.line, d.frame = stmt, line, frame
+
+	if d.detached {
+		return
+	}
+	stop := false
+	switch d.mode {
+	case modeStep:
+		stop = true
+	case modeNext:
+		stop = depth <= d.depth
+	case modeFinish:
+		stop = depth < d.depth
+	}
+	if !stop && !(d.breaks[line] && newLine) {
+		return
+	}
+
+	d.show(frame)
+	d.prompt(env, depth)
+}
+
+// show prints where the program has stopped.
+func (d *Debugger) show(frame *object.Frame) {
+	name := "main"
+	if frame != nil {
+		name = frame.Name
+	}
+	fmt.Fprintf(d.out, "%s:%d (%s)\n", d.name, d.line, name)
+	d.list(d.line, d.line)
+}
+
+// list prints the source from one line to another.
+func (d *Debugger) list(from, to int) {
+	if from < 1 {
+		from = 1
+	}
+	if to > len(d.lines) {
+		to = len(d.lines)
+	}
+	for n := from; n <= to; n++ {
+		mark := " "
+		if n == d.line {
+			mark = ">"
+		} else if d.breaks[n] {
+			mark = "*"
+		}
+		fmt.Fprintf(d.out, "%s%4d  %s\n", mark, n, d.lines[n-1])
+	}
+}
+
+// prompt reads commands until one resumes the program.
+func (d *Debugger) prompt(env *object.Environment, depth int) {
+	for {
+		fmt.Fprintf(d.out, "(debug) ")
+		if !d.in.Scan() {
+			fmt.Fprintf(d.out, "\n")
+			d.detached = true
+			return
+		}
+		line := strings.TrimSpace(d.in.Text())
+		if line == "" {
+			line = d.last
+		}
+		d.last = line
+		cmd, arg := line, ""
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			cmd, arg = line[:i], strings.TrimSpace(line[i+1:])
+		}
+
+		switch cmd {
+		case "":
+		case "c", "continue":
+			d.mode = modeContinue
+			return
+		case "s", "step":
+			d.mode = modeStep
+			return
+		case "n", "next":
+			d.mode, d.depth = modeNext, depth
+			return
+		case "f", "finish":
+			if depth == 0 {
+				fmt.Fprintf(d.out, "not in a function\n")
+				continue
+			}
+			d.mode, d.depth = modeFinish, depth
+			return
+		case "q", "quit":
+			d.detached = true
+			panic(quitting{})
+
+		case "b", "break":
+			if arg == "" {
+				d.breakpoints()
+			} else if n, ok := d.lineNumber(arg); ok {
+				d.breaks[n] = true
+				fmt.Fprintf(d.out, "breakpoint at %s:%d\n", d.name, n)
+			}
+		case "d", "delete":
+			if n, ok := d.lineNumber(arg); ok {
+				if !d.breaks[n] {
+					fmt.Fprintf(d.out, "no breakpoint at line %d\n", n)
+				}
+				delete(d.breaks, n)
+			}
+		case "bt", "where":
+			d.where(env)
+		case "p", "print":
+			d.print(arg, env)
+		case "v", "vars":
+			d.vars(env)
+		case "l", "list":
+			d.list(d.line-5, d.line+5)
+		case "h", "help":
+			fmt.Fprint(d.out, help)
+		default:
+			fmt.Fprintf(d.out, "unknown command %q; try help\n", cmd)
+		}
+	}
+}
+
+// lineNumber parses the line number a command is given.
+func (d *Debugger) lineNumber(arg string) (int, bool) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > len(d.lines) {
+		fmt.Fprintf(d.out, "invalid line %q\n", arg)
+		return 0, false
+	}
+	return n, true
+}
+
+// breakpoints lists the breakpoints.
+func (d *Debugger) breakpoints() {
+	var lines []int
+	for n := range d.breaks {
+		lines = append(lines, n)
+	}
+	if len(lines) == 0 {
+		fmt.Fprintf(d.out, "no breakpoints\n")
+		return
+	}
+	sort.Ints(lines)
+	for _, n := range lines {
+		fmt.Fprintf(d.out, "%s:%d\n", d.name, n)
+	}
+}
+
+// where prints the call stack, innermost first.
+func (d *Debugger) where(env *object.Environment) {
+	line := d.line
+	for i, frame := range env.Stack() {
+		fmt.Fprintf(d.out, "#%d %s at %s:%d\n", i, frame.Name, d.name, line)
+		line = frame.Call.GetToken().Line + 1
+	}
+	fmt.Fprintf(d.out, "#%d main at %s:%d\n", len(env.Stack()), d.name, line)
+}
+
+// print evaluates an expression where the program has stopped.  Lets
+// stay local to it, but assignments change the program's variables.
+func (d *Debugger) print(src string, env *object.Environment) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Fprintf(d.out, "%s\n", err.Msg)
+		}
+		return
+	}
+
+	scratch := object.NewEnclosedEnvironment(env)
+	scratch.SetTrace(nil)
+	atomic.StoreInt32(&d.evaluating, 1)
+	result := evaluator.Eval(program, scratch)
+	atomic.StoreInt32(&d.evaluating, 0)
+
+	switch result := result.(type) {
+	case nil:
+		fmt.Fprintf(d.out, "null\n")
+	case *object.Error:
+		fmt.Fprintf(d.out, "error: %s\n", result.Message)
+	default:
+		fmt.Fprintf(d.out, "%s\n", result.Inspect())
+	}
+}
+
+// vars prints the variables of each scope, from the innermost out to
+// the globals.
+func (d *Debugger) vars(env *object.Environment) {
+	for ; env != nil; env = env.Outer() {
+		locals := env.Locals()
+		if len(locals) == 0 {
+			continue
+		}
+		switch {
+		case env.Outer() == nil:
+			fmt.Fprintf(d.out, "globals:\n")
+		case env.Frame() != env.Outer().Frame():
+			// the environment of a call, rather than a block in one
+			fmt.Fprintf(d.out, "%s:\n", env.Frame().Name)
+		default:
+			fmt.Fprintf(d.out, "block:\n")
+		}
+		var names []string
+		for name := range locals {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(d.out, "  %s = %s\n", name, locals[name].Inspect())
+		}
+	}
+}
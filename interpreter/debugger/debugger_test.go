@@ -0,0 +1,111 @@
+package debugger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/kasworld/nonkey/interpreter/object"
+)
+
+const script = `let total = 0;
+function add(a, b) {
+    let sum = a + b;
+    return sum;
+}
+foreach i in 1..3 {
+    total = add(total, i);
+}
+let done = true;
+`
+
+// session runs the script with commands as input, returning what the
+// debugger wrote and the environment the script ran in.
+func session(t *testing.T, commands ...string) (string, *object.Environment) {
+	var out bytes.Buffer
+	d := New(strings.NewReader(strings.Join(commands, "\n")+"\n"), &out)
+	env := object.NewEnvironment()
+	if err := d.Run("t.mon", script, env); err != nil {
+		t.Fatalf("%s", err)
+	}
+	return out.String(), env
+}
+
+// stops returns the locations the debugger stopped at.
+func stops(out string) []string {
+	var list []string
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimPrefix(line, "(debug) ")
+		if strings.HasPrefix(line, "t.mon:") {
+			list = append(list, line)
+		}
+	}
+	return list
+}
+
+func TestStepping(t *testing.T) {
+	tests := []struct {
+		commands []string
+		expected []string
+	}{
+		{[]string{"c"}, []string{"t.mon:1 (main)"}},
+		{[]string{"s", "s", "s", "s", "q"}, []string{
+			"t.mon:1 (main)", "t.mon:2 (main)", "t.mon:6 (main)", "t.mon:7 (main)", "t.mon:3 (add)",
+		}},
+		{[]string{"b 3", "c", "n", "n", "c", "c", "q"}, []string{
+			"t.mon:1 (main)", "t.mon:3 (add)", "t.mon:4 (add)", "t.mon:7 (main)", "t.mon:3 (add)", "t.mon:3 (add)",
+		}},
+		{[]string{"b 3", "c", "f", "d 3", "c"}, []string{
+			"t.mon:1 (main)", "t.mon:3 (add)", "t.mon:7 (main)",
+		}},
+		{[]string{"b 9", "c", "", "c"}, []string{"t.mon:1 (main)", "t.mon:9 (main)"}},
+	}
+
+	for _, tt := range tests {
+		out, _ := session(t, tt.commands...)
+		if got := stops(out); strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("%q: expected stops %q, got %q\n%s", tt.commands, tt.expected, got, out)
+		}
+	}
+}
+
+func TestFinished(t *testing.T) {
+	out, env := session(t, "c")
+	if !strings.HasSuffix(out, "program finished\n") {
+		t.Errorf("expected the program to finish, got %s", out)
+	}
+	if total, _ := env.Get("total"); total == nil || total.Inspect() != "6" {
+		t.Errorf("expected total 6, got %v", total)
+	}
+
+	out, env = session(t, "q")
+	if strings.Contains(out, "program finished") {
+		t.Errorf("expected the program to stop, got %s", out)
+	}
+	if _, ok := env.Get("total"); ok {
+		t.Errorf("expected quitting before total was set")
+	}
+}
+
+func TestInspecting(t *testing.T) {
+	out, _ := session(t, "b 4", "c", "where", "p sum * 10", "p nosuch(", "vars", "b", "q")
+
+	for _, expected := range []string{
+		"#0 add at t.mon:4\n#1 main at t.mon:7\n",
+		"(debug) 10\n",
+		"(debug) expected next token to be )",
+		"add:\n  a = 0\n  b = 1\n  sum = 1\n",
+		"globals:\n  add = fn(a, b)",
+		"(debug) t.mon:4\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in:\n%s", expected, out)
+		}
+	}
+
+	// Blocks have their own scope.
+	out, _ = session(t, "b 7", "c", "c", "vars", "q")
+	if !strings.Contains(out, "block:\n  i = 2\nglobals:\n") {
+		t.Errorf("expected the loop variable in:\n%s", out)
+	}
+}
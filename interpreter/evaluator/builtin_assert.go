@@ -0,0 +1,193 @@
+package evaluator
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kasworld/nonkey/interpreter/asti"
+	"github.com/kasworld/nonkey/interpreter/lexer"
+	"github.com/kasworld/nonkey/interpreter/object"
+	"github.com/kasworld/nonkey/interpreter/parser"
+)
+
+// assertion returns the error of a failed assertion, led by the
+// message the script gave, if any, which is the argument after want.
+func assertion(node asti.NodeI, name string, args []object.ObjectI, want int, format string, a ...interface{}) object.ObjectI {
+	msg := fmt.Sprintf(format, a...)
+	if len(args) > want {
+		msg = args[want].Inspect() + ": " + msg
+	}
+	return object.NewError(node, "%s failed: %s", name, msg)
+}
+
+// assertArgs checks an assertion was given want arguments, and maybe
+// a message.
+func assertArgs(node asti.NodeI, args []object.ObjectI, want int) object.ObjectI {
+	if len(args) != want && len(args) != want+1 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=%d or %d",
+			len(args), want, want+1)
+	}
+	return nil
+}
+
+// assert( cond [, msg] )
+//
+// Fails, with an error which stops the script, unless cond is true.
+// A STRING cond is code, as older scripts give it, which must
+// evaluate to true.
+func builtinAssert(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if err := assertArgs(node, args, 1); err != nil {
+		return err
+	}
+	if src, ok := args[0].(*object.String); ok {
+		p := parser.New(lexer.New(src.Value))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			return object.NewError(node, "assert: can't parse %s: %s",
+				strconv.Quote(src.Value), p.Errors()[0].Msg)
+		}
+		result := Eval(program, env)
+		if object.IsError(result) {
+			return result
+		}
+		if !isTruthy(result) {
+			return assertion(node, "assert", args, 1, "%s gave %s", strconv.Quote(src.Value), show(result))
+		}
+		return object.NULL
+	}
+	if !isTruthy(args[0]) {
+		return assertion(node, "assert", args, 1, "got %s", show(args[0]))
+	}
+	return object.NULL
+}
+
+// assert_eq( got, want [, msg] )
+//
+// Fails unless got equals want, showing how they differ.
+func builtinAssertEq(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if err := assertArgs(node, args, 2); err != nil {
+		return err
+	}
+	got, want := args[0], args[1]
+	if object.Equals(got, want) {
+		return object.NULL
+	}
+	g, w := show(got), show(want)
+	if g == w {
+		// They look alike, so tell them apart.
+		g += fmt.Sprintf(" (%s)", got.Type())
+		w += fmt.Sprintf(" (%s)", want.Type())
+	}
+	gs, gok := got.(*object.String)
+	ws, wok := want.(*object.String)
+	if gok && wok && (strings.Contains(gs.Value, "\n") || strings.Contains(ws.Value, "\n")) {
+		return assertion(node, "assert_eq", args, 2, "strings differ:\n%s", diff(ws.Value, gs.Value))
+	}
+	return assertion(node, "assert_eq", args, 2, "got %s, want %s", g, w)
+}
+
+// assert_ne( got, other [, msg] )
+//
+// Fails if got equals other.
+func builtinAssertNe(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if err := assertArgs(node, args, 2); err != nil {
+		return err
+	}
+	if object.Equals(args[0], args[1]) {
+		return assertion(node, "assert_ne", args, 2, "got %s, which it shouldn't be", show(args[0]))
+	}
+	return object.NULL
+}
+
+// assert_raises( fn [, substring] ) -> string
+//
+// Calls fn, failing unless it returns an error, whose message must
+// contain substring if that's given.  Returns the message.
+func builtinAssertRaises(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 1 && len(args) != 2 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=1 or 2",
+			len(args))
+	}
+	switch args[0].(type) {
+	case *object.Function, *object.Builtin:
+	default:
+		return object.NewError(node, "argument to `assert_raises` must be FUNCTION, got=%s",
+			args[0].Type())
+	}
+	var substring string
+	if len(args) == 2 {
+		s, ok := args[1].(*object.String)
+		if !ok {
+			return object.NewError(node, "argument to `assert_raises` must be STRING, got=%s",
+				args[1].Type())
+		}
+		substring = s.Value
+	}
+
+	result := applyFunction(node, env, args[0], nil)
+	err, ok := result.(*object.Error)
+	if !ok {
+		return object.NewError(node, "assert_raises failed: got %s, want an error", show(result))
+	}
+	if !strings.Contains(err.Message, substring) {
+		return object.NewError(node, "assert_raises failed: got error %s, want one containing %s",
+			strconv.Quote(err.Message), strconv.Quote(substring))
+	}
+	return &object.String{Value: err.Message}
+}
+
+// show returns how an assertion shows a value, quoting strings.
+func show(obj object.ObjectI) string {
+	switch obj := obj.(type) {
+	case nil:
+		return "null"
+	case *object.String:
+		return strconv.Quote(obj.Value)
+	}
+	return obj.Inspect()
+}
+
+// diff returns the lines of want and got, marking those only in want
+// with "-" and those only in got with "+".
+func diff(want, got string) string {
+	a, b := strings.Split(want, "\n"), strings.Split(got, "\n")
+
+	// common[i][j] is the length of the longest common subsequence
+	// of a[i:] and b[j:].
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else if common[i+1][j] >= common[i][j+1] {
+				common[i][j] = common[i+1][j]
+			} else {
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteString("--- want\n+++ got\n")
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			fmt.Fprintf(&out, "  %s\n", a[i])
+			i++
+			j++
+		case j == len(b) || (i < len(a) && common[i+1][j] >= common[i][j+1]):
+			fmt.Fprintf(&out, "- %s\n", a[i])
+			i++
+		default:
+			fmt.Fprintf(&out, "+ %s\n", b[j])
+			j++
+		}
+	}
+	return strings.TrimSuffix(out.String(), "\n")
+}
@@ -48,6 +48,20 @@ func builtinChmod(node asti.NodeI, env *object.Environment, args ...object.Objec
 	return &object.Boolean{Value: true}
 }
 
+// compare two values, returning -1, 0, or 1; suitable for sorting.
+func builtinCompare(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 2 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	c, ok := object.Compare(args[0], args[1])
+	if !ok {
+		return object.NewError(node, "arguments to `compare` can't be ordered, got=%s, %s",
+			args[0].Type(), args[1].Type())
+	}
+	return &object.Integer{Value: int64(c)}
+}
+
 // Delete a given hash-key
 func builtinDelete(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
 	if len(args) != 2 {
@@ -69,16 +83,8 @@ func builtinDelete(node asti.NodeI, env *object.Environment, args ...object.Obje
 			args[1].Type())
 	}
 
-	// Make a new hash
-	newHash := make(map[object.HashKey]object.HashPair)
-
-	// Copy the values EXCEPT the one we have.
-	for k, v := range hash.Pairs {
-		if k != key.HashKey() {
-			newHash[k] = v
-		}
-	}
-	return &object.Hash{Pairs: newHash}
+	// The new hash shares everything EXCEPT the one we have.
+	return hash.Delete(key.HashKey())
 }
 
 // evaluate a string containing monkey-code
@@ -182,20 +188,17 @@ func builtinKeys(node asti.NodeI, env *object.Environment, args ...object.Object
 
 	// The object we're working with
 	hash := args[0].(*object.Hash)
-	ents := len(hash.Pairs)
 
 	// Create a new array for the results.
-	array := make([]object.ObjectI, ents)
+	array := make([]object.ObjectI, 0, hash.Len())
 
 	// Now copy the keys into it.
-	i := 0
-	for _, ent := range hash.Pairs {
-		array[i] = ent.Key
-		i++
+	for _, ent := range hash.Pairs() {
+		array = append(array, ent.Key)
 	}
 
 	// Return the array.
-	return &object.Array{Elements: array}
+	return object.NewArray(array)
 }
 
 // length of item
@@ -210,13 +213,48 @@ func builtinLen(node asti.NodeI, env *object.Environment, args ...object.ObjectI
 	case *object.Null:
 		return &object.Integer{Value: 0}
 	case *object.Array:
-		return &object.Integer{Value: int64(len(arg.Elements))}
+		return &object.Integer{Value: int64(arg.Len())}
+	case *object.Set:
+		return &object.Integer{Value: int64(arg.Len())}
+	case *object.Range:
+		return &object.Integer{Value: arg.Len()}
+	case *object.Bytes:
+		return &object.Integer{Value: int64(len(arg.Value))}
 	default:
 		return object.NewError(node, "argument to `len` not supported, got=%s",
 			args[0].Type())
 	}
 }
 
+// bytes( string|array|bytes ) -> bytes
+//
+// Strings are taken as UTF-8, and arrays must hold integers 0-255.
+func builtinBytes(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 1 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	switch arg := args[0].(type) {
+	case *object.String:
+		return &object.Bytes{Value: []byte(arg.Value)}
+	case *object.Bytes:
+		return arg
+	case *object.Array:
+		data := make([]byte, arg.Len())
+		for i, e := range arg.Elements() {
+			b, ok := e.(*object.Integer)
+			if !ok || b.Value < 0 || b.Value > 255 {
+				return object.NewError(node, "argument to `bytes` must hold integers 0-255, got=%s",
+					e.Inspect())
+			}
+			data[i] = byte(b.Value)
+		}
+		return &object.Bytes{Value: data}
+	}
+	return object.NewError(node, "argument to `bytes` must be STRING, ARRAY or BYTES, got=%s",
+		args[0].Type())
+}
+
 // regular expression match
 func builtinMatch(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
 	if len(args) != 2 {
@@ -241,7 +279,7 @@ func builtinMatch(node asti.NodeI, env *object.Environment, args ...object.Objec
 
 	if len(res) > 0 {
 
-		newHash := make(map[object.HashKey]object.HashPair)
+		newHash := &object.Hash{}
 
 		//
 		// If we get a match then the output is an array
@@ -256,22 +294,26 @@ func builtinMatch(node asti.NodeI, env *object.Environment, args ...object.Objec
 				v := &object.String{Value: res[i]}
 
 				newHashPair := object.HashPair{Key: k, Value: v}
-				newHash[k.HashKey()] = newHashPair
+				newHash = newHash.Set(k.HashKey(), newHashPair)
 
 			}
 		}
 
-		return &object.Hash{Pairs: newHash}
+		return newHash
 	}
 
 	// No match
 	return object.NULL
 }
 
-// mkdir
+// mkdir( path [, mode] [, parents] ) -> boolean
+//
+// Creates a directory, reporting false if it can't, as when it exists
+// already or its parent doesn't.  With parents true, missing parents
+// are created too, and an existing directory is fine, like mkdir -p.
 func builtinMkdir(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
-	if len(args) != 1 {
-		return object.NewError(node, "wrong number of arguments. got=%d, want=1",
+	if len(args) < 1 || len(args) > 3 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=1|2|3",
 			len(args))
 	}
 
@@ -282,20 +324,72 @@ func builtinMkdir(node asti.NodeI, env *object.Environment, args ...object.Objec
 
 	path := args[0].(*object.String).Value
 
-	// Can't fail?
-	mode, err := strconv.ParseInt("755", 8, 64)
-	if err != nil {
-		return &object.Boolean{Value: false}
+	// The mode is octal, as a string, like chmod's.
+	mode := int64(0755)
+	rest := args[1:]
+	if len(rest) > 0 {
+		if str, ok := rest[0].(*object.String); ok {
+			var err error
+			mode, err = strconv.ParseInt(str.Value, 8, 64)
+			if err != nil {
+				return object.NewError(node, "invalid mode %q for `mkdir`", str.Value)
+			}
+			rest = rest[1:]
+		}
+	}
+	parents := false
+	if len(rest) > 0 {
+		b, ok := rest[0].(*object.Boolean)
+		if !ok || len(rest) > 1 {
+			return object.NewError(node, "arguments to `mkdir` must be a STRING mode and a BOOLEAN, got %s",
+				rest[0].Type())
+		}
+		parents = b.Value
 	}
 
-	err = os.MkdirAll(path, os.FileMode(mode))
-	if err != nil {
+	mkdir := os.Mkdir
+	if parents {
+		mkdir = os.MkdirAll
+	}
+	if err := mkdir(path, os.FileMode(mode)); err != nil {
 		return &object.Boolean{Value: false}
 	}
 	return &object.Boolean{Value: true}
 
 }
 
+// new_set creates a set, optionally from the elements of an array or
+// the keys of a hash.
+func builtinNewSet(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) > 1 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=0 or 1",
+			len(args))
+	}
+	if len(args) == 0 {
+		return &object.Set{}
+	}
+	var elements []object.ObjectI
+	switch arg := args[0].(type) {
+	case *object.Array:
+		elements = arg.Elements()
+	case *object.Hash:
+		for _, pair := range arg.Pairs() {
+			elements = append(elements, pair.Key)
+		}
+	case *object.Set:
+		return arg
+	default:
+		return object.NewError(node, "argument to `new_set` must be ARRAY, HASH or SET, got=%s",
+			args[0].Type())
+	}
+	for _, e := range elements {
+		if _, ok := e.(object.HashableI); !ok {
+			return object.NewError(node, "unusable as set element: %s", e.Type())
+		}
+	}
+	return object.NewSet(elements)
+}
+
 // set a global pragma
 func builtinPragma(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
 
@@ -314,9 +408,9 @@ func builtinPragma(node asti.NodeI, env *object.Environment, args ...object.Obje
 
 			if strings.HasPrefix(input, "no-") {
 				real := strings.TrimPrefix(input, "no-")
-				delete(pragmas.PRAGMAS, real)
+				pragmas.Disable(real)
 			} else {
-				pragmas.PRAGMAS[input] = 1
+				pragmas.Enable(input)
 			}
 		default:
 			return object.NewError(node, "argument to `pragma` not supported, got=%s",
@@ -325,21 +419,20 @@ func builtinPragma(node asti.NodeI, env *object.Environment, args ...object.Obje
 	}
 
 	// Now return the pragmas that are in-use.
-	len := len(pragmas.PRAGMAS)
+	names := pragmas.Names()
 
 	// Create a new array for the results.
-	array := make([]object.ObjectI, len)
-
-	i := 0
-	for key := range pragmas.PRAGMAS {
+	array := make([]object.ObjectI, len(names))
+	for i, key := range names {
 		array[i] = &object.String{Value: key}
-		i++
-
 	}
-	return &object.Array{Elements: array}
+	return object.NewArray(array)
 }
 
 // Open a file
+//
+// mode is one of "r", "w", "a" or "x" (create, failing if the file
+// exists), optionally followed by "+" to both read and write.
 func builtinOpen(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
 
 	path := ""
@@ -375,7 +468,9 @@ func builtinOpen(node asti.NodeI, env *object.Environment, args ...object.Object
 
 	// Create the object
 	file := &object.File{Filename: path}
-	file.Open(mode)
+	if err := file.Open(mode); err != nil {
+		return object.NewError(node, "open: %s", err)
+	}
 	return (file)
 }
 
@@ -390,11 +485,7 @@ func builtinPush(node asti.NodeI, env *object.Environment, args ...object.Object
 			args[0].Type())
 	}
 	arr := args[0].(*object.Array)
-	length := len(arr.Elements)
-	newElements := make([]object.ObjectI, length+1)
-	copy(newElements, arr.Elements)
-	newElements[length] = args[1]
-	return &object.Array{Elements: newElements}
+	return arr.Append(args[1])
 }
 
 // output a string to stdout
@@ -436,15 +527,10 @@ func builtinSet(node asti.NodeI, env *object.Environment, args ...object.ObjectI
 		return object.NewError(node, "key `set` into HASH must be Hashable, got=%s",
 			args[1].Type())
 	}
-	newHash := make(map[object.HashKey]object.HashPair)
 	hash := args[0].(*object.Hash)
-	for k, v := range hash.Pairs {
-		newHash[k] = v
-	}
 	newHashKey := key.HashKey()
 	newHashPair := object.HashPair{Key: args[1], Value: args[2]}
-	newHash[newHashKey] = newHashPair
-	return &object.Hash{Pairs: newHash}
+	return hash.Set(newHashKey, newHashPair)
 }
 
 // sprintfFun is the implementation of our `sprintf` function.
@@ -468,8 +554,13 @@ func builtinSprintf(node asti.NodeI, env *object.Environment, args ...object.Obj
 	argLen := len(args)
 	fmtArgs := make([]interface{}, argLen-1)
 
-	// Here we convert and assign.
+	// Here we convert and assign.  Null converts to nil, which
+	// go would show as "%!s(<nil>)", so it keeps its own name.
 	for i, v := range args[1:] {
+		if _, ok := v.(*object.Null); ok {
+			fmtArgs[i] = "<NULL>"
+			continue
+		}
 		fmtArgs[i] = v.ToInterface()
 	}
 
@@ -490,11 +581,16 @@ func builtinStat(node asti.NodeI, env *object.Environment, args ...object.Object
 	path := args[0].Inspect()
 	info, err := os.Stat(path)
 
-	res := make(map[object.HashKey]object.HashPair)
 	if err != nil {
 		// Empty hash as we've not yet set anything
-		return &object.Hash{Pairs: res}
+		return &object.Hash{}
 	}
+	return statHash(info)
+}
+
+// statHash describes a file, for stat() and fs.walk().
+func statHash(info os.FileInfo) *object.Hash {
+	res := &object.Hash{}
 
 	//
 	// OK populate the hash
@@ -504,26 +600,26 @@ func builtinStat(node asti.NodeI, env *object.Environment, args ...object.Object
 	sizeData := &object.Integer{Value: info.Size()}
 	sizeKey := &object.String{Value: "size"}
 	sizeHash := object.HashPair{Key: sizeKey, Value: sizeData}
-	res[sizeKey.HashKey()] = sizeHash
+	res = res.Set(sizeKey.HashKey(), sizeHash)
 
-	// mod-time -> int
-	mtimeData := &object.Integer{Value: info.ModTime().Unix()}
+	// mod-time -> time
+	mtimeData := &object.Time{Value: info.ModTime()}
 	mtimeKey := &object.String{Value: "mtime"}
 	mtimeHash := object.HashPair{Key: mtimeKey, Value: mtimeData}
-	res[mtimeKey.HashKey()] = mtimeHash
+	res = res.Set(mtimeKey.HashKey(), mtimeHash)
 
 	// Perm -> string
 	permData := &object.String{Value: info.Mode().String()}
 	permKey := &object.String{Value: "perm"}
 	permHash := object.HashPair{Key: permKey, Value: permData}
-	res[permKey.HashKey()] = permHash
+	res = res.Set(permKey.HashKey(), permHash)
 
 	// Mode -> string  (because we want to emphasise the octal nature)
 	m := fmt.Sprintf("%04o", info.Mode().Perm())
 	modeData := &object.String{Value: m}
 	modeKey := &object.String{Value: "mode"}
 	modeHash := object.HashPair{Key: modeKey, Value: modeData}
-	res[modeKey.HashKey()] = modeHash
+	res = res.Set(modeKey.HashKey(), modeHash)
 
 	typeStr := "unknown"
 	if info.Mode().IsDir() {
@@ -537,12 +633,12 @@ func builtinStat(node asti.NodeI, env *object.Environment, args ...object.Object
 	typeData := &object.String{Value: typeStr}
 	typeKey := &object.String{Value: "type"}
 	typeHash := object.HashPair{Key: typeKey, Value: typeData}
-	res[typeKey.HashKey()] = typeHash
-
-	return &object.Hash{Pairs: res}
+	res = res.Set(typeKey.HashKey(), typeHash)
 
+	return res
 }
 
+// convert a value to a string, as puts would print it
 func builtinString(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
 	if len(args) != 1 {
 		return object.NewError(node, "wrong number of arguments. got=%d, want=1",
@@ -553,6 +649,44 @@ func builtinString(node asti.NodeI, env *object.Environment, args ...object.Obje
 	return &object.String{Value: out}
 }
 
+// try( fn, args... ) -> {ok, value, error, stack}
+//
+// Calls fn with args, catching the error it fails with, if any.  On
+// failure ok is false, error is the message and stack is the calls
+// which led to the error, most recent last, as hashes of function and
+// line.
+func builtinTry(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) < 1 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=1+",
+			len(args))
+	}
+	switch args[0].(type) {
+	case *object.Function, *object.Builtin:
+	default:
+		return object.NewError(node, "argument to `try` must be FUNCTION, got=%s",
+			args[0].Type())
+	}
+
+	result := applyFunction(node, env, args[0], args[1:])
+	if err, ok := result.(*object.Error); ok {
+		return stringHash(map[string]object.ObjectI{
+			"ok":    object.FALSE,
+			"value": object.NULL,
+			"error": &object.String{Value: err.Message},
+			"stack": err.StackArray(),
+		})
+	}
+	if result == nil {
+		result = object.NULL
+	}
+	return stringHash(map[string]object.ObjectI{
+		"ok":    object.TRUE,
+		"value": result,
+		"error": object.NULL,
+		"stack": object.NewArray(nil),
+	})
+}
+
 // type of an item
 func builtinType(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
 	if len(args) != 1 {
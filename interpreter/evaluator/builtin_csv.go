@@ -0,0 +1,187 @@
+package evaluator
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/kasworld/nonkey/interpreter/asti"
+	"github.com/kasworld/nonkey/interpreter/object"
+)
+
+// csv.read( path|file [, header] ) -> array
+//
+// Each record becomes an array of strings.  When header is true the
+// first record names the columns and each following record becomes a
+// hash of column -> value instead.
+func builtinCSVRead(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 1 && len(args) != 2 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=1|2",
+			len(args))
+	}
+	header := false
+	if len(args) == 2 {
+		b, ok := args[1].(*object.Boolean)
+		if !ok {
+			return object.NewError(node, "argument to `csv.read` must be BOOLEAN, got=%s",
+				args[1].Type())
+		}
+		header = b.Value
+	}
+
+	var r io.Reader
+	switch arg := args[0].(type) {
+	case *object.String:
+		fh, err := os.Open(arg.Value)
+		if err != nil {
+			return object.NewError(node, "csv.read: %s", err)
+		}
+		defer fh.Close()
+		r = fh
+	case *object.File:
+		r = arg
+	default:
+		return object.NewError(node, "argument to `csv.read` must be STRING or FILE, got=%s",
+			args[0].Type())
+	}
+
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		if perr, ok := err.(*csv.ParseError); ok {
+			return object.NewError(node, "csv.read: line %d: %s", perr.Line, perr.Err)
+		}
+		return object.NewError(node, "csv.read: %s", err)
+	}
+
+	if !header {
+		rows := make([]object.ObjectI, len(records))
+		for i, record := range records {
+			rows[i] = csvRecord(record)
+		}
+		return object.NewArray(rows)
+	}
+	if len(records) == 0 {
+		return object.NewArray(nil)
+	}
+	columns := records[0]
+	rows := make([]object.ObjectI, len(records)-1)
+	for i, record := range records[1:] {
+		hash := &object.Hash{}
+		for j, column := range columns {
+			key := &object.String{Value: column}
+			val := &object.String{Value: record[j]}
+			hash = hash.Set(key.HashKey(), object.HashPair{Key: key, Value: val})
+		}
+		rows[i] = hash
+	}
+	return object.NewArray(rows)
+}
+
+func csvRecord(record []string) object.ObjectI {
+	fields := make([]object.ObjectI, len(record))
+	for i, field := range record {
+		fields[i] = &object.String{Value: field}
+	}
+	return object.NewArray(fields)
+}
+
+// csv.write( path|file, rows [, header] ) -> true
+//
+// rows is an array of arrays or of hashes.  header is an array of
+// column names written as the first record; for hash rows it also
+// picks the columns and their order, defaulting to the sorted keys of
+// the first row.  Values which aren't strings are written as they'd
+// be printed.
+func builtinCSVWrite(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 2 && len(args) != 3 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=2|3",
+			len(args))
+	}
+	rows, ok := args[1].(*object.Array)
+	if !ok {
+		return object.NewError(node, "argument to `csv.write` must be ARRAY, got=%s",
+			args[1].Type())
+	}
+
+	var columns []string
+	if len(args) == 3 {
+		header, ok := args[2].(*object.Array)
+		if !ok {
+			return object.NewError(node, "argument to `csv.write` must be ARRAY, got=%s",
+				args[2].Type())
+		}
+		for _, e := range header.Elements() {
+			columns = append(columns, csvField(e))
+		}
+	}
+
+	var records [][]string
+	for i, row := range rows.Elements() {
+		switch row := row.(type) {
+		case *object.Array:
+			var record []string
+			for _, e := range row.Elements() {
+				record = append(record, csvField(e))
+			}
+			records = append(records, record)
+		case *object.Hash:
+			if columns == nil {
+				for _, pair := range row.Pairs() {
+					columns = append(columns, csvField(pair.Key))
+				}
+				sort.Strings(columns)
+			}
+			record := make([]string, len(columns))
+			for j, column := range columns {
+				key := &object.String{Value: column}
+				if pair, ok := row.Get(key.HashKey()); ok {
+					record[j] = csvField(pair.Value)
+				}
+			}
+			records = append(records, record)
+		default:
+			return object.NewError(node, "csv.write: row %d must be ARRAY or HASH, got=%s",
+				i, row.Type())
+		}
+	}
+	if columns != nil {
+		records = append([][]string{columns}, records...)
+	}
+
+	var err error
+	switch arg := args[0].(type) {
+	case *object.String:
+		var fh *os.File
+		fh, err = os.Create(arg.Value)
+		if err == nil {
+			err = writeCSV(fh, records)
+			if cerr := fh.Close(); err == nil {
+				err = cerr
+			}
+		}
+	case *object.File:
+		err = writeCSV(arg, records)
+		if err == nil {
+			err = arg.Flush()
+		}
+	default:
+		return object.NewError(node, "argument to `csv.write` must be STRING or FILE, got=%s",
+			args[0].Type())
+	}
+	if err != nil {
+		return object.NewError(node, "csv.write: %s", err)
+	}
+	return object.TRUE
+}
+
+func writeCSV(w io.Writer, records [][]string) error {
+	return csv.NewWriter(w).WriteAll(records)
+}
+
+func csvField(obj object.ObjectI) string {
+	if str, ok := obj.(*object.String); ok {
+		return str.Value
+	}
+	return obj.Inspect()
+}
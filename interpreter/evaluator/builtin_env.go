@@ -12,7 +12,7 @@ import (
 func builtinOsEnvironment(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
 
 	osenv := os.Environ()
-	newHash := make(map[object.HashKey]object.HashPair)
+	newHash := &object.Hash{}
 
 	//
 	// If we get a match then the output is an array
@@ -26,10 +26,10 @@ func builtinOsEnvironment(node asti.NodeI, env *object.Environment, args ...obje
 		v := &object.String{Value: os.Getenv(osenv[i])}
 
 		newHashPair := object.HashPair{Key: k, Value: v}
-		newHash[k.HashKey()] = newHashPair
+		newHash = newHash.Set(k.HashKey(), newHashPair)
 	}
 
-	return &object.Hash{Pairs: newHash}
+	return newHash
 }
 
 // os.getenv( "PATH" ) -> string
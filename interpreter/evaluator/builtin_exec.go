@@ -0,0 +1,252 @@
+package evaluator
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kasworld/nonkey/interpreter/asti"
+	"github.com/kasworld/nonkey/interpreter/object"
+)
+
+// execCommand builds the command for exec.run and exec.start from an
+// argv array (or a command line, split as backticks do) and an
+// optional hash of options: stdin, env, dir and timeout.  It returns
+// the command's input and timeout separately, as only exec.run uses
+// them.
+func execCommand(node asti.NodeI, name string, args []object.ObjectI) (*exec.Cmd, []byte, time.Duration, object.ObjectI) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, nil, 0, object.NewError(node, "wrong number of arguments. got=%d, want=1|2",
+			len(args))
+	}
+
+	var argv []string
+	switch arg := args[0].(type) {
+	case *object.String:
+		words, err := splitCommand(arg.Value)
+		if err != nil {
+			return nil, nil, 0, object.NewError(node, "%s: %s", name, err)
+		}
+		argv = words
+	case *object.Array:
+		words, errObj := stringArgs(node, name, arg.Elements())
+		if errObj != nil {
+			return nil, nil, 0, errObj
+		}
+		argv = words
+	default:
+		return nil, nil, 0, object.NewError(node, "argument to `%s` must be ARRAY or STRING, got=%s",
+			name, args[0].Type())
+	}
+	if len(argv) == 0 {
+		return nil, nil, 0, object.NewError(node, "%s: empty command", name)
+	}
+	cmd := exec.Command(argv[0], argv[1:]...)
+
+	var stdin []byte
+	var timeout time.Duration
+	if len(args) == 1 {
+		return cmd, stdin, timeout, nil
+	}
+	opts, ok := args[1].(*object.Hash)
+	if !ok {
+		return nil, nil, 0, object.NewError(node, "argument to `%s` must be HASH, got=%s",
+			name, args[1].Type())
+	}
+	for _, pair := range opts.Pairs() {
+		key := pair.Key.Inspect()
+		switch val := pair.Value.(type) {
+		case *object.String:
+			switch key {
+			case "stdin":
+				stdin = []byte(val.Value)
+			case "dir":
+				cmd.Dir = val.Value
+			default:
+				return nil, nil, 0, object.NewError(node, "%s: unknown or invalid option %s", name, key)
+			}
+		case *object.Bytes:
+			if key != "stdin" {
+				return nil, nil, 0, object.NewError(node, "%s: unknown or invalid option %s", name, key)
+			}
+			stdin = val.Value
+		case *object.Integer, *object.Float:
+			if key != "timeout" {
+				return nil, nil, 0, object.NewError(node, "%s: unknown or invalid option %s", name, key)
+			}
+			if i, ok := val.(*object.Integer); ok {
+				timeout = time.Duration(i.Value) * time.Second
+			} else {
+				timeout = time.Duration(val.(*object.Float).Value * float64(time.Second))
+			}
+			if timeout <= 0 {
+				return nil, nil, 0, object.NewError(node, "%s: timeout must be positive", name)
+			}
+		case *object.Hash:
+			if key != "env" {
+				return nil, nil, 0, object.NewError(node, "%s: unknown or invalid option %s", name, key)
+			}
+			// The variables given are added to, or replace,
+			// those we were run with.
+			var vars []string
+			for _, v := range val.Pairs() {
+				vars = append(vars, v.Key.Inspect()+"="+v.Value.Inspect())
+			}
+			sort.Strings(vars)
+			cmd.Env = append(os.Environ(), vars...)
+		default:
+			return nil, nil, 0, object.NewError(node, "%s: unknown or invalid option %s", name, key)
+		}
+	}
+	return cmd, stdin, timeout, nil
+}
+
+// exec.run( argv [, {stdin, env, dir, timeout}] ) -> hash
+//
+// Runs a command to completion, returning a hash of exit_code, stdout,
+// stderr and duration in seconds.  A non-zero exit isn't an error, but
+// failing to start the command or running past the timeout is.
+func builtinExecRun(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	return runCommand(node, "exec.run", args)
+}
+
+// runCommand implements exec.run, and backticks.
+func runCommand(node asti.NodeI, name string, args []object.ObjectI) object.ObjectI {
+	cmd, stdin, timeout, errObj := execCommand(node, name, args)
+	if errObj != nil {
+		return errObj
+	}
+
+	var outb, errb bytes.Buffer
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &outb
+	cmd.Stderr = &errb
+	if timeout > 0 {
+		newProcessGroup(cmd)
+	}
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return object.NewError(node, "%s: %s", name, err)
+	}
+
+	// The timer kills the command only if it's still running, so
+	// killed says whether the command was cut short.
+	var mu sync.Mutex
+	finished, killed := false, false
+	if timeout > 0 {
+		timer := time.AfterFunc(timeout, func() {
+			mu.Lock()
+			defer mu.Unlock()
+			if !finished {
+				killed = true
+				killProcessGroup(cmd)
+			}
+		})
+		defer timer.Stop()
+	}
+	err := cmd.Wait()
+	duration := time.Since(start)
+	mu.Lock()
+	finished = true
+	mu.Unlock()
+	if killed {
+		return object.NewError(node, "%s: %s timed out after %s", name, cmd.Args[0], timeout)
+	}
+	code, err := object.ExitCode(cmd, err)
+	if err != nil {
+		return object.NewError(node, "%s: %s", name, err)
+	}
+
+	return stringHash(map[string]object.ObjectI{
+		"exit_code": &object.Integer{Value: code},
+		"stdout":    &object.String{Value: outb.String()},
+		"stderr":    &object.String{Value: errb.String()},
+		"duration":  &object.Float{Value: duration.Seconds()},
+	})
+}
+
+// exec.start( argv [, {env, dir}] ) -> process
+//
+// Starts a command without waiting for it.  The process it returns has
+// read_line, write, close, wait and kill methods.
+func builtinExecStart(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	cmd, stdin, timeout, errObj := execCommand(node, "exec.start", args)
+	if errObj != nil {
+		return errObj
+	}
+	if stdin != nil || timeout != 0 {
+		return object.NewError(node, "exec.start: stdin and timeout are only supported by exec.run")
+	}
+	proc, err := object.StartProcess(cmd)
+	if err != nil {
+		return object.NewError(node, "exec.start: %s", err)
+	}
+	return proc
+}
+
+// splitCommand splits a command line into words, much as a shell
+// would but without any expansion.
+//
+// So this input:
+//
+//	/bin/sh -c "ls /etc"
+//
+// Would give output of the form:
+//
+//	/bin/sh
+//	-c
+//	ls /etc
+//
+// Single quotes keep everything literally, while inside double quotes
+// a backslash escapes '"', '\' and '$'.  Elsewhere a backslash escapes
+// any character.
+func splitCommand(input string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	for i := 0; i < len(input); i++ {
+		c := input[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+			continue
+		case c == '\'':
+			end := strings.IndexByte(input[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote in %q", input)
+			}
+			word.WriteString(input[i+1 : i+1+end])
+			i += end + 1
+		case c == '"':
+			i++
+			for ; i < len(input) && input[i] != '"'; i++ {
+				if input[i] == '\\' && i+1 < len(input) && strings.IndexByte("\"\\$", input[i+1]) >= 0 {
+					i++
+				}
+				word.WriteByte(input[i])
+			}
+			if i >= len(input) {
+				return nil, fmt.Errorf("unterminated quote in %q", input)
+			}
+		case c == '\\' && i+1 < len(input):
+			i++
+			word.WriteByte(input[i])
+		default:
+			word.WriteByte(c)
+		}
+		inWord = true
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -1,21 +1,31 @@
 package evaluator
 
 import (
+	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/kasworld/nonkey/interpreter/asti"
 	"github.com/kasworld/nonkey/interpreter/object"
 )
 
 // array = directory.glob( "/etc/*.conf" )
+//
+// A "**" path element matches any number of directories, so
+// "src/**/*.go" finds go files anywhere below src.
 func builtinDirectoryGlob(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
 	if len(args) != 1 {
 		return object.NewError(node, "wrong number of arguments. got=%d, want=1",
 			len(args))
 	}
-	pattern := args[0].(*object.String).Value
+	str, ok := args[0].(*object.String)
+	if !ok {
+		return object.NewError(node, "argument to `directory.glob` must be STRING, got=%s",
+			args[0].Type())
+	}
+	pattern := str.Value
 
-	entries, err := filepath.Glob(pattern)
+	entries, err := glob(pattern)
 	if err != nil {
 		return object.NULL
 	}
@@ -26,5 +36,66 @@ func builtinDirectoryGlob(node asti.NodeI, env *object.Environment, args ...obje
 	for i, txt := range entries {
 		result[i] = &object.String{Value: txt}
 	}
-	return &object.Array{Elements: result}
+	return object.NewArray(result)
+}
+
+// glob is filepath.Glob with support for "**".
+func glob(pattern string) ([]string, error) {
+	if !strings.Contains(pattern, "**") {
+		return filepath.Glob(pattern)
+	}
+
+	// Walk from the leading directories which have no wildcards,
+	// matching everything found against the rest of the pattern
+	// one element at a time.
+	parts := strings.Split(filepath.ToSlash(pattern), "/")
+	i := 0
+	for i < len(parts) && !strings.ContainsAny(parts[i], `*?[\`) {
+		i++
+	}
+	for _, part := range parts[i:] {
+		if _, err := filepath.Match(part, ""); err != nil {
+			return nil, err
+		}
+	}
+	root := filepath.FromSlash(strings.Join(parts[:i], "/"))
+	switch {
+	case i > 0 && root == "":
+		root = string(filepath.Separator)
+	case root == "":
+		root = "."
+	}
+
+	var matches []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Skip what we can't read, as filepath.Glob does.
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." {
+			return nil
+		}
+		if globMatch(parts[i:], strings.Split(filepath.ToSlash(rel), "/")) {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	return matches, err
+}
+
+// globMatch matches path elements against pattern elements.
+func globMatch(pattern, elems []string) bool {
+	if len(pattern) == 0 {
+		return len(elems) == 0
+	}
+	if pattern[0] == "**" {
+		return globMatch(pattern[1:], elems) ||
+			(len(elems) > 0 && globMatch(pattern, elems[1:]))
+	}
+	if len(elems) == 0 {
+		return false
+	}
+	ok, _ := filepath.Match(pattern[0], elems[0])
+	return ok && globMatch(pattern[1:], elems[1:])
 }
@@ -0,0 +1,245 @@
+package evaluator
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/kasworld/nonkey/interpreter/asti"
+	"github.com/kasworld/nonkey/interpreter/object"
+)
+
+// stringArgs checks that every argument is a string, returning their
+// values or an error object.
+func stringArgs(node asti.NodeI, name string, args []object.ObjectI) ([]string, object.ObjectI) {
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		str, ok := arg.(*object.String)
+		if !ok {
+			return nil, object.NewError(node, "argument to `%s` must be STRING, got=%s",
+				name, arg.Type())
+		}
+		strs[i] = str.Value
+	}
+	return strs, nil
+}
+
+// walkError carries an error raised by the function given to fs.walk
+// out of filepath.Walk.
+type walkError struct {
+	obj object.ObjectI
+}
+
+func (e walkError) Error() string {
+	return e.obj.Inspect()
+}
+
+// fs.walk( dir, fn ) -> true
+//
+// Calls fn(path, info) for dir and everything below it, in lexical
+// order, where info is the hash stat() returns.  If fn returns false
+// for a directory its contents are skipped.
+func builtinFsWalk(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 2 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	root, errObj := stringArgs(node, "fs.walk", args[:1])
+	if errObj != nil {
+		return errObj
+	}
+	fn := args[1]
+
+	err := filepath.Walk(root[0], func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		res := applyFunction(node, env, fn, []object.ObjectI{&object.String{Value: path}, statHash(info)})
+		if object.IsError(res) {
+			return walkError{res}
+		}
+		if b, ok := res.(*object.Boolean); ok && !b.Value && info.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if werr, ok := err.(walkError); ok {
+		return werr.obj
+	}
+	if err != nil {
+		return object.NewError(node, "fs.walk: %s", err)
+	}
+	return object.TRUE
+}
+
+// fs.read_dir( dir ) -> array of names, sorted
+func builtinFsReadDir(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 1 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	dir, errObj := stringArgs(node, "fs.read_dir", args)
+	if errObj != nil {
+		return errObj
+	}
+	entries, err := ioutil.ReadDir(dir[0])
+	if err != nil {
+		return object.NewError(node, "fs.read_dir: %s", err)
+	}
+	result := make([]object.ObjectI, len(entries))
+	for i, entry := range entries {
+		result[i] = &object.String{Value: entry.Name()}
+	}
+	return object.NewArray(result)
+}
+
+// fs.exists( path ) -> bool
+func builtinFsExists(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 1 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	path, errObj := stringArgs(node, "fs.exists", args)
+	if errObj != nil {
+		return errObj
+	}
+	_, err := os.Stat(path[0])
+	return nativeBoolToBooleanObject(err == nil)
+}
+
+// fs.copy( src, dst ) -> true
+//
+// Directories are copied recursively.  Copying a file to an existing
+// directory puts it inside, as cp does.  Permissions are kept.
+func builtinFsCopy(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 2 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	paths, errObj := stringArgs(node, "fs.copy", args)
+	if errObj != nil {
+		return errObj
+	}
+	src, dst := paths[0], paths[1]
+
+	info, err := os.Stat(src)
+	if err == nil && !info.IsDir() {
+		if dinfo, derr := os.Stat(dst); derr == nil && dinfo.IsDir() {
+			dst = filepath.Join(dst, filepath.Base(src))
+		}
+		err = copyFile(src, dst, info.Mode())
+	} else if err == nil {
+		err = filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(src, path)
+			if err != nil {
+				return err
+			}
+			target := filepath.Join(dst, rel)
+			if info.IsDir() {
+				return os.MkdirAll(target, info.Mode().Perm())
+			}
+			return copyFile(path, target, info.Mode())
+		})
+	}
+	if err != nil {
+		return object.NewError(node, "fs.copy: %s", err)
+	}
+	return object.TRUE
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// fs.rename( src, dst ) -> true
+func builtinFsRename(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 2 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	paths, errObj := stringArgs(node, "fs.rename", args)
+	if errObj != nil {
+		return errObj
+	}
+	if err := os.Rename(paths[0], paths[1]); err != nil {
+		return object.NewError(node, "fs.rename: %s", err)
+	}
+	return object.TRUE
+}
+
+// fs.remove_all( path ) -> true
+//
+// Removes path and anything below it; a missing path isn't an error.
+func builtinFsRemoveAll(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 1 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	path, errObj := stringArgs(node, "fs.remove_all", args)
+	if errObj != nil {
+		return errObj
+	}
+	if err := os.RemoveAll(path[0]); err != nil {
+		return object.NewError(node, "fs.remove_all: %s", err)
+	}
+	return object.TRUE
+}
+
+// fs.temp_dir( [pattern] ) -> path
+//
+// Creates a new directory in the system temporary directory.  A "*"
+// in pattern is replaced by a random string.
+func builtinFsTempDir(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) > 1 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=0|1",
+			len(args))
+	}
+	pattern, errObj := stringArgs(node, "fs.temp_dir", args)
+	if errObj != nil {
+		return errObj
+	}
+	dir, err := ioutil.TempDir("", append(pattern, "")[0])
+	if err != nil {
+		return object.NewError(node, "fs.temp_dir: %s", err)
+	}
+	return &object.String{Value: dir}
+}
+
+// fs.temp_file( [pattern] ) -> path
+//
+// Creates a new empty file in the system temporary directory, named
+// as for fs.temp_dir.
+func builtinFsTempFile(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) > 1 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=0|1",
+			len(args))
+	}
+	pattern, errObj := stringArgs(node, "fs.temp_file", args)
+	if errObj != nil {
+		return errObj
+	}
+	file, err := ioutil.TempFile("", append(pattern, "")[0])
+	if err != nil {
+		return object.NewError(node, "fs.temp_file: %s", err)
+	}
+	file.Close()
+	return &object.String{Value: file.Name()}
+}
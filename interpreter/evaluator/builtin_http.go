@@ -0,0 +1,308 @@
+package evaluator
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kasworld/nonkey/interpreter/asti"
+	"github.com/kasworld/nonkey/interpreter/object"
+)
+
+// httpTimeout is used for requests which don't set a timeout.
+const httpTimeout = 30 * time.Second
+
+// http.request( {method, url, headers, body, timeout} ) -> hash
+//
+// method defaults to "GET", headers is a hash of name -> string (or
+// array of strings), body is a string and timeout is in seconds.  The
+// result is a hash of status, headers and body; a status which isn't
+// 2xx is not an error, only failing to get a response at all is.
+func builtinHTTPRequest(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 1 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	opts, ok := args[0].(*object.Hash)
+	if !ok {
+		return object.NewError(node, "argument to `http.request` must be HASH, got=%s",
+			args[0].Type())
+	}
+
+	method, url, body := "GET", "", ""
+	for name, dst := range map[string]*string{"method": &method, "url": &url, "body": &body} {
+		val, ok := hashField(opts, name)
+		if !ok {
+			continue
+		}
+		str, ok := val.(*object.String)
+		if !ok {
+			return object.NewError(node, "http.request: %s must be STRING, got=%s",
+				name, val.Type())
+		}
+		*dst = str.Value
+	}
+	if url == "" {
+		return object.NewError(node, "http.request: url is required")
+	}
+
+	timeout := httpTimeout
+	if val, ok := hashField(opts, "timeout"); ok {
+		switch val := val.(type) {
+		case *object.Integer:
+			timeout = time.Duration(val.Value) * time.Second
+		case *object.Float:
+			timeout = time.Duration(val.Value * float64(time.Second))
+		default:
+			return object.NewError(node, "http.request: timeout must be INTEGER or FLOAT, got=%s",
+				val.Type())
+		}
+	}
+
+	req, err := http.NewRequest(strings.ToUpper(method), url, strings.NewReader(body))
+	if err != nil {
+		return object.NewError(node, "http.request: %s", err)
+	}
+	if val, ok := hashField(opts, "headers"); ok {
+		if err := setHTTPHeaders(req.Header, val); err != nil {
+			return object.NewError(node, "http.request: %s", err)
+		}
+	}
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return object.NewError(node, "http.request: %s", err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return object.NewError(node, "http.request: %s", err)
+	}
+
+	return stringHash(map[string]object.ObjectI{
+		"status":  &object.Integer{Value: int64(resp.StatusCode)},
+		"headers": httpHeaders(resp.Header),
+		"body":    &object.String{Value: string(data)},
+	})
+}
+
+// http.get( url [, headers] ) -> hash
+func builtinHTTPGet(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 1 && len(args) != 2 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=1|2",
+			len(args))
+	}
+	opts := map[string]object.ObjectI{"method": &object.String{Value: "GET"}, "url": args[0]}
+	if len(args) == 2 {
+		opts["headers"] = args[1]
+	}
+	return builtinHTTPRequest(node, env, stringHash(opts))
+}
+
+// http.post( url, body [, headers] ) -> hash
+func builtinHTTPPost(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 2 && len(args) != 3 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=2|3",
+			len(args))
+	}
+	opts := map[string]object.ObjectI{"method": &object.String{Value: "POST"}, "url": args[0], "body": args[1]}
+	if len(args) == 3 {
+		opts["headers"] = args[2]
+	}
+	return builtinHTTPRequest(node, env, stringHash(opts))
+}
+
+// hashField looks up a string key in a hash.
+func hashField(hash *object.Hash, name string) (object.ObjectI, bool) {
+	key := &object.String{Value: name}
+	pair, ok := hash.Get(key.HashKey())
+	if !ok {
+		return nil, false
+	}
+	return pair.Value, true
+}
+
+// stringHash makes a hash with string keys.
+func stringHash(fields map[string]object.ObjectI) *object.Hash {
+	hash := &object.Hash{}
+	for name, val := range fields {
+		key := &object.String{Value: name}
+		hash = hash.Set(key.HashKey(), object.HashPair{Key: key, Value: val})
+	}
+	return hash
+}
+
+// httpHeaders converts headers to a hash, joining repeated headers
+// with commas.
+func httpHeaders(header http.Header) *object.Hash {
+	fields := make(map[string]object.ObjectI, len(header))
+	for name, values := range header {
+		fields[name] = &object.String{Value: strings.Join(values, ", ")}
+	}
+	return stringHash(fields)
+}
+
+// setHTTPHeaders adds headers from a hash of name -> string or array
+// of strings.
+func setHTTPHeaders(header http.Header, obj object.ObjectI) error {
+	hash, ok := obj.(*object.Hash)
+	if !ok {
+		return fmt.Errorf("headers must be HASH, got=%s", obj.Type())
+	}
+	pairs := hash.Pairs()
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Key.Inspect() < pairs[j].Key.Inspect()
+	})
+	for _, pair := range pairs {
+		name, ok := pair.Key.(*object.String)
+		if !ok {
+			return fmt.Errorf("header name must be STRING, got=%s", pair.Key.Type())
+		}
+		switch val := pair.Value.(type) {
+		case *object.String:
+			header.Add(name.Value, val.Value)
+		case *object.Array:
+			for _, e := range val.Elements() {
+				str, ok := e.(*object.String)
+				if !ok {
+					return fmt.Errorf("header %s must be STRING, got=%s", name.Value, e.Type())
+				}
+				header.Add(name.Value, str.Value)
+			}
+		default:
+			return fmt.Errorf("header %s must be STRING or ARRAY, got=%s", name.Value, val.Type())
+		}
+	}
+	return nil
+}
+
+// http.serve( addr, handler ) -> error
+//
+// Serves HTTP on addr until it fails, calling handler with a hash of
+// method, path, query, headers and body for each request.  handler
+// returns a hash of status, headers and body, or just a body string.
+// Requests are handled concurrently, each in its own environment.
+func builtinHTTPServe(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 2 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	addr, ok := args[0].(*object.String)
+	if !ok {
+		return object.NewError(node, "argument to `http.serve` must be STRING, got=%s",
+			args[0].Type())
+	}
+	switch args[1].(type) {
+	case *object.Function, *object.Builtin:
+	default:
+		return object.NewError(node, "argument to `http.serve` must be FUNCTION, got=%s",
+			args[1].Type())
+	}
+
+	handler := &httpHandler{node: node, env: env, fn: args[1]}
+	err := http.ListenAndServe(addr.Value, handler)
+	return object.NewError(node, "http.serve: %s", err)
+}
+
+// httpHandler serves requests with a nonkey function.
+type httpHandler struct {
+	node asti.NodeI
+	env  *object.Environment
+	fn   object.ObjectI
+}
+
+func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query := make(map[string]object.ObjectI, len(r.URL.Query()))
+	for name, values := range r.URL.Query() {
+		if len(values) == 1 {
+			query[name] = &object.String{Value: values[0]}
+			continue
+		}
+		elements := make([]object.ObjectI, len(values))
+		for i, v := range values {
+			elements[i] = &object.String{Value: v}
+		}
+		query[name] = object.NewArray(elements)
+	}
+	req := stringHash(map[string]object.ObjectI{
+		"method":  &object.String{Value: r.Method},
+		"path":    &object.String{Value: r.URL.Path},
+		"query":   stringHash(query),
+		"headers": httpHeaders(r.Header),
+		"body":    &object.String{Value: string(body)},
+	})
+
+	env := object.NewEnclosedEnvironment(h.env)
+	res := applyFunction(h.node, env, h.fn, []object.ObjectI{req})
+	resp, err := newHTTPResponse(res)
+	if err != nil {
+		fmt.Fprintf(env.ErrorLog(), "http.serve: %s %s: %s\n", r.Method, r.URL.Path, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	for name, values := range resp.header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(resp.status)
+	if _, err := io.WriteString(w, resp.body); err != nil {
+		fmt.Fprintf(env.ErrorLog(), "http.serve: %s %s: %s\n", r.Method, r.URL.Path, err)
+	}
+}
+
+// httpResponse is what a handler answers with.
+type httpResponse struct {
+	status int
+	header http.Header
+	body   string
+}
+
+// newHTTPResponse converts the result of a handler, which is either a
+// body string or a hash of status, headers and body.  It checks the
+// whole result, so nothing is written for one which is wrong.
+func newHTTPResponse(res object.ObjectI) (*httpResponse, error) {
+	resp := &httpResponse{status: http.StatusOK, header: http.Header{}}
+	switch res := res.(type) {
+	case nil, *object.Null:
+		return nil, fmt.Errorf("handler returned nothing")
+	case *object.String:
+		resp.body = res.Value
+		return resp, nil
+	case *object.Error:
+		return nil, fmt.Errorf("%s", res.Message)
+	case *object.Hash:
+		if val, ok := hashField(res, "status"); ok {
+			code, ok := val.(*object.Integer)
+			if !ok {
+				return nil, fmt.Errorf("status must be INTEGER, got=%s", val.Type())
+			}
+			if code.Value < 100 || code.Value > 999 {
+				return nil, fmt.Errorf("status must be 100-999, got=%d", code.Value)
+			}
+			resp.status = int(code.Value)
+		}
+		if val, ok := hashField(res, "body"); ok {
+			str, ok := val.(*object.String)
+			if !ok {
+				return nil, fmt.Errorf("body must be STRING, got=%s", val.Type())
+			}
+			resp.body = str.Value
+		}
+		if val, ok := hashField(res, "headers"); ok {
+			if err := setHTTPHeaders(resp.header, val); err != nil {
+				return nil, err
+			}
+		}
+		return resp, nil
+	}
+	return nil, fmt.Errorf("handler must return HASH or STRING, got=%s", res.Type())
+}
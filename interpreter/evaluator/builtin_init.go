@@ -1,6 +1,8 @@
 package evaluator
 
 import (
+	"path/filepath"
+
 	"github.com/kasworld/nonkey/config/builtinfunctions"
 	"github.com/kasworld/nonkey/interpreter/object"
 )
@@ -9,15 +11,27 @@ func init() {
 	builtinfunctions.BuiltinFunctions = map[string]*object.Builtin{
 		"version":        {Fn: builtinVersion},
 		"args":           {Fn: builtinArgs},
+		"assert":         {Fn: builtinAssert},
+		"assert_eq":      {Fn: builtinAssertEq},
+		"assert_ne":      {Fn: builtinAssertNe},
+		"assert_raises":  {Fn: builtinAssertRaises},
+		"bytes":          {Fn: builtinBytes},
+		"chan":           {Fn: builtinChan},
 		"chmod":          {Fn: builtinChmod},
+		"compare":        {Fn: builtinCompare},
 		"delete":         {Fn: builtinDelete},
+		"enumerate":      {Fn: builtinEnumerate},
 		"eval":           {Fn: builtinEval},
 		"exit":           {Fn: builtinExit},
+		"filter":         {Fn: builtinFilter},
 		"int":            {Fn: builtinInt},
+		"iter":           {Fn: builtinIter},
 		"keys":           {Fn: builtinKeys},
 		"len":            {Fn: builtinLen},
+		"map":            {Fn: builtinMap},
 		"match":          {Fn: builtinMatch},
 		"mkdir":          {Fn: builtinMkdir},
+		"new_set":        {Fn: builtinNewSet},
 		"pragma":         {Fn: builtinPragma},
 		"open":           {Fn: builtinOpen},
 		"push":           {Fn: builtinPush},
@@ -27,14 +41,53 @@ func init() {
 		"sprintf":        {Fn: builtinSprintf},
 		"stat":           {Fn: builtinStat},
 		"string":         {Fn: builtinString},
+		"take":           {Fn: builtinTake},
+		"try":            {Fn: builtinTry},
 		"type":           {Fn: builtinType},
 		"unlink":         {Fn: builtinUnlink},
+		"wait":           {Fn: builtinWait},
+		"zip":            {Fn: builtinZip},
 		"os.getenv":      {Fn: builtinOsGetEnv},
 		"os.setenv":      {Fn: builtinOsSetEnv},
 		"os.environment": {Fn: builtinOsEnvironment},
 		"directory.glob": {Fn: builtinDirectoryGlob},
+		"csv.read":       {Fn: builtinCSVRead},
+		"csv.write":      {Fn: builtinCSVWrite},
+		"exec.run":       {Fn: builtinExecRun},
+		"exec.start":     {Fn: builtinExecStart},
+		"fs.copy":        {Fn: builtinFsCopy},
+		"fs.exists":      {Fn: builtinFsExists},
+		"fs.read_dir":    {Fn: builtinFsReadDir},
+		"fs.remove_all":  {Fn: builtinFsRemoveAll},
+		"fs.rename":      {Fn: builtinFsRename},
+		"fs.temp_dir":    {Fn: builtinFsTempDir},
+		"fs.temp_file":   {Fn: builtinFsTempFile},
+		"fs.walk":        {Fn: builtinFsWalk},
+		"http.get":       {Fn: builtinHTTPGet},
+		"http.post":      {Fn: builtinHTTPPost},
+		"http.request":   {Fn: builtinHTTPRequest},
+		"http.serve":     {Fn: builtinHTTPServe},
+		"json.decode":    {Fn: builtinJSONDecode},
+		"json.encode":    {Fn: builtinJSONEncode},
 		"math.abs":       {Fn: builtinMathAbs},
 		"math.random":    {Fn: builtinMathRandom},
 		"math.sqrt":      {Fn: builtinMathSqrt},
+		"path.abs":       {Fn: builtinPathAbs},
+		"path.base":      {Fn: pathFunc("path.base", filepath.Base)},
+		"path.clean":     {Fn: pathFunc("path.clean", filepath.Clean)},
+		"path.dir":       {Fn: pathFunc("path.dir", filepath.Dir)},
+		"path.ext":       {Fn: pathFunc("path.ext", filepath.Ext)},
+		"path.join":      {Fn: builtinPathJoin},
+		"path.rel":       {Fn: builtinPathRel},
+		"time.date":      {Fn: builtinTimeDate},
+		"time.duration":  {Fn: builtinTimeDuration},
+		"time.now":       {Fn: builtinTimeNow},
+		"time.parse":     {Fn: builtinTimeParse},
+		"time.since":     {Fn: builtinTimeSince},
+		"time.unix":      {Fn: builtinTimeUnix},
+		"toml.decode":    {Fn: builtinTOMLDecode},
+		"toml.encode":    {Fn: builtinTOMLEncode},
+		"yaml.decode":    {Fn: builtinYAMLDecode},
+		"yaml.encode":    {Fn: builtinYAMLEncode},
 	}
 }
@@ -0,0 +1,178 @@
+package evaluator
+
+import (
+	"github.com/kasworld/nonkey/interpreter/asti"
+	"github.com/kasworld/nonkey/interpreter/object"
+)
+
+// iterOf returns a fresh iterator over an iterable argument.
+func iterOf(node asti.NodeI, name string, arg object.ObjectI) (object.IteratorI, object.ObjectI) {
+	iterable, ok := arg.(object.IterableI)
+	if !ok {
+		return nil, object.NewError(node, "argument to `%s` must be iterable, got=%s",
+			name, arg.Type())
+	}
+	return iterable.Iter(), nil
+}
+
+// iter returns an iterator over any iterable, for use with `.next()`.
+func builtinIter(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 1 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	it, err := iterOf(node, "iter", args[0])
+	if err != nil {
+		return err
+	}
+	if it, ok := it.(*object.Iterator); ok {
+		return it
+	}
+	return object.NewIterator(it.Next)
+}
+
+// map lazily applies fn to each item.
+func builtinMap(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 2 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	it, err := iterOf(node, "map", args[0])
+	if err != nil {
+		return err
+	}
+	fn := args[1]
+	out := object.NewIterator(func() (object.ObjectI, object.ObjectI, bool) {
+		val, idx, ok := it.Next()
+		if !ok || object.IsError(val) {
+			return val, idx, ok
+		}
+		return applyFunction(node, env, fn, []object.ObjectI{val}), idx, true
+	})
+	out.OnClose(func() { object.CloseIterator(it) })
+	return out
+}
+
+// filter lazily keeps the items for which fn is truthy.
+func builtinFilter(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 2 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	it, err := iterOf(node, "filter", args[0])
+	if err != nil {
+		return err
+	}
+	fn := args[1]
+	var count int64
+	out := object.NewIterator(func() (object.ObjectI, object.ObjectI, bool) {
+		for {
+			val, idx, ok := it.Next()
+			if !ok || object.IsError(val) {
+				return val, idx, ok
+			}
+			keep := applyFunction(node, env, fn, []object.ObjectI{val})
+			if object.IsError(keep) {
+				return keep, idx, true
+			}
+			if isTruthy(keep) {
+				count++
+				return val, &object.Integer{Value: count - 1}, true
+			}
+		}
+	})
+	out.OnClose(func() { object.CloseIterator(it) })
+	return out
+}
+
+// take lazily stops after the first n items, closing what it takes
+// them from.
+func builtinTake(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 2 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	it, err := iterOf(node, "take", args[0])
+	if err != nil {
+		return err
+	}
+	n, ok := args[1].(*object.Integer)
+	if !ok {
+		return object.NewError(node, "argument to `take` must be INTEGER, got=%s",
+			args[1].Type())
+	}
+	var taken int64
+	out := object.NewIterator(func() (object.ObjectI, object.ObjectI, bool) {
+		if taken >= n.Value {
+			return nil, &object.Integer{Value: 0}, false
+		}
+		taken++
+		val, idx, ok := it.Next()
+		if taken == n.Value {
+			object.CloseIterator(it)
+		}
+		return val, idx, ok
+	})
+	out.OnClose(func() { object.CloseIterator(it) })
+	return out
+}
+
+// zip lazily yields arrays holding one item from each argument,
+// stopping with the shortest, and closing the rest.
+func builtinZip(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) < 1 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want>=1",
+			len(args))
+	}
+	its := make([]object.IteratorI, len(args))
+	for i, arg := range args {
+		it, err := iterOf(node, "zip", arg)
+		if err != nil {
+			return err
+		}
+		its[i] = it
+	}
+	var count int64
+	out := object.NewIterator(func() (object.ObjectI, object.ObjectI, bool) {
+		items := make([]object.ObjectI, len(its))
+		for i, it := range its {
+			val, idx, ok := it.Next()
+			if !ok || object.IsError(val) {
+				return val, idx, ok
+			}
+			items[i] = val
+		}
+		count++
+		return object.NewArray(items), &object.Integer{Value: count - 1}, true
+	})
+	out.OnClose(func() {
+		for _, it := range its {
+			object.CloseIterator(it)
+		}
+	})
+	return out
+}
+
+// enumerate lazily yields [index, item] arrays.
+func builtinEnumerate(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 1 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	it, err := iterOf(node, "enumerate", args[0])
+	if err != nil {
+		return err
+	}
+	var count int64
+	out := object.NewIterator(func() (object.ObjectI, object.ObjectI, bool) {
+		val, idx, ok := it.Next()
+		if !ok || object.IsError(val) {
+			return val, idx, ok
+		}
+		count++
+		pos := &object.Integer{Value: count - 1}
+		return object.NewArray([]object.ObjectI{pos, val}), pos, true
+	})
+	out.OnClose(func() { object.CloseIterator(it) })
+	return out
+}
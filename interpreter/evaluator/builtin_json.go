@@ -0,0 +1,212 @@
+package evaluator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kasworld/nonkey/interpreter/asti"
+	"github.com/kasworld/nonkey/interpreter/object"
+)
+
+// json.encode( value [, indent] ) -> string
+//
+// Hash keys must be strings and are written sorted, so the output is
+// stable.  Floats always keep a fraction or exponent, so they decode
+// as floats again.  indent is a number of spaces or a string.
+func builtinJSONEncode(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 1 && len(args) != 2 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=1|2",
+			len(args))
+	}
+
+	var buf bytes.Buffer
+	if err := encodeJSON(&buf, args[0]); err != nil {
+		return object.NewError(node, "json.encode: %s", err)
+	}
+	if len(args) == 1 {
+		return &object.String{Value: buf.String()}
+	}
+
+	var indent string
+	switch arg := args[1].(type) {
+	case *object.Integer:
+		indent = strings.Repeat(" ", int(arg.Value))
+	case *object.String:
+		indent = arg.Value
+	default:
+		return object.NewError(node, "json.encode: indent must be INTEGER or STRING, got=%s",
+			args[1].Type())
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", indent); err != nil {
+		return object.NewError(node, "json.encode: %s", err)
+	}
+	return &object.String{Value: out.String()}
+}
+
+func encodeJSON(buf *bytes.Buffer, obj object.ObjectI) error {
+	switch obj := obj.(type) {
+	case *object.Null:
+		buf.WriteString("null")
+	case *object.Boolean:
+		buf.WriteString(strconv.FormatBool(obj.Value))
+	case *object.Integer:
+		buf.WriteString(strconv.FormatInt(obj.Value, 10))
+	case *object.Float:
+		if math.IsInf(obj.Value, 0) || math.IsNaN(obj.Value) {
+			return fmt.Errorf("unsupported float value %v", obj.Value)
+		}
+		s := strconv.FormatFloat(obj.Value, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		buf.WriteString(s)
+	case *object.String:
+		b, err := json.Marshal(obj.Value)
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+	case *object.Time:
+		buf.WriteString(quoteString(obj.Value.Format(time.RFC3339Nano)))
+	case *object.Array:
+		buf.WriteByte('[')
+		for i, e := range obj.Elements() {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeJSON(buf, e); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case *object.Hash:
+		pairs := obj.Pairs()
+		keys := make([]string, len(pairs))
+		values := make(map[string]object.ObjectI, len(pairs))
+		for i, pair := range pairs {
+			key, ok := pair.Key.(*object.String)
+			if !ok {
+				return fmt.Errorf("hash key must be STRING, got %s", pair.Key.Type())
+			}
+			keys[i] = key.Value
+			values[key.Value] = pair.Value
+		}
+		sort.Strings(keys)
+		buf.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			b, err := json.Marshal(key)
+			if err != nil {
+				return err
+			}
+			buf.Write(b)
+			buf.WriteByte(':')
+			if err := encodeJSON(buf, values[key]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("can't encode %s", obj.Type())
+	}
+	return nil
+}
+
+// quoteString returns s as a double-quoted JSON string, which YAML
+// and TOML read the same way.
+func quoteString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// json.decode( string ) -> value
+//
+// Numbers without a fraction or exponent become integers, the rest
+// floats.
+func builtinJSONDecode(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 1 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	str, ok := args[0].(*object.String)
+	if !ok {
+		return object.NewError(node, "argument to `json.decode` must be STRING, got=%s",
+			args[0].Type())
+	}
+
+	dec := json.NewDecoder(strings.NewReader(str.Value))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return object.NewError(node, "json.decode: %s", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return object.NewError(node, "json.decode: unexpected data after value")
+	}
+	obj, err := decodeJSON(value)
+	if err != nil {
+		return object.NewError(node, "json.decode: %s", err)
+	}
+	return obj
+}
+
+func decodeJSON(value interface{}) (object.ObjectI, error) {
+	switch value := value.(type) {
+	case nil:
+		return object.NULL, nil
+	case bool:
+		if value {
+			return object.TRUE, nil
+		}
+		return object.FALSE, nil
+	case json.Number:
+		s := value.String()
+		if !strings.ContainsAny(s, ".eE") {
+			if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return &object.Integer{Value: i}, nil
+			}
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, err
+		}
+		return &object.Float{Value: f}, nil
+	case string:
+		return &object.String{Value: value}, nil
+	case []interface{}:
+		elements := make([]object.ObjectI, len(value))
+		for i, e := range value {
+			obj, err := decodeJSON(e)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = obj
+		}
+		return object.NewArray(elements), nil
+	case map[string]interface{}:
+		hash := &object.Hash{}
+		for k, v := range value {
+			obj, err := decodeJSON(v)
+			if err != nil {
+				return nil, err
+			}
+			key := &object.String{Value: k}
+			hash = hash.Set(key.HashKey(), object.HashPair{Key: key, Value: obj})
+		}
+		return hash, nil
+	}
+	return nil, fmt.Errorf("unexpected value %v", value)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/kasworld/nonkey/interpreter/object"
 )
 
+// val = math.abs(int|float);
 func builtinMathAbs(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
 	if len(args) != 1 {
 		return object.NewError(node, "wrong number of arguments. got=%d, want=1",
@@ -0,0 +1,68 @@
+package evaluator
+
+import (
+	"path/filepath"
+
+	"github.com/kasworld/nonkey/interpreter/asti"
+	"github.com/kasworld/nonkey/interpreter/object"
+)
+
+// pathFunc makes a path.* builtin from a function of one path.
+func pathFunc(name string, fn func(string) string) func(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	return func(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+		if len(args) != 1 {
+			return object.NewError(node, "wrong number of arguments. got=%d, want=1",
+				len(args))
+		}
+		path, errObj := stringArgs(node, name, args)
+		if errObj != nil {
+			return errObj
+		}
+		return &object.String{Value: fn(path[0])}
+	}
+}
+
+// path.join( elem, ... ) -> string
+func builtinPathJoin(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	elems, errObj := stringArgs(node, "path.join", args)
+	if errObj != nil {
+		return errObj
+	}
+	return &object.String{Value: filepath.Join(elems...)}
+}
+
+// path.abs( path ) -> string
+func builtinPathAbs(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 1 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	path, errObj := stringArgs(node, "path.abs", args)
+	if errObj != nil {
+		return errObj
+	}
+	abs, err := filepath.Abs(path[0])
+	if err != nil {
+		return object.NewError(node, "path.abs: %s", err)
+	}
+	return &object.String{Value: abs}
+}
+
+// path.rel( base, target ) -> string
+//
+// Returns target relative to base.
+func builtinPathRel(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 2 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	paths, errObj := stringArgs(node, "path.rel", args)
+	if errObj != nil {
+		return errObj
+	}
+	rel, err := filepath.Rel(paths[0], paths[1])
+	if err != nil {
+		return object.NewError(node, "path.rel: %s", err)
+	}
+	return &object.String{Value: rel}
+}
@@ -24,5 +24,5 @@ func builtinArgs(node asti.NodeI, env *object.Environment, args ...object.Object
 	for i, txt := range os.Args[1:] {
 		result[i] = &object.String{Value: txt}
 	}
-	return &object.Array{Elements: result}
+	return object.NewArray(result)
 }
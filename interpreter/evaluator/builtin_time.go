@@ -0,0 +1,145 @@
+package evaluator
+
+import (
+	"time"
+
+	"github.com/kasworld/nonkey/interpreter/asti"
+	"github.com/kasworld/nonkey/interpreter/object"
+)
+
+// timeLocation returns the zone named by an optional final string
+// argument, or UTC, along with the remaining arguments.
+func timeLocation(node asti.NodeI, name string, args []object.ObjectI) (*time.Location, []object.ObjectI, object.ObjectI) {
+	if len(args) == 0 {
+		return time.UTC, args, nil
+	}
+	str, ok := args[len(args)-1].(*object.String)
+	if !ok {
+		return time.UTC, args, nil
+	}
+	loc, err := object.LoadLocation(str.Value)
+	if err != nil {
+		return nil, nil, object.NewError(node, "%s: %s", name, err)
+	}
+	return loc, args[:len(args)-1], nil
+}
+
+// time.now() -> time
+//
+// Hosts may replace the clock this reads, see Environment.SetClock.
+func builtinTimeNow(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 0 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=0",
+			len(args))
+	}
+	return &object.Time{Value: env.Now()}
+}
+
+// time.since( t ) -> duration
+func builtinTimeSince(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 1 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	t, ok := args[0].(*object.Time)
+	if !ok {
+		return object.NewError(node, "argument to `time.since` must be TIME, got=%s",
+			args[0].Type())
+	}
+	return &object.Duration{Value: env.Now().Sub(t.Value)}
+}
+
+// time.parse( layout, str [, zone] ) -> time
+//
+// The layout is either Go's reference time, "2006-01-02 15:04:05",
+// or a name such as "RFC3339".  A time without an offset is taken
+// to be in zone, or UTC.
+func builtinTimeParse(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 2 && len(args) != 3 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=2|3",
+			len(args))
+	}
+	strs, errObj := stringArgs(node, "time.parse", args)
+	if errObj != nil {
+		return errObj
+	}
+	loc, _, errObj := timeLocation(node, "time.parse", args[2:])
+	if errObj != nil {
+		return errObj
+	}
+	t, err := time.ParseInLocation(object.Layout(strs[0]), strs[1], loc)
+	if err != nil {
+		return object.NewError(node, "time.parse: %s", err)
+	}
+	return &object.Time{Value: t}
+}
+
+// time.unix( seconds [, nanoseconds] ) -> time, in UTC
+func builtinTimeUnix(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 1 && len(args) != 2 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=1|2",
+			len(args))
+	}
+	var parts [2]int64
+	for i, arg := range args {
+		n, ok := arg.(*object.Integer)
+		if !ok {
+			return object.NewError(node, "argument to `time.unix` must be INTEGER, got=%s",
+				arg.Type())
+		}
+		parts[i] = n.Value
+	}
+	return &object.Time{Value: time.Unix(parts[0], parts[1]).UTC()}
+}
+
+// time.date( year, month, day [, hour, minute, second, nanosecond] [, zone] ) -> time
+//
+// Out of range values are normalized, so October 32 is November 1.
+// The zone defaults to UTC.
+func builtinTimeDate(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	loc, nums, errObj := timeLocation(node, "time.date", args)
+	if errObj != nil {
+		return errObj
+	}
+	if len(nums) < 3 || len(nums) > 7 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=3..7 and a zone",
+			len(args))
+	}
+	var parts [7]int
+	for i, arg := range nums {
+		n, ok := arg.(*object.Integer)
+		if !ok {
+			return object.NewError(node, "argument to `time.date` must be INTEGER, got=%s",
+				arg.Type())
+		}
+		parts[i] = int(n.Value)
+	}
+	t := time.Date(parts[0], time.Month(parts[1]), parts[2], parts[3], parts[4], parts[5], parts[6], loc)
+	return &object.Time{Value: t}
+}
+
+// time.duration( str | seconds ) -> duration
+//
+// Strings are as "1h30m", "250ms" or "-1.5s".
+func builtinTimeDuration(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 1 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	switch arg := args[0].(type) {
+	case *object.String:
+		d, err := time.ParseDuration(arg.Value)
+		if err != nil {
+			return object.NewError(node, "time.duration: %s", err)
+		}
+		return &object.Duration{Value: d}
+	case *object.Integer:
+		return &object.Duration{Value: time.Duration(arg.Value) * time.Second}
+	case *object.Float:
+		return &object.Duration{Value: time.Duration(arg.Value * float64(time.Second))}
+	case *object.Duration:
+		return arg
+	}
+	return object.NewError(node, "argument to `time.duration` must be STRING, INTEGER or FLOAT, got=%s",
+		args[0].Type())
+}
@@ -0,0 +1,754 @@
+package evaluator
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kasworld/nonkey/interpreter/asti"
+	"github.com/kasworld/nonkey/interpreter/object"
+)
+
+// toml.decode( string ) -> hash
+//
+// Dates and times have no type of their own yet, so they're returned
+// as strings.
+func builtinTOMLDecode(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 1 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	str, ok := args[0].(*object.String)
+	if !ok {
+		return object.NewError(node, "argument to `toml.decode` must be STRING, got=%s",
+			args[0].Type())
+	}
+	p := &tomlParser{s: str.Value}
+	root, err := p.parse()
+	if err != nil {
+		return object.NewError(node, "toml.decode: %s", err)
+	}
+	return root.toHash()
+}
+
+// toml.encode( hash ) -> string
+//
+// Nested hashes become [tables] and arrays of hashes [[arrays of
+// tables]].  TOML has no null, so null values are an error.
+func builtinTOMLEncode(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 1 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	hash, ok := args[0].(*object.Hash)
+	if !ok {
+		return object.NewError(node, "argument to `toml.encode` must be HASH, got=%s",
+			args[0].Type())
+	}
+	var buf bytes.Buffer
+	if err := encodeTOMLTable(&buf, hash, nil); err != nil {
+		return object.NewError(node, "toml.encode: %s", err)
+	}
+	return &object.String{Value: strings.TrimPrefix(buf.String(), "\n")}
+}
+
+// tomlTable is a table under construction.  Its values are scalars
+// and arrays as objects, or nested *tomlTable and *tomlArray.
+type tomlTable struct {
+	values map[string]interface{}
+
+	// header is set once the table has had its own [header], and
+	// dotted once it's been created by a dotted key; either way a
+	// later [header] for it is an error.
+	header bool
+	dotted bool
+}
+
+// tomlArray is an array of tables, built up by [[header]]s.
+type tomlArray struct {
+	tables []*tomlTable
+}
+
+func newTOMLTable() *tomlTable {
+	return &tomlTable{values: make(map[string]interface{})}
+}
+
+func (t *tomlTable) toHash() *object.Hash {
+	hash := &object.Hash{}
+	for k, v := range t.values {
+		var val object.ObjectI
+		switch v := v.(type) {
+		case *tomlTable:
+			val = v.toHash()
+		case *tomlArray:
+			elements := make([]object.ObjectI, len(v.tables))
+			for i, table := range v.tables {
+				elements[i] = table.toHash()
+			}
+			val = object.NewArray(elements)
+		case object.ObjectI:
+			val = v
+		}
+		key := &object.String{Value: k}
+		hash = hash.Set(key.HashKey(), object.HashPair{Key: key, Value: val})
+	}
+	return hash
+}
+
+type tomlParser struct {
+	s string
+	i int
+}
+
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	line := strings.Count(p.s[:p.i], "\n") + 1
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func (p *tomlParser) peek() byte {
+	if p.i < len(p.s) {
+		return p.s[p.i]
+	}
+	return 0
+}
+
+// skip moves past spaces, and past newlines and comments too when
+// lines is set.
+func (p *tomlParser) skip(lines bool) {
+	for p.i < len(p.s) {
+		switch p.s[p.i] {
+		case ' ', '\t':
+		case '\r', '\n':
+			if !lines {
+				return
+			}
+		case '#':
+			if !lines {
+				return
+			}
+			for p.i < len(p.s) && p.s[p.i] != '\n' {
+				p.i++
+			}
+			continue
+		default:
+			return
+		}
+		p.i++
+	}
+}
+
+// endOfLine expects nothing but a comment before the next line.
+func (p *tomlParser) endOfLine() error {
+	p.skip(false)
+	if p.peek() == '#' {
+		for p.i < len(p.s) && p.s[p.i] != '\n' {
+			p.i++
+		}
+	}
+	if p.peek() == '\r' {
+		p.i++
+	}
+	if p.i < len(p.s) && p.s[p.i] != '\n' {
+		return p.errorf("expected end of line, got %q", p.rest())
+	}
+	return nil
+}
+
+// rest returns the remainder of the current line, for error messages.
+func (p *tomlParser) rest() string {
+	rest := p.s[p.i:]
+	if n := strings.IndexByte(rest, '\n'); n >= 0 {
+		rest = rest[:n]
+	}
+	return rest
+}
+
+func (p *tomlParser) parse() (*tomlTable, error) {
+	root := newTOMLTable()
+	current := root
+	for {
+		p.skip(true)
+		if p.i >= len(p.s) {
+			return root, nil
+		}
+
+		var err error
+		if p.peek() == '[' {
+			current, err = p.header(root)
+		} else {
+			err = p.keyValue(current)
+		}
+		if err == nil {
+			err = p.endOfLine()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// header parses a [table] or [[array of tables]] header, returning
+// the table which following keys belong to.
+func (p *tomlParser) header(root *tomlTable) (*tomlTable, error) {
+	p.i++
+	array := p.peek() == '['
+	if array {
+		p.i++
+	}
+	p.skip(false)
+	start := p.i
+	path, err := p.key()
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(p.s[start:p.i])
+	p.skip(false)
+	closing := "]"
+	if array {
+		closing = "]]"
+	}
+	if !strings.HasPrefix(p.s[p.i:], closing) {
+		return nil, p.errorf("expected %q after table name", closing)
+	}
+	p.i += len(closing)
+
+	table := root
+	for _, k := range path[:len(path)-1] {
+		switch v := table.values[k].(type) {
+		case nil:
+			next := newTOMLTable()
+			table.values[k] = next
+			table = next
+		case *tomlTable:
+			table = v
+		case *tomlArray:
+			table = v.tables[len(v.tables)-1]
+		default:
+			return nil, p.errorf("key %s is already defined", name)
+		}
+	}
+
+	last := path[len(path)-1]
+	if array {
+		switch v := table.values[last].(type) {
+		case nil:
+			arr := &tomlArray{}
+			table.values[last] = arr
+			next := newTOMLTable()
+			arr.tables = append(arr.tables, next)
+			return next, nil
+		case *tomlArray:
+			next := newTOMLTable()
+			v.tables = append(v.tables, next)
+			return next, nil
+		}
+		return nil, p.errorf("key %s is already defined", name)
+	}
+	switch v := table.values[last].(type) {
+	case nil:
+		next := newTOMLTable()
+		next.header = true
+		table.values[last] = next
+		return next, nil
+	case *tomlTable:
+		if !v.header && !v.dotted {
+			v.header = true
+			return v, nil
+		}
+	}
+	return nil, p.errorf("table %s is already defined", name)
+}
+
+// keyValue parses "key = value" into table.
+func (p *tomlParser) keyValue(table *tomlTable) error {
+	start := p.i
+	path, err := p.key()
+	if err != nil {
+		return err
+	}
+	name := strings.TrimSpace(p.s[start:p.i])
+	p.skip(false)
+	if p.peek() != '=' {
+		return p.errorf("expected '=' after key %s", name)
+	}
+	p.i++
+	p.skip(false)
+	val, err := p.value()
+	if err != nil {
+		return err
+	}
+
+	for _, k := range path[:len(path)-1] {
+		switch v := table.values[k].(type) {
+		case nil:
+			next := newTOMLTable()
+			next.dotted = true
+			table.values[k] = next
+			table = next
+		case *tomlTable:
+			if v.header {
+				return p.errorf("key %s is already defined", name)
+			}
+			table = v
+		default:
+			return p.errorf("key %s is already defined", name)
+		}
+	}
+	last := path[len(path)-1]
+	if _, ok := table.values[last]; ok {
+		return p.errorf("key %s is already defined", name)
+	}
+	table.values[last] = val
+	return nil
+}
+
+// key parses a possibly dotted key.
+func (p *tomlParser) key() ([]string, error) {
+	var path []string
+	for {
+		p.skip(false)
+		var k string
+		switch p.peek() {
+		case '"':
+			s, err := p.basicString()
+			if err != nil {
+				return nil, err
+			}
+			k = s
+		case '\'':
+			s, err := p.literalString()
+			if err != nil {
+				return nil, err
+			}
+			k = s
+		default:
+			start := p.i
+			for p.i < len(p.s) && tomlBare(p.s[p.i]) {
+				p.i++
+			}
+			if p.i == start {
+				return nil, p.errorf("expected a key, got %q", p.rest())
+			}
+			k = p.s[start:p.i]
+		}
+		path = append(path, k)
+		p.skip(false)
+		if p.peek() != '.' {
+			return path, nil
+		}
+		p.i++
+	}
+}
+
+func tomlBare(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '_' || c == '-'
+}
+
+var (
+	tomlInt      = regexp.MustCompile(`^[-+]?(0|[1-9](_?[0-9])*)$`)
+	tomlRadix    = regexp.MustCompile(`^0(x[0-9A-Fa-f](_?[0-9A-Fa-f])*|o[0-7](_?[0-7])*|b[01](_?[01])*)$`)
+	tomlFloat    = regexp.MustCompile(`^[-+]?(0|[1-9](_?[0-9])*)(\.[0-9](_?[0-9])*)?([eE][-+]?[0-9](_?[0-9])*)?$`)
+	tomlDateTime = regexp.MustCompile(`^([0-9]{4}-[0-9]{2}-[0-9]{2}([Tt ][0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]+)?([Zz]|[-+][0-9]{2}:[0-9]{2})?)?|[0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]+)?)$`)
+	tomlDate     = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}$`)
+)
+
+func (p *tomlParser) value() (interface{}, error) {
+	switch p.peek() {
+	case '"':
+		s, err := p.basicString()
+		return &object.String{Value: s}, err
+	case '\'':
+		s, err := p.literalString()
+		return &object.String{Value: s}, err
+	case '[':
+		return p.array()
+	case '{':
+		return p.inlineTable()
+	}
+
+	start := p.i
+	for p.i < len(p.s) && (tomlBare(p.s[p.i]) || strings.IndexByte("+.:", p.s[p.i]) >= 0) {
+		p.i++
+	}
+	// A date and a time may be separated by a space.
+	if tomlDate.MatchString(p.s[start:p.i]) && p.i+1 < len(p.s) && p.s[p.i] == ' ' &&
+		p.s[p.i+1] >= '0' && p.s[p.i+1] <= '9' {
+		p.i++
+		for p.i < len(p.s) && (tomlBare(p.s[p.i]) || strings.IndexByte("+.:", p.s[p.i]) >= 0) {
+			p.i++
+		}
+	}
+	tok := p.s[start:p.i]
+
+	switch tok {
+	case "true":
+		return object.TRUE, nil
+	case "false":
+		return object.FALSE, nil
+	case "inf", "+inf":
+		return &object.Float{Value: math.Inf(1)}, nil
+	case "-inf":
+		return &object.Float{Value: math.Inf(-1)}, nil
+	case "nan", "+nan", "-nan":
+		return &object.Float{Value: math.NaN()}, nil
+	}
+	clean := strings.Replace(tok, "_", "", -1)
+	switch {
+	case tomlInt.MatchString(tok):
+		i, err := strconv.ParseInt(clean, 10, 64)
+		if err != nil {
+			return nil, p.errorf("integer %s out of range", tok)
+		}
+		return &object.Integer{Value: i}, nil
+	case tomlRadix.MatchString(tok):
+		base := map[byte]int{'x': 16, 'o': 8, 'b': 2}[tok[1]]
+		i, err := strconv.ParseInt(clean[2:], base, 64)
+		if err != nil {
+			return nil, p.errorf("integer %s out of range", tok)
+		}
+		return &object.Integer{Value: i}, nil
+	case tomlFloat.MatchString(tok):
+		f, err := strconv.ParseFloat(clean, 64)
+		if err != nil {
+			return nil, p.errorf("invalid float %s", tok)
+		}
+		return &object.Float{Value: f}, nil
+	case tomlDateTime.MatchString(tok):
+		return &object.String{Value: tok}, nil
+	}
+	if tok == "" {
+		return nil, p.errorf("expected a value, got %q", p.rest())
+	}
+	return nil, p.errorf("invalid value %s", tok)
+}
+
+func (p *tomlParser) array() (interface{}, error) {
+	p.i++
+	var elements []object.ObjectI
+	for {
+		p.skip(true)
+		if p.peek() == ']' {
+			p.i++
+			return object.NewArray(elements), nil
+		}
+		val, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, tomlObject(val))
+		p.skip(true)
+		switch p.peek() {
+		case ',':
+			p.i++
+		case ']':
+		default:
+			return nil, p.errorf("expected ',' or ']' in array, got %q", p.rest())
+		}
+	}
+}
+
+func (p *tomlParser) inlineTable() (interface{}, error) {
+	p.i++
+	table := newTOMLTable()
+	p.skip(false)
+	if p.peek() == '}' {
+		p.i++
+		return table.toHash(), nil
+	}
+	for {
+		if err := p.keyValue(table); err != nil {
+			return nil, err
+		}
+		p.skip(false)
+		switch p.peek() {
+		case ',':
+			p.i++
+		case '}':
+			p.i++
+			return table.toHash(), nil
+		default:
+			return nil, p.errorf("expected ',' or '}' in inline table, got %q", p.rest())
+		}
+	}
+}
+
+// tomlObject converts a parsed value, which is only ever an object
+// outside of tables, to an object.
+func tomlObject(v interface{}) object.ObjectI {
+	if t, ok := v.(*tomlTable); ok {
+		return t.toHash()
+	}
+	return v.(object.ObjectI)
+}
+
+func (p *tomlParser) basicString() (string, error) {
+	multi := strings.HasPrefix(p.s[p.i:], `"""`)
+	if multi {
+		p.i += 3
+		p.skipNewline()
+	} else {
+		p.i++
+	}
+
+	var buf bytes.Buffer
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+		switch {
+		case multi && strings.HasPrefix(p.s[p.i:], `"""`):
+			// Up to two quotes may sit just before the closing ones.
+			for strings.HasPrefix(p.s[p.i+1:], `"""`) {
+				buf.WriteByte('"')
+				p.i++
+			}
+			p.i += 3
+			return buf.String(), nil
+		case !multi && c == '"':
+			p.i++
+			return buf.String(), nil
+		case !multi && c == '\n':
+			return "", p.errorf("unterminated string")
+		case c == '\\':
+			p.i++
+			if err := p.escape(&buf, multi); err != nil {
+				return "", err
+			}
+		default:
+			buf.WriteByte(c)
+			p.i++
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *tomlParser) escape(buf *bytes.Buffer, multi bool) error {
+	if p.i >= len(p.s) {
+		return p.errorf("unterminated string")
+	}
+	c := p.s[p.i]
+	p.i++
+	simple := map[byte]string{
+		'b': "\b", 't': "\t", 'n': "\n", 'f': "\f", 'r': "\r", 'e': "\x1b",
+		'"': "\"", '\\': "\\",
+	}
+	if s, ok := simple[c]; ok {
+		buf.WriteString(s)
+		return nil
+	}
+	if multi && (c == ' ' || c == '\t' || c == '\r' || c == '\n') {
+		// A backslash ending a line trims all the whitespace
+		// which follows it.
+		p.i--
+		for p.i < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.i]) >= 0 {
+			p.i++
+		}
+		return nil
+	}
+	size := map[byte]int{'u': 4, 'U': 8}[c]
+	if size == 0 || p.i+size > len(p.s) {
+		return p.errorf("invalid escape \\%c", c)
+	}
+	r, err := strconv.ParseUint(p.s[p.i:p.i+size], 16, 32)
+	if err != nil || !utf8.ValidRune(rune(r)) {
+		return p.errorf("invalid escape \\%c%s", c, p.s[p.i:p.i+size])
+	}
+	p.i += size
+	buf.WriteRune(rune(r))
+	return nil
+}
+
+func (p *tomlParser) literalString() (string, error) {
+	if strings.HasPrefix(p.s[p.i:], "'''") {
+		p.i += 3
+		p.skipNewline()
+		end := strings.Index(p.s[p.i:], "'''")
+		if end < 0 {
+			return "", p.errorf("unterminated string")
+		}
+		for strings.HasPrefix(p.s[p.i+end+1:], "'''") {
+			end++
+		}
+		s := p.s[p.i : p.i+end]
+		p.i += end + 3
+		return s, nil
+	}
+	p.i++
+	end := strings.IndexAny(p.s[p.i:], "'\n")
+	if end < 0 || p.s[p.i+end] != '\'' {
+		return "", p.errorf("unterminated string")
+	}
+	s := p.s[p.i : p.i+end]
+	p.i += end + 1
+	return s, nil
+}
+
+// skipNewline skips the newline straight after the opening quotes of
+// a multi-line string.
+func (p *tomlParser) skipNewline() {
+	if strings.HasPrefix(p.s[p.i:], "\r\n") {
+		p.i += 2
+	} else if p.peek() == '\n' {
+		p.i++
+	}
+}
+
+func encodeTOMLTable(buf *bytes.Buffer, hash *object.Hash, path []string) error {
+	keys := make([]string, 0, hash.Len())
+	values := make(map[string]object.ObjectI, hash.Len())
+	for _, pair := range hash.Pairs() {
+		key, ok := pair.Key.(*object.String)
+		if !ok {
+			return fmt.Errorf("hash key must be STRING, got %s", pair.Key.Type())
+		}
+		keys = append(keys, key.Value)
+		values[key.Value] = pair.Value
+	}
+	sort.Strings(keys)
+
+	// Plain keys have to come before any table headers, since they
+	// would belong to the last table otherwise.
+	var tables, arrays []string
+	for _, key := range keys {
+		switch val := values[key].(type) {
+		case *object.Hash:
+			tables = append(tables, key)
+			continue
+		case *object.Array:
+			if tomlTableArray(val) {
+				arrays = append(arrays, key)
+				continue
+			}
+		}
+		str, err := tomlValue(values[key])
+		if err != nil {
+			return fmt.Errorf("%s: %s", key, err)
+		}
+		fmt.Fprintf(buf, "%s = %s\n", tomlKey(key), str)
+	}
+
+	for _, key := range tables {
+		sub := append(append([]string{}, path...), tomlKey(key))
+		if tomlNeedsHeader(values[key].(*object.Hash)) {
+			fmt.Fprintf(buf, "\n[%s]\n", strings.Join(sub, "."))
+		}
+		if err := encodeTOMLTable(buf, values[key].(*object.Hash), sub); err != nil {
+			return err
+		}
+	}
+	for _, key := range arrays {
+		sub := append(append([]string{}, path...), tomlKey(key))
+		for _, e := range values[key].(*object.Array).Elements() {
+			fmt.Fprintf(buf, "\n[[%s]]\n", strings.Join(sub, "."))
+			if err := encodeTOMLTable(buf, e.(*object.Hash), sub); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// tomlNeedsHeader reports whether a table needs a [header] of its
+// own, rather than being implied by the headers of its subtables.
+func tomlNeedsHeader(hash *object.Hash) bool {
+	if hash.Len() == 0 {
+		return true
+	}
+	for _, pair := range hash.Pairs() {
+		switch val := pair.Value.(type) {
+		case *object.Hash:
+			continue
+		case *object.Array:
+			if tomlTableArray(val) {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// tomlTableArray reports whether arr is written as an array of tables.
+func tomlTableArray(arr *object.Array) bool {
+	if arr.Len() == 0 {
+		return false
+	}
+	for _, e := range arr.Elements() {
+		if _, ok := e.(*object.Hash); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+var tomlBareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func tomlKey(key string) string {
+	if tomlBareKey.MatchString(key) {
+		return key
+	}
+	return quoteString(key)
+}
+
+// tomlValue formats a value which fits on one line.
+func tomlValue(obj object.ObjectI) (string, error) {
+	switch obj := obj.(type) {
+	case *object.Boolean:
+		return strconv.FormatBool(obj.Value), nil
+	case *object.Integer:
+		return strconv.FormatInt(obj.Value, 10), nil
+	case *object.Float:
+		switch {
+		case math.IsNaN(obj.Value):
+			return "nan", nil
+		case math.IsInf(obj.Value, 1):
+			return "inf", nil
+		case math.IsInf(obj.Value, -1):
+			return "-inf", nil
+		}
+		s := strconv.FormatFloat(obj.Value, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		return s, nil
+	case *object.String:
+		return quoteString(obj.Value), nil
+	case *object.Array, *object.Set:
+		elements, _ := object.Collect(obj.(object.IterableI).Iter())
+		parts := make([]string, len(elements))
+		for i, e := range elements {
+			str, err := tomlValue(e)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = str
+		}
+		return "[" + strings.Join(parts, ", ") + "]", nil
+	case *object.Hash:
+		pairs := obj.Pairs()
+		parts := make([]string, len(pairs))
+		for i, pair := range pairs {
+			key, ok := pair.Key.(*object.String)
+			if !ok {
+				return "", fmt.Errorf("hash key must be STRING, got %s", pair.Key.Type())
+			}
+			str, err := tomlValue(pair.Value)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = tomlKey(key.Value) + " = " + str
+		}
+		sort.Strings(parts)
+		if len(parts) == 0 {
+			return "{}", nil
+		}
+		return "{ " + strings.Join(parts, ", ") + " }", nil
+	case *object.Null:
+		return "", fmt.Errorf("TOML has no null")
+	}
+	return "", fmt.Errorf("can't encode %s", obj.Type())
+}
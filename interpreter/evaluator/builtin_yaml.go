@@ -0,0 +1,738 @@
+package evaluator
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kasworld/nonkey/interpreter/asti"
+	"github.com/kasworld/nonkey/interpreter/object"
+)
+
+// yaml.decode( string ) -> value
+//
+// This understands the commonly used subset of YAML: block mappings
+// and sequences, flow collections, plain and quoted scalars, literal
+// and folded block scalars, and comments.  Anchors, aliases, tags and
+// multiple documents are rejected.
+func builtinYAMLDecode(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 1 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	str, ok := args[0].(*object.String)
+	if !ok {
+		return object.NewError(node, "argument to `yaml.decode` must be STRING, got=%s",
+			args[0].Type())
+	}
+	obj, err := decodeYAML(str.Value)
+	if err != nil {
+		return object.NewError(node, "yaml.decode: %s", err)
+	}
+	return obj
+}
+
+// yaml.encode( value ) -> string
+//
+// Hash keys are written sorted, and strings are quoted only where
+// they'd otherwise read back as something else.
+func builtinYAMLEncode(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) != 1 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	var buf bytes.Buffer
+	if err := encodeYAML(&buf, args[0], 0); err != nil {
+		return object.NewError(node, "yaml.encode: %s", err)
+	}
+	return &object.String{Value: buf.String()}
+}
+
+// yamlLine is a line of YAML with its indentation and any comment
+// removed.  Blank and comment-only lines are dropped.
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+type yamlParser struct {
+	raw   []string
+	lines []yamlLine
+	pos   int
+}
+
+func decodeYAML(input string) (object.ObjectI, error) {
+	p := &yamlParser{raw: strings.Split(input, "\n")}
+	for i, raw := range p.raw {
+		raw = strings.TrimSuffix(raw, "\r")
+		p.raw[i] = raw
+		content := strings.TrimLeft(raw, " ")
+		text := strings.TrimRight(yamlStripComment(content), " \t")
+		if text == "" {
+			continue
+		}
+		if strings.HasPrefix(content, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed in indentation", i+1)
+		}
+		indent := len(raw) - len(content)
+		if indent == 0 {
+			if text == "..." {
+				break
+			}
+			if strings.HasPrefix(text, "%") && len(p.lines) == 0 {
+				continue
+			}
+			if text == "---" {
+				if len(p.lines) > 0 {
+					return nil, fmt.Errorf("line %d: multiple documents are not supported", i+1)
+				}
+				continue
+			}
+		}
+		p.lines = append(p.lines, yamlLine{num: i + 1, indent: indent, text: text})
+	}
+	if len(p.lines) == 0 {
+		return object.NULL, nil
+	}
+
+	obj, err := p.parseNode(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: bad indentation", p.lines[p.pos].num)
+	}
+	return obj, nil
+}
+
+// yamlStripComment removes a trailing comment, which starts with a
+// '#' at the start of the line or after whitespace, outside quotes.
+func yamlStripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || strings.IndexByte(" \t[{,:", s[i-1]) >= 0 {
+				quote = c
+			}
+		case c == '#':
+			if i == 0 || s[i-1] == ' ' || s[i-1] == '\t' {
+				return s[:i]
+			}
+		}
+	}
+	return s
+}
+
+func yamlIsItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// yamlSplitKey splits "key: value" into the key and the value text.
+func yamlSplitKey(text string) (object.ObjectI, string, bool) {
+	if text == "" || strings.IndexByte("[{", text[0]) >= 0 {
+		return nil, "", false
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		f := &yamlFlow{s: text}
+		key, err := f.quoted()
+		if err != nil {
+			return nil, "", false
+		}
+		rest := strings.TrimLeft(text[f.i:], " ")
+		if rest == ":" || strings.HasPrefix(rest, ": ") {
+			return key, strings.TrimSpace(rest[1:]), true
+		}
+		return nil, "", false
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			key := strings.TrimRight(text[:i], " ")
+			return yamlResolve(key), strings.TrimSpace(text[i+1:]), true
+		}
+	}
+	return nil, "", false
+}
+
+func (p *yamlParser) parseNode(indent int) (object.ObjectI, error) {
+	l := p.lines[p.pos]
+	if yamlIsItem(l.text) {
+		return p.parseSequence(indent)
+	}
+	if _, _, ok := yamlSplitKey(l.text); ok {
+		return p.parseMapping(indent)
+	}
+	p.pos++
+	return p.parseValue(l.text, l, indent-1)
+}
+
+func (p *yamlParser) parseMapping(indent int) (object.ObjectI, error) {
+	hash := &object.Hash{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent {
+		l := p.lines[p.pos]
+		key, rest, ok := yamlSplitKey(l.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected a mapping key", l.num)
+		}
+		hashKey, ok := key.(object.HashableI)
+		if !ok {
+			return nil, fmt.Errorf("line %d: unusable as hash key: %s", l.num, key.Type())
+		}
+		if _, ok := hash.Get(hashKey.HashKey()); ok {
+			return nil, fmt.Errorf("line %d: duplicate key %s", l.num, key.Inspect())
+		}
+		p.pos++
+
+		var val object.ObjectI = object.NULL
+		var err error
+		switch {
+		case rest != "":
+			val, err = p.parseValue(rest, l, indent)
+		case p.pos < len(p.lines) && p.lines[p.pos].indent > indent:
+			val, err = p.parseNode(p.lines[p.pos].indent)
+		case p.pos < len(p.lines) && p.lines[p.pos].indent == indent && yamlIsItem(p.lines[p.pos].text):
+			val, err = p.parseSequence(indent)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+			return nil, fmt.Errorf("line %d: bad indentation", p.lines[p.pos].num)
+		}
+		hash = hash.Set(hashKey.HashKey(), object.HashPair{Key: key, Value: val})
+	}
+	return hash, nil
+}
+
+func (p *yamlParser) parseSequence(indent int) (object.ObjectI, error) {
+	var elements []object.ObjectI
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && yamlIsItem(p.lines[p.pos].text) {
+		l := p.lines[p.pos]
+		rest := strings.TrimLeft(l.text[1:], " ")
+
+		var val object.ObjectI = object.NULL
+		var err error
+		if rest == "" {
+			p.pos++
+			if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+				val, err = p.parseNode(p.lines[p.pos].indent)
+			}
+		} else {
+			// Treat the rest of the line as though it started
+			// on a line of its own, so "- a: 1" can carry on
+			// with more keys below.
+			offset := len(l.text) - len(rest)
+			p.lines[p.pos].indent += offset
+			p.lines[p.pos].text = rest
+			val, err = p.parseNode(indent + offset)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+			return nil, fmt.Errorf("line %d: bad indentation", p.lines[p.pos].num)
+		}
+		elements = append(elements, val)
+	}
+	return object.NewArray(elements), nil
+}
+
+// parseValue parses the value text found on line l, which may carry
+// on over following lines indented deeper than parent.
+func (p *yamlParser) parseValue(text string, l yamlLine, parent int) (object.ObjectI, error) {
+	switch text[0] {
+	case '|', '>':
+		return p.parseBlockScalar(text, l, parent)
+	case '&', '*', '!':
+		return nil, fmt.Errorf("line %d: anchors, aliases and tags are not supported", l.num)
+	case '[', '{':
+		for !yamlFlowClosed(text) && p.pos < len(p.lines) && p.lines[p.pos].indent > parent {
+			text += " " + p.lines[p.pos].text
+			p.pos++
+		}
+		fallthrough
+	case '"', '\'':
+		f := &yamlFlow{s: text, line: l.num, flow: text[0] == '[' || text[0] == '{'}
+		return f.parse()
+	}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent > parent {
+		next := p.lines[p.pos].text
+		if _, _, ok := yamlSplitKey(next); ok || yamlIsItem(next) {
+			break
+		}
+		text += " " + next
+		p.pos++
+	}
+	return yamlResolve(text), nil
+}
+
+func (p *yamlParser) parseBlockScalar(text string, l yamlLine, parent int) (object.ObjectI, error) {
+	chomp := byte(0)
+	if len(text) > 1 {
+		chomp = text[1]
+		if len(text) > 2 || (chomp != '-' && chomp != '+') {
+			return nil, fmt.Errorf("line %d: unsupported block scalar header %q", l.num, text)
+		}
+	}
+
+	// Find the indentation of the content from its first line, then
+	// take lines up to the first one indented less.
+	indent := -1
+	last := l.num
+	var lines []string
+	for i := l.num; i < len(p.raw); i++ {
+		raw := p.raw[i]
+		if strings.TrimSpace(raw) == "" {
+			lines = append(lines, "")
+			continue
+		}
+		n := len(raw) - len(strings.TrimLeft(raw, " "))
+		if indent < 0 {
+			indent = n
+		}
+		if n < indent || n <= parent {
+			break
+		}
+		lines = append(lines, raw[indent:])
+		last = i + 1
+	}
+	lines = lines[:last-l.num]
+	for p.pos < len(p.lines) && p.lines[p.pos].num <= last {
+		p.pos++
+	}
+
+	var body string
+	if text[0] == '|' {
+		body = strings.Join(lines, "\n")
+	} else {
+		var buf bytes.Buffer
+		for i, line := range lines {
+			switch {
+			case i == 0:
+			case line == "" || lines[i-1] == "":
+				if line == "" {
+					buf.WriteByte('\n')
+				}
+			case strings.HasPrefix(line, " ") || strings.HasPrefix(lines[i-1], " "):
+				buf.WriteByte('\n')
+			default:
+				buf.WriteByte(' ')
+			}
+			buf.WriteString(line)
+		}
+		body = buf.String()
+	}
+
+	switch chomp {
+	case '-':
+		body = strings.TrimRight(body, "\n")
+	case '+':
+		body += "\n"
+	default:
+		if body = strings.TrimRight(body, "\n"); body != "" {
+			body += "\n"
+		}
+	}
+	return &object.String{Value: body}, nil
+}
+
+// yamlFlowClosed reports whether every bracket opened in the flow
+// collection text has been closed.
+func yamlFlowClosed(text string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+var (
+	yamlInt   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlFloat = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+)
+
+// yamlResolve gives a plain scalar its type.
+func yamlResolve(s string) object.ObjectI {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return object.NULL
+	case "true", "True", "TRUE":
+		return object.TRUE
+	case "false", "False", "FALSE":
+		return object.FALSE
+	case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF":
+		return &object.Float{Value: math.Inf(1)}
+	case "-.inf", "-.Inf", "-.INF":
+		return &object.Float{Value: math.Inf(-1)}
+	case ".nan", ".NaN", ".NAN":
+		return &object.Float{Value: math.NaN()}
+	}
+	if yamlInt.MatchString(s) {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return &object.Integer{Value: i}
+		}
+	}
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0o") {
+		base := 16
+		if s[1] == 'o' {
+			base = 8
+		}
+		if i, err := strconv.ParseInt(s[2:], base, 64); err == nil {
+			return &object.Integer{Value: i}
+		}
+	}
+	if yamlFloat.MatchString(s) {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return &object.Float{Value: f}
+		}
+	}
+	return &object.String{Value: s}
+}
+
+// yamlFlow parses flow collections and quoted scalars, which may all
+// be nested inside each other.
+type yamlFlow struct {
+	s    string
+	i    int
+	line int
+
+	// flow is set inside a collection, where ",[]{}" end a plain
+	// scalar.
+	flow bool
+}
+
+func (f *yamlFlow) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", f.line, fmt.Sprintf(format, args...))
+}
+
+func (f *yamlFlow) skip() {
+	for f.i < len(f.s) && f.s[f.i] == ' ' {
+		f.i++
+	}
+}
+
+func (f *yamlFlow) parse() (object.ObjectI, error) {
+	val, err := f.value(false)
+	if err != nil {
+		return nil, err
+	}
+	f.skip()
+	if f.i < len(f.s) {
+		return nil, f.errorf("unexpected %q", f.s[f.i:])
+	}
+	return val, nil
+}
+
+func (f *yamlFlow) value(key bool) (object.ObjectI, error) {
+	f.skip()
+	if f.i >= len(f.s) {
+		return nil, f.errorf("unexpected end of flow collection")
+	}
+	switch f.s[f.i] {
+	case '[':
+		return f.sequence()
+	case '{':
+		return f.mapping()
+	case '"', '\'':
+		return f.quoted()
+	}
+
+	start := f.i
+	for ; f.i < len(f.s); f.i++ {
+		c := f.s[f.i]
+		if f.flow && strings.IndexByte(",[]{}", c) >= 0 {
+			break
+		}
+		if key && c == ':' && (f.i+1 == len(f.s) || strings.IndexByte(" ,]}", f.s[f.i+1]) >= 0) {
+			break
+		}
+	}
+	return yamlResolve(strings.TrimRight(f.s[start:f.i], " ")), nil
+}
+
+func (f *yamlFlow) sequence() (object.ObjectI, error) {
+	f.i++
+	var elements []object.ObjectI
+	for {
+		f.skip()
+		if f.i < len(f.s) && f.s[f.i] == ']' {
+			f.i++
+			return object.NewArray(elements), nil
+		}
+		val, err := f.value(false)
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, val)
+		if err := f.next(']'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (f *yamlFlow) mapping() (object.ObjectI, error) {
+	f.i++
+	hash := &object.Hash{}
+	for {
+		f.skip()
+		if f.i < len(f.s) && f.s[f.i] == '}' {
+			f.i++
+			return hash, nil
+		}
+		key, err := f.value(true)
+		if err != nil {
+			return nil, err
+		}
+		hashKey, ok := key.(object.HashableI)
+		if !ok {
+			return nil, f.errorf("unusable as hash key: %s", key.Type())
+		}
+		f.skip()
+		var val object.ObjectI = object.NULL
+		if f.i < len(f.s) && f.s[f.i] == ':' {
+			f.i++
+			if val, err = f.value(false); err != nil {
+				return nil, err
+			}
+		}
+		hash = hash.Set(hashKey.HashKey(), object.HashPair{Key: key, Value: val})
+		if err := f.next('}'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// next moves past the comma between two entries, leaving a closing
+// bracket for the caller.
+func (f *yamlFlow) next(end byte) error {
+	f.skip()
+	if f.i >= len(f.s) {
+		return f.errorf("missing %q", end)
+	}
+	switch f.s[f.i] {
+	case ',':
+		f.i++
+		return nil
+	case end:
+		return nil
+	}
+	return f.errorf("expected ',' or %q, got %q", end, f.s[f.i:])
+}
+
+func (f *yamlFlow) quoted() (object.ObjectI, error) {
+	quote := f.s[f.i]
+	f.i++
+	var buf bytes.Buffer
+	for f.i < len(f.s) {
+		c := f.s[f.i]
+		f.i++
+		switch {
+		case c == quote && quote == '\'' && f.i < len(f.s) && f.s[f.i] == '\'':
+			buf.WriteByte('\'')
+			f.i++
+		case c == quote:
+			return &object.String{Value: buf.String()}, nil
+		case c == '\\' && quote == '"':
+			if err := f.escape(&buf); err != nil {
+				return nil, err
+			}
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return nil, f.errorf("unterminated string")
+}
+
+func (f *yamlFlow) escape(buf *bytes.Buffer) error {
+	if f.i >= len(f.s) {
+		return f.errorf("unterminated string")
+	}
+	c := f.s[f.i]
+	f.i++
+	simple := map[byte]string{
+		'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", 'n': "\n", 'v': "\v",
+		'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"", '/': "/",
+		'\\': "\\", 'N': "\u0085", '_': "\u00a0",
+	}
+	if s, ok := simple[c]; ok {
+		buf.WriteString(s)
+		return nil
+	}
+	size := map[byte]int{'x': 2, 'u': 4, 'U': 8}[c]
+	if size == 0 || f.i+size > len(f.s) {
+		return f.errorf("invalid escape \\%c", c)
+	}
+	r, err := strconv.ParseUint(f.s[f.i:f.i+size], 16, 32)
+	if err != nil || !utf8.ValidRune(rune(r)) {
+		return f.errorf("invalid escape \\%c%s", c, f.s[f.i:f.i+size])
+	}
+	f.i += size
+	buf.WriteRune(rune(r))
+	return nil
+}
+
+func encodeYAML(buf *bytes.Buffer, obj object.ObjectI, indent int) error {
+	pad := strings.Repeat(" ", indent)
+	switch obj := obj.(type) {
+	case *object.Hash:
+		if obj.Len() == 0 {
+			break
+		}
+		keys := make([]string, 0, obj.Len())
+		values := make(map[string]object.ObjectI, obj.Len())
+		for _, pair := range obj.Pairs() {
+			key, err := yamlScalar(pair.Key)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, key)
+			values[key] = pair.Value
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if yamlNested(values[key]) {
+				fmt.Fprintf(buf, "%s%s:\n", pad, key)
+				if err := encodeYAML(buf, values[key], indent+2); err != nil {
+					return err
+				}
+				continue
+			}
+			val, err := yamlScalar(values[key])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(buf, "%s%s: %s\n", pad, key, val)
+		}
+		return nil
+	case *object.Array, *object.Set:
+		elements, _ := object.Collect(obj.(object.IterableI).Iter())
+		if len(elements) == 0 {
+			break
+		}
+		for _, e := range elements {
+			if yamlNested(e) {
+				// Write the element one level deeper, then put
+				// the dash in the indentation of its first line.
+				var child bytes.Buffer
+				if err := encodeYAML(&child, e, indent+2); err != nil {
+					return err
+				}
+				buf.WriteString(pad + "- ")
+				buf.Write(child.Bytes()[indent+2:])
+				continue
+			}
+			val, err := yamlScalar(e)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(buf, "%s- %s\n", pad, val)
+		}
+		return nil
+	}
+	val, err := yamlScalar(obj)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(buf, "%s%s\n", pad, val)
+	return nil
+}
+
+// yamlNested reports whether obj is written as a block of its own.
+func yamlNested(obj object.ObjectI) bool {
+	switch obj := obj.(type) {
+	case *object.Hash:
+		return obj.Len() > 0
+	case *object.Array:
+		return obj.Len() > 0
+	case *object.Set:
+		return obj.Len() > 0
+	}
+	return false
+}
+
+func yamlScalar(obj object.ObjectI) (string, error) {
+	switch obj := obj.(type) {
+	case *object.Null:
+		return "null", nil
+	case *object.Boolean:
+		return strconv.FormatBool(obj.Value), nil
+	case *object.Integer:
+		return strconv.FormatInt(obj.Value, 10), nil
+	case *object.Float:
+		switch {
+		case math.IsNaN(obj.Value):
+			return ".nan", nil
+		case math.IsInf(obj.Value, 1):
+			return ".inf", nil
+		case math.IsInf(obj.Value, -1):
+			return "-.inf", nil
+		}
+		s := strconv.FormatFloat(obj.Value, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		return s, nil
+	case *object.String:
+		if yamlPlain(obj.Value) {
+			return obj.Value, nil
+		}
+		return quoteString(obj.Value), nil
+	case *object.Hash, *object.Array, *object.Set:
+		if yamlNested(obj) {
+			return "", fmt.Errorf("can't encode %s as a scalar", obj.Type())
+		}
+		if _, ok := obj.(*object.Hash); ok {
+			return "{}", nil
+		}
+		return "[]", nil
+	}
+	return "", fmt.Errorf("can't encode %s", obj.Type())
+}
+
+// yamlPlain reports whether s can be written without quotes and still
+// read back as the same string.
+func yamlPlain(s string) bool {
+	if _, ok := yamlResolve(s).(*object.String); !ok {
+		return false
+	}
+	if s != strings.TrimSpace(s) || strings.IndexByte("-?:,[]{}#&*!|>'\"%@`", s[0]) >= 0 {
+		return false
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return false
+	}
+	for _, r := range s {
+		if r < ' ' || r == 0x7f {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package evaluator
+
+import (
+	"reflect"
+
+	"github.com/kasworld/nonkey/interpreter/ast"
+	"github.com/kasworld/nonkey/interpreter/asti"
+	"github.com/kasworld/nonkey/interpreter/object"
+)
+
+// evalSpawnExpression starts the call in a goroutine, with its own
+// child environment, and returns a channel which receives the result
+// of the call and is then closed.
+//
+// The function and its arguments are evaluated before the goroutine
+// starts, so they see the caller's variables as they are now.
+func evalSpawnExpression(node *ast.SpawnExpression, env *object.Environment) object.ObjectI {
+	child := object.NewEnclosedEnvironment(env)
+
+	var run func() object.ObjectI
+	switch call := node.Call.(type) {
+	case *ast.CallExpression:
+		function := Eval(call.Function, env)
+		if object.IsError(function) {
+			return function
+		}
+		args := evalExpression(call.Arguments, env)
+		if len(args) == 1 && object.IsError(args[0]) {
+			return args[0]
+		}
+		run = func() object.ObjectI {
+			return applyFunction(call, child, function, args)
+		}
+	default:
+		run = func() object.ObjectI {
+			return Eval(call, child)
+		}
+	}
+
+	result := object.NewChannel(1)
+	spawned := env.Spawned()
+	spawned.Add(1)
+	go func() {
+		defer spawned.Done()
+		result.Send(run())
+		result.Close()
+	}()
+	return result
+}
+
+// evalSelectExpression waits until one of the channels has a value,
+// or is closed, and runs that case with the value bound to its
+// variable; null is bound for a closed channel.  With a default case
+// it doesn't wait.
+func evalSelectExpression(node *ast.SelectExpression, env *object.Environment) object.ObjectI {
+	if len(node.Cases) == 0 {
+		return object.NewError(node, "select has no cases")
+	}
+	cases := make([]reflect.SelectCase, len(node.Cases))
+	for i, c := range node.Cases {
+		if c.Default {
+			cases[i] = reflect.SelectCase{Dir: reflect.SelectDefault}
+			continue
+		}
+		val := Eval(c.Channel, env)
+		if object.IsError(val) {
+			return val
+		}
+		ch, ok := val.(*object.Channel)
+		if !ok {
+			return object.NewError(c, "select case must be CHANNEL, got %s", val.Type())
+		}
+		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch.Value)}
+	}
+
+	chosen, recv, ok := reflect.Select(cases)
+	c := node.Cases[chosen]
+	if c.Ident == "" {
+		return Eval(c.Block, env)
+	}
+	var val object.ObjectI = object.NULL
+	if ok {
+		val = recv.Interface().(object.ObjectI)
+	}
+	child := object.NewTemporaryScope(env, []string{c.Ident})
+	child.Set(c.Ident, val)
+	return Eval(c.Block, child)
+}
+
+// chan creates a channel, buffering the given number of values.
+func builtinChan(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) > 1 {
+		return object.NewError(node, "wrong number of arguments. got=%d, want=0|1",
+			len(args))
+	}
+	size := 0
+	if len(args) == 1 {
+		n, ok := args[0].(*object.Integer)
+		if !ok || n.Value < 0 {
+			return object.NewError(node, "argument to `chan` must be a non-negative INTEGER, got=%s",
+				args[0].Inspect())
+		}
+		size = int(n.Value)
+	}
+	return object.NewChannel(size)
+}
+
+// wait blocks until every function the program spawned has finished.
+// Given the channels returned by spawn, it instead waits for just
+// those and returns their results.
+func builtinWait(node asti.NodeI, env *object.Environment, args ...object.ObjectI) object.ObjectI {
+	if len(args) == 0 {
+		env.Spawned().Wait()
+		return object.NULL
+	}
+	results := make([]object.ObjectI, len(args))
+	for i, arg := range args {
+		ch, ok := arg.(*object.Channel)
+		if !ok {
+			return object.NewError(node, "argument to `wait` must be CHANNEL, got=%s",
+				arg.Type())
+		}
+		results[i], _ = ch.Recv()
+	}
+	return object.NewArray(results)
+}
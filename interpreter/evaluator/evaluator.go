@@ -3,13 +3,13 @@
 package evaluator
 
 import (
-	"bytes"
 	"fmt"
 	"math"
 	"os"
-	"os/exec"
 	"regexp"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/kasworld/nonkey/config/builtinfunctions"
 	"github.com/kasworld/nonkey/config/pragmas"
@@ -56,8 +56,8 @@ func Eval(node asti.NodeI, env *object.Environment) object.ObjectI {
 		}
 		res := evalInfixExpression(node, node.Operator, left, right, env)
 		if object.IsError(res) {
-			fmt.Fprintf(os.Stderr, "%s\n", res.Inspect())
-			if pragmas.PRAGMAS["strict"] == 1 {
+			fmt.Fprintf(env.ErrorLog(), "%s\n", res.Inspect())
+			if pragmas.Enabled("strict") {
 				os.Exit(1)
 			}
 		}
@@ -79,11 +79,14 @@ func Eval(node asti.NodeI, env *object.Environment) object.ObjectI {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
+	case *ast.YieldStatement:
+		return evalYieldStatement(node, env)
 	case *ast.LetStatement:
 		val := Eval(node.Value, env)
 		if object.IsError(val) {
 			return val
 		}
+		nameFunction(node.Value, val, node.Name.Value)
 		env.Set(node.Name.Value, val)
 		return val
 	case *ast.ConstStatement:
@@ -91,6 +94,7 @@ func Eval(node asti.NodeI, env *object.Environment) object.ObjectI {
 		if object.IsError(val) {
 			return val
 		}
+		nameFunction(node.Value, val, node.Name.Value)
 		env.SetConst(node.Name.Value, val)
 		return val
 	case *ast.Identifier:
@@ -99,19 +103,19 @@ func Eval(node asti.NodeI, env *object.Environment) object.ObjectI {
 		params := node.Parameters
 		body := node.Body
 		defaults := node.Defaults
-		return &object.Function{Parameters: params, Env: env, Body: body, Defaults: defaults}
+		return &object.Function{Parameters: params, Env: env, Body: body, Defaults: defaults, Generator: node.Generator}
 	case *ast.FunctionDefineLiteral:
 		params := node.Parameters
 		body := node.Body
 		defaults := node.Defaults
-		env.Set(node.GetToken().Literal, &object.Function{Parameters: params, Env: env, Body: body, Defaults: defaults})
+		env.Set(node.GetToken().Literal, &object.Function{Name: node.GetToken().Literal, Parameters: params, Env: env, Body: body, Defaults: defaults, Generator: node.Generator})
 		return object.NULL
 	case *ast.ObjectCallExpression:
 		res := evalObjectCallExpression(node, env)
 		if object.IsError(res) {
-			fmt.Fprintf(os.Stderr, "%s\n",
+			fmt.Fprintf(env.ErrorLog(), "%s\n",
 				res.Inspect())
-			if pragmas.PRAGMAS["strict"] == 1 {
+			if pragmas.Enabled("strict") {
 				os.Exit(1)
 			}
 		}
@@ -127,8 +131,8 @@ func Eval(node asti.NodeI, env *object.Environment) object.ObjectI {
 		}
 		res := applyFunction(node, env, function, args)
 		if object.IsError(res) {
-			fmt.Fprintf(os.Stderr, "%v %v\n", res.Inspect(), node.Function)
-			if pragmas.PRAGMAS["strict"] == 1 {
+			fmt.Fprintf(env.ErrorLog(), "%v %v\n", res.Inspect(), node.Function)
+			if pragmas.Enabled("strict") {
 				os.Exit(1)
 			}
 			return res
@@ -140,13 +144,13 @@ func Eval(node asti.NodeI, env *object.Environment) object.ObjectI {
 		if len(elements) == 1 && object.IsError(elements[0]) {
 			return elements[0]
 		}
-		return &object.Array{Elements: elements}
+		return object.NewArray(elements)
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 	case *ast.RegexpLiteral:
 		return &object.Regexp{Value: node.Value, Flags: node.Flags}
 	case *ast.BacktickLiteral:
-		return backTickOperation(node.Value)
+		return backTickOperation(node)
 	case *ast.IndexExpression:
 		left := Eval(node.Left, env)
 		if object.IsError(left) {
@@ -157,12 +161,20 @@ func Eval(node asti.NodeI, env *object.Environment) object.ObjectI {
 			return index
 		}
 		return evalIndexExpression(node, left, index)
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
 	case *ast.AssignStatement:
 		return evalAssignStatement(node, env)
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, env)
+	case *ast.SetLiteral:
+		return evalSetLiteral(node, env)
 	case *ast.SwitchExpression:
 		return evalSwitchStatement(node, env)
+	case *ast.SpawnExpression:
+		return evalSpawnExpression(node, env)
+	case *ast.SelectExpression:
+		return evalSelectExpression(node, env)
 	}
 	return nil
 }
@@ -170,7 +182,11 @@ func Eval(node asti.NodeI, env *object.Environment) object.ObjectI {
 // eval block statement
 func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.ObjectI {
 	var result object.ObjectI
+	trace := env.Trace()
 	for _, statement := range block.Statements {
+		if trace != nil {
+			trace(statement, env)
+		}
 		result = Eval(statement, env)
 		if result != nil {
 			rt := result.Type()
@@ -259,6 +275,8 @@ func evalMinusPrefixOperatorExpression(node asti.NodeI, right object.ObjectI) ob
 		return &object.Integer{Value: -obj.Value}
 	case *object.Float:
 		return &object.Float{Value: -obj.Value}
+	case *object.Duration:
+		return &object.Duration{Value: -obj.Value}
 	default:
 		return object.NewError(node, "unknown operator: -%s", right.Type())
 	}
@@ -266,6 +284,8 @@ func evalMinusPrefixOperatorExpression(node asti.NodeI, right object.ObjectI) ob
 
 func evalInfixExpression(node asti.NodeI, operator tokentype.TokenType, left, right object.ObjectI, env *object.Environment) object.ObjectI {
 	switch {
+	case operator == tokentype.DOTDOT:
+		return evalRangeExpression(node, left, right)
 	case left.Type() == objecttype.INTEGER && right.Type() == objecttype.INTEGER:
 		return evalIntegerInfixExpression(node, operator, left, right)
 	case left.Type() == objecttype.FLOAT && right.Type() == objecttype.FLOAT:
@@ -284,12 +304,23 @@ func evalInfixExpression(node asti.NodeI, operator tokentype.TokenType, left, ri
 		return notMatches(node, left, right)
 	case operator == tokentype.CONTAINS:
 		return matches(node, left, right, env)
+	case left.Type() == objecttype.ARRAY && right.Type() == objecttype.ARRAY:
+		return evalArrayInfixExpression(node, operator, left, right)
+	case left.Type() == objecttype.SET && right.Type() == objecttype.SET:
+		return evalSetInfixExpression(node, operator, left, right)
+	case left.Type() == objecttype.BYTES && right.Type() == objecttype.BYTES && operator == tokentype.PLUS:
+		l, r := left.(*object.Bytes).Value, right.(*object.Bytes).Value
+		data := make([]byte, 0, len(l)+len(r))
+		return &object.Bytes{Value: append(append(data, l...), r...)}
+	case left.Type() == objecttype.TIME || left.Type() == objecttype.DURATION ||
+		right.Type() == objecttype.TIME || right.Type() == objecttype.DURATION:
+		return evalTimeInfixExpression(node, operator, left, right)
 
 	case operator == tokentype.EQ:
-		return nativeBoolToBooleanObject(left == right)
+		return nativeBoolToBooleanObject(object.Equals(left, right))
 
 	case operator == tokentype.NOT_EQ:
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(!object.Equals(left, right))
 	case left.Type() == objecttype.BOOLEAN && right.Type() == objecttype.BOOLEAN:
 		return evalBooleanInfixExpression(node, operator, left, right)
 	case left.Type() != right.Type():
@@ -424,16 +455,6 @@ func evalIntegerInfixExpression(node asti.NodeI, operator tokentype.TokenType, l
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case tokentype.NOT_EQ:
 		return nativeBoolToBooleanObject(leftVal != rightVal)
-	case tokentype.DOTDOT:
-		len := int(rightVal-leftVal) + 1
-		array := make([]object.ObjectI, len)
-		i := 0
-		for i < len {
-			array[i] = &object.Integer{Value: leftVal}
-			leftVal++
-			i++
-		}
-		return &object.Array{Elements: array}
 	default:
 		return object.NewError(node, "unknown operator: %s %s %s",
 			left.Type(), operator.Literal(), right.Type())
@@ -586,6 +607,177 @@ func evalStringInfixExpression(node asti.NodeI, operator tokentype.TokenType, le
 		left.Type(), operator.Literal(), right.Type())
 }
 
+// array operations, compare element by element.
+func evalArrayInfixExpression(node asti.NodeI, operator tokentype.TokenType, left, right object.ObjectI) object.ObjectI {
+	switch operator {
+	case tokentype.EQ:
+		return nativeBoolToBooleanObject(object.Equals(left, right))
+	case tokentype.NOT_EQ:
+		return nativeBoolToBooleanObject(!object.Equals(left, right))
+	case tokentype.LT, tokentype.LT_EQUALS, tokentype.GT, tokentype.GT_EQUALS:
+		c, ok := object.Compare(left, right)
+		if !ok {
+			return object.NewError(node, "can't compare %s and %s",
+				left.Inspect(), right.Inspect())
+		}
+		switch operator {
+		case tokentype.LT:
+			return nativeBoolToBooleanObject(c < 0)
+		case tokentype.LT_EQUALS:
+			return nativeBoolToBooleanObject(c <= 0)
+		case tokentype.GT:
+			return nativeBoolToBooleanObject(c > 0)
+		default:
+			return nativeBoolToBooleanObject(c >= 0)
+		}
+	}
+	return object.NewError(node, "unknown operator: %s %s %s",
+		left.Type(), operator.Literal(), right.Type())
+}
+
+// evalSetInfixExpression handles set algebra: `|` is the union, `&`
+// the intersection and `-` the difference, while the comparisons test
+// for subsets and supersets.
+func evalSetInfixExpression(node asti.NodeI, operator tokentype.TokenType, left, right object.ObjectI) object.ObjectI {
+	l := left.(*object.Set)
+	r := right.(*object.Set)
+	switch operator {
+	case tokentype.PIPE:
+		return l.Union(r)
+	case tokentype.AMPERSAND:
+		return l.Intersection(r)
+	case tokentype.MINUS:
+		return l.Difference(r)
+	case tokentype.EQ:
+		return nativeBoolToBooleanObject(object.Equals(l, r))
+	case tokentype.NOT_EQ:
+		return nativeBoolToBooleanObject(!object.Equals(l, r))
+	case tokentype.LT_EQUALS:
+		return nativeBoolToBooleanObject(l.Difference(r).Len() == 0)
+	case tokentype.LT:
+		return nativeBoolToBooleanObject(l.Len() < r.Len() && l.Difference(r).Len() == 0)
+	case tokentype.GT_EQUALS:
+		return nativeBoolToBooleanObject(r.Difference(l).Len() == 0)
+	case tokentype.GT:
+		return nativeBoolToBooleanObject(l.Len() > r.Len() && r.Difference(l).Len() == 0)
+	}
+	return object.NewError(node, "unknown operator: %s %s %s",
+		left.Type(), operator.Literal(), right.Type())
+}
+
+// evalTimeInfixExpression handles arithmetic and comparisons where
+// either side is a time or a duration.
+//
+// Durations may be added to or subtracted from times, and each other,
+// and scaled by numbers.  Subtracting one time from another gives the
+// duration between them.
+func evalTimeInfixExpression(node asti.NodeI, operator tokentype.TokenType, left, right object.ObjectI) object.ObjectI {
+	// `t += d` and friends work as their plain operators do.
+	switch operator {
+	case tokentype.PLUS_EQUALS:
+		operator = tokentype.PLUS
+	case tokentype.MINUS_EQUALS:
+		operator = tokentype.MINUS
+	case tokentype.ASTERISK_EQUALS:
+		operator = tokentype.ASTERISK
+	case tokentype.SLASH_EQUALS:
+		operator = tokentype.SLASH
+	}
+
+	switch operator {
+	case tokentype.EQ:
+		return nativeBoolToBooleanObject(object.Equals(left, right))
+	case tokentype.NOT_EQ:
+		return nativeBoolToBooleanObject(!object.Equals(left, right))
+	case tokentype.LT, tokentype.LT_EQUALS, tokentype.GT, tokentype.GT_EQUALS:
+		c, ok := object.Compare(left, right)
+		if !ok {
+			break
+		}
+		switch operator {
+		case tokentype.LT:
+			return nativeBoolToBooleanObject(c < 0)
+		case tokentype.LT_EQUALS:
+			return nativeBoolToBooleanObject(c <= 0)
+		case tokentype.GT:
+			return nativeBoolToBooleanObject(c > 0)
+		}
+		return nativeBoolToBooleanObject(c >= 0)
+	}
+
+	// scale reports a number as a float, for multiplying durations.
+	scale := func(obj object.ObjectI) (float64, bool) {
+		switch obj := obj.(type) {
+		case *object.Integer:
+			return float64(obj.Value), true
+		case *object.Float:
+			return obj.Value, true
+		}
+		return 0, false
+	}
+
+	switch l := left.(type) {
+	case *object.Time:
+		switch r := right.(type) {
+		case *object.Duration:
+			switch operator {
+			case tokentype.PLUS:
+				return &object.Time{Value: l.Value.Add(r.Value)}
+			case tokentype.MINUS:
+				return &object.Time{Value: l.Value.Add(-r.Value)}
+			}
+		case *object.Time:
+			if operator == tokentype.MINUS {
+				return &object.Duration{Value: l.Value.Sub(r.Value)}
+			}
+		}
+	case *object.Duration:
+		switch r := right.(type) {
+		case *object.Time:
+			if operator == tokentype.PLUS {
+				return &object.Time{Value: r.Value.Add(l.Value)}
+			}
+		case *object.Duration:
+			switch operator {
+			case tokentype.PLUS:
+				return &object.Duration{Value: l.Value + r.Value}
+			case tokentype.MINUS:
+				return &object.Duration{Value: l.Value - r.Value}
+			case tokentype.SLASH:
+				if r.Value == 0 {
+					return object.NewError(node, "division by zero")
+				}
+				return &object.Float{Value: float64(l.Value) / float64(r.Value)}
+			}
+		default:
+			n, ok := scale(right)
+			if !ok {
+				break
+			}
+			switch operator {
+			case tokentype.ASTERISK:
+				return &object.Duration{Value: time.Duration(float64(l.Value) * n)}
+			case tokentype.SLASH:
+				if n == 0 {
+					return object.NewError(node, "division by zero")
+				}
+				return &object.Duration{Value: time.Duration(float64(l.Value) / n)}
+			}
+		}
+	default:
+		r, isDuration := right.(*object.Duration)
+		if n, ok := scale(left); ok && isDuration && operator == tokentype.ASTERISK {
+			return &object.Duration{Value: time.Duration(n * float64(r.Value))}
+		}
+	}
+	if left.Type() != right.Type() {
+		return object.NewError(node, "type mismatch: %s %s %s",
+			left.Type(), operator.Literal(), right.Type())
+	}
+	return object.NewError(node, "unknown operator: %s %s %s",
+		left.Type(), operator.Literal(), right.Type())
+}
+
 // evalIfExpression handles an `if` expression, running the block
 // if the condition matches, and running any optional else block
 // otherwise.
@@ -605,14 +797,24 @@ func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Obje
 		return condition
 	}
 	if isTruthy(condition) {
+		traceBranch(ie.Consequence, nEnv)
 		return Eval(ie.Consequence, nEnv)
 	} else if ie.Alternative != nil {
+		traceBranch(ie.Alternative, nEnv)
 		return Eval(ie.Alternative, nEnv)
 	} else {
+		traceBranch(ie, nEnv)
 		return object.NULL
 	}
 }
 
+// traceBranch tells any branch trace of env that a branch was taken.
+func traceBranch(branch asti.NodeI, env *object.Environment) {
+	if trace := env.BranchTrace(); trace != nil {
+		trace(branch, env)
+	}
+}
+
 // evalTernaryExpression handles a ternary-expression.  If the condition
 // is true we return the contents of evaluating the true-branch, otherwise
 // the false-branch.  (Unlike an `if` statement we know that we always have
@@ -636,6 +838,11 @@ func evalAssignStatement(a *ast.AssignStatement, env *object.Environment) (val o
 		return evaluated
 	}
 
+	// "x[i] = y", "x.name = y", and friends.
+	if a.Target != nil {
+		return evalIndexAssign(a, evaluated, env)
+	}
+
 	//
 	// An assignment is generally:
 	//
@@ -657,7 +864,7 @@ func evalAssignStatement(a *ast.AssignStatement, env *object.Environment) (val o
 
 		res := evalInfixExpression(a, tokentype.PLUS_EQUALS, current, evaluated, env)
 		if object.IsError(res) {
-			fmt.Fprintf(os.Stderr, "%v\n", res.Inspect())
+			fmt.Fprintf(env.ErrorLog(), "%v\n", res.Inspect())
 			return res
 		}
 
@@ -674,7 +881,7 @@ func evalAssignStatement(a *ast.AssignStatement, env *object.Environment) (val o
 
 		res := evalInfixExpression(a, tokentype.MINUS_EQUALS, current, evaluated, env)
 		if object.IsError(res) {
-			fmt.Fprintf(os.Stderr, "%v\n", res.Inspect())
+			fmt.Fprintf(env.ErrorLog(), "%v\n", res.Inspect())
 			return res
 		}
 
@@ -690,7 +897,7 @@ func evalAssignStatement(a *ast.AssignStatement, env *object.Environment) (val o
 
 		res := evalInfixExpression(a, tokentype.ASTERISK_EQUALS, current, evaluated, env)
 		if object.IsError(res) {
-			fmt.Fprintf(os.Stderr, "%v\n", res.Inspect())
+			fmt.Fprintf(env.ErrorLog(), "%v\n", res.Inspect())
 			return res
 		}
 
@@ -707,7 +914,7 @@ func evalAssignStatement(a *ast.AssignStatement, env *object.Environment) (val o
 
 		res := evalInfixExpression(a, tokentype.SLASH_EQUALS, current, evaluated, env)
 		if object.IsError(res) {
-			fmt.Fprintf(os.Stderr, "%v\n", res.Inspect())
+			fmt.Fprintf(env.ErrorLog(), "%v\n", res.Inspect())
 			return res
 		}
 
@@ -717,7 +924,7 @@ func evalAssignStatement(a *ast.AssignStatement, env *object.Environment) (val o
 	case tokentype.ASSIGN:
 		// If we're running with the strict-pragma it is
 		// a bug to set a variable which wasn't declared (via let).
-		if pragmas.PRAGMAS["strict"] == 1 {
+		if pragmas.Enabled("strict") {
 			_, ok := env.Get(a.Name.String())
 			if !ok {
 				fmt.Fprintf(os.Stderr,
@@ -732,6 +939,103 @@ func evalAssignStatement(a *ast.AssignStatement, env *object.Environment) (val o
 	return evaluated
 }
 
+// evalIndexAssign handles assignment to an element of an array or hash,
+// including nested targets such as `cfg["db"]["port"] = 5432`.
+//
+// Collections have value semantics, so rather than changing them in place
+// we build an updated copy of each collection on the path and finally
+// rebind the variable the path starts from.
+func evalIndexAssign(a *ast.AssignStatement, value object.ObjectI, env *object.Environment) object.ObjectI {
+
+	// Collect the index-expressions, outermost first.
+	var chain []*ast.IndexExpression
+	var exp asti.ExpressionI = a.Target
+	for {
+		ie, ok := exp.(*ast.IndexExpression)
+		if !ok {
+			break
+		}
+		chain = append([]*ast.IndexExpression{ie}, chain...)
+		exp = ie.Left
+	}
+	root, ok := exp.(*ast.Identifier)
+	if !ok {
+		return object.NewError(a, "cannot assign to %v", a.Target)
+	}
+	current, ok := env.Get(root.Value)
+	if !ok {
+		return object.NewError(a, "%s is unknown", root.Value)
+	}
+
+	// Evaluate each index once, remembering the collection it applies to.
+	containers := make([]object.ObjectI, len(chain))
+	indexes := make([]object.ObjectI, len(chain))
+	for i, ie := range chain {
+		index := Eval(ie.Index, env)
+		if object.IsError(index) {
+			return index
+		}
+		containers[i] = current
+		indexes[i] = index
+		if i < len(chain)-1 {
+			current = evalIndexExpression(ie, current, index)
+			if object.IsError(current) {
+				return current
+			}
+		}
+	}
+
+	// "x[i] += y" operates upon the existing element.
+	last := len(chain) - 1
+	if a.Operator != tokentype.ASSIGN {
+		old := evalIndexExpression(chain[last], containers[last], indexes[last])
+		if object.IsError(old) {
+			return old
+		}
+		value = evalInfixExpression(a, a.Operator, old, value, env)
+		if object.IsError(value) {
+			return value
+		}
+	}
+
+	// Now rebuild the collections from the innermost outwards.
+	updated := value
+	for i := last; i >= 0; i-- {
+		updated = setIndex(chain[i], containers[i], indexes[i], updated)
+		if object.IsError(updated) {
+			return updated
+		}
+	}
+	env.Set(root.Value, updated)
+	return value
+}
+
+// setIndex returns an updated array or hash with the given element
+// replaced, sharing storage with the original.
+func setIndex(node asti.NodeI, container, index, value object.ObjectI) object.ObjectI {
+	switch container := container.(type) {
+	case *object.Array:
+		idx, ok := index.(*object.Integer)
+		if !ok {
+			return object.NewError(node, "array index must be INTEGER, got=%s", index.Type())
+		}
+		i, ok := sequenceIndex(idx.Value, container.Len())
+		if !ok {
+			return object.NewError(node, "index out of range: %d, array length %d",
+				idx.Value, container.Len())
+		}
+		return container.Set(i, value)
+	case *object.Hash:
+		key, ok := index.(object.HashableI)
+		if !ok {
+			return object.NewError(node, "unusable as hash key: %s", index.Type())
+		}
+		return container.Set(key.HashKey(), object.HashPair{Key: index, Value: value})
+	default:
+		return object.NewError(node, "index assignment not support:%s", container.Type())
+	}
+}
+
 func evalSwitchStatement(se *ast.SwitchExpression, env *object.Environment) object.ObjectI {
 
 	// Get the value.
@@ -757,6 +1061,7 @@ func evalSwitchStatement(se *ast.SwitchExpression, env *object.Environment) obje
 				(obj.Inspect() == out.Inspect()) {
 
 				// Evaluate the block and return the value
+				traceBranch(opt.Block, env)
 				out := evalBlockStatement(opt.Block, env)
 				return out
 			}
@@ -768,6 +1073,7 @@ func evalSwitchStatement(se *ast.SwitchExpression, env *object.Environment) obje
 				if m == object.TRUE {
 
 					// Evaluate the block and return the value
+					traceBranch(opt.Block, env)
 					out := evalBlockStatement(opt.Block, env)
 					return out
 
@@ -782,11 +1088,13 @@ func evalSwitchStatement(se *ast.SwitchExpression, env *object.Environment) obje
 		// skip default
 		if opt.Default {
 
+			traceBranch(opt.Block, env)
 			out := evalBlockStatement(opt.Block, env)
 			return out
 		}
 	}
 
+	traceBranch(se, env)
 	return nil
 }
 
@@ -814,8 +1122,11 @@ func evalForeachExpression(fle *ast.ForeachStatement, env *object.Environment) o
 
 	// expression
 	val := Eval(fle.Value, env)
+	if object.IsError(val) {
+		return val
+	}
 
-	helper, ok := val.(object.IterableI)
+	iterable, ok := val.(object.IterableI)
 	if !ok {
 		return object.NewError(fle,
 			"%s object doesn't implement the Iterable interface", val.Type())
@@ -834,13 +1145,16 @@ func evalForeachExpression(fle *ast.ForeachStatement, env *object.Environment) o
 	// except the two variables named in the permit-array
 	child := object.NewTemporaryScope(env, permit)
 
-	// Reset the state of any previous iteration.
-	helper.Reset()
+	// Start a fresh pass, separate from any other loop over val.
+	helper := iterable.Iter()
 
 	// Get the initial values.
 	ret, idx, ok := helper.Next()
 
 	for ok {
+		if object.IsError(ret) {
+			return ret
+		}
 
 		// Set the index + name
 		child.Set(fle.Ident, ret)
@@ -854,9 +1168,11 @@ func evalForeachExpression(fle *ast.ForeachStatement, env *object.Environment) o
 		rt := Eval(fle.Body, child)
 
 		//
-		// If we got an error/return then we handle it.
+		// If we got an error/return then we handle it, closing the
+		// iterator we leave unfinished.
 		//
 		if !object.IsError(rt) && (rt.Type() == objecttype.RETURN_VALUE || rt.Type() == objecttype.ERROR) {
+			object.CloseIterator(helper)
 			return rt
 		}
 
@@ -882,7 +1198,11 @@ func isTruthy(obj object.ObjectI) bool {
 
 func evalProgram(program *ast.Program, env *object.Environment) object.ObjectI {
 	var result object.ObjectI
+	trace := env.Trace()
 	for _, statement := range program.Statements {
+		if trace != nil {
+			trace(statement, env)
+		}
 		result = Eval(statement, env)
 		switch result := result.(type) {
 		case *object.ReturnValue:
@@ -901,8 +1221,8 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 	if builtin, ok := builtinfunctions.BuiltinFunctions[node.Value]; ok {
 		return builtin
 	}
-	fmt.Fprintf(os.Stderr, "identifier not found: %v\n", node.Token)
-	if pragmas.PRAGMAS["strict"] == 1 {
+	fmt.Fprintf(env.ErrorLog(), "identifier not found: %v\n", node.Token)
+	if pragmas.Enabled("strict") {
 		os.Exit(1)
 	}
 	return object.NewError(node, "identifier not found: "+node.Value)
@@ -920,88 +1240,10 @@ func evalExpression(exps []asti.ExpressionI, env *object.Environment) []object.O
 	return result
 }
 
-// Split a line of text into tokens, but keep anything "quoted"
-// together..
-//
-// So this input:
-//
-//   /bin/sh -c "ls /etc"
-//
-// Would give output of the form:
-//   /bin/sh
-//   -c
-//   ls /etc
-//
-func splitCommand(input string) []string {
-
-	//
-	// This does the split into an array
-	//
-	r := regexp.MustCompile(`[^\s"']+|"([^"]*)"|'([^']*)`)
-	res := r.FindAllString(input, -1)
-
-	//
-	// However the resulting pieces might be quoted.
-	// So we have to remove them, if present.
-	//
-	var result []string
-	for _, e := range res {
-		result = append(result, trimQuotes(e, '"'))
-	}
-	return (result)
-}
-
-// Remove balanced characters around a string.
-func trimQuotes(in string, c byte) string {
-	if len(in) >= 2 {
-		if in[0] == c && in[len(in)-1] == c {
-			return in[1 : len(in)-1]
-		}
-	}
-	return in
-}
-
 // Run a command and return a hash containing the result.
-// `stderr`, `stdout`, and `error` will be the fields
-func backTickOperation(command string) object.ObjectI {
-
-	// split the command
-	toExec := splitCommand(command)
-	cmd := exec.Command(toExec[0], toExec[1:]...)
-
-	// get the result
-	var outb, errb bytes.Buffer
-	cmd.Stdout = &outb
-	cmd.Stderr = &errb
-	err := cmd.Run()
-
-	// If the command exits with a non-zero exit-code it
-	// is regarded as a failure.  Here we test for ExitError
-	// to regard that as a non-failure.
-	if err != nil && err != err.(*exec.ExitError) {
-		fmt.Fprintf(os.Stderr, "Failed to run '%s' -> %s\n", command, err.Error())
-		return object.NULL
-	}
-
-	//
-	// The result-objects to store in our hash.
-	//
-	stdout := &object.String{Value: outb.String()}
-	stderr := &object.String{Value: errb.String()}
-
-	// Create keys
-	stdoutKey := &object.String{Value: "stdout"}
-	stdoutHash := object.HashPair{Key: stdoutKey, Value: stdout}
-
-	stderrKey := &object.String{Value: "stderr"}
-	stderrHash := object.HashPair{Key: stderrKey, Value: stderr}
-
-	// Make a new hash, and populate it
-	newHash := make(map[object.HashKey]object.HashPair)
-	newHash[stdoutKey.HashKey()] = stdoutHash
-	newHash[stderrKey.HashKey()] = stderrHash
-
-	return &object.Hash{Pairs: newHash}
+// `exit_code`, `stdout`, `stderr` and `duration` will be the fields
+func backTickOperation(node *ast.BacktickLiteral) object.ObjectI {
+	return runCommand(node, "backtick", []object.ObjectI{&object.String{Value: node.Value}})
 }
 
 func evalIndexExpression(node asti.NodeI, left, index object.ObjectI) object.ObjectI {
@@ -1010,8 +1252,12 @@ func evalIndexExpression(node asti.NodeI, left, index object.ObjectI) object.Obj
 		return evalArrayIndexExpression(left, index)
 	case left.Type() == objecttype.HASH:
 		return evalHashIndexExpression(node, left, index)
-	case left.Type() == objecttype.STRING:
+	case left.Type() == objecttype.STRING && index.Type() == objecttype.INTEGER:
 		return evalStringIndexExpression(left, index)
+	case left.Type() == objecttype.RANGE && index.Type() == objecttype.INTEGER:
+		return evalRangeIndexExpression(left, index)
+	case left.Type() == objecttype.BYTES && index.Type() == objecttype.INTEGER:
+		return evalBytesIndexExpression(left, index)
 	default:
 		return object.NewError(node, "index operator not support:%s", left.Type())
 
@@ -1020,12 +1266,19 @@ func evalIndexExpression(node asti.NodeI, left, index object.ObjectI) object.Obj
 
 func evalArrayIndexExpression(array, index object.ObjectI) object.ObjectI {
 	arrayObject := array.(*object.Array)
+	i, ok := sequenceIndex(index.(*object.Integer).Value, arrayObject.Len())
+	if !ok {
+		return object.NULL
+	}
+	return arrayObject.Get(i)
+}
+func evalRangeIndexExpression(rng, index object.ObjectI) object.ObjectI {
+	rangeObject := rng.(*object.Range)
 	idx := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
-	if idx < 0 || idx > max {
+	if idx < 0 || idx >= rangeObject.Len() {
 		return object.NULL
 	}
-	return arrayObject.Elements[idx]
+	return rangeObject.Get(idx)
 }
 func evalHashIndexExpression(node asti.NodeI, hash, index object.ObjectI) object.ObjectI {
 	hashObject := hash.(*object.Hash)
@@ -1033,33 +1286,170 @@ func evalHashIndexExpression(node asti.NodeI, hash, index object.ObjectI) object
 	if !ok {
 		return object.NewError(node, "unusable as hash key: %s", index.Type())
 	}
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	pair, ok := hashObject.Get(key.HashKey())
 	if !ok {
 		return object.NULL
 	}
 	return pair.Value
 }
 
-func evalStringIndexExpression(input, index object.ObjectI) object.ObjectI {
-	str := input.(*object.String).Value
+func evalBytesIndexExpression(input, index object.ObjectI) object.ObjectI {
+	data := input.(*object.Bytes).Value
 	idx := index.(*object.Integer).Value
-	max := int64(len(str))
-	if idx < 0 || idx > max {
+	if idx < 0 || idx >= int64(len(data)) {
 		return object.NULL
 	}
+	return &object.Integer{Value: int64(data[idx])}
+}
 
+func evalStringIndexExpression(input, index object.ObjectI) object.ObjectI {
 	// Get the characters as an array of runes
-	chars := []rune(str)
+	chars := []rune(input.(*object.String).Value)
 
 	// Now index
-	ret := chars[idx]
+	i, ok := sequenceIndex(index.(*object.Integer).Value, len(chars))
+	if !ok {
+		return object.NULL
+	}
+	ret := chars[i]
 
 	// And return as a string.
 	return &object.String{Value: string(ret)}
 }
 
+// sequenceIndex returns the position idx refers to in an array or
+// string of length elements, counting a negative idx back from the
+// end as slices do, and false if there's no such element.
+func sequenceIndex(idx int64, length int) (int, bool) {
+	if idx < 0 {
+		idx += int64(length)
+	}
+	if idx < 0 || idx >= int64(length) {
+		return 0, false
+	}
+	return int(idx), true
+}
+
+// evalSliceExpression handles `x[start:end:step]` against arrays and
+// strings, following the python rules for negative and missing values.
+func evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.ObjectI {
+	left := Eval(node.Left, env)
+	if object.IsError(left) {
+		return left
+	}
+
+	// The start, end, and step are all optional.
+	var bounds [3]*int64
+	for i, exp := range []asti.ExpressionI{node.Start, node.End, node.Step} {
+		if exp == nil {
+			continue
+		}
+		val := Eval(exp, env)
+		if object.IsError(val) {
+			return val
+		}
+		switch val := val.(type) {
+		case *object.Integer:
+			v := val.Value
+			bounds[i] = &v
+		case *object.Null:
+		default:
+			return object.NewError(node, "slice index must be INTEGER, got=%s", val.Type())
+		}
+	}
+
+	var length int
+	switch left := left.(type) {
+	case *object.Array:
+		length = left.Len()
+	case *object.String:
+		length = utf8.RuneCountInString(left.Value)
+	case *object.Bytes:
+		length = len(left.Value)
+	default:
+		return object.NewError(node, "slice operator not support:%s", left.Type())
+	}
+
+	indexes, err := sliceIndexes(length, bounds[0], bounds[1], bounds[2])
+	if err != nil {
+		return object.NewError(node, "%v", err)
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		elements := make([]object.ObjectI, len(indexes))
+		for i, idx := range indexes {
+			elements[i] = left.Get(idx)
+		}
+		return object.NewArray(elements)
+	case *object.Bytes:
+		out := make([]byte, len(indexes))
+		for i, idx := range indexes {
+			out[i] = left.Value[idx]
+		}
+		return &object.Bytes{Value: out}
+	default:
+		chars := []rune(left.(*object.String).Value)
+		out := make([]rune, len(indexes))
+		for i, idx := range indexes {
+			out[i] = chars[idx]
+		}
+		return &object.String{Value: string(out)}
+	}
+}
+
+// sliceIndexes returns the positions selected by slicing a sequence
+// of the given length.  Negative start/end count from the end of the
+// sequence, and out of range values are clamped.
+func sliceIndexes(length int, start, end, step *int64) ([]int, error) {
+	st := 1
+	if step != nil {
+		st = int(*step)
+	}
+	if st == 0 {
+		return nil, fmt.Errorf("slice step cannot be zero")
+	}
+
+	// the lowest and highest position we may select
+	lower, upper := 0, length
+	if st < 0 {
+		lower, upper = -1, length-1
+	}
+
+	bound := func(v *int64, def int) int {
+		if v == nil {
+			return def
+		}
+		i := int(*v)
+		if i < 0 {
+			i += length
+		}
+		if i < lower {
+			return lower
+		}
+		if i > upper {
+			return upper
+		}
+		return i
+	}
+
+	var indexes []int
+	if st > 0 {
+		from, to := bound(start, lower), bound(end, upper)
+		for i := from; i < to; i += st {
+			indexes = append(indexes, i)
+		}
+	} else {
+		from, to := bound(start, upper), bound(end, lower)
+		for i := from; i > to; i += st {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes, nil
+}
+
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.ObjectI {
-	pairs := make(map[object.HashKey]object.HashPair)
+	pairs := &object.Hash{}
 	for keyNode, valueNode := range node.Pairs {
 		key := Eval(keyNode, env)
 		if object.IsError(key) {
@@ -1074,19 +1464,44 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 			return value
 		}
 		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		pairs = pairs.Set(hashed, object.HashPair{Key: key, Value: value})
+
+	}
+	return pairs
+
+}
 
+func evalSetLiteral(node *ast.SetLiteral, env *object.Environment) object.ObjectI {
+	set := &object.Set{}
+	for _, elementNode := range node.Elements {
+		element := Eval(elementNode, env)
+		if object.IsError(element) {
+			return element
+		}
+		if _, ok := element.(object.HashableI); !ok {
+			return object.NewError(node, "unusable as set element: %s", element.Type())
+		}
+		set = set.Add(element)
 	}
-	return &object.Hash{Pairs: pairs}
+	return set
+}
 
+// Call calls fn with args, as node would from env, returning its
+// result.  It lets hosts call the functions a program defines, as the
+// test runner calls each test.
+func Call(node asti.NodeI, env *object.Environment, fn object.ObjectI, args ...object.ObjectI) object.ObjectI {
+	return applyFunction(node, env, fn, args)
 }
 
 func applyFunction(node asti.NodeI, env *object.Environment, fn object.ObjectI, args []object.ObjectI) object.ObjectI {
 	switch fn := fn.(type) {
 	case *object.Function:
 		extendEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendEnv)
-		return upwrapReturnValue(evaluated)
+		extendEnv.SetFrame(newFrame(fn, node, env))
+		if fn.Generator {
+			return newGenerator(fn, extendEnv)
+		}
+		return callFunction(fn, extendEnv)
 	case *object.Builtin:
 		return fn.Fn(node, env, args...)
 	default:
@@ -1110,6 +1525,44 @@ func extendFunctionEnv(fn *object.Function, args []object.ObjectI) *object.Envir
 	return env
 }
 
+// newFrame records a call of fn by node, from env.
+func newFrame(fn *object.Function, node asti.NodeI, env *object.Environment) *object.Frame {
+	name := fn.Name
+	if name == "" {
+		name = "fn"
+	}
+	return &object.Frame{Name: name, Function: fn, Call: node, Caller: env}
+}
+
+// callFunction runs the body of fn in env, the environment of a call
+// of it, telling any call trace as the call starts and finishes.
+func callFunction(fn *object.Function, env *object.Environment) object.ObjectI {
+	if trace := env.CallTrace(); trace != nil {
+		defer trace(env)()
+	}
+	return withStack(upwrapReturnValue(Eval(fn.Body, env)), env)
+}
+
+// withStack records the call stack on an error leaving a function,
+// unless a call deeper down already has.
+func withStack(obj object.ObjectI, env *object.Environment) object.ObjectI {
+	if err, ok := obj.(*object.Error); ok && err.Stack == nil {
+		err.Stack = env.Stack()
+	}
+	return obj
+}
+
+// nameFunction names the function a let or const gives a name, when
+// it's defined there, so calls of it are reported by that name.
+func nameFunction(value asti.ExpressionI, val object.ObjectI, name string) {
+	if _, ok := value.(*ast.FunctionLiteral); !ok {
+		return
+	}
+	if fn, ok := val.(*object.Function); ok {
+		fn.Name = name
+	}
+}
+
 func upwrapReturnValue(obj object.ObjectI) object.ObjectI {
 	if returnValue, ok := obj.(*object.ReturnValue); ok {
 		return returnValue.Value
@@ -1132,6 +1585,10 @@ func evalObjectCallExpression(call *ast.ObjectCallExpression, env *object.Enviro
 		//
 		args := evalExpression(call.Call.(*ast.CallExpression).Arguments, env)
 		ret := obj.InvokeMethod(method.Function.String(), *env, args...)
+		if err, ok := ret.(*object.Error); ok && err.Node == nil {
+			// methods don't see the AST, so point the error at the call.
+			err.Node = call
+		}
 		if ret != nil {
 			return ret
 		}
@@ -1170,7 +1627,11 @@ func evalObjectCallExpression(call *ast.ObjectCallExpression, env *object.Enviro
 		//
 		//
 		attempts := []string{}
-		attempts = append(attempts, strings.ToLower(string(obj.Type())))
+		attempts = append(attempts, strings.ToLower(obj.Type().String()))
+		if obj.Type() == objecttype.RANGE {
+			// ranges index like arrays, so array methods work on them.
+			attempts = append(attempts, "array")
+		}
 		attempts = append(attempts, "object")
 
 		//
@@ -1198,13 +1659,15 @@ func evalObjectCallExpression(call *ast.ObjectCallExpression, env *object.Enviro
 				// which the function-call will be operating.
 				//
 				extendEnv.Set("self", obj)
+				extendEnv.SetFrame(newFrame(fn.(*object.Function), call, env))
+				if fn.(*object.Function).Generator {
+					return newGenerator(fn.(*object.Function), extendEnv)
+				}
 
 				//
 				// Finally invoke & return.
 				//
-				evaluated := Eval(fn.(*object.Function).Body, extendEnv)
-				obj = upwrapReturnValue(evaluated)
-				return obj
+				return callFunction(fn.(*object.Function), extendEnv)
 			} else {
 				//fmt.Fprintf(os.Stderr,"fail to exec %v %v\n", name, env)
 			}
@@ -1247,12 +1710,12 @@ func objectToNativeBoolean(o object.ObjectI) bool {
 		}
 		return true
 	case *object.Array:
-		if len(obj.Elements) == 0 {
+		if obj.Len() == 0 {
 			return false
 		}
 		return true
 	case *object.Hash:
-		if len(obj.Pairs) == 0 {
+		if obj.Len() == 0 {
 			return false
 		}
 		return true
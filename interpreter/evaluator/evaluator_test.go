@@ -1,8 +1,18 @@
 package evaluator
 
 import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
 	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/kasworld/nonkey/interpreter/lexer"
 	"github.com/kasworld/nonkey/interpreter/object"
@@ -385,13 +395,13 @@ func TestArrayLiterals(t *testing.T) {
 		t.Fatalf("object is not Array, got=%T(%v)",
 			evaluated, evaluated)
 	}
-	if len(result.Elements) != 3 {
+	if result.Len() != 3 {
 		t.Fatalf("array has wrong num of elements. got=%d",
-			len(result.Elements))
+			result.Len())
 	}
-	testDecimalObject(t, result.Elements[0], 1)
-	testDecimalObject(t, result.Elements[1], 4)
-	testDecimalObject(t, result.Elements[2], 6)
+	testDecimalObject(t, result.Get(0), 1)
+	testDecimalObject(t, result.Get(1), 4)
+	testDecimalObject(t, result.Get(2), 6)
 }
 
 func TestArrayIndexExpression(t *testing.T) {
@@ -433,6 +443,14 @@ func TestArrayIndexExpression(t *testing.T) {
 		},
 		{
 			"[1,2,3][-1]",
+			3,
+		},
+		{
+			"[1,2,3][-3]",
+			1,
+		},
+		{
+			"[1,2,3][-4]",
 			nil,
 		},
 	}
@@ -464,10 +482,22 @@ func TestStringIndexExpression(t *testing.T) {
 			"\"Steve\"[101]",
 			nil,
 		},
+		{
+			"\"Steve\"[5]",
+			nil,
+		},
 		{
 			"\"Steve\"[-1]",
+			"e",
+		},
+		{
+			"\"Steve\"[-6]",
 			nil,
 		},
+		{
+			"\"狐犬\"[-2]",
+			"狐",
+		},
 		{
 			"\"狐犬\"[0]",
 			"狐",
@@ -512,11 +542,11 @@ func TestHashLiterals(t *testing.T) {
 		object.TRUE.HashKey():                      5,
 		object.FALSE.HashKey():                     6,
 	}
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	if result.Len() != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", result.Len())
 	}
 	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
+		pair, ok := result.Get(expectedKey)
 		if !ok {
 			t.Errorf("no pair for given key in Pairs")
 		}
@@ -622,3 +652,1073 @@ func TestTypeBuiltin(t *testing.T) {
 		}
 	}
 }
+
+func TestConversionBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`int("3") + 1`, "4"},
+		{`int(2.5)`, "2"},
+		{`string(12) + "a"`, "12a"},
+		{`let float = 1.5; float`, "1.5"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestSprintf(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`sprintf("%d %s", 1, "a")`, "1 a"},
+		{`sprintf("%s|%v", [][0], [][0])`, "<NULL>|<NULL>"},
+		{`sprintf("%v", [1, "a", [][0]])`, "[1 a <nil>]"},
+		{`sprintf("%v", {"k": 2})`, "map[k:2]"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestSliceExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1,2,3,4,5][1:3]`, "[2, 3]"},
+		{`[1,2,3,4,5][:2]`, "[1, 2]"},
+		{`[1,2,3,4,5][3:]`, "[4, 5]"},
+		{`[1,2,3,4,5][:]`, "[1, 2, 3, 4, 5]"},
+		{`[1,2,3,4,5][-2:]`, "[4, 5]"},
+		{`[1,2,3,4,5][:-1]`, "[1, 2, 3, 4]"},
+		{`[1,2,3,4,5][::2]`, "[1, 3, 5]"},
+		{`[1,2,3,4,5][::-1]`, "[5, 4, 3, 2, 1]"},
+		{`[1,2,3,4,5][3:0:-1]`, "[4, 3, 2]"},
+		{`[1,2,3,4,5][10:20]`, "[]"},
+		{`[1,2,3,4,5][-10:2]`, "[1, 2]"},
+		{`"Steve"[1:3]`, "te"},
+		{`"Steve"[:-1]`, "Stev"},
+		{`"Steve"[::-1]`, "evetS"},
+		{`"狐犬狐"[1:]`, "犬狐"},
+		{`let a = [1,2,3]; let i = 1; a[i:i+1]`, "[2]"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: got=%v, want=%s", tt.input, evaluated, tt.expected)
+		}
+	}
+
+	errors := []string{`[1,2,3][::0]`, `[1,2,3]["a":]`, `5[1:2]`}
+	for _, input := range errors {
+		evaluated := testEval(input)
+		if !object.IsError(evaluated) {
+			t.Errorf("%s: expected error, got=%T(%+v)", input, evaluated, evaluated)
+		}
+	}
+}
+
+func TestIndexAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let a = [1,2,3]; a[1] = 5; a`, "[1, 5, 3]"},
+		{`let a = [1,2,3]; a[2] += 10; a`, "[1, 2, 13]"},
+		{`let a = [1,2,3]; a[-1] = 9; a[-3] += 1; a`, "[2, 2, 9]"},
+		{`let a = [1,2,3]; let b = a; a[0] = 9; b`, "[1, 2, 3]"},
+		{`let h = {"a": 1}; h["a"] = 2; h["a"]`, "2"},
+		{`let h = {"a": 1}; h["b"] = 3; h["b"]`, "3"},
+		{`let h = {"a": 1}; h["a"] *= 7; h["a"]`, "7"},
+		{`let h = {"name": "x"}; h.name = "steve"; h.name`, "steve"},
+		{`let cfg = {"db": {"port": 1}}; cfg["db"]["port"] = 5432; cfg["db"]["port"]`, "5432"},
+		{`let cfg = {"db": {"port": 1}}; cfg.db.port = 80; cfg.db.port`, "80"},
+		{`let time = {"start": 1}; time.start += 1; time.start`, "2"},
+		{`let process = {"id": 7}; process.id`, "7"},
+		{`let time = {"start": 1}; time.now().year() > 2000`, "true"},
+		{`let m = [[1,2],[3,4]]; m[1][0] = 7; m`, "[[1, 2], [7, 4]]"},
+		{`let h = {"l": [1,2]}; h["l"][1] = "x"; h["l"]`, "[1, x]"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: got=%v, want=%s", tt.input, evaluated, tt.expected)
+		}
+	}
+
+	errors := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`let a = [1,2,3]; a[3] = 1;`, "index out of range: 3, array length 3"},
+		{`let a = [1,2,3]; a[-4] = 1;`, "index out of range: -4, array length 3"},
+		{`let s = "abc"; s[0] = "x";`, "index assignment not support:STRING"},
+		{`nothere[0] = 1;`, "nothere is unknown"},
+	}
+	for _, tt := range errors {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestCompositeComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"[1,2] == [1,2]", true},
+		{"[1,2] != [1,2]", false},
+		{"[1,2] == [1,2,3]", false},
+		{"[1,[2,3]] == [1,[2,3]]", true},
+		{"[1,2] == [1.0,2]", true},
+		{`{"a":1, "b":[1]} == {"b":[1], "a":1}`, true},
+		{`{"a":1} == {"a":2}`, false},
+		{`{"a":1} != {"b":1}`, true},
+		{`{} == {}`, true},
+		{`[1] == "x"`, false},
+		{"[1,2] < [1,3]", true},
+		{"[1,2] < [1,2,0]", true},
+		{"[2] > [1,9]", true},
+		{"[1,2] <= [1,2]", true},
+		{`["a","b"] >= ["a"]`, true},
+		{`let h = {[1,2]: "pair"}; h[[1,2]] == "pair"`, true},
+		{`{[1]: "a"}[[1.0]] == "a"`, true},
+		{`{1: "a"}[1.0] == "a"`, true},
+		{`{[{"k": 1}]: "a"}[[{"k": 1.0}]] == "a"`, true},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+
+	errors := []string{`[1] < ["a"]`, `{"a":1} < {"a":2}`}
+	for _, input := range errors {
+		evaluated := testEval(input)
+		if !object.IsError(evaluated) {
+			t.Errorf("%s: expected error, got=%T(%+v)", input, evaluated, evaluated)
+		}
+	}
+}
+
+func TestCompareBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"compare(1, 2)", -1},
+		{"compare(2, 1)", 1},
+		{"compare(2, 2.0)", 0},
+		{`compare("b", "a")`, 1},
+		{"compare(false, true)", -1},
+		{"compare([1,2], [1,2])", 0},
+		{"compare([1], [1,2])", -1},
+		{`compare(1, "a")`, nil},
+		{"compare(1)", nil},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testDecimalObject(t, evaluated, int64(integer))
+		} else if !object.IsError(evaluated) {
+			t.Errorf("%s: expected error, got=%T(%+v)", tt.input, evaluated, evaluated)
+		}
+	}
+}
+
+func TestSetExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"{3, 1, 2, 1}", "{1, 2, 3}"},
+		{`{"b", 2, "a", 1.5, true}`, "{1.5, 2, true, a, b}"},
+		{"new_set()", "new_set()"},
+		{`new_set([2, 1, 2])`, "{1, 2}"},
+		{`new_set({"a": 1, "b": 2})`, "{a, b}"},
+		{"{1, 2, 3} | {3, 4}", "{1, 2, 3, 4}"},
+		{"{1, 2, 3} & {2, 3, 4}", "{2, 3}"},
+		{"{1, 2, 3} - {2}", "{1, 3}"},
+		{"{1, 2} | {3} & {3, 4}", "{1, 2, 3}"},
+		{"let s = {1, 2}; s.add(3, 4).remove(1)", "{2, 3, 4}"},
+		{"let s = {1, 2}; s.add(3); s", "{1, 2}"},
+		{"let a = []; foreach v in {3, 1, 2} { a = push(a, v) }; a", "[1, 2, 3]"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	booleans := []struct {
+		input    string
+		expected bool
+	}{
+		{"{1, 2}.has(2)", true},
+		{"{1, 2}.has(3)", false},
+		{"{1, 2} == {2, 1}", true},
+		{"{1, 2} != {1}", true},
+		{"{1} <= {1, 2}", true},
+		{"{1, 2} < {1, 2}", false},
+		{"{1, 2} >= {2}", true},
+		{"{1, 3} > {2}", false},
+		{"len({1, 2, 2}) == 2", true},
+		{"{{1, 2}, {2, 1}} == {{1, 2}}", true},
+	}
+	for _, tt := range booleans {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+
+	errors := []string{`{1, fn(x) { x }}`, `new_set(1)`, `{1} + {2}`, `{1}.add(fn(x) { x })`}
+	for _, input := range errors {
+		evaluated := testEval(input)
+		if !object.IsError(evaluated) {
+			t.Errorf("%s: expected error, got=%T(%+v)", input, evaluated, evaluated)
+		}
+	}
+}
+
+func TestIterators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// nested loops over the same collection don't interfere.
+		{`let a = [1, 2]; let r = []; foreach x in a { foreach y in a { r = push(r, x * 10 + y) } }; r`, "[11, 12, 21, 22]"},
+		{`let s = "ab"; let r = []; foreach x in s { foreach y in s { r = push(r, x + y) } }; r`, "[aa, ab, ba, bb]"},
+		{"1..1e9", "1..1000000000"},
+		{"len(1..1e9)", "1000000000"},
+		{"(3..5)[1]", "4"},
+		{"(3..5)[3]", "null"},
+		{"len(5..1)", "0"},
+		{"(1..4).to_array()", "[1, 2, 3, 4]"},
+		{"let r = []; foreach i, v in 5..7 { r = push(r, [i, v]) }; r", "[[0, 5], [1, 6], [2, 7]]"},
+		{"function array.first() { return self[0]; }; (4..6).first()", "4"},
+		{"let g = fn(n) { let i = 0; for (i < n) { yield i * i; i++; } }; g(4).to_array()", "[0, 1, 4, 9]"},
+		{"let g = fn() { yield 1; yield; }; g().to_array()", "[1, null]"},
+		{"let g = fn() { let i = 0; for (true) { yield i; i++; } }; take(g(), 3).to_array()", "[0, 1, 2]"},
+		{"let g = fn() { yield 1; }; let it = g(); it.next(); it.next()", "null"},
+		{"take(map(1..1e9, fn(x) { x * 2 }), 3).to_array()", "[2, 4, 6]"},
+		{"filter([1, 2, 3, 4], fn(x) { x % 2 == 0 }).to_array()", "[2, 4]"},
+		{`zip([1, 2, 3], "ab").to_array()`, "[[1, a], [2, b]]"},
+		{`enumerate(["a", "b"]).to_array()`, "[[0, a], [1, b]]"},
+		{"let it = iter([1, 2, 3]); it.next(); it.to_array()", "[2, 3]"},
+		{"let it = iter([1, 2]); let r = []; foreach x in it { r = push(r, x) }; foreach x in it { r = push(r, x) }; r", "[1, 2]"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	errors := []string{
+		"1..2.5",
+		"map(1, fn(x) { x })",
+		"take([1], 1.5)",
+		`map([1, "a"], fn(x) { x - 1 }).to_array()`,
+		`let g = fn() { yield 1; yield 1 - "a"; }; foreach x in g() { x }`,
+		"(0 - 9223372036854775807 - 1)..9223372036854775807",
+	}
+	for _, input := range errors {
+		evaluated := testEval(input)
+		if !object.IsError(evaluated) {
+			t.Errorf("%s: expected error, got=%T(%+v)", input, evaluated, evaluated)
+		}
+	}
+}
+
+func TestGeneratorClose(t *testing.T) {
+	before := runtime.NumGoroutine()
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn() { foreach x in g() { if (x == 2) { return x; } } }; f()", "2"},
+		{"let f = fn() { foreach x in map(g(), fn(x) { x * 2 }) { if (x == 2) { return x; } } }; f()", "2"},
+		{"take(g(), 3).to_array()", "[0, 1, 2]"},
+		{"zip(g(), [1]).to_array()", "[[0, 1]]"},
+		{"let it = g(); it.next(); it.close(); it.next()", "null"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval("let g = fn() { let i = 0; for (true) { yield i; i++; } }; " + tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	// The generators' goroutines end once they're closed, without
+	// waiting to be garbage collected.
+	for i := 0; runtime.NumGoroutine() > before && i < 100; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("%d goroutines left running", n-before)
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let sq = fn(n) { n * n }; wait(spawn sq(2), spawn sq(3))", "[4, 9]"},
+		{`let c = spawn "ab".len(); c.recv()`, "2"},
+		{"let c = chan(2); c.send(1); c.send(2); c.close(); let r = []; foreach v in c { r = push(r, v) }; r", "[1, 2]"},
+		{"let c = chan(); let f = fn() { foreach i in 1..3 { c.send(i) }; c.close() }; spawn f(); let r = []; foreach v in c { r = push(r, v) }; r", "[1, 2, 3]"},
+		{"let c = chan(1); c.close(); c.recv()", "null"},
+		{"let c = chan(1); select { case v in c { v } default { 0 } }", "0"},
+		{"let c = chan(1); c.send(7); select { case v in c { v } default { 0 } }", "7"},
+		{"let a = chan(); let b = chan(1); b.send(2); select { case v in a { v } case v in b { v * 10 } }", "20"},
+		{"let c = chan(1); c.close(); select { case v in c { v } }", "null"},
+		{"let c = chan(1); c.send(1); select { case c { 5 } }", "5"},
+		{"let total = chan(10); let f = fn(n) { total.send(n) }; foreach n in 1..10 { spawn f(n) }; wait(); total.len()", "10"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	errors := []string{
+		"let c = chan(1); c.close(); c.close()",
+		"let c = chan(1); c.close(); c.send(1)",
+		"chan(-1)",
+		"select { case 1 { 1 } }",
+		"wait(1)",
+	}
+	for _, input := range errors {
+		evaluated := testEval(input)
+		if !object.IsError(evaluated) {
+			t.Errorf("%s: expected error, got=%T(%+v)", input, evaluated, evaluated)
+		}
+	}
+
+	// wait() in one program doesn't wait for another's functions.
+	run := func(env *object.Environment, input string) object.ObjectI {
+		return Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+	}
+	blocked := object.NewEnvironment()
+	run(blocked, "let c = chan(); spawn c.recv();")
+	done := make(chan bool)
+	go func() {
+		run(object.NewEnvironment(), "wait()")
+		done <- true
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Errorf("expected wait() to ignore another program's functions")
+	}
+	if res := run(blocked, "c.send(1); wait(); 1"); res.Inspect() != "1" {
+		t.Errorf("expected the program's own wait() to finish, got %s", res.Inspect())
+	}
+}
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`json.encode({"b": [1, 2.0, true], "a": "x\"y", "c": {}})`, `{"a":"x\"y","b":[1,2.0,true],"c":{}}`},
+		{`json.encode([1.5, 1e21, -0.25])`, `[1.5,1e+21,-0.25]`},
+		{`json.encode(json.decode("null"))`, `null`},
+		{`json.encode({"a": [1]}, 1)`, "{\n \"a\": [\n  1\n ]\n}"},
+		{`json.encode([1], "\t")`, "[\n\t1\n]"},
+		{`json.encode(json.decode(" {\"k\": [1, 1.0, 1e2, \"s\", false, null]} "))`, `{"k":[1,1.0,100.0,"s",false,null]}`},
+		{`type(json.decode("3"))`, "INTEGER"},
+		{`type(json.decode("3.0"))`, "FLOAT"},
+		{`type(json.decode("12345678901234567890"))`, "FLOAT"},
+		{`json.decode("[1, 2]")[1]`, "2"},
+		{`json.decode("{\"a\": {\"b\": \"c\"}}")["a"]["b"]`, "c"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	errors := []string{
+		`json.decode("[1,")`,
+		`json.decode("1 2")`,
+		`json.decode(1)`,
+		`json.encode({1: 2})`,
+		`json.encode(fn() {})`,
+		`json.encode([1], [2])`,
+	}
+	for _, input := range errors {
+		evaluated := testEval(input)
+		if !object.IsError(evaluated) {
+			t.Errorf("%s: expected error, got=%T(%+v)", input, evaluated, evaluated)
+		}
+	}
+}
+
+func TestCSV(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.csv")
+	if err := ioutil.WriteFile(path, []byte("name,age\nbob,42\n\"smith, j\",7\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out.csv")
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{fmt.Sprintf(`csv.read(%q)`, path), `[[name, age], [bob, 42], [smith, j, 7]]`},
+		{fmt.Sprintf(`csv.read(%q, true)[1]["name"]`, path), `smith, j`},
+		{fmt.Sprintf(`len(csv.read(%q, true))`, path), `2`},
+		{fmt.Sprintf(`csv.read(open(%q), true)[0]["age"]`, path), `42`},
+		{fmt.Sprintf(`csv.write(%q, [[1, "a,b"], [2.5, true]]); csv.read(%q)`, out, out), `[[1, a,b], [2.5, true]]`},
+		{fmt.Sprintf(`csv.write(%q, [{"b": 1, "a": 2}, {"a": 3}]); csv.read(%q)`, out, out), `[[a, b], [2, 1], [3, ]]`},
+		{fmt.Sprintf(`csv.write(%q, [{"b": 1, "a": 2}], ["b"]); csv.read(%q)`, out, out), `[[b], [1]]`},
+		{fmt.Sprintf(`let f = open(%q, "w"); csv.write(f, [["x"]], ["h"]); csv.read(%q)`, out, out), `[[h], [x]]`},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	bad := filepath.Join(dir, "bad.csv")
+	if err := ioutil.WriteFile(bad, []byte("a,b\n1,2\n3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	errors := map[string]string{
+		fmt.Sprintf(`csv.read(%q)`, bad):                "line 3",
+		fmt.Sprintf(`csv.read(%q)`, dir+"/missing.csv"): "no such file",
+		`csv.read(1)`:                          "must be STRING or FILE",
+		fmt.Sprintf(`csv.write(%q, [1])`, out): "row 0 must be ARRAY or HASH",
+	}
+	for input, message := range errors {
+		evaluated := testEval(input)
+		err, ok := evaluated.(*object.Error)
+		if !ok || !strings.Contains(err.Message, message) {
+			t.Errorf("%s: expected error containing %q, got=%s", input, message, evaluated.Inspect())
+		}
+	}
+}
+
+func TestYAML(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`yaml.decode("a: 1\nb: [x, 'y z', \"q\"]\nc:\n  - 1.5\n  - true\n  - ~\n") == {"a": 1, "b": ["x", "y z", "q"], "c": [1.5, true, json.decode("null")]}`, "true"},
+		{`yaml.decode("# c\nlist:\n- k: 1   # c\n  j: {m: 2}\n- - 3\n")["list"] == [{"k": 1, "j": {"m": 2}}, [3]]`, "true"},
+		{`yaml.decode("s: |\n  one\n  two\nf: >-\n  one\n  two\n\n  three\n")["s"]`, "one\ntwo\n"},
+		{`yaml.decode("s: |\n  one\n  two\nf: >-\n  one\n  two\n\n  three\n")["f"]`, "one two\nthree"},
+		{`yaml.decode("url: http://a.b/c # c\nhex: 0x10\nstr: \"1\"") == {"url": "http://a.b/c", "hex": 16, "str": "1"}`, "true"},
+		{`type(yaml.decode("v: '1'")["v"])`, "STRING"},
+		{`yaml.decode("")`, "null"},
+		{`yaml.decode("just text")`, "just text"},
+		{`yaml.encode({"b": [1, {"x": 2, "y": [true]}], "a": "1", "c": {}, "d": "plain text"})`,
+			"a: \"1\"\nb:\n  - 1\n  - x: 2\n    y:\n      - true\nc: {}\nd: plain text\n"},
+		{`yaml.encode([[1, 2], "a: b", "", 2.0])`, "- - 1\n  - 2\n- \"a: b\"\n- \"\"\n- 2.0\n"},
+		{`let v = {"a": [1, "2", {"b": "multi\nline"}]}; yaml.decode(yaml.encode(v)) == v`, "true"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	errors := map[string]string{
+		`yaml.decode("a: 1\na: 2")`:       "line 2: duplicate key",
+		`yaml.decode("a: 1\n  b: 2")`:     "line 2: bad indentation",
+		`yaml.decode("a:\n  - 1\n b: 2")`: "line 3: bad indentation",
+		`yaml.decode("x: 1\ny: [1, 2")`:   "line 2: missing",
+		`yaml.decode("a: &anchor 1")`:     "line 1: anchors",
+		`yaml.decode("a: 1\n---\nb: 2")`:  "line 2: multiple documents",
+		`yaml.decode("a: \"\\q\"")`:       "line 1: invalid escape",
+		`yaml.encode(fn() {})`:            "can't encode",
+	}
+	for input, message := range errors {
+		evaluated := testEval(input)
+		err, ok := evaluated.(*object.Error)
+		if !ok || !strings.Contains(err.Message, message) {
+			t.Errorf("%s: expected error containing %q, got=%s", input, message, evaluated.Inspect())
+		}
+	}
+}
+
+func TestTOML(t *testing.T) {
+	doc := `title = "TOML" # comment
+[owner]
+name = 'Tom'
+dob = 1979-05-27 07:32:00Z
+
+[db]
+ports = [ 8000,
+  8001, # comment
+]
+conn = { max = 5_000, ratio = 1e-1 }
+a.b = 0x1F
+
+[[products]]
+name = """
+multi
+line"""
+[[products]]
+"quoted key" = -inf
+`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`t["title"]`, "TOML"},
+		{`t["owner"]["dob"]`, "1979-05-27 07:32:00Z"},
+		{`t["db"] == {"a": {"b": 31}, "conn": {"max": 5000, "ratio": 0.1}, "ports": [8000, 8001]}`, "true"},
+		{`t["products"][0]["name"]`, "multi\nline"},
+		{`t["products"][1]["quoted key"]`, "-Inf"},
+		{`toml.decode(toml.encode(t)) == t`, "true"},
+		{`toml.encode({"b": {"c": {"d": 1}}, "a": [1, "x"], "e": [{"f": {"g": 2}}], "h i": {}})`,
+			"a = [1, \"x\"]\n\n[b.c]\nd = 1\n\n[\"h i\"]\n\n[[e]]\n\n[e.f]\ng = 2\n"},
+		{`toml.encode({"a": [{"b": 1}, 2], "c": [{}]})`, "a = [{ b = 1 }, 2]\n\n[[c]]\n"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(fmt.Sprintf("let t = toml.decode(%q); %s", doc, tt.input))
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	errors := map[string]string{
+		`toml.decode("a = 1\nb =\n")`:             "line 2: expected a value",
+		`toml.decode("a = 1\na = 2")`:             "line 2: key a is already defined",
+		`toml.decode("[x]\ny = 1\n[x]")`:          "line 3: table x is already defined",
+		`toml.decode("a = 1 2")`:                  "line 1: expected end of line",
+		`toml.decode("s = \"open\nx = 1")`:        "line 1: unterminated string",
+		`toml.decode("\n\nn = 1__0")`:             "line 3: invalid value",
+		`toml.encode({"a": json.decode("null")})`: "TOML has no null",
+		`toml.encode([1])`:                        "must be HASH",
+	}
+	for input, message := range errors {
+		evaluated := testEval(input)
+		err, ok := evaluated.(*object.Error)
+		if !ok || !strings.Contains(err.Message, message) {
+			t.Errorf("%s: expected error containing %q, got=%s", input, message, evaluated.Inspect())
+		}
+	}
+}
+
+func TestHTTPClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.Header().Add("X-Multi", "a")
+		w.Header().Add("X-Multi", "b")
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		fmt.Fprintf(w, "%s %s %s", r.Header.Get("X-Token"), r.URL.RawQuery, body)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`http.get(url + "/?q=1")["body"]`, " q=1 "},
+		{`http.get(url, {"X-Token": "t"})["body"]`, "t  "},
+		{`http.get(url)["status"]`, "200"},
+		{`http.get(url + "/missing")["status"]`, "404"},
+		{`http.get(url)["headers"]["X-Multi"]`, "a, b"},
+		{`http.post(url, "data")["headers"]["X-Method"]`, "POST"},
+		{`http.post(url, "data", {"X-Token": ["u"]})["body"]`, "u  data"},
+		{`http.request({"method": "put", "url": url, "body": "x"})["headers"]["X-Method"]`, "PUT"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(fmt.Sprintf("let url = %q; %s", server.URL, tt.input))
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	errors := map[string]string{
+		`http.request({"url": url + "/slow", "timeout": 0.05})`: "Timeout",
+		`http.request({"method": "GET"})`:                       "url is required",
+		`http.request({"url": 1})`:                              "url must be STRING",
+		`http.get(url, {"X-Token": 1})`:                         "header X-Token must be STRING or ARRAY",
+		`http.get("http://[::1]:namedport")`:                    "http.request:",
+	}
+	for input, message := range errors {
+		evaluated := testEval(fmt.Sprintf("let url = %q; %s", server.URL, input))
+		err, ok := evaluated.(*object.Error)
+		if !ok || !strings.Contains(err.Message, message) {
+			t.Errorf("%s: expected error containing %q, got=%s", input, message, evaluated.Inspect())
+		}
+	}
+}
+
+func TestHTTPServe(t *testing.T) {
+	input := `
+let count = 0;
+let handle = fn(req) {
+	let count = count + 1;
+	if (req["path"] == "/fail") {
+		return 1 / "x";
+	}
+	if (req["path"] == "/text") {
+		return sprintf("plain %d", count);
+	}
+	if (req["path"] == "/status") {
+		return {"status": 42};
+	}
+	if (req["path"] == "/none") {
+		return;
+	}
+	if (req["path"] == "/headers") {
+		return {"headers": {"A": "set", "B": 1}};
+	}
+	return {"status": 201, "headers": {"X-Path": req["path"]},
+		"body": sprintf("%s %s %s %s", req["method"], req["query"]["a"], req["headers"]["X-Token"], req["body"])};
+};
+`
+	env := object.NewEnvironment()
+	Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+	fn, _ := env.Get("handle")
+	server := httptest.NewServer(&httpHandler{env: env, fn: fn})
+	defer server.Close()
+
+	req, _ := http.NewRequest("PUT", server.URL+"/x?a=1&a=2", strings.NewReader("payload"))
+	req.Header.Set("X-Token", "t")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 201 || resp.Header.Get("X-Path") != "/x" || string(body) != "PUT [1 2] t payload" {
+		t.Errorf("unexpected response %d %v %q", resp.StatusCode, resp.Header, body)
+	}
+
+	// Each request gets its own environment, so the handler's
+	// `let` never touches the global count.
+	for i := 0; i < 2; i++ {
+		resp, err = http.Get(server.URL + "/text")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != 200 || string(body) != "plain 1" {
+			t.Errorf("unexpected response %d %q", resp.StatusCode, body)
+		}
+	}
+
+	// Handlers which fail, give a status net/http can't send, give
+	// a bad header, or return nothing are internal errors, with
+	// nothing of what they did give.
+	for _, path := range []string{"/fail", "/status", "/none", "/headers"} {
+		rec := httptest.NewRecorder()
+		server.Config.Handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != 500 || rec.Header().Get("A") != "" ||
+			rec.Body.String() != http.StatusText(500)+"\n" {
+			t.Errorf("%s: expected a plain 500, got %d %v %q", path, rec.Code, rec.Header(), rec.Body.String())
+		}
+	}
+
+	errors := map[string]string{
+		`http.serve("no-such-host:http-x", fn(r) {})`: "http.serve:",
+		`http.serve(":0", 1)`:                         "must be FUNCTION",
+	}
+	for input, message := range errors {
+		evaluated := testEval(input)
+		err, ok := evaluated.(*object.Error)
+		if !ok || !strings.Contains(err.Message, message) {
+			t.Errorf("%s: expected error containing %q, got=%s", input, message, evaluated.Inspect())
+		}
+	}
+}
+
+func TestBytesAndFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data")
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`bytes("hé")`, `b"hé"`},
+		{`bytes([104, 195])`, `b"h\xc3"`},
+		{`len(bytes("hé"))`, "3"},
+		{`bytes([104, 105]).to_string()`, "hi"},
+		{`bytes("abc")[1]`, "98"},
+		{`bytes("abc")[3]`, "null"},
+		{`bytes("abcd")[1:3]`, `b"bc"`},
+		{`bytes("a") + bytes([0])`, `b"a\x00"`},
+		{`bytes("ab") == bytes([97, 98])`, "true"},
+		{`bytes("ab").to_array()`, "[97, 98]"},
+		{`let f = open(path, "w"); f.write("one\ntwo\n"); f.write(bytes([0, 255])); f.close(); let f = open(path); [f.read(), f.read(2), f.tell(), f.read_bytes()]`,
+			`[one
+, tw, 6, b"o\n\x00\xff"]`},
+		{`let f = open(path, "w+"); f.write("abcdef"); f.seek(-2, 2); [f.read_all(), f.read(), f.read(3), f.read_bytes(1)]`, `[ef, , , b""]`},
+		{`let f = open(path, "w+"); f.write("abcdef"); f.seek(2); f.truncate(); f.rewind(); f.read_all()`, "ab"},
+		{`let f = open(path, "w"); f.write("a\nb"); f.flush(); open(path).lines()`, "[a\n, b]"},
+		{`let f = open(path, "w"); f.write(1.5); f.close(); open(path).read_all()`, "1.5"},
+		{`let f = open(path, "w"); f.write("hello\n"); open(path).read_all()`, "hello\n"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(fmt.Sprintf("let path = %q; %s", path, tt.input))
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	errors := map[string]string{
+		`let f = open(path, "w"); f.close(); f.write("x")`: "is closed",
+		`let f = open(path, "w"); f.close(); f.close()`:    "already closed",
+		`open(path, "w"); open(path).write("x")`:           "not open for writing",
+		`open(path, "w"); open(path, "x")`:                 "file exists",
+		`open(path + ".missing")`:                          "no such file",
+		`open(path, "z")`:                                  "invalid file mode",
+		`open(path, "w").read_bytes(-1)`:                   "non-negative INTEGER",
+		`bytes([1, 300])`:                                  "integers 0-255",
+		`bytes(1)`:                                         "must be STRING, ARRAY or BYTES",
+	}
+	for input, message := range errors {
+		evaluated := testEval(fmt.Sprintf("let path = %q; %s", path, input))
+		err, ok := evaluated.(*object.Error)
+		if !ok || !strings.Contains(err.Message, message) {
+			t.Errorf("%s: expected error containing %q, got=%s", input, message, evaluated.Inspect())
+		}
+	}
+}
+
+func TestFilesystem(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a/b/c.go", "a/d.go", "a/e.txt", "f.go"} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(name), 0640); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`fs.read_dir(dir)`, "[a, f.go]"},
+		{`fs.exists(path.join(dir, "a", "d.go"))`, "true"},
+		{`fs.exists(path.join(dir, "nope"))`, "false"},
+		{`let seen = chan(10); fs.walk(dir, fn(p, info) { seen.send(path.rel(dir, p) + ":" + info["type"]); }); seen.close(); iter(seen).to_array()`,
+			"[.:directory, a:directory, a/b:directory, a/b/c.go:file, a/d.go:file, a/e.txt:file, f.go:file]"},
+		{`let seen = chan(10); fs.walk(dir, fn(p, info) { seen.send(p); return path.base(p) != "b"; }); seen.len()`, "6"},
+		{`map(iter(directory.glob(dir + "/**/*.go")), fn(p) { path.rel(dir, p) }).to_array()`, "[a/b/c.go, a/d.go, f.go]"},
+		{`map(iter(directory.glob(dir + "/a/**")), fn(p) { path.rel(dir, p) }).to_array()`, "[a/b, a/b/c.go, a/d.go, a/e.txt]"},
+		{`len(directory.glob(dir + "/*.go"))`, "1"},
+		{`fs.copy(path.join(dir, "a"), path.join(dir, "copy")); fs.read_dir(path.join(dir, "copy", "b"))`, "[c.go]"},
+		{`fs.copy(path.join(dir, "f.go"), path.join(dir, "a")); stat(path.join(dir, "a", "f.go"))["mode"]`, "0640"},
+		{`fs.rename(path.join(dir, "copy"), path.join(dir, "moved")); [fs.exists(path.join(dir, "copy")), fs.exists(path.join(dir, "moved/d.go"))]`, "[false, true]"},
+		{`fs.remove_all(path.join(dir, "moved")); fs.remove_all(path.join(dir, "moved")); fs.exists(path.join(dir, "moved"))`, "false"},
+		{`mkdir(path.join(dir, "x/y/z"), "700", true); stat(path.join(dir, "x/y/z"))["mode"]`, "0700"},
+		{`[mkdir(path.join(dir, "p/q")), fs.exists(path.join(dir, "p"))]`, "[false, false]"},
+		{`[mkdir(path.join(dir, "p")), mkdir(path.join(dir, "p")), mkdir(path.join(dir, "p"), true)]`, "[true, false, true]"},
+		{`[mkdir(path.join(dir, "r/s"), true), fs.exists(path.join(dir, "r/s"))]`, "[true, true]"},
+		{`let d = fs.temp_dir("nk*"); let ok = fs.exists(d) && path.base(d).len() > 2; fs.remove_all(d); ok`, "true"},
+		{`let f = fs.temp_file(); let ok = stat(f)["size"] == 0; unlink(f); ok`, "true"},
+		{`[path.base("/a/b.c"), path.dir("/a/b.c"), path.ext("/a/b.c"), path.clean("/a/../b//c/")]`, "[b.c, /a, .c, /b/c]"},
+		{`path.rel("/a/b", "/a/c/d")`, "../c/d"},
+		{`path.abs("/x/./y")`, "/x/y"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(fmt.Sprintf("let dir = %q; %s", dir, tt.input))
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	errors := map[string]string{
+		`fs.walk(dir, fn(p, info) { 1 / "x" })`:    "type mismatch",
+		`fs.walk(path.join(dir, "nope"), fn() {})`: "fs.walk:",
+		`fs.copy(path.join(dir, "nope"), dir)`:     "fs.copy:",
+		`fs.rename(path.join(dir, "nope"), dir)`:   "fs.rename:",
+		`fs.read_dir(1)`:                           "must be STRING",
+		`path.rel("a", "/b")`:                      "path.rel:",
+		`mkdir(dir, "9")`:                          "invalid mode",
+		`mkdir(dir, "755", "p")`:                   "must be a STRING mode and a BOOLEAN",
+		`directory.glob(1)`:                        "must be STRING",
+	}
+	for input, message := range errors {
+		evaluated := testEval(fmt.Sprintf("let dir = %q; %s", dir, input))
+		err, ok := evaluated.(*object.Error)
+		if !ok || !strings.Contains(err.Message, message) {
+			t.Errorf("%s: expected error containing %q, got=%s", input, message, evaluated.Inspect())
+		}
+	}
+}
+
+func TestExec(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let r = exec.run(["sh", "-c", "echo out; echo err >&2; exit 3"]); [r["exit_code"], r["stdout"] == "out\n", r["stderr"] == "err\n"]`,
+			"[3, true, true]"},
+		{`exec.run(["cat"], {"stdin": "some input"})["stdout"]`, "some input"},
+		{`exec.run(["cat"], {"stdin": bytes([104, 105])})["stdout"]`, "hi"},
+		{`exec.run(["sh", "-c", "echo -n $NK_TEST"], {"env": {"NK_TEST": "set"}})["stdout"]`, "set"},
+		{`exec.run(["pwd"], {"dir": dir})["stdout"] == dir + "\n"`, "true"},
+		{`exec.run("printf '%s|' 'a b' \"c \\\"d\\\"\" e\\ f")["stdout"]`, `a b|c "d"|e f|`},
+		{`type(exec.run(["true"])["duration"])`, "FLOAT"},
+		{"let r = `sh -c \"echo hi; exit 2\"`; [r[\"exit_code\"], r[\"stdout\"] == \"hi\\n\"]", "[2, true]"},
+		{`let p = exec.start(["cat"]); p.write("one\n"); let a = p.read_line(); p.write("two\n"); p.close(); let r = p.wait(); [a == "one\n", r["stdout"] == "two\n", r["exit_code"]]`,
+			"[true, true, 0]"},
+		{`let p = exec.start(["sleep", "10"]); p.kill(); p.wait()["exit_code"]`, "-1"},
+		{`let p = exec.start(["echo", "x"]); p.read_line(); p.read_line() == ""`, "true"},
+		{`type(exec.start(["true"]))`, "PROCESS"},
+		{`exec.run(["echo", "quick"], {"timeout": 5})["stdout"]`, "quick\n"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(fmt.Sprintf("let dir = %q; %s", dir, tt.input))
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	errors := map[string]string{
+		`exec.run(["sleep", "10"], {"timeout": 0.1})`: "timed out",
+		`exec.run(["/no/such/command"])`:              "exec.run:",
+		`exec.run([])`:                                "empty command",
+		`exec.run("echo 'oops")`:                      "unterminated quote",
+		`exec.run(["echo"], {"shell": true})`:         "unknown or invalid option shell",
+		`exec.run(1)`:                                 "must be ARRAY or STRING",
+		`exec.start(["cat"], {"stdin": "x"})`:         "only supported by exec.run",
+		"`/no/such/command`":                          "backtick:",
+	}
+	for input, message := range errors {
+		evaluated := testEval(fmt.Sprintf("let dir = %q; %s", dir, input))
+		err, ok := evaluated.(*object.Error)
+		if !ok || !strings.Contains(err.Message, message) {
+			t.Errorf("%s: expected error containing %q, got=%s", input, message, evaluated.Inspect())
+		}
+	}
+
+	// A timeout also kills whatever the command started, which would
+	// otherwise keep its output open.
+	start := time.Now()
+	evaluated := testEval(`exec.run(["sh", "-c", "sleep 3; echo hi"], {"timeout": 0.2})`)
+	if err, ok := evaluated.(*object.Error); !ok || !strings.Contains(err.Message, "timed out") {
+		t.Errorf("expected a timeout, got=%s", evaluated.Inspect())
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("expected the timeout to end the command, took %s", d)
+	}
+}
+
+func TestTime(t *testing.T) {
+	clock := time.Date(2024, 2, 28, 23, 30, 0, 0, time.UTC)
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`time.now()`, "2024-02-28T23:30:00Z"},
+		{`time.now() + time.duration("1h")`, "2024-02-29T00:30:00Z"},
+		{`time.now() - time.duration(90)`, "2024-02-28T23:28:30Z"},
+		{`time.duration("1m") + time.now()`, "2024-02-28T23:31:00Z"},
+		{`time.since(time.date(2024, 2, 28))`, "23h30m0s"},
+		{`time.now().format("DateOnly")`, "2024-02-28"},
+		{`time.now().format("Mon Jan 2 15:04")`, "Wed Feb 28 23:30"},
+		{`time.now().in("Asia/Seoul").format()`, "2024-02-29T08:30:00+09:00"},
+		{`time.now().in("-05:30").format("15:04 -0700")`, "18:00 -0530"},
+		{`time.now().in("Asia/Seoul") == time.now()`, "true"},
+		{`let t = time.now(); t += time.duration("24h"); t.day()`, "29"},
+		{`time.parse("2006-01-02 15:04", "2024-03-01 12:00") - time.now()`, "36h30m0s"},
+		{`time.parse("RFC3339", "2024-03-01T12:00:00+01:00").utc()`, "2024-03-01T11:00:00Z"},
+		{`time.parse("DateTime", "2024-03-01 12:00:00", "Europe/Paris").unix()`, "1709290800"},
+		{`time.unix(1709290800).hour()`, "11"},
+		{`time.unix(0, 1500000).unix_ms()`, "1"},
+		{`time.date(2024, 10, 32).format("DateOnly")`, "2024-11-01"},
+		{`time.date(2024, 1, 1, 9, 0, 0, 0, "+09:00").utc()`, "2024-01-01T00:00:00Z"},
+		{`[time.now() < time.now() + time.duration(1), time.now() >= time.now(), time.now() > time.date(2025, 1, 1)]`, "[true, true, false]"},
+		{`[time.now().year(), time.now().month(), time.now().weekday(), time.now().yday()]`, "[2024, 2, Wednesday, 59]"},
+		{`time.duration("1h30m") * 2`, "3h0m0s"},
+		{`3 * time.duration("20m")`, "1h0m0s"},
+		{`time.duration("1h") / 4`, "15m0s"},
+		{`time.duration("1h") / time.duration("20m")`, "3"},
+		{`-time.duration("2s") + time.duration("500ms")`, "-1.5s"},
+		{`time.duration(1.5).milliseconds()`, "1500"},
+		{`time.duration("90m").hours()`, "1.5"},
+		{`time.duration("1h") > time.duration("59m")`, "true"},
+		{`time.now().truncate(time.duration("1h")).format("15:04")`, "23:00"},
+		{`json.encode({"at": time.now()})`, `{"at":"2024-02-28T23:30:00Z"}`},
+		{`let h = {}; h[time.now()] = 1; h[time.now().in("UTC")]`, "1"},
+		{`[type(time.now()), type(time.duration(1))]`, "[TIME, DURATION]"},
+	}
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		env.SetClock(func() time.Time { return clock })
+		evaluated := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), env)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	errors := map[string]string{
+		`time.parse("DateOnly", "yesterday")`: "time.parse:",
+		`time.now().in("Nowhere/Special")`:    "unknown time zone",
+		`time.now().in("+25:00")`:             "invalid time zone offset",
+		`time.duration("soon")`:               "time.duration:",
+		`time.now() + 1`:                      "type mismatch: TIME + INTEGER",
+		`time.now() * time.duration("1s")`:    "type mismatch: TIME * DURATION",
+		`time.duration("1s") / 0`:             "division by zero",
+		`time.duration("1s") < 1`:             "type mismatch: DURATION < INTEGER",
+		`time.date(2024)`:                     "wrong number of arguments",
+		`time.since(1)`:                       "must be TIME",
+		`time.now().add(1)`:                   "must be DURATION",
+	}
+	for input, message := range errors {
+		evaluated := testEval(input)
+		err, ok := evaluated.(*object.Error)
+		if !ok || !strings.Contains(err.Message, message) {
+			t.Errorf("%s: expected error containing %q, got=%s", input, message, evaluated.Inspect())
+		}
+	}
+}
+
+func TestErrorLog(t *testing.T) {
+	input := `function f(x) { return x + "y"; }
+f(1);`
+	var log bytes.Buffer
+	env := object.NewEnvironment()
+	env.SetErrorLog(&log)
+	Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+	if !strings.Contains(log.String(), "type mismatch: INTEGER + STRING") {
+		t.Errorf("expected the error in the log, got %q", log.String())
+	}
+}
+
+func TestTraceback(t *testing.T) {
+	input := `function inner(x) {
+	return x.nosuch();
+}
+let outer = fn(x) {
+	let y = x + 1;
+	return inner(y);
+};
+function string.twice() { return outer(2); }
+"a".twice();
+`
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	err, ok := Eval(program, object.NewEnvironment()).(*object.Error)
+	if !ok {
+		t.Fatalf("expected an error")
+	}
+
+	var names []string
+	for _, frame := range err.Stack {
+		names = append(names, frame.Name)
+	}
+	if strings.Join(names, " ") != "inner outer string.twice" {
+		t.Errorf("wrong stack %v", names)
+	}
+
+	expected := `Traceback (most recent call last):
+  line 9, in main
+    "a".twice();
+  line 8, in string.twice
+    function string.twice() { return outer(2); }
+  line 6, in outer
+    return inner(y);
+  line 2, in inner
+    return x.nosuch();
+error: Failed to invoke method: nosuch
+`
+	if got := err.Traceback(l.GetLineStr); got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+
+	// try() gives scripts the same stack.
+	input = strings.TrimSuffix(input, "\"a\".twice();\n")
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input + `let r = try(fn() { "a".twice() }); [r["ok"], r["error"]]`,
+			"[false, Failed to invoke method: nosuch]"},
+		{input + `let s = try(fn() { "a".twice() })["stack"]; [len(s), s[0]["function"], s[0]["line"], s[4]["function"], s[4]["line"]]`,
+			"[5, main, 9, inner, 2]"},
+		{`let r = try(fn(a, b) { a + b }, 1, 2); [r["ok"], r["value"], r["error"], r["stack"]]`,
+			"[true, 3, null, []]"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+	if res := testEval(`try(1)`); !strings.Contains(res.Inspect(), "must be FUNCTION") {
+		t.Errorf("expected an error, got=%s", res.Inspect())
+	}
+}
+
+func TestAssertions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // the error, or empty if it passes
+	}{
+		{`assert(1 < 2)`, ""},
+		{`assert(1 > 2)`, "assert failed: got false"},
+		{`assert(false, "why")`, "assert failed: why: got false"},
+		{`let x = 2; assert("x == 2")`, ""},
+		{`assert("1 == 2")`, "assert failed: \"1 == 2\" gave false"},
+		{`assert("nosuch")`, "identifier not found: nosuch"},
+		{`assert("1 +* 2")`, "assert: can't parse \"1 +* 2\": no prefix parse function for *"},
+		{`assert_eq([1, 2.0], [1, 2])`, ""},
+		{`assert_eq("1", 1)`, "assert_eq failed: got \"1\", want 1"},
+		{`assert_eq({"a": 1}, {"a": 2}, "hash")`, "assert_eq failed: hash: got {a: 1}, want {a: 2}"},
+		{`assert_eq("a\nb\nc", "a\nB\nc\nd")`, "assert_eq failed: strings differ:\n--- want\n+++ got\n  a\n- B\n+ b\n  c\n- d"},
+		{`assert_ne(1, 2)`, ""},
+		{`assert_ne(1, 1.0)`, "assert_ne failed: got 1, which it shouldn't be"},
+		{`assert_raises(fn() { return 1 + "a"; }, "mismatch")`, ""},
+		{`assert_raises(fn() { return 1; })`, "assert_raises failed: got 1, want an error"},
+		{`assert_raises(fn() { assert(false); }, "nosuch")`, "assert_raises failed: got error \"assert failed: got false\", want one containing \"nosuch\""},
+		{`assert_raises(1)`, "argument to `assert_raises` must be FUNCTION, got=INTEGER"},
+		{`assert()`, "wrong number of arguments. got=0, want=1 or 2"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		err, ok := evaluated.(*object.Error)
+		switch {
+		case tt.expected == "" && ok:
+			t.Errorf("%s: expected to pass, got %s", tt.input, err.Message)
+		case tt.expected != "" && !ok:
+			t.Errorf("%s: expected %q, got %v", tt.input, tt.expected, evaluated)
+		case ok && err.Message != tt.expected:
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected, err.Message)
+		}
+	}
+
+	if msg := testEval(`assert_raises(fn() { return nosuch; })`); msg.Inspect() != "identifier not found: nosuch" {
+		t.Errorf("expected assert_raises to return the message, got %s", msg.Inspect())
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd
+
+package evaluator
+
+import "os/exec"
+
+// newProcessGroup does nothing here; only cmd itself can be killed.
+func newProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the started cmd.
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,21 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+// +build linux darwin freebsd netbsd openbsd
+
+package evaluator
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// newProcessGroup makes cmd start in a process group of its own, so
+// killProcessGroup can reach anything it starts too.
+func newProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup kills the started cmd, and whatever it has started
+// in turn; those may be holding its output open.
+func killProcessGroup(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
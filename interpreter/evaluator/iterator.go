@@ -0,0 +1,129 @@
+package evaluator
+
+import (
+	"math"
+	"runtime"
+	"sync"
+
+	"github.com/kasworld/nonkey/interpreter/ast"
+	"github.com/kasworld/nonkey/interpreter/asti"
+	"github.com/kasworld/nonkey/interpreter/object"
+)
+
+// newGenerator runs the body of the generator function fn in env and
+// returns an iterator over the values it yields.
+//
+// The body runs on its own goroutine, but only while the consumer is
+// waiting for the next value, so the two never run at the same time.
+// If the iterator is closed before the body finishes, the pending
+// yield returns from the body instead of resuming it, and Close waits
+// for it to unwind.  Iterators nobody closes are closed when they're
+// garbage collected.
+func newGenerator(fn *object.Function, env *object.Environment) *object.Iterator {
+	items := make(chan object.ObjectI)
+	resume := make(chan struct{})
+	abandon := make(chan struct{})
+	finished := make(chan struct{})
+
+	env.SetYield(func(val object.ObjectI) bool {
+		select {
+		case items <- val:
+		case <-abandon:
+			return false
+		}
+		select {
+		case <-resume:
+			return true
+		case <-abandon:
+			return false
+		}
+	})
+
+	var once sync.Once
+	started := false
+	var count int64
+	it := object.NewIterator(func() (object.ObjectI, object.ObjectI, bool) {
+		if !started {
+			started = true
+			go func() {
+				res := withStack(Eval(fn.Body, env), env)
+				if object.IsError(res) {
+					select {
+					case items <- res:
+					case <-abandon:
+					}
+				}
+				close(items)
+				close(finished)
+			}()
+		} else {
+			resume <- struct{}{}
+		}
+		val, ok := <-items
+		if !ok {
+			return nil, &object.Integer{Value: 0}, false
+		}
+		count++
+		return val, &object.Integer{Value: count - 1}, true
+	})
+	it.OnClose(func() {
+		once.Do(func() { close(abandon) })
+		if started {
+			<-finished
+		}
+	})
+	runtime.SetFinalizer(it, func(*object.Iterator) {
+		once.Do(func() { close(abandon) })
+	})
+	return it
+}
+
+// evalYieldStatement hands a value to the consumer of the running
+// generator, and waits until the next value is wanted.
+func evalYieldStatement(node *ast.YieldStatement, env *object.Environment) object.ObjectI {
+	yield := env.Yield()
+	if yield == nil {
+		return object.NewError(node, "yield outside of a generator")
+	}
+	var val object.ObjectI = object.NULL
+	if node.Value != nil {
+		val = Eval(node.Value, env)
+		if object.IsError(val) {
+			return val
+		}
+	}
+	if !yield(val) {
+		// Nobody will ask for more; unwind the generator body.
+		return &object.ReturnValue{Value: object.NULL}
+	}
+	return object.NULL
+}
+
+// evalRangeExpression builds the lazy range `start..end`.  Integral
+// floats are accepted as bounds, so `1..1e9` works.
+func evalRangeExpression(node asti.NodeI, left, right object.ObjectI) object.ObjectI {
+	start, ok := rangeBound(left)
+	if !ok {
+		return object.NewError(node, "range bound must be INTEGER, got %s", left.Inspect())
+	}
+	end, ok := rangeBound(right)
+	if !ok {
+		return object.NewError(node, "range bound must be INTEGER, got %s", right.Inspect())
+	}
+	if start <= end && uint64(end)-uint64(start) >= math.MaxInt64 {
+		return object.NewError(node, "range %d..%d is too long", start, end)
+	}
+	return &object.Range{Start: start, End: end}
+}
+
+func rangeBound(obj object.ObjectI) (int64, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.Value, true
+	case *object.Float:
+		if obj.Value == float64(int64(obj.Value)) {
+			return int64(obj.Value), true
+		}
+	}
+	return 0, false
+}
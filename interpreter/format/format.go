@@ -0,0 +1,707 @@
+// Package format prints programs in a canonical layout, keeping their
+// comments.
+//
+// Statements go one to a line, indented by four spaces for each block
+// they're in, with single blank lines kept where the source had any.
+// Anything written on one line stays on one line, while the elements
+// of a list split across lines keep their line breaks.
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/kasworld/nonkey/enum/precedence"
+	"github.com/kasworld/nonkey/enum/tokentype"
+	"github.com/kasworld/nonkey/interpreter/ast"
+	"github.com/kasworld/nonkey/interpreter/asti"
+	"github.com/kasworld/nonkey/interpreter/lexer"
+	"github.com/kasworld/nonkey/interpreter/parser"
+	"github.com/kasworld/nonkey/interpreter/token"
+)
+
+// indent is the text each level of blocks is indented by.
+const indent = "    "
+
+// Source formats the program src.
+//
+// Programs which don't parse are reported as an error, as is output
+// which wouldn't parse to the same program; neither should happen.
+func Source(src string) (string, error) {
+	program, err := parse(src)
+	if err != nil {
+		return "", err
+	}
+
+	p := newPrinter(src)
+	p.statements(program.Statements, token.Token{Line: len(p.lines)})
+	if !p.atStart {
+		p.newline()
+	}
+	out := p.buf.String()
+
+	again, err := parse(out)
+	if err != nil || again.String() != program.String() {
+		return "", fmt.Errorf("formatting changed the program")
+	}
+	return out, nil
+}
+
+// parse parses src, returning its first error if it has any.
+func parse(src string) (*ast.Program, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return nil, fmt.Errorf("%d:%d: %s", errs[0].Line+1, errs[0].Pos, errs[0].Msg)
+	}
+	return program, nil
+}
+
+// printer holds the state of formatting one program.
+type printer struct {
+	buf bytes.Buffer
+
+	// depth is the number of levels lines are indented by.
+	depth int
+
+	// atStart is set at the start of an output line, and fresh
+	// until something has been printed inside the current block.
+	atStart bool
+	fresh   bool
+
+	// lines holds the source, by line, to find blank lines.
+	lines []string
+
+	// toks holds the tokens of the source, without comments, and
+	// index finds a token there.  Tokens are looked up whole, as an
+	// identifier and the bracket after it have the same position.
+	toks  []token.Token
+	index map[token.Token]int
+
+	// closers maps the index of each opening bracket to that of
+	// the bracket which closes it.
+	closers map[int]int
+
+	// comments holds the comments of the source, and trailing
+	// marks those which follow code on their line.  The comments
+	// before next have been printed.
+	comments []token.Token
+	trailing []bool
+	next     int
+
+	// glued is set when a comment has been printed in front of
+	// the code which followed it on its line.
+	glued bool
+}
+
+func newPrinter(src string) *printer {
+	p := &printer{
+		atStart: true,
+		fresh:   true,
+		lines:   strings.Split(src, "\n"),
+		index:   make(map[token.Token]int),
+		closers: make(map[int]int),
+	}
+
+	l := lexer.NewWithComments(src)
+	var open []int
+	for tok := l.NextToken(); tok.Type != tokentype.EOF; tok = l.NextToken() {
+		if tok.Type == tokentype.COMMENT {
+			last := len(p.toks) - 1
+			p.comments = append(p.comments, tok)
+			p.trailing = append(p.trailing, last >= 0 && p.toks[last].Line == tok.Line)
+			continue
+		}
+		i := len(p.toks)
+		p.toks = append(p.toks, tok)
+		p.index[tok] = i
+		switch tok.Type {
+		case tokentype.LPAREN, tokentype.LBRACKET, tokentype.LBRACE:
+			open = append(open, i)
+		case tokentype.RPAREN, tokentype.RBRACKET, tokentype.RBRACE:
+			if len(open) > 0 {
+				p.closers[open[len(open)-1]] = i
+				open = open[:len(open)-1]
+			}
+		}
+	}
+	return p
+}
+
+// write adds text to the current line, indenting it if it's the
+// first thing there.
+func (p *printer) write(text string) {
+	if p.atStart {
+		p.buf.WriteString(strings.Repeat(indent, p.depth))
+		p.atStart = false
+	}
+	p.buf.WriteString(text)
+	p.fresh = false
+}
+
+// newline ends the current line.
+func (p *printer) newline() {
+	p.buf.WriteString("\n")
+	p.atStart = true
+}
+
+// breakLine starts a new line for something which was on line in the
+// source, leaving a blank line before it if the source did.
+func (p *printer) breakLine(line int) {
+	if p.glued {
+		p.glued = false
+		return
+	}
+	if !p.atStart {
+		p.newline()
+	}
+	if !p.fresh && line > 0 && line <= len(p.lines) && strings.TrimSpace(p.lines[line-1]) == "" {
+		p.newline()
+	}
+}
+
+// flush prints the comments from before tok.  Those which followed
+// code go at the end of the current line, and those leading the line
+// of tok stay in front of it, while the rest go on lines of their own.
+func (p *printer) flush(tok token.Token) {
+	for p.pending(tok) {
+		c := p.comments[p.next]
+		trailing := p.trailing[p.next]
+		p.next++
+		switch {
+		case trailing && !p.atStart:
+			p.buf.WriteString(" " + c.Literal)
+		case c.Line == tok.Line:
+			p.breakLine(c.Line)
+			p.write(c.Literal + " ")
+			p.glued = true
+			continue
+		default:
+			p.breakLine(c.Line)
+			p.write(c.Literal)
+		}
+		if !strings.HasPrefix(c.Literal, "/*") {
+			p.newline()
+		}
+	}
+}
+
+// pending reports whether there are comments before tok left to
+// print.
+func (p *printer) pending(tok token.Token) bool {
+	if p.next >= len(p.comments) {
+		return false
+	}
+	c := p.comments[p.next]
+	return c.Line < tok.Line || c.Line == tok.Line && c.Pos < tok.Pos
+}
+
+// pos returns the index of tok among the tokens of the source.
+func (p *printer) pos(tok token.Token) int {
+	return p.index[tok]
+}
+
+// closer returns the token closing the bracket tok.
+func (p *printer) closer(tok token.Token) token.Token {
+	return p.toks[p.closers[p.pos(tok)]]
+}
+
+// first returns the first token of a statement or expression.
+func (p *printer) first(node asti.NodeI) token.Token {
+	switch n := node.(type) {
+	case *ast.ExpressionStatement:
+		if n.Expression != nil {
+			return p.first(n.Expression)
+		}
+	case *ast.InfixExpression:
+		return p.first(n.Left)
+	case *ast.CallExpression:
+		return p.first(n.Function)
+	case *ast.ObjectCallExpression:
+		return p.first(n.Object)
+	case *ast.IndexExpression:
+		return p.first(n.Left)
+	case *ast.SliceExpression:
+		return p.first(n.Left)
+	case *ast.TernaryExpression:
+		return p.first(n.Condition)
+	case *ast.AssignStatement:
+		if n.Target != nil {
+			return p.first(n.Target)
+		}
+		return n.Name.Token
+	case *ast.FunctionDefineLiteral:
+		// The token is the name, after `function`.
+		if i := p.pos(n.Token); i > 0 {
+			return p.toks[i-1]
+		}
+	}
+	return node.GetToken()
+}
+
+// statements prints a list of statements, one to a line, and then
+// the comments before end, which follows them.
+func (p *printer) statements(stmts []asti.StatementI, end token.Token) {
+	for i, stmt := range stmts {
+		if i+1 < len(stmts) && postfixed(stmt, stmts[i+1]) {
+			continue
+		}
+
+		first := p.first(stmt)
+		p.flush(first)
+		p.breakLine(first.Line)
+		p.statement(stmt)
+		if endsWithBlock(stmt) && i+1 < len(stmts) && p.continues(stmts[i+1]) {
+			p.write(";")
+		}
+	}
+	p.flush(end)
+}
+
+// postfixed reports whether stmt is the `x` of an `x++` or `x--`,
+// which parses as `x` followed by a postfix expression that prints
+// both.
+func postfixed(stmt, next asti.StatementI) bool {
+	es, ok := stmt.(*ast.ExpressionStatement)
+	if !ok {
+		return false
+	}
+	if _, ok := es.Expression.(*ast.Identifier); !ok {
+		return false
+	}
+	es, ok = next.(*ast.ExpressionStatement)
+	if !ok {
+		return false
+	}
+	_, ok = es.Expression.(*ast.PostfixExpression)
+	return ok
+}
+
+// single returns the only statement of a list, if it has only one.
+func single(stmts []asti.StatementI) asti.StatementI {
+	switch {
+	case len(stmts) == 1:
+		return stmts[0]
+	case len(stmts) == 2 && postfixed(stmts[0], stmts[1]):
+		return stmts[1]
+	}
+	return nil
+}
+
+// endsWithBlock reports whether stmt ends with a block, and so is
+// printed without a semicolon.
+func endsWithBlock(stmt asti.StatementI) bool {
+	es, ok := stmt.(*ast.ExpressionStatement)
+	if !ok {
+		return false
+	}
+	switch es.Expression.(type) {
+	case *ast.IfExpression, *ast.ForLoopExpression, *ast.ForeachStatement,
+		*ast.FunctionDefineLiteral, *ast.SwitchExpression, *ast.SelectExpression:
+		return true
+	}
+	return false
+}
+
+// continues reports whether stmt starts with a token which would
+// continue an expression before it, so that needs a semicolon.
+func (p *printer) continues(stmt asti.StatementI) bool {
+	es, ok := stmt.(*ast.ExpressionStatement)
+	if !ok {
+		return false
+	}
+	e := es.Expression
+	for {
+		if prec(e) < precedence.LOWEST {
+			return true
+		}
+		switch n := e.(type) {
+		case *ast.InfixExpression:
+			if prec(n.Left) < infixPrec(n.Operator) {
+				return true
+			}
+			e = n.Left
+		case *ast.CallExpression:
+			e = n.Function
+		case *ast.ObjectCallExpression:
+			e = n.Object
+		case *ast.IndexExpression:
+			e = n.Left
+		case *ast.SliceExpression:
+			e = n.Left
+		case *ast.TernaryExpression:
+			if prec(n.Condition) < precedence.TERNARY {
+				return true
+			}
+			e = n.Condition
+		case *ast.PrefixExpression:
+			return n.Operator == tokentype.MINUS
+		case *ast.ArrayLiteral:
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// statement prints one statement.
+func (p *printer) statement(stmt asti.StatementI) {
+	switch s := stmt.(type) {
+	case *ast.LetStatement:
+		p.write("let " + s.Name.Value + " = ")
+		p.expr(s.Value, precedence.LOWEST)
+		p.write(";")
+	case *ast.ConstStatement:
+		p.write("const " + s.Name.Value + " = ")
+		p.expr(s.Value, precedence.LOWEST)
+		p.write(";")
+	case *ast.ReturnStatement:
+		p.write("return ")
+		p.expr(s.ReturnValue, precedence.LOWEST)
+		p.write(";")
+	case *ast.YieldStatement:
+		p.write("yield")
+		if s.Value != nil {
+			p.write(" ")
+			p.expr(s.Value, precedence.LOWEST)
+		}
+		p.write(";")
+	case *ast.ExpressionStatement:
+		if s.Expression == nil {
+			return
+		}
+		p.expr(s.Expression, precedence.LOWEST)
+		if !endsWithBlock(s) {
+			p.write(";")
+		}
+	}
+}
+
+// block prints a block, on one line if it has a single statement
+// which the source had on one line.
+func (p *printer) block(b *ast.BlockStatement) {
+	end := p.closer(b.Token)
+	switch {
+	case len(b.Statements) == 0 && !p.pending(end):
+		p.write("{}")
+	case single(b.Statements) != nil && end.Line == b.Token.Line && !p.pending(end):
+		p.write("{ ")
+		p.statement(single(b.Statements))
+		p.write(" }")
+	default:
+		p.write("{")
+		p.depth++
+		p.fresh = true
+		p.statements(b.Statements, end)
+		p.depth--
+		p.breakLine(0)
+		p.write("}")
+	}
+}
+
+// prec returns the precedence of an expression, for deciding whether
+// it needs brackets.  Those which can't take another operand, such as
+// calls and literals, have the highest.
+func prec(e asti.ExpressionI) precedence.Precedence {
+	switch n := e.(type) {
+	case *ast.InfixExpression:
+		return infixPrec(n.Operator)
+	case *ast.AssignStatement:
+		return precedence.LOWEST
+	case *ast.TernaryExpression:
+		return precedence.TERNARY
+	case *ast.PrefixExpression, *ast.SpawnExpression:
+		return precedence.PREFIX
+	}
+	return precedence.HIGHEST
+}
+
+func infixPrec(op tokentype.TokenType) precedence.Precedence {
+	return tokentype.Token2Precedences[op]
+}
+
+// expr prints an expression, in brackets if its precedence is lower
+// than min.
+func (p *printer) expr(e asti.ExpressionI, min precedence.Precedence) {
+	if prec(e) < min {
+		p.write("(")
+		defer p.write(")")
+	}
+
+	switch n := e.(type) {
+	case *ast.Identifier:
+		p.write(n.Value)
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.Boolean:
+		p.write(n.GetToken().Literal)
+	case *ast.StringLiteral:
+		p.stringLiteral(n)
+	case *ast.RegexpLiteral:
+		p.write("/" + n.Value + "/" + n.Flags)
+	case *ast.BacktickLiteral:
+		p.write("`" + n.Value + "`")
+	case *ast.PrefixExpression:
+		p.write(n.Operator.Literal())
+		p.expr(n.Right, precedence.PREFIX+1)
+	case *ast.PostfixExpression:
+		p.write(n.Token.Literal + n.Operator.Literal())
+	case *ast.InfixExpression:
+		op := infixPrec(n.Operator)
+		p.expr(n.Left, op)
+		if _, ok := n.Left.(*ast.IntegerLiteral); ok && n.Operator == tokentype.DOTDOT {
+			p.write(n.Operator.Literal())
+		} else {
+			p.write(" " + n.Operator.Literal() + " ")
+		}
+		p.expr(n.Right, op+1)
+	case *ast.AssignStatement:
+		if n.Target != nil {
+			p.expr(n.Target, precedence.LOWEST)
+		} else {
+			p.write(n.Name.Value)
+		}
+		p.write(" " + n.Operator.Literal() + " ")
+		p.expr(n.Value, precedence.LOWEST)
+	case *ast.TernaryExpression:
+		p.expr(n.Condition, precedence.TERNARY)
+		p.write(" ? ")
+		p.expr(n.IfTrue, precedence.TERNARY+1)
+		p.write(" : ")
+		p.expr(n.IfFalse, precedence.TERNARY+1)
+	case *ast.CallExpression:
+		p.expr(n.Function, precedence.CALL)
+		p.list(n.Token, n.Arguments, nil)
+	case *ast.ObjectCallExpression:
+		p.expr(n.Object, precedence.CALL)
+		p.write(".")
+		p.expr(n.Call, precedence.LOWEST)
+	case *ast.IndexExpression:
+		p.expr(n.Left, precedence.INDEX)
+		if key, ok := n.Index.(*ast.StringLiteral); ok && n.Token.Type == tokentype.PERIOD && key.Token.Type != tokentype.STRING {
+			p.write("." + key.Token.Literal)
+			break
+		}
+		p.write("[")
+		p.expr(n.Index, precedence.LOWEST)
+		p.write("]")
+	case *ast.SliceExpression:
+		p.expr(n.Left, precedence.INDEX)
+		p.write("[")
+		if n.Start != nil {
+			p.expr(n.Start, precedence.LOWEST)
+		}
+		p.write(":")
+		if n.End != nil {
+			p.expr(n.End, precedence.LOWEST)
+		}
+		if n.Step != nil {
+			p.write(":")
+			p.expr(n.Step, precedence.LOWEST)
+		}
+		p.write("]")
+	case *ast.ArrayLiteral:
+		p.list(n.Token, n.Elements, nil)
+	case *ast.SetLiteral:
+		p.list(n.Token, n.Elements, nil)
+	case *ast.HashLiteral:
+		p.list(n.Token, n.Keys, n.Pairs)
+	case *ast.FunctionLiteral:
+		p.write("fn")
+		p.parameters(n.Parameters, n.Defaults)
+		p.write(" ")
+		p.block(n.Body)
+	case *ast.FunctionDefineLiteral:
+		p.write("function " + n.Token.Literal)
+		p.parameters(n.Parameters, n.Defaults)
+		p.write(" ")
+		p.block(n.Body)
+	case *ast.IfExpression:
+		p.write("if (")
+		p.expr(n.Condition, precedence.LOWEST)
+		p.write(") ")
+		p.block(n.Consequence)
+		if n.Alternative != nil {
+			p.write(" else ")
+			p.block(n.Alternative)
+		}
+	case *ast.ForLoopExpression:
+		p.write("for (")
+		p.expr(n.Condition, precedence.LOWEST)
+		p.write(") ")
+		p.block(n.Consequence)
+	case *ast.ForeachStatement:
+		p.write("foreach ")
+		if n.Index != "" {
+			p.write(n.Index + ", ")
+		}
+		p.write(n.Ident + " in ")
+		p.expr(n.Value, precedence.LOWEST)
+		p.write(" ")
+		p.block(n.Body)
+	case *ast.SpawnExpression:
+		p.write("spawn ")
+		p.expr(n.Call, precedence.PREFIX+1)
+	case *ast.SwitchExpression:
+		p.write("switch (")
+		p.expr(n.Value, precedence.LOWEST)
+		p.write(") ")
+		cases := make([]caseClause, len(n.Choices))
+		for i, c := range n.Choices {
+			cases[i] = caseClause{c.Token, c.Default, c.Expr, c.Block, ""}
+		}
+		// The brace follows the bracketed value.
+		p.cases(p.toks[p.closers[p.pos(n.Token)+1]+1], cases)
+	case *ast.SelectExpression:
+		p.write("select ")
+		cases := make([]caseClause, len(n.Cases))
+		for i, c := range n.Cases {
+			var match []asti.ExpressionI
+			if c.Channel != nil {
+				match = []asti.ExpressionI{c.Channel}
+			}
+			cases[i] = caseClause{c.Token, c.Default, match, c.Block, c.Ident}
+		}
+		p.cases(p.toks[p.pos(n.Token)+1], cases)
+	default:
+		// Anything else prints as the parser's own form.
+		p.write(e.String())
+	}
+}
+
+// stringLiteral prints a string, re-escaping what the lexer
+// unescaped.
+func (p *printer) stringLiteral(s *ast.StringLiteral) {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	p.write(`"` + r.Replace(s.Value) + `"`)
+}
+
+// parameters prints the parameters of a function.
+func (p *printer) parameters(params []*ast.Identifier, defaults map[string]asti.ExpressionI) {
+	p.write("(")
+	for i, param := range params {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.write(param.Value)
+		if def, ok := defaults[param.Value]; ok {
+			p.write(" = ")
+			p.expr(def, precedence.LOWEST)
+		}
+	}
+	p.write(")")
+}
+
+// list prints the elements of a call, array, set or hash, between the
+// brackets open and its closer.  When pairs is given the elements are
+// keys, printed with their values.
+//
+// Where the source broke the line before an element, or before the
+// closing bracket, so does the output.
+func (p *printer) list(open token.Token, elems []asti.ExpressionI, pairs map[asti.ExpressionI]asti.ExpressionI) {
+	start := p.pos(open)
+	end := p.closers[start]
+	p.write(open.Literal)
+	if p.toks[end].Line == open.Line {
+		for i, e := range elems {
+			if i > 0 {
+				p.write(",")
+			}
+			p.flush(p.first(e))
+			if i > 0 {
+				p.write(" ")
+			}
+			p.element(e, pairs)
+		}
+		p.flush(p.toks[end])
+		p.write(p.toks[end].Literal)
+		return
+	}
+
+	p.depth++
+	for i, e := range elems {
+		// Find the comma, or bracket, before the element.
+		before := p.pos(p.first(e)) - 1
+		for before > start && p.toks[before].Type == tokentype.LPAREN {
+			before--
+		}
+		if i > 0 {
+			p.write(",")
+		}
+		line := p.toks[before+1].Line
+		p.flush(p.toks[before+1])
+		if line > p.toks[before].Line || p.atStart {
+			p.breakLine(line)
+		} else if i > 0 {
+			p.write(" ")
+		}
+		p.element(e, pairs)
+	}
+	closer := p.toks[end]
+	p.flush(closer)
+	p.depth--
+	if closer.Line > p.toks[end-1].Line || p.atStart {
+		p.breakLine(0)
+	}
+	p.write(closer.Literal)
+}
+
+// element prints an element of a list.
+func (p *printer) element(e asti.ExpressionI, pairs map[asti.ExpressionI]asti.ExpressionI) {
+	p.expr(e, precedence.LOWEST)
+	if pairs != nil {
+		p.write(": ")
+		p.expr(pairs[e], precedence.LOWEST)
+	}
+}
+
+// caseClause is a case of a switch or select statement.
+type caseClause struct {
+	token     token.Token
+	isDefault bool
+	match     []asti.ExpressionI
+	block     *ast.BlockStatement
+	ident     string
+}
+
+// cases prints the cases of a switch or select statement, within the
+// braces starting at open.  Like a block they stay on one line if
+// they were written on one.
+func (p *printer) cases(open token.Token, cases []caseClause) {
+	end := p.closer(open)
+	oneLine := end.Line == open.Line
+	p.write("{")
+	p.depth++
+	p.fresh = true
+	for _, c := range cases {
+		if oneLine {
+			p.write(" ")
+		} else {
+			p.flush(c.token)
+			p.breakLine(c.token.Line)
+		}
+		if c.isDefault {
+			p.write("default ")
+		} else {
+			p.write("case ")
+			if c.ident != "" {
+				p.write(c.ident + " in ")
+			}
+			for i, e := range c.match {
+				if i > 0 {
+					p.write(", ")
+				}
+				p.expr(e, precedence.LOWEST)
+			}
+			p.write(" ")
+		}
+		p.block(c.block)
+	}
+	p.depth--
+	if oneLine {
+		p.write(" }")
+		return
+	}
+	p.flush(end)
+	p.breakLine(0)
+	p.write("}")
+}
@@ -0,0 +1,106 @@
+package format
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/kasworld/nonkey/interpreter/lexer"
+	"github.com/kasworld/nonkey/interpreter/parser"
+)
+
+// TestExamples formats each of the examples, checking the result parses
+// to the same program and doesn't change when formatted again.
+func TestExamples(t *testing.T) {
+	mon, _ := filepath.Glob("../../mon_examples/*.mon")
+	nky, _ := filepath.Glob("../../nky_example/*.nky")
+	files := append(mon, nky...)
+	if len(files) == 0 {
+		t.Fatalf("no examples found")
+	}
+	for _, name := range files {
+		src, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		out, err := Source(string(src))
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		before := parser.New(lexer.New(string(src))).ParseProgram().String()
+		after := parser.New(lexer.New(out)).ParseProgram().String()
+		if before != after {
+			t.Errorf("%s: program changed, got\n%s\nwant\n%s", name, after, before)
+		}
+		again, err := Source(out)
+		if err != nil || again != out {
+			t.Errorf("%s: not idempotent, got\n%s\nwant\n%s", name, again, out)
+		}
+	}
+}
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let  a=1+2*3 ;", "let a = 1 + 2 * 3;\n"},
+		{"let a = (1 + 2) * (3 - (4 - 5));", "let a = (1 + 2) * (3 - (4 - 5));\n"},
+		{"let a = ((1 + 2)) + (3 + 4);", "let a = 1 + 2 + (3 + 4);\n"},
+		{"puts( \"a\\tb\\n\" , 1..3 );", "puts(\"a\\tb\\n\", 1..3);\n"},
+		{"x++", "x++;\n"},
+		{"if(a){b}else{c}", "if (a) { b; } else { c; }\n"},
+		{"if (a) {\nb;\n}", "if (a) {\n    b;\n}\n"},
+		{"function f(a, b=2) { return a+b; }", "function f(a, b = 2) { return a + b; }\n"},
+		{"let f = fn(){};", "let f = fn() {};\n"},
+		{"foreach i,x in [1,2] { puts(x); }", "foreach i, x in [1, 2] { puts(x); }\n"},
+		{"for(i<3){i++;}", "for (i < 3) { i++; }\n"},
+		{"let h = {\"a\":1, b: 2,};", "let h = {\"a\": 1, b: 2};\n"},
+		{"h.a.len()", "h.a.len();\n"},
+		{"let m = s ~= /a\\.b/i;", "let m = s ~= /a\\.b/i;\n"},
+		{"let x = a ? b : c;", "let x = a ? b : c;\n"},
+		{"let x = -(a + b);", "let x = -(a + b);\n"},
+
+		// Blank lines are kept, but only one.
+		{"a;\n\n\n\nb;", "a;\n\nb;\n"},
+
+		// Statements ending with a block need a semicolon when
+		// the next could continue them.
+		{"if (a) { b; };\n(c + d) * e;", "if (a) { b; };\n(c + d) * e;\n"},
+		{"if (a) { b; };\n(c);", "if (a) { b; }\nc;\n"},
+		{"if (a) { b; };\n[1].len();", "if (a) { b; };\n[1].len();\n"},
+
+		// Lists keep their line breaks.
+		{"f(a,\n  b,\n c\n);", "f(a,\n    b,\n    c\n);\n"},
+		{"let a = [\n1, 2,\n3];", "let a = [\n    1, 2,\n    3];\n"},
+
+		// Comments stay where they were.
+		{"// head\nlet a = 1;   // one\n\n# two\nb;", "// head\nlet a = 1; // one\n\n# two\nb;\n"},
+		{"f(a, /* x */ b);", "f(a, /* x */ b);\n"},
+		{"if (a) { // why\nb;\n}", "if (a) { // why\n    b;\n}\n"},
+		{"if (a) {\n  b;\n  // done\n}", "if (a) {\n    b;\n    // done\n}\n"},
+		{"let h = {\n  a: 1, // one\n  /* two */ b: 2\n};", "let h = {\n    a: 1, // one\n    /* two */ b: 2\n};\n"},
+		{"switch (a) {\ncase 1 { b; }\n// other\ndefault { c; }\n}",
+			"switch (a) {\n    case 1 { b; }\n    // other\n    default { c; }\n}\n"},
+		{"// only", "// only\n"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		out, err := Source(tt.input)
+		if err != nil {
+			t.Errorf("%q: %s", tt.input, err)
+			continue
+		}
+		if out != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected, out)
+		}
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	if _, err := Source("let a = ;"); err == nil {
+		t.Errorf("expected an error for a program which doesn't parse")
+	}
+}
@@ -11,6 +11,11 @@ import (
 	"github.com/kasworld/nonkey/interpreter/token"
 )
 
+// UnterminatedString is the literal of the ILLEGAL token given for a
+// string or backtick command which runs to the end of the input
+// without its closing quote.
+const UnterminatedString = "unterminated string"
+
 // Lexer holds our object-state.
 type Lexer struct {
 	// for debug,error message
@@ -18,6 +23,10 @@ type Lexer struct {
 	curPosInLine   int
 	codeLineBegins []int // line begin pos
 
+	// tokLine and tokPos are where the token being read starts.
+	tokLine int
+	tokPos  int
+
 	// The current character position
 	position int
 
@@ -32,6 +41,10 @@ type Lexer struct {
 
 	// Previous token.
 	prevToken token.Token
+
+	// comments is set if comments are returned as tokens, rather
+	// than skipped.
+	comments bool
 }
 
 // New a Lexer instance from string input.
@@ -42,8 +55,22 @@ func New(input string) *Lexer {
 	return l
 }
 
+// NewWithComments returns a Lexer which returns comments as COMMENT
+// tokens, for tools such as the formatter which need to keep them.
+//
+// The parser doesn't expect these, so it should be given a Lexer made
+// by New.
+func NewWithComments(input string) *Lexer {
+	l := New(input)
+	l.comments = true
+	return l
+}
+
 // GetLineStr return source code line
 func (l *Lexer) GetLineStr(line int) string {
+	if line < 0 || line >= len(l.codeLineBegins) {
+		return ""
+	}
 	lineBegin := l.codeLineBegins[line]
 	if len(l.codeLineBegins) > line+1 {
 		lineEnd := l.codeLineBegins[line+1]
@@ -87,17 +114,25 @@ func (l *Lexer) readChar() {
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 	l.skipWhitespace()
+	l.markToken()
 
 	// skip single-line comments
 	if l.ch == rune('#') ||
 		(l.ch == rune('/') && l.peekChar() == rune('/')) {
+		if l.comments {
+			return l.readComment(l.skipComment)
+		}
 		l.skipComment()
 		return l.NextToken()
 	}
 
 	// multi-line comments
 	if l.ch == rune('/') && l.peekChar() == rune('*') {
+		if l.comments {
+			return l.readComment(l.skipMultiLineComment)
+		}
 		l.skipMultiLineComment()
+		l.markToken()
 	}
 
 	switch l.ch {
@@ -106,12 +141,16 @@ func (l *Lexer) NextToken() token.Token {
 			ch := l.ch
 			l.readChar()
 			tok = l.newToken(tokentype.AND, string(ch)+string(l.ch))
+		} else {
+			tok = l.newToken(tokentype.AMPERSAND, string(l.ch))
 		}
 	case rune('|'):
 		if l.peekChar() == rune('|') {
 			ch := l.ch
 			l.readChar()
 			tok = l.newToken(tokentype.OR, string(ch)+string(l.ch))
+		} else {
+			tok = l.newToken(tokentype.PIPE, string(l.ch))
 		}
 
 	case rune('='):
@@ -255,12 +294,20 @@ func (l *Lexer) NextToken() token.Token {
 			}
 		}
 	case rune('"'):
-		str := l.readString()
-		tok = l.newToken(tokentype.STRING, str)
+		str, ok := l.readString()
+		if ok {
+			tok = l.newToken(tokentype.STRING, str)
+		} else {
+			tok = l.newToken(tokentype.ILLEGAL, UnterminatedString)
+		}
 
 	case rune('`'):
-		str := l.readBacktick()
-		tok = l.newToken(tokentype.BACKTICK, str)
+		str, ok := l.readBacktick()
+		if ok {
+			tok = l.newToken(tokentype.BACKTICK, str)
+		} else {
+			tok = l.newToken(tokentype.ILLEGAL, UnterminatedString)
+		}
 	case rune('['):
 		tok = l.newToken(tokentype.LBRACKET, string(l.ch))
 	case rune(']'):
@@ -278,6 +325,11 @@ func (l *Lexer) NextToken() token.Token {
 
 		}
 		str := l.readIdentifier()
+		if str == "" {
+			// not something which can start a token
+			tok = l.newToken(tokentype.ILLEGAL, string(l.ch))
+			break
+		}
 		tType := tokentype.LookupKeyword(str)
 		tok = l.newToken(tType, str)
 
@@ -295,11 +347,18 @@ func (l *Lexer) newToken(tokenType tokentype.TokenType, s string) token.Token {
 	return token.Token{
 		Type:    tokenType,
 		Literal: s,
-		Line:    l.curLine,
-		Pos:     l.curPosInLine,
+		Line:    l.tokLine,
+		Pos:     l.tokPos,
 	}
 }
 
+// markToken records the current position as the start of the next
+// token, so tokens are placed where they start rather than wherever
+// reading them ended.
+func (l *Lexer) markToken() {
+	l.tokLine, l.tokPos = l.curLine, l.curPosInLine
+}
+
 // readIdentifier is designed to read an identifier (name of variable,
 // function, etc).
 //
@@ -327,14 +386,49 @@ func (l *Lexer) readIdentifier() string {
 	// Functions which are permitted to have dots in their name.
 	//
 	valid := map[string]bool{
+		"csv.read":           true,
+		"csv.write":          true,
 		"directory.glob":     true,
+		"exec.run":           true,
+		"exec.start":         true,
+		"fs.copy":            true,
+		"fs.exists":          true,
+		"fs.read_dir":        true,
+		"fs.remove_all":      true,
+		"fs.rename":          true,
+		"fs.temp_dir":        true,
+		"fs.temp_file":       true,
+		"fs.walk":            true,
+		"http.get":           true,
+		"http.post":          true,
+		"http.request":       true,
+		"http.serve":         true,
+		"json.decode":        true,
+		"json.encode":        true,
 		"math.abs":           true,
 		"math.random":        true,
 		"math.sqrt":          true,
 		"os.environment":     true,
 		"os.getenv":          true,
 		"os.setenv":          true,
+		"path.abs":           true,
+		"path.base":          true,
+		"path.clean":         true,
+		"path.dir":           true,
+		"path.ext":           true,
+		"path.join":          true,
+		"path.rel":           true,
 		"string.interpolate": true,
+		"time.date":          true,
+		"time.duration":      true,
+		"time.now":           true,
+		"time.parse":         true,
+		"time.since":         true,
+		"time.unix":          true,
+		"toml.decode":        true,
+		"toml.encode":        true,
+		"yaml.decode":        true,
+		"yaml.encode":        true,
 	}
 
 	//
@@ -346,6 +440,14 @@ func (l *Lexer) readIdentifier() string {
 		"integer.",
 		"float.",
 		"hash.",
+		"set.",
+		"range.",
+		"iterator.",
+		"channel.",
+		"bytes.",
+		"process.",
+		"time.",
+		"duration.",
 		"object."}
 
 	id := ""
@@ -356,6 +458,7 @@ func (l *Lexer) readIdentifier() string {
 	//
 	position := l.position
 	rposition := l.readPosition
+	column := l.curPosInLine
 
 	//
 	// Build up our identifier, handling only valid characters.
@@ -377,8 +480,9 @@ func (l *Lexer) readIdentifier() string {
 		ok := valid[id]
 
 		// If not see if it has a type-prefix, which will
-		// let the definition succeed.
-		if !ok {
+		// let the definition succeed.  Only a definition: elsewhere
+		// "time.start" is the field of a variable named time.
+		if !ok && l.prevToken.Type == tokentype.DEFINE_FUNCTION {
 			for _, i := range types {
 				if strings.HasPrefix(id, i) {
 					ok = true
@@ -412,6 +516,7 @@ func (l *Lexer) readIdentifier() string {
 			// the length of the bits we went too-far.
 			l.position = position
 			l.readPosition = rposition
+			l.curPosInLine = column
 			for offset > 0 {
 				l.readChar()
 				offset--
@@ -464,6 +569,26 @@ func (l *Lexer) skipMultiLineComment() {
 	l.skipWhitespace()
 }
 
+// readComment returns the comment which skip passes over as a COMMENT
+// token, without any whitespace that follows it.
+//
+// The previous token is left alone, as it decides whether a following
+// "/" is division or starts a regular expression.
+func (l *Lexer) readComment(skip func()) token.Token {
+	tok := l.newToken(tokentype.COMMENT, "")
+	start := l.position
+	skip()
+	end := l.position
+	if end > len(l.characters) {
+		end = len(l.characters)
+	}
+	for end > start && isWhitespace(l.characters[end-1]) {
+		end--
+	}
+	tok.Literal = string(l.characters[start:end])
+	return tok
+}
+
 // read number - this handles 0x1234 and 0b101010101 too.
 func (l *Lexer) readNumber() string {
 	str := ""
@@ -509,17 +634,51 @@ func (l *Lexer) readDecimal() token.Token {
 		//
 		l.readChar()
 		fraction := l.readNumber()
-		return l.newToken(tokentype.FLOAT, integer+"."+fraction)
+		return l.newToken(tokentype.FLOAT, integer+"."+fraction+l.readExponent())
+	}
+
+	//
+	// An exponent makes a float too, e.g. `1e9`.
+	//
+	if !strings.HasPrefix(integer, "0x") && !strings.HasPrefix(integer, "0b") {
+		if exp := l.readExponent(); exp != "" {
+			return l.newToken(tokentype.FLOAT, integer+exp)
+		}
 	}
 	return l.newToken(tokentype.INT, integer)
 }
 
-// read string
-func (l *Lexer) readString() string {
+// readExponent reads an optional `e[+-]digits` suffix of a number.
+func (l *Lexer) readExponent() string {
+	if l.ch != rune('e') && l.ch != rune('E') {
+		return ""
+	}
+	digit := l.readPosition
+	if l.peekChar() == rune('+') || l.peekChar() == rune('-') {
+		digit++
+	}
+	if digit >= len(l.characters) || !isDigit(l.characters[digit]) {
+		return ""
+	}
+	exp := string(l.ch)
+	l.readChar()
+	if l.ch == rune('+') || l.ch == rune('-') {
+		exp += string(l.ch)
+		l.readChar()
+	}
+	return exp + l.readNumber()
+}
+
+// read string, up to its closing quote, reporting false if the input
+// ends first
+func (l *Lexer) readString() (string, bool) {
 	out := ""
 
 	for {
 		l.readChar()
+		if l.ch == rune(0) {
+			return out, false
+		}
 		if l.ch == '"' {
 			break
 		}
@@ -549,7 +708,7 @@ func (l *Lexer) readString() string {
 		out = out + string(l.ch)
 	}
 
-	return out
+	return out, true
 }
 
 // read a regexp, including flags.
@@ -564,9 +723,6 @@ func (l *Lexer) readRegexp() (string, error) {
 		}
 		if l.ch == '/' {
 
-			// consume the terminating "/".
-			l.readChar()
-
 			// prepare to look for flags
 			flags := ""
 
@@ -574,7 +730,11 @@ func (l *Lexer) readRegexp() (string, error) {
 			//   i -> Ignore-case
 			//   m -> Multiline
 			//
-			for l.ch == rune('i') || l.ch == rune('m') {
+			// The flags are read up to the last of them, or
+			// the terminating "/", as the caller reads the
+			// character after the token.
+			for l.peekChar() == rune('i') || l.peekChar() == rune('m') {
+				l.readChar()
 
 				// save the char - unless it is a repeat
 				if !strings.Contains(flags, string(l.ch)) {
@@ -585,9 +745,6 @@ func (l *Lexer) readRegexp() (string, error) {
 					flags = strings.Join(tmp, "")
 
 				}
-
-				// read the next
-				l.readChar()
 			}
 
 			// convert the regexp to go-lang
@@ -602,17 +759,21 @@ func (l *Lexer) readRegexp() (string, error) {
 	return out, nil
 }
 
-// read the end of a backtick-quoted string
-func (l *Lexer) readBacktick() string {
+// read the end of a backtick-quoted string, up to its closing backtick,
+// reporting false if the input ends first
+func (l *Lexer) readBacktick() (string, bool) {
 	position := l.position + 1
 	for {
 		l.readChar()
+		if l.ch == rune(0) {
+			return "", false
+		}
 		if l.ch == '`' {
 			break
 		}
 	}
 	out := string(l.characters[position:l.position])
-	return out
+	return out, true
 }
 
 // peek character
@@ -7,7 +7,7 @@ import (
 )
 
 func TestNextToken1(t *testing.T) {
-	input := "%=+(){},;?|| &&`/bin/ls`++--***=.."
+	input := "%=+(){},;?|| &&`/bin/ls`++--***=..| &"
 
 	tests := []struct {
 		expectedType    tokentype.TokenType
@@ -31,6 +31,8 @@ func TestNextToken1(t *testing.T) {
 		{tokentype.POW, "**"},
 		{tokentype.ASTERISK_EQUALS, "*="},
 		{tokentype.DOTDOT, ".."},
+		{tokentype.PIPE, "|"},
+		{tokentype.AMPERSAND, "&"},
 		{tokentype.EOF, ""},
 	}
 	l := New(input)
@@ -386,11 +388,11 @@ math.abs
 math.random
 math.sqrt
 string.interpolate
-string.toupper
-string.tolower
-string.trim
-string.reverse
-string.split
+function string.toupper
+function string.tolower
+function time.start
+time.start
+time.now
 moi.kissa
 `
 
@@ -406,11 +408,16 @@ moi.kissa
 		{tokentype.IDENT, "math.random"},
 		{tokentype.IDENT, "math.sqrt"},
 		{tokentype.IDENT, "string.interpolate"},
+		{tokentype.DEFINE_FUNCTION, "function"},
 		{tokentype.IDENT, "string.toupper"},
+		{tokentype.DEFINE_FUNCTION, "function"},
 		{tokentype.IDENT, "string.tolower"},
-		{tokentype.IDENT, "string.trim"},
-		{tokentype.IDENT, "string.reverse"},
-		{tokentype.IDENT, "string.split"},
+		{tokentype.DEFINE_FUNCTION, "function"},
+		{tokentype.IDENT, "time.start"},
+		{tokentype.IDENT, "time"},
+		{tokentype.PERIOD, "."},
+		{tokentype.IDENT, "start"},
+		{tokentype.IDENT, "time.now"},
 		{tokentype.IDENT, "moi"},
 		{tokentype.PERIOD, "."},
 		{tokentype.IDENT, "kissa"},
@@ -511,6 +518,37 @@ func TestIntDotMethod(t *testing.T) {
 	}
 }
 
+// TestExponent ensures numbers with an exponent are floats.
+func TestExponent(t *testing.T) {
+	input := `1..1e9 2.5E-3 1e+2 0x1e 3.e`
+
+	tests := []struct {
+		expectedType    tokentype.TokenType
+		expectedLiteral string
+	}{
+		{tokentype.INT, "1"},
+		{tokentype.DOTDOT, ".."},
+		{tokentype.FLOAT, "1e9"},
+		{tokentype.FLOAT, "2.5E-3"},
+		{tokentype.FLOAT, "1e+2"},
+		{tokentype.INT, "0x1e"},
+		{tokentype.INT, "3"},
+		{tokentype.PERIOD, "."},
+		{tokentype.IDENT, "e"},
+		{tokentype.EOF, ""},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong, expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - Literal wrong, expected=%v, got=%q", i, tt, tok)
+		}
+	}
+}
+
 // TestRegexp ensures a simple regexp can be parsed.
 func TestRegexp(t *testing.T) {
 	input := `if ( f ~= /steve/i )
@@ -594,6 +632,33 @@ func TestIllegalRegexp(t *testing.T) {
 	}
 }
 
+// TestUnterminatedString ensures a string missing its closing quote
+// ends with the input, as an illegal token.
+func TestUnterminatedString(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    tokentype.TokenType
+		expectedLiteral string
+	}{
+		{`puts("steve`, tokentype.ILLEGAL, UnterminatedString},
+		{"puts(`steve", tokentype.ILLEGAL, UnterminatedString},
+		{`puts("steve\"`, tokentype.ILLEGAL, UnterminatedString},
+		{`puts("steve"`, tokentype.STRING, "steve"},
+	}
+	for _, tt := range tests {
+		l := New(tt.input)
+		l.NextToken()
+		l.NextToken()
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Errorf("%s: expected %q %q, got %q %q", tt.input, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if tok = l.NextToken(); tok.Type != tokentype.EOF {
+			t.Errorf("%s: expected EOF, got %q", tt.input, tok.Type)
+		}
+	}
+}
+
 // TestDiv is designed to test that a division is recognized; that it is
 // not confused with a regular-expression.
 func TestDiv(t *testing.T) {
@@ -713,3 +778,145 @@ func TestGetLineStr(t *testing.T) {
 		println(l.GetLineStr(i))
 	}
 }
+
+// TestTypeNames checks the names of the types are read as identifiers,
+// so the builtins which convert to them can be called.
+func TestTypeNames(t *testing.T) {
+	l := New(`int("1") float string(2)`)
+	tests := []struct {
+		expectedType    tokentype.TokenType
+		expectedLiteral string
+	}{
+		{tokentype.IDENT, "int"},
+		{tokentype.LPAREN, "("},
+		{tokentype.STRING, "1"},
+		{tokentype.RPAREN, ")"},
+		{tokentype.IDENT, "float"},
+		{tokentype.IDENT, "string"},
+		{tokentype.LPAREN, "("},
+		{tokentype.INT, "2"},
+		{tokentype.RPAREN, ")"},
+		{tokentype.EOF, ""},
+	}
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong, expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - Literal wrong, expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+// TestTokenPositions checks tokens are placed where they start.
+func TestTokenPositions(t *testing.T) {
+	input := `let abc = f(10);
+  "str" + x /* note */ y
+puts(x.len(), );`
+
+	tests := []struct {
+		expectedLiteral string
+		line, pos       int
+	}{
+		{"let", 0, 1},
+		{"abc", 0, 5},
+		{"=", 0, 9},
+		{"f", 0, 11},
+		{"(", 0, 12},
+		{"10", 0, 13},
+		{")", 0, 15},
+		{";", 0, 16},
+		{"str", 1, 3},
+		{"+", 1, 9},
+		{"x", 1, 11},
+		{"y", 1, 24},
+		{"puts", 2, 1},
+		{"(", 2, 5},
+		{"x", 2, 6},
+		{".", 2, 7},
+		{"len", 2, 8},
+		{"(", 2, 11},
+		{")", 2, 12},
+		{",", 2, 13},
+		{")", 2, 15},
+		{";", 2, 16},
+		{"", 2, 17},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - Literal wrong, expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Line != tt.line || tok.Pos != tt.pos {
+			t.Fatalf("tests[%d] - position wrong, expected=%d:%d, got=%d:%d", i, tt.line, tt.pos, tok.Line, tok.Pos)
+		}
+	}
+}
+
+// TestRegexpFollowed checks the character after a regexp, with or
+// without flags, is read as the next token.
+func TestRegexpFollowed(t *testing.T) {
+	l := New(`f(/x/i); g(/y/)`)
+	expected := []string{"f", "(", "(?i)x", ")", ";", "g", "(", "y", ")", ""}
+	for i, literal := range expected {
+		if tok := l.NextToken(); tok.Literal != literal {
+			t.Fatalf("tests[%d] - Literal wrong, expected=%q, got=%q", i, literal, tok.Literal)
+		}
+	}
+}
+
+// TestComments checks comments are returned by NewWithComments, and that
+// tokens are placed where they start.
+func TestComments(t *testing.T) {
+	input := `# hash
+let a = f(/x/i); // slash
+/* multi
+   line */ a`
+
+	tests := []struct {
+		expectedType    tokentype.TokenType
+		expectedLiteral string
+		line, pos       int
+	}{
+		{tokentype.COMMENT, "# hash", 0, 1},
+		{tokentype.LET, "let", 1, 1},
+		{tokentype.IDENT, "a", 1, 5},
+		{tokentype.ASSIGN, "=", 1, 7},
+		{tokentype.IDENT, "f", 1, 9},
+		{tokentype.LPAREN, "(", 1, 10},
+		{tokentype.REGEXP, "(?i)x", 1, 11},
+		{tokentype.RPAREN, ")", 1, 15},
+		{tokentype.SEMICOLON, ";", 1, 16},
+		{tokentype.COMMENT, "// slash", 1, 18},
+		{tokentype.COMMENT, "/* multi\n   line */", 2, 1},
+		{tokentype.IDENT, "a", 3, 12},
+		{tokentype.EOF, "", 3, 13},
+	}
+	l := NewWithComments(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong, expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - Literal wrong, expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Line != tt.line || tok.Pos != tt.pos {
+			t.Fatalf("tests[%d] - position wrong, expected=%d:%d, got=%d:%d", i, tt.line, tt.pos, tok.Line, tok.Pos)
+		}
+	}
+
+	// Without comments the same input gives the same tokens, less
+	// the comments.
+	l = New(input)
+	for _, tt := range tests {
+		if tt.expectedType == tokentype.COMMENT {
+			continue
+		}
+		if tok := l.NextToken(); tok.Literal != tt.expectedLiteral {
+			t.Fatalf("Literal wrong, expected=%q, got=%q", tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
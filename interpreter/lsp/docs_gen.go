@@ -0,0 +1,87 @@
+// Code generated by "go run gendocs.go"; DO NOT EDIT.
+
+package lsp
+
+// builtinDocs holds the documentation of each builtin.
+var builtinDocs = map[string]string{
+	"args":           "Implemention of \"args()\" function.",
+	"assert":         "assert( cond [, msg] )\n\nFails, with an error which stops the script, unless cond is true.\nA STRING cond is code, as older scripts give it, which must\nevaluate to true.",
+	"assert_eq":      "assert_eq( got, want [, msg] )\n\nFails unless got equals want, showing how they differ.",
+	"assert_ne":      "assert_ne( got, other [, msg] )\n\nFails if got equals other.",
+	"assert_raises":  "assert_raises( fn [, substring] ) -> string\n\nCalls fn, failing unless it returns an error, whose message must\ncontain substring if that's given.  Returns the message.",
+	"bytes":          "bytes( string|array|bytes ) -> bytes\n\nStrings are taken as UTF-8, and arrays must hold integers 0-255.",
+	"chan":           "chan creates a channel, buffering the given number of values.",
+	"chmod":          "Change a mode of a file - note the second argument is a string\nto emphasise octal.",
+	"compare":        "compare two values, returning -1, 0, or 1; suitable for sorting.",
+	"csv.read":       "csv.read( path|file [, header] ) -> array\n\nEach record becomes an array of strings.  When header is true the\nfirst record names the columns and each following record becomes a\nhash of column -> value instead.",
+	"csv.write":      "csv.write( path|file, rows [, header] ) -> true\n\nrows is an array of arrays or of hashes.  header is an array of\ncolumn names written as the first record; for hash rows it also\npicks the columns and their order, defaulting to the sorted keys of\nthe first row.  Values which aren't strings are written as they'd\nbe printed.",
+	"delete":         "Delete a given hash-key",
+	"directory.glob": "array = directory.glob( \"/etc/*.conf\" )\n\nA \"**\" path element matches any number of directories, so\n\"src/**/*.go\" finds go files anywhere below src.",
+	"enumerate":      "enumerate lazily yields [index, item] arrays.",
+	"eval":           "evaluate a string containing monkey-code",
+	"exec.run":       "exec.run( argv [, {stdin, env, dir, timeout}] ) -> hash\n\nRuns a command to completion, returning a hash of exit_code, stdout,\nstderr and duration in seconds.  A non-zero exit isn't an error, but\nfailing to start the command or running past the timeout is.",
+	"exec.start":     "exec.start( argv [, {env, dir}] ) -> process\n\nStarts a command without waiting for it.  The process it returns has\nread_line, write, close, wait and kill methods.",
+	"exit":           "exit a program.",
+	"filter":         "filter lazily keeps the items for which fn is truthy.",
+	"fs.copy":        "fs.copy( src, dst ) -> true\n\nDirectories are copied recursively.  Copying a file to an existing\ndirectory puts it inside, as cp does.  Permissions are kept.",
+	"fs.exists":      "fs.exists( path ) -> bool",
+	"fs.read_dir":    "fs.read_dir( dir ) -> array of names, sorted",
+	"fs.remove_all":  "fs.remove_all( path ) -> true\n\nRemoves path and anything below it; a missing path isn't an error.",
+	"fs.rename":      "fs.rename( src, dst ) -> true",
+	"fs.temp_dir":    "fs.temp_dir( [pattern] ) -> path\n\nCreates a new directory in the system temporary directory.  A \"*\"\nin pattern is replaced by a random string.",
+	"fs.temp_file":   "fs.temp_file( [pattern] ) -> path\n\nCreates a new empty file in the system temporary directory, named\nas for fs.temp_dir.",
+	"fs.walk":        "fs.walk( dir, fn ) -> true\n\nCalls fn(path, info) for dir and everything below it, in lexical\norder, where info is the hash stat() returns.  If fn returns false\nfor a directory its contents are skipped.",
+	"http.get":       "http.get( url [, headers] ) -> hash",
+	"http.post":      "http.post( url, body [, headers] ) -> hash",
+	"http.request":   "http.request( {method, url, headers, body, timeout} ) -> hash\n\nmethod defaults to \"GET\", headers is a hash of name -> string (or\narray of strings), body is a string and timeout is in seconds.  The\nresult is a hash of status, headers and body; a status which isn't\n2xx is not an error, only failing to get a response at all is.",
+	"http.serve":     "http.serve( addr, handler ) -> error\n\nServes HTTP on addr until it fails, calling handler with a hash of\nmethod, path, query, headers and body for each request.  handler\nreturns a hash of status, headers and body, or just a body string.\nRequests are handled concurrently, each in its own environment.",
+	"int":            "convert a double/string to an int",
+	"iter":           "iter returns an iterator over any iterable, for use with `.next()`.",
+	"json.decode":    "json.decode( string ) -> value\n\nNumbers without a fraction or exponent become integers, the rest\nfloats.",
+	"json.encode":    "json.encode( value [, indent] ) -> string\n\nHash keys must be strings and are written sorted, so the output is\nstable.  Floats always keep a fraction or exponent, so they decode\nas floats again.  indent is a number of spaces or a string.",
+	"keys":           "Get hash keys",
+	"len":            "length of item",
+	"map":            "map lazily applies fn to each item.",
+	"match":          "regular expression match",
+	"math.abs":       "val = math.abs(int|float);",
+	"math.random":    "val = math.random()",
+	"math.sqrt":      "val = math.sqrt(int);",
+	"mkdir":          "mkdir( path [, mode] [, parents] ) -> boolean\n\nCreates a directory, reporting false if it can't, as when it exists\nalready or its parent doesn't.  With parents true, missing parents\nare created too, and an existing directory is fine, like mkdir -p.",
+	"new_set":        "new_set creates a set, optionally from the elements of an array or\nthe keys of a hash.",
+	"open":           "Open a file\n\nmode is one of \"r\", \"w\", \"a\" or \"x\" (create, failing if the file\nexists), optionally followed by \"+\" to both read and write.",
+	"os.environment": "os.getenv() -> ( Hash )",
+	"os.getenv":      "os.getenv( \"PATH\" ) -> string",
+	"os.setenv":      "os.setenv( \"PATH\", \"/home/skx/bin:/usr/bin\" );",
+	"path.abs":       "path.abs( path ) -> string",
+	"path.base":      "path.base( path ) -> string\n\nAs Go's filepath.Base.",
+	"path.clean":     "path.clean( path ) -> string\n\nAs Go's filepath.Clean.",
+	"path.dir":       "path.dir( path ) -> string\n\nAs Go's filepath.Dir.",
+	"path.ext":       "path.ext( path ) -> string\n\nAs Go's filepath.Ext.",
+	"path.join":      "path.join( elem, ... ) -> string",
+	"path.rel":       "path.rel( base, target ) -> string\n\nReturns target relative to base.",
+	"pragma":         "set a global pragma",
+	"printf":         "printfFun is the implementation of our `printf` function.",
+	"push":           "push something onto an array",
+	"puts":           "output a string to stdout",
+	"set":            "set a hash-field",
+	"sprintf":        "sprintfFun is the implementation of our `sprintf` function.",
+	"stat":           "Get file info.",
+	"string":         "convert a value to a string, as puts would print it",
+	"take":           "take lazily stops after the first n items, closing what it takes\nthem from.",
+	"time.date":      "time.date( year, month, day [, hour, minute, second, nanosecond] [, zone] ) -> time\n\nOut of range values are normalized, so October 32 is November 1.\nThe zone defaults to UTC.",
+	"time.duration":  "time.duration( str | seconds ) -> duration\n\nStrings are as \"1h30m\", \"250ms\" or \"-1.5s\".",
+	"time.now":       "time.now() -> time\n\nHosts may replace the clock this reads, see Environment.SetClock.",
+	"time.parse":     "time.parse( layout, str [, zone] ) -> time\n\nThe layout is either Go's reference time, \"2006-01-02 15:04:05\",\nor a name such as \"RFC3339\".  A time without an offset is taken\nto be in zone, or UTC.",
+	"time.since":     "time.since( t ) -> duration",
+	"time.unix":      "time.unix( seconds [, nanoseconds] ) -> time, in UTC",
+	"toml.decode":    "toml.decode( string ) -> hash\n\nDates and times have no type of their own yet, so they're returned\nas strings.",
+	"toml.encode":    "toml.encode( hash ) -> string\n\nNested hashes become [tables] and arrays of hashes [[arrays of\ntables]].  TOML has no null, so null values are an error.",
+	"try":            "try( fn, args... ) -> {ok, value, error, stack}\n\nCalls fn with args, catching the error it fails with, if any.  On\nfailure ok is false, error is the message and stack is the calls\nwhich led to the error, most recent last, as hashes of function and\nline.",
+	"type":           "type of an item",
+	"unlink":         "Remove a file/directory.",
+	"version":        "Implemention of \"version()\" function.",
+	"wait":           "wait blocks until every function the program spawned has finished.\nGiven the channels returned by spawn, it instead waits for just\nthose and returns their results.",
+	"yaml.decode":    "yaml.decode( string ) -> value\n\nThis understands the commonly used subset of YAML: block mappings\nand sequences, flow collections, plain and quoted scalars, literal\nand folded block scalars, and comments.  Anchors, aliases, tags and\nmultiple documents are rejected.",
+	"yaml.encode":    "yaml.encode( value ) -> string\n\nHash keys are written sorted, and strings are quoted only where\nthey'd otherwise read back as something else.",
+	"zip":            "zip lazily yields arrays holding one item from each argument,\nstopping with the shortest, and closing the rest.",
+}
@@ -0,0 +1,86 @@
+//go:build ignore
+// +build ignore
+
+// gendocs writes docs_gen.go, holding the documentation of each builtin
+// for hovers, taken from the comment on the function implementing it.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, "../evaluator", nil, parser.ParseComments)
+	if err != nil {
+		log.Fatal(err)
+	}
+	pkg := pkgs["evaluator"]
+
+	// The comments on each function.
+	funcs := make(map[string]string)
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Doc != nil {
+				funcs[fn.Name.Name] = fn.Doc.Text()
+			}
+		}
+	}
+
+	// The table of builtins, in builtin_init.go.
+	docs := make(map[string]string)
+	ast.Inspect(pkg.Files["../evaluator/builtin_init.go"], func(n ast.Node) bool {
+		kv, ok := n.(*ast.KeyValueExpr)
+		if !ok {
+			return true
+		}
+		name, err := strconv.Unquote(fmt.Sprint(kv.Key.(*ast.BasicLit).Value))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fn := kv.Value.(*ast.CompositeLit).Elts[0].(*ast.KeyValueExpr).Value
+		switch fn := fn.(type) {
+		case *ast.Ident:
+			docs[name] = strings.TrimSpace(funcs[fn.Name])
+		case *ast.CallExpr:
+			// pathFunc("path.base", filepath.Base)
+			sel := fn.Args[1].(*ast.SelectorExpr)
+			docs[name] = fmt.Sprintf("%s( path ) -> string\n\nAs Go's filepath.%s.", name, sel.Sel.Name)
+		}
+		return false
+	})
+
+	var names []string
+	for name := range docs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by \"go run gendocs.go\"; DO NOT EDIT.\n\n")
+	buf.WriteString("package lsp\n\n")
+	buf.WriteString("// builtinDocs holds the documentation of each builtin.\n")
+	buf.WriteString("var builtinDocs = map[string]string{\n")
+	for _, name := range names {
+		fmt.Fprintf(&buf, "\t%q: %q,\n", name, docs[name])
+	}
+	buf.WriteString("}\n")
+
+	out, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile("docs_gen.go", out, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,670 @@
+// Package lsp is a language server for monkey programs, talking the
+// Language Server Protocol over a pair of streams.
+//
+// It reports parse errors and the findings of vet as diagnostics, and
+// offers hovers, go-to-definition, completion and document symbols.
+// Documents are synchronized in full on each change.
+package lsp
+
+//go:generate go run gendocs.go
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	"github.com/kasworld/nonkey/config/builtinfunctions"
+	"github.com/kasworld/nonkey/interpreter/ast"
+	"github.com/kasworld/nonkey/interpreter/asti"
+	"github.com/kasworld/nonkey/interpreter/lexer"
+	"github.com/kasworld/nonkey/interpreter/object"
+	"github.com/kasworld/nonkey/interpreter/parser"
+	"github.com/kasworld/nonkey/interpreter/token"
+	"github.com/kasworld/nonkey/interpreter/vet"
+)
+
+// Server is a language server, reading requests from one stream and
+// writing its replies and notifications to another.
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	// docs holds the open documents, by URI.
+	docs map[string]*document
+
+	// shutdown is set once the client has asked us to shut down,
+	// after which only exit is expected.
+	shutdown bool
+}
+
+// NewServer returns a server reading from in and writing to out.
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:   bufio.NewReader(in),
+		out:  out,
+		docs: make(map[string]*document),
+	}
+}
+
+// Run serves requests until the client sends exit, or closes its end.
+func (s *Server) Run() error {
+	for {
+		body, err := ReadMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			s.write(errorResponse{JSONRPC: "2.0", Error: &responseError{Code: -32700, Message: err.Error()}})
+			continue
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+
+		result, rerr := s.handle(msg.Method, msg.Params)
+		if msg.ID == nil {
+			// Notifications get no reply.
+			continue
+		}
+		if rerr != nil {
+			s.write(errorResponse{JSONRPC: "2.0", ID: msg.ID, Error: rerr})
+		} else {
+			s.write(response{JSONRPC: "2.0", ID: msg.ID, Result: result})
+		}
+	}
+}
+
+// write sends a message to the client.  Failures will show up when
+// reading its next request, so they're ignored here.
+func (s *Server) write(v interface{}) {
+	WriteMessage(s.out, v)
+}
+
+// notify sends a notification to the client.
+func (s *Server) notify(method string, params interface{}) {
+	s.write(struct {
+		JSONRPC string      `json:"jsonrpc"`
+		Method  string      `json:"method"`
+		Params  interface{} `json:"params"`
+	}{"2.0", method, params})
+}
+
+// handle runs a request or notification, returning its result.
+func (s *Server) handle(method string, params json.RawMessage) (interface{}, *responseError) {
+	if s.shutdown {
+		return nil, &responseError{Code: codeInvalidRequest, Message: "server is shut down"}
+	}
+
+	switch method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":       1, // full
+				"hoverProvider":          true,
+				"definitionProvider":     true,
+				"documentSymbolProvider": true,
+				"completionProvider": map[string]interface{}{
+					"triggerCharacters": []string{"."},
+				},
+			},
+			"serverInfo": map[string]string{"name": "nonkey"},
+		}, nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var p didOpenParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, invalidParams(err)
+		}
+		s.open(p.TextDocument.URI, p.TextDocument.Text)
+		return nil, nil
+	case "textDocument/didChange":
+		var p didChangeParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, invalidParams(err)
+		}
+		if n := len(p.ContentChanges); n > 0 {
+			s.open(p.TextDocument.URI, p.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var p didCloseParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, invalidParams(err)
+		}
+		delete(s.docs, p.TextDocument.URI)
+		s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+			URI:         p.TextDocument.URI,
+			Diagnostics: []diagnostic{},
+		})
+		return nil, nil
+
+	case "textDocument/hover", "textDocument/definition", "textDocument/completion":
+		var p textDocumentPositionParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, invalidParams(err)
+		}
+		doc, ok := s.docs[p.TextDocument.URI]
+		if !ok {
+			return nil, nil
+		}
+		switch method {
+		case "textDocument/hover":
+			if h := doc.hover(p.Position); h != nil {
+				return h, nil
+			}
+		case "textDocument/definition":
+			if l := doc.definition(p.Position); l != nil {
+				return l, nil
+			}
+		default:
+			return doc.completion(p.Position), nil
+		}
+		return nil, nil
+	case "textDocument/documentSymbol":
+		var p documentSymbolParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, invalidParams(err)
+		}
+		doc, ok := s.docs[p.TextDocument.URI]
+		if !ok {
+			return nil, nil
+		}
+		return doc.symbols(doc.decls), nil
+	}
+
+	if strings.HasPrefix(method, "$/") {
+		// Optional notifications, such as $/cancelRequest.
+		return nil, nil
+	}
+	return nil, &responseError{Code: codeMethodNotFound, Message: "method not found: " + method}
+}
+
+func invalidParams(err error) *responseError {
+	return &responseError{Code: codeInvalidParams, Message: err.Error()}
+}
+
+// open parses the text of a document, and publishes its diagnostics.
+func (s *Server) open(uri, text string) {
+	doc := newDocument(uri, text)
+	s.docs[uri] = doc
+	s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: doc.diagnostics(),
+	})
+}
+
+// document is an open document, and what parsing it found.
+type document struct {
+	uri     string
+	lines   [][]rune
+	program *ast.Program
+	errors  []parser.Error
+
+	// decls holds the names the document declares at the top
+	// level, and through them those declared within functions.
+	decls []*decl
+}
+
+func newDocument(uri, text string) *document {
+	p := parser.New(lexer.New(text))
+	doc := &document{uri: uri, program: p.ParseProgram(), errors: p.Errors()}
+	for _, line := range strings.Split(text, "\n") {
+		doc.lines = append(doc.lines, []rune(line))
+	}
+	doc.decls = declarations(doc.program.Statements)
+	return doc
+}
+
+// toLSP returns the position of a character, counted in runes from
+// zero, as the protocol counts it.
+func (d *document) toLSP(line, col int) position {
+	if line < 0 {
+		line = 0
+	}
+	if line >= len(d.lines) {
+		line = len(d.lines) - 1
+	}
+	runes := d.lines[line]
+	if col > len(runes) {
+		col = len(runes)
+	}
+	if col < 0 {
+		col = 0
+	}
+	return position{Line: line, Character: len(utf16.Encode(runes[:col]))}
+}
+
+// fromLSP returns the line and rune of a position.
+func (d *document) fromLSP(p position) (int, int) {
+	if p.Line < 0 || p.Line >= len(d.lines) {
+		return -1, -1
+	}
+	runes := d.lines[p.Line]
+	units := 0
+	for i, r := range runes {
+		if units >= p.Character {
+			return p.Line, i
+		}
+		units += len(utf16.Encode([]rune{r}))
+	}
+	return p.Line, len(runes)
+}
+
+// span returns the range of length runes from a position.
+func (d *document) span(line, col, length int) rng {
+	return rng{Start: d.toLSP(line, col), End: d.toLSP(line, col+length)}
+}
+
+// tokenRange returns the range of a token naming something.
+func (d *document) tokenRange(tok token.Token) rng {
+	return d.span(tok.Line, tok.Pos-1, len([]rune(tok.Literal)))
+}
+
+// isIdentifier matches the characters the lexer allows in names.
+func isIdentifier(ch rune) bool {
+	return unicode.IsLetter(ch) || unicode.IsDigit(ch) || ch == '.' || ch == '?' || ch == '$' || ch == '_'
+}
+
+// wordAt returns the name around a position, along with where it
+// starts and the position within it.
+func (d *document) wordAt(p position) (string, int, int) {
+	line, col := d.fromLSP(p)
+	if line < 0 {
+		return "", 0, 0
+	}
+	runes := d.lines[line]
+	start, end := col, col
+	for start > 0 && isIdentifier(runes[start-1]) {
+		start--
+	}
+	for end < len(runes) && isIdentifier(runes[end]) {
+		end++
+	}
+	return string(runes[start:end]), start, col - start
+}
+
+// diagnostics returns the parse errors of the document, or if it
+// parsed, what vet finds in it.
+func (d *document) diagnostics() []diagnostic {
+	diags := []diagnostic{}
+	for _, err := range d.errors {
+		diags = append(diags, diagnostic{
+			Range:    d.span(err.Line, err.Pos-1, err.End-err.Pos),
+			Severity: severityError,
+			Source:   "nonkey",
+			Message:  err.Msg,
+		})
+	}
+	if len(d.errors) > 0 {
+		return diags
+	}
+	for _, v := range vet.Program(d.program) {
+		line, col := v.Line-1, v.Column-1
+		length := 1
+		if line < len(d.lines) {
+			runes := d.lines[line]
+			for col+length < len(runes) && isIdentifier(runes[col+length]) {
+				length++
+			}
+		}
+		diags = append(diags, diagnostic{
+			Range:    d.span(line, col, length),
+			Severity: severityWarning,
+			Source:   "nonkey vet",
+			Code:     v.Check,
+			Message:  v.Message,
+		})
+	}
+	return diags
+}
+
+// decl is a name the document declares.
+type decl struct {
+	name     string
+	tok      token.Token
+	kind     int
+	detail   string
+	children []*decl
+}
+
+// declarations returns the names declared by a list of statements,
+// including those in the blocks of its if, for, foreach, switch and
+// select statements.  Functions hold what they declare as children.
+func declarations(stmts []asti.StatementI) []*decl {
+	var decls []*decl
+	var block func(b *ast.BlockStatement)
+	block = func(b *ast.BlockStatement) {
+		if b != nil {
+			decls = append(decls, declarations(b.Statements)...)
+		}
+	}
+
+	for _, stmt := range stmts {
+		switch n := stmt.(type) {
+		case *ast.LetStatement:
+			decls = append(decls, valueDecl("let", n.Name, n.Value))
+		case *ast.ConstStatement:
+			decls = append(decls, valueDecl("const", n.Name, n.Value))
+		case *ast.ExpressionStatement:
+			switch e := n.Expression.(type) {
+			case *ast.FunctionDefineLiteral:
+				decls = append(decls, &decl{
+					name:     e.Token.Literal,
+					tok:      e.Token,
+					kind:     symbolFunction,
+					detail:   "function " + e.Token.Literal + signature(e.Parameters, e.Defaults),
+					children: declarations(e.Body.Statements),
+				})
+			case *ast.IfExpression:
+				block(e.Consequence)
+				block(e.Alternative)
+			case *ast.ForLoopExpression:
+				block(e.Consequence)
+			case *ast.ForeachStatement:
+				block(e.Body)
+			case *ast.SwitchExpression:
+				for _, c := range e.Choices {
+					block(c.Block)
+				}
+			case *ast.SelectExpression:
+				for _, c := range e.Cases {
+					block(c.Block)
+				}
+			}
+		}
+	}
+	return decls
+}
+
+// valueDecl returns the declaration of a let or const.
+func valueDecl(keyword string, name *ast.Identifier, value asti.ExpressionI) *decl {
+	d := &decl{name: name.Value, tok: name.Token, kind: symbolVariable, detail: keyword + " " + name.Value}
+	if keyword == "const" {
+		d.kind = symbolConstant
+	}
+	if fn, ok := value.(*ast.FunctionLiteral); ok {
+		d.kind = symbolFunction
+		d.detail += " = fn" + signature(fn.Parameters, fn.Defaults)
+		d.children = declarations(fn.Body.Statements)
+	}
+	return d
+}
+
+// signature returns the parameters of a function, as written.
+func signature(params []*ast.Identifier, defaults map[string]asti.ExpressionI) string {
+	var list []string
+	for _, p := range params {
+		if def, ok := defaults[p.Value]; ok {
+			list = append(list, p.Value+" = "+def.String())
+		} else {
+			list = append(list, p.Value)
+		}
+	}
+	return "(" + strings.Join(list, ", ") + ")"
+}
+
+// all returns every declaration, nested or not.
+func all(decls []*decl) []*decl {
+	var out []*decl
+	for _, d := range decls {
+		out = append(out, d)
+		out = append(out, all(d.children)...)
+	}
+	return out
+}
+
+// find returns the declaration of a name used at line, which is the
+// last one before it, or failing that, as functions may use what's
+// declared after them, the first.
+func (d *document) find(name string, line int) *decl {
+	var before, after *decl
+	for _, dl := range all(d.decls) {
+		switch {
+		case dl.name != name:
+		case dl.tok.Line <= line:
+			before = dl
+		case after == nil:
+			after = dl
+		}
+	}
+	if before != nil {
+		return before
+	}
+	return after
+}
+
+// candidates returns the names a word might refer to from the
+// position within it: the whole word, as in math.sqrt, then the part
+// up to the position, as in the h of h.name.
+func candidates(word string, at int) []string {
+	list := []string{word}
+	if end := strings.IndexByte(word[at:], '.'); end >= 0 {
+		list = append(list, word[:at+end])
+	}
+	return list
+}
+
+// lookup finds the declaration the name at a position refers to,
+// returning it with the name and where that starts, and its length.
+func (d *document) lookup(p position) (*decl, string, int, int) {
+	word, start, at := d.wordAt(p)
+	if word == "" {
+		return nil, "", 0, 0
+	}
+	for _, name := range candidates(word, at) {
+		if dl := d.find(name, p.Line); dl != nil {
+			return dl, name, start, len([]rune(name))
+		}
+	}
+
+	// A method of a value, as the shout of "x".shout().
+	from := strings.LastIndexByte(word[:at], '.') + 1
+	method := word[from:]
+	if end := strings.IndexByte(method, '.'); end >= 0 {
+		method = method[:end]
+	}
+	if from > 0 && method != "" {
+		for _, dl := range all(d.decls) {
+			if dl.kind == symbolFunction && strings.HasSuffix(dl.name, "."+method) {
+				return dl, method, start + len([]rune(word[:from])), len([]rune(method))
+			}
+		}
+	}
+	return nil, word, start, len([]rune(word))
+}
+
+func (d *document) definition(p position) *location {
+	dl, _, _, _ := d.lookup(p)
+	if dl == nil {
+		return nil
+	}
+	return &location{URI: d.uri, Range: d.tokenRange(dl.tok)}
+}
+
+func (d *document) hover(p position) *hover {
+	word, start, at := d.wordAt(p)
+	if word == "" {
+		return nil
+	}
+	markdown := func(text string, length int) *hover {
+		r := d.span(p.Line, start, length)
+		return &hover{Contents: markupContent{Kind: "markdown", Value: text}, Range: &r}
+	}
+
+	if doc, ok := builtinDocs[word]; ok {
+		return markdown("```\n"+word+"\n```\n"+doc, len([]rune(word)))
+	}
+	if dl, name, _, length := d.lookup(p); dl != nil {
+		return markdown("```\n"+dl.detail+"\n```", length)
+	} else if doc, ok := builtinDocs[name]; ok {
+		return markdown("```\n"+name+"\n```\n"+doc, length)
+	}
+
+	// A method, after the last dot.
+	dot := strings.LastIndexByte(word[:at], '.')
+	if dot < 0 {
+		return nil
+	}
+	method := word[dot+1:]
+	if end := strings.IndexByte(method, '.'); end >= 0 {
+		method = method[:end]
+	}
+	if types := methodTypes(d.methodTable(), method); len(types) > 0 {
+		r := d.span(p.Line, start+len([]rune(word[:dot+1])), len([]rune(method)))
+		text := "```\n" + method + "()\n```\nmethod of " + strings.Join(types, ", ")
+		return &hover{Contents: markupContent{Kind: "markdown", Value: text}, Range: &r}
+	}
+	return nil
+}
+
+// methods holds the names of the methods of each type, as their
+// methods() method gives them, by the type name user-defined methods
+// are named with, such as "string" in string.trim.
+var methods = func() map[string][]string {
+	objects := []object.ObjectI{
+		object.NewArray(nil), object.TRUE, &object.Builtin{}, &object.Bytes{},
+		object.NewChannel(0), &object.File{}, &object.Float{}, &object.Function{},
+		&object.Hash{}, &object.Integer{}, object.NewIterator(nil), &object.Process{},
+		&object.Range{}, object.NewSet(nil), &object.String{}, &object.Time{},
+		&object.Duration{},
+	}
+	env := object.NewEnvironment()
+	out := make(map[string][]string)
+	for _, obj := range objects {
+		list, ok := obj.InvokeMethod("methods", *env).(*object.Array)
+		if !ok {
+			continue
+		}
+		var names []string
+		for _, name := range list.Elements() {
+			names = append(names, name.Inspect())
+		}
+		out[strings.ToLower(obj.Type().String())] = names
+	}
+	return out
+}()
+
+// methodTable returns the names of the methods of each type, along
+// with those the document defines, as in function string.trim.
+func (d *document) methodTable() map[string][]string {
+	table := make(map[string][]string)
+	for typ, names := range methods {
+		table[typ] = append([]string{}, names...)
+	}
+	for _, dl := range all(d.decls) {
+		bits := strings.Split(dl.name, ".")
+		if dl.kind == symbolFunction && len(bits) == 2 {
+			table[bits[0]] = append(table[bits[0]], bits[1])
+		}
+	}
+	return table
+}
+
+// methodTypes returns the types with the named method, sorted.
+func methodTypes(table map[string][]string, method string) []string {
+	var types []string
+	for typ, names := range table {
+		for _, name := range names {
+			if name == method {
+				types = append(types, typ)
+				break
+			}
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+// completion returns what might complete the name before a position.
+//
+// After a type name and a dot, as in "string.", these are the methods
+// of that type, and after a builtin's prefix, as in "math.", those
+// builtins.  After any other dot they're the methods of every type,
+// and otherwise the builtins and what the document declares.
+func (d *document) completion(p position) completionList {
+	list := completionList{Items: []completionItem{}}
+	word, _, at := d.wordAt(p)
+	word = word[:at]
+
+	dot := strings.LastIndexByte(word, '.')
+	if dot < 0 {
+		for name := range builtinfunctions.BuiltinFunctions {
+			list.Items = append(list.Items, completionItem{Label: name, Kind: kindFunction, Detail: "builtin"})
+		}
+		seen := make(map[string]bool)
+		for _, dl := range all(d.decls) {
+			if seen[dl.name] {
+				continue
+			}
+			seen[dl.name] = true
+			kind := kindVariable
+			switch dl.kind {
+			case symbolFunction:
+				kind = kindFunction
+			case symbolConstant:
+				kind = kindConstant
+			}
+			list.Items = append(list.Items, completionItem{Label: dl.name, Kind: kind, Detail: dl.detail})
+		}
+	} else {
+		table := d.methodTable()
+		head := word[:dot]
+		if names, ok := table[head]; ok {
+			for _, name := range names {
+				list.Items = append(list.Items, completionItem{Label: name, Kind: kindMethod, Detail: head + "." + name})
+			}
+		}
+		for name := range builtinfunctions.BuiltinFunctions {
+			if strings.HasPrefix(name, head+".") {
+				list.Items = append(list.Items, completionItem{Label: name[dot+1:], Kind: kindFunction, Detail: name})
+			}
+		}
+		if len(list.Items) == 0 {
+			seen := make(map[string]bool)
+			for _, names := range table {
+				for _, name := range names {
+					if !seen[name] {
+						seen[name] = true
+						detail := "method of " + strings.Join(methodTypes(table, name), ", ")
+						list.Items = append(list.Items, completionItem{Label: name, Kind: kindMethod, Detail: detail})
+					}
+				}
+			}
+		}
+	}
+
+	sort.Slice(list.Items, func(i, j int) bool {
+		return list.Items[i].Label < list.Items[j].Label
+	})
+	return list
+}
+
+// symbols returns the document symbols of declarations.
+func (d *document) symbols(decls []*decl) []documentSymbol {
+	out := []documentSymbol{}
+	for _, dl := range decls {
+		r := d.tokenRange(dl.tok)
+		out = append(out, documentSymbol{
+			Name:           dl.name,
+			Detail:         dl.detail,
+			Kind:           dl.kind,
+			Range:          r,
+			SelectionRange: r,
+			Children:       d.symbols(dl.children),
+		})
+	}
+	return out
+}
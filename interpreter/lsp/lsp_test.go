@@ -0,0 +1,273 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	// The evaluator registers the builtins.
+	_ "github.com/kasworld/nonkey/interpreter/evaluator"
+)
+
+// client talks to a server running in-process.
+type client struct {
+	t    *testing.T
+	in   *io.PipeWriter
+	out  *bufio.Reader
+	id   int
+	done chan error
+}
+
+func newClient(t *testing.T) *client {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{t: t, in: inW, out: bufio.NewReader(outR), done: make(chan error, 1)}
+	go func() {
+		err := NewServer(inR, outW).Run()
+		outW.Close()
+		c.done <- err
+	}()
+	return c
+}
+
+// read returns the next message from the server.
+func (c *client) read() message {
+	body, err := ReadMessage(c.out)
+	if err != nil {
+		c.t.Fatalf("reading: %s", err)
+	}
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		c.t.Fatalf("%s: %s", body, err)
+	}
+	return msg
+}
+
+// notify sends a notification.
+func (c *client) notify(method string, params interface{}) {
+	err := WriteMessage(c.in, map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+	if err != nil {
+		c.t.Fatalf("writing: %s", err)
+	}
+}
+
+// call sends a request, and decodes the result of its reply into
+// result.
+func (c *client) call(method string, params interface{}, result interface{}) *responseError {
+	c.id++
+	err := WriteMessage(c.in, map[string]interface{}{"jsonrpc": "2.0", "id": c.id, "method": method, "params": params})
+	if err != nil {
+		c.t.Fatalf("writing: %s", err)
+	}
+	msg := c.read()
+	if msg.ID == nil || string(*msg.ID) != strings.TrimSpace(string(mustJSON(c.id))) {
+		c.t.Fatalf("%s: expected reply %d, got %+v", method, c.id, msg)
+	}
+	if msg.Error != nil {
+		return msg.Error
+	}
+	if result != nil {
+		if err := json.Unmarshal(msg.Result, result); err != nil {
+			c.t.Fatalf("%s: %s: %s", method, msg.Result, err)
+		}
+	}
+	return nil
+}
+
+// diagnostics reads the diagnostics published for a document.
+func (c *client) diagnostics() publishDiagnosticsParams {
+	msg := c.read()
+	if msg.Method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("expected diagnostics, got %+v", msg)
+	}
+	var p publishDiagnosticsParams
+	if err := json.Unmarshal(msg.Params, &p); err != nil {
+		c.t.Fatalf("%s", err)
+	}
+	return p
+}
+
+func mustJSON(v interface{}) []byte {
+	out, _ := json.Marshal(v)
+	return out
+}
+
+func at(line, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]string{"uri": "file:///t.mon"},
+		"position":     position{Line: line, Character: character},
+	}
+}
+
+const source = `let total = 0;
+function add(a, b = 1) {
+    let sum = a + b;
+    return sum;
+}
+function string.shout() { return self + "!"; }
+total = add(total, math.abs(-2));
+puts(total, "😀".shout());
+`
+
+func TestServer(t *testing.T) {
+	c := newClient(t)
+
+	var init struct {
+		Capabilities map[string]interface{} `json:"capabilities"`
+	}
+	c.call("initialize", map[string]interface{}{"capabilities": map[string]interface{}{}}, &init)
+	if init.Capabilities["hoverProvider"] != true {
+		t.Errorf("no hover in %v", init.Capabilities)
+	}
+	c.notify("initialized", map[string]interface{}{})
+
+	// A parse error.
+	c.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]string{"uri": "file:///t.mon", "languageId": "monkey", "text": "let a = ;\n"},
+	})
+	diags := c.diagnostics()
+	if len(diags.Diagnostics) != 1 || diags.Diagnostics[0].Severity != severityError {
+		t.Fatalf("expected a parse error, got %+v", diags)
+	}
+
+	// Fixing it, vet has its say.
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": "file:///t.mon", "version": 2},
+		"contentChanges": []map[string]string{{"text": "let unused = 1;\n"}},
+	})
+	diags = c.diagnostics()
+	if len(diags.Diagnostics) != 1 || diags.Diagnostics[0].Code != "unused" {
+		t.Fatalf("expected an unused warning, got %+v", diags)
+	}
+	if r := diags.Diagnostics[0].Range; r.Start.Character != 4 || r.End.Character != 10 {
+		t.Errorf("wrong range %+v", r)
+	}
+
+	// A method call earlier on the line leaves the ranges alone.
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": "file:///t.mon", "version": 3},
+		"contentChanges": []map[string]string{{"text": "let x = [1]; puts(x.len()); let unused = 1;\n"}},
+	})
+	diags = c.diagnostics()
+	if len(diags.Diagnostics) != 1 || diags.Diagnostics[0].Code != "unused" {
+		t.Fatalf("expected an unused warning, got %+v", diags)
+	}
+	if r := diags.Diagnostics[0].Range; r.Start.Character != 32 || r.End.Character != 38 {
+		t.Errorf("wrong range %+v", r)
+	}
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": "file:///t.mon", "version": 4},
+		"contentChanges": []map[string]string{{"text": "let x = [1];\nputs(x.len(), );\n"}},
+	})
+	diags = c.diagnostics()
+	if len(diags.Diagnostics) != 1 || diags.Diagnostics[0].Severity != severityError {
+		t.Fatalf("expected a parse error, got %+v", diags)
+	}
+	if r := diags.Diagnostics[0].Range; r.Start.Line != 1 || r.Start.Character != 14 || r.End.Character != 15 {
+		t.Errorf("wrong range %+v", r)
+	}
+
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": "file:///t.mon", "version": 5},
+		"contentChanges": []map[string]string{{"text": source}},
+	})
+	if diags = c.diagnostics(); len(diags.Diagnostics) != 0 {
+		t.Fatalf("expected no diagnostics, got %+v", diags)
+	}
+
+	// Hovering over a builtin, and a function.
+	var h hover
+	c.call("textDocument/hover", at(6, 25), &h)
+	if !strings.Contains(h.Contents.Value, "math.abs") || !strings.Contains(h.Contents.Value, "val = math.abs") {
+		t.Errorf("wrong hover for math.abs: %q", h.Contents.Value)
+	}
+	c.call("textDocument/hover", at(6, 9), &h)
+	if !strings.Contains(h.Contents.Value, "function add(a, b = 1)") {
+		t.Errorf("wrong hover for add: %q", h.Contents.Value)
+	}
+
+	// Definitions.
+	tests := []struct {
+		line, character int
+		expected        position
+	}{
+		{6, 9, position{1, 9}},  // add
+		{6, 2, position{0, 4}},  // total
+		{3, 12, position{2, 8}}, // sum
+		{7, 18, position{5, 9}}, // shout, after a surrogate pair
+	}
+	for _, tt := range tests {
+		var l *location
+		c.call("textDocument/definition", at(tt.line, tt.character), &l)
+		if l == nil || l.Range.Start != tt.expected {
+			t.Errorf("definition at %d:%d: expected %v, got %+v", tt.line, tt.character, tt.expected, l)
+		}
+	}
+	var none *location
+	c.call("textDocument/definition", at(7, 1), &none)
+	if none != nil {
+		t.Errorf("expected no definition of puts, got %+v", none)
+	}
+
+	// Completions.
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": "file:///t.mon", "version": 4},
+		"contentChanges": []map[string]string{{"text": "math.\nstring.\nx.\nad\nfunction string.shout() { return self; }\n"}},
+	})
+	c.diagnostics()
+	complete := func(line, character int) map[string]string {
+		var list completionList
+		c.call("textDocument/completion", at(line, character), &list)
+		labels := make(map[string]string)
+		for _, item := range list.Items {
+			labels[item.Label] = item.Detail
+		}
+		return labels
+	}
+	if got := complete(0, 5); got["abs"] != "math.abs" || got["sqrt"] == "" {
+		t.Errorf("wrong completions for math.: %v", got)
+	}
+	if got := complete(1, 7); got["len"] == "" || got["shout"] != "string.shout" {
+		t.Errorf("wrong completions for string.: %v", got)
+	}
+	if got := complete(2, 2); got["to_i"] != "method of string" || !strings.Contains(got["len"], "array") {
+		t.Errorf("wrong completions for x.: %v", got)
+	}
+	if got := complete(3, 2); got["puts"] != "builtin" {
+		t.Errorf("wrong completions at the top level: %v", got)
+	}
+
+	// Symbols.
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": "file:///t.mon", "version": 5},
+		"contentChanges": []map[string]string{{"text": source}},
+	})
+	c.diagnostics()
+	var symbols []documentSymbol
+	c.call("textDocument/documentSymbol", map[string]interface{}{
+		"textDocument": map[string]string{"uri": "file:///t.mon"},
+	}, &symbols)
+	var names []string
+	for _, s := range symbols {
+		names = append(names, s.Name)
+		for _, child := range s.Children {
+			names = append(names, s.Name+"/"+child.Name)
+		}
+	}
+	if strings.Join(names, " ") != "total add add/sum string.shout" {
+		t.Errorf("wrong symbols %v", names)
+	}
+
+	// Unknown requests.
+	if err := c.call("textDocument/formatting", at(0, 0), nil); err == nil || err.Code != codeMethodNotFound {
+		t.Errorf("expected method not found, got %v", err)
+	}
+
+	c.call("shutdown", nil, nil)
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("run: %s", err)
+	}
+}
@@ -0,0 +1,175 @@
+package object
+
+import "bytes"
+
+// Equals reports whether two objects are structurally equal.
+//
+// Numbers compare by value, so 1 == 1.0, arrays are equal when they
+// hold equal elements in the same order, hashes when they hold the
+// same keys bound to equal values, and sets when they hold the same
+// members.  Strings and bytes compare by content, and times are equal
+// when they're the same instant.  Everything else falls back to
+// identity.
+func Equals(a, b ObjectI) bool {
+	switch a := a.(type) {
+	case *Integer:
+		switch b := b.(type) {
+		case *Integer:
+			return a.Value == b.Value
+		case *Float:
+			return float64(a.Value) == b.Value
+		}
+	case *Float:
+		switch b := b.(type) {
+		case *Integer:
+			return a.Value == float64(b.Value)
+		case *Float:
+			return a.Value == b.Value
+		}
+	case *String:
+		if b, ok := b.(*String); ok {
+			return a.Value == b.Value
+		}
+	case *Bytes:
+		if b, ok := b.(*Bytes); ok {
+			return bytes.Equal(a.Value, b.Value)
+		}
+	case *Time:
+		if b, ok := b.(*Time); ok {
+			return a.Value.Equal(b.Value)
+		}
+	case *Duration:
+		if b, ok := b.(*Duration); ok {
+			return a.Value == b.Value
+		}
+	case *Boolean:
+		if b, ok := b.(*Boolean); ok {
+			return a.Value == b.Value
+		}
+	case *Null:
+		_, ok := b.(*Null)
+		return ok
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !Equals(a.Get(i), b.Get(i)) {
+				return false
+			}
+		}
+		return true
+	case *Hash:
+		b, ok := b.(*Hash)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+		for _, pair := range a.Pairs() {
+			other, ok := b.Get(pair.Key.(HashableI).HashKey())
+			if !ok || !Equals(pair.Value, other.Value) {
+				return false
+			}
+		}
+		return true
+	case *Set:
+		b, ok := b.(*Set)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+		return a.Difference(b).Len() == 0
+	}
+	return a == b
+}
+
+// Compare orders two objects, returning -1, 0, or +1.
+//
+// Numbers, strings, times, durations, and booleans (false before
+// true) are ordered naturally, and arrays element by element with a shorter prefix
+// first.  The boolean result is false if the objects can't be
+// ordered against each other.
+func Compare(a, b ObjectI) (int, bool) {
+	switch a := a.(type) {
+	case *Integer:
+		switch b := b.(type) {
+		case *Integer:
+			return compareInt(a.Value, b.Value), true
+		case *Float:
+			return compareFloat(float64(a.Value), b.Value), true
+		}
+	case *Float:
+		switch b := b.(type) {
+		case *Integer:
+			return compareFloat(a.Value, float64(b.Value)), true
+		case *Float:
+			return compareFloat(a.Value, b.Value), true
+		}
+	case *String:
+		if b, ok := b.(*String); ok {
+			switch {
+			case a.Value < b.Value:
+				return -1, true
+			case a.Value > b.Value:
+				return 1, true
+			}
+			return 0, true
+		}
+	case *Time:
+		if b, ok := b.(*Time); ok {
+			switch {
+			case a.Value.Before(b.Value):
+				return -1, true
+			case a.Value.After(b.Value):
+				return 1, true
+			}
+			return 0, true
+		}
+	case *Duration:
+		if b, ok := b.(*Duration); ok {
+			return compareInt(int64(a.Value), int64(b.Value)), true
+		}
+	case *Boolean:
+		if b, ok := b.(*Boolean); ok {
+			switch {
+			case a.Value == b.Value:
+				return 0, true
+			case b.Value:
+				return -1, true
+			}
+			return 1, true
+		}
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok {
+			return 0, false
+		}
+		for i := 0; i < a.Len() && i < b.Len(); i++ {
+			c, ok := Compare(a.Get(i), b.Get(i))
+			if !ok || c != 0 {
+				return c, ok
+			}
+		}
+		return compareInt(int64(a.Len()), int64(b.Len())), true
+	}
+	return 0, false
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -3,11 +3,19 @@ package object
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/kasworld/nonkey/interpreter/asti"
 )
 
 func (env *Environment) String() string {
+	env.mu.RLock()
+	defer env.mu.RUnlock()
+
 	var buf bytes.Buffer
 	fmt.Fprintf(&buf, "Environment[\n")
 	fmt.Fprintf(&buf, "store[\n")
@@ -35,7 +43,15 @@ func (env *Environment) String() string {
 }
 
 // Environment stores our functions, variables, constants, etc.
+//
+// Environments are shared by spawned functions, so access to their
+// fields is guarded by mu.
 type Environment struct {
+	// mu guards the fields below it, other than outer and spawned,
+	// which never change.  It's a pointer so the copies handed to
+	// InvokeMethod share the lock.
+	mu *sync.RWMutex
+
 	// store holds variables, including functions.
 	store map[string]ObjectI
 
@@ -49,22 +65,82 @@ type Environment struct {
 	// permit stores the names of variables we can set in this
 	// environment, if any
 	permit []string
+
+	// yield hands a value to the consumer of the generator whose
+	// body runs in this environment.  It reports false once the
+	// generator has been abandoned.
+	yield func(ObjectI) bool
+
+	// clock, if set, replaces time.Now for this environment and
+	// those it encloses.
+	clock func() time.Time
+
+	// errorLog, if set, replaces os.Stderr as where errors are
+	// reported as they happen, for this environment and those it
+	// encloses.
+	errorLog io.Writer
+
+	// frame is set on the environment of each function call.
+	frame *Frame
+
+	// trace, if set, is called before each statement runs.
+	trace func(stmt asti.StatementI, env *Environment)
+
+	// callTrace, if set, is called as each function call starts.
+	callTrace func(env *Environment) func()
+
+	// branchTrace, if set, is called as each branch is taken.
+	branchTrace func(branch asti.NodeI, env *Environment)
+
+	// spawned counts the functions started by spawn in the program
+	// running in this environment which haven't finished.  It's
+	// shared by every environment the outermost one encloses.
+	spawned *sync.WaitGroup
+}
+
+// Frame records a function call, for debuggers and error reports.
+type Frame struct {
+	// Name is the name of the function, or "fn" for an anonymous
+	// one.
+	Name string
+
+	// Function is the function called.
+	Function *Function
+
+	// Call is the node which called the function.
+	Call asti.NodeI
+
+	// Caller is the environment the function was called from.
+	Caller *Environment
 }
 
 // NewEnvironment creates new environment
 func NewEnvironment() *Environment {
 	s := make(map[string]ObjectI)
 	r := make(map[string]bool)
-	return &Environment{store: s, readonly: r, outer: nil}
+	return &Environment{mu: &sync.RWMutex{}, store: s, readonly: r, outer: nil,
+		spawned: &sync.WaitGroup{}}
 }
 
 // NewEnclosedEnvironment create new environment by outer parameter
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
-	env.outer = outer
+	env.enclose(outer)
 	return env
 }
 
+// enclose makes env a scope within outer, following the same traces
+// and counting spawned functions with it.
+func (env *Environment) enclose(outer *Environment) {
+	outer.mu.RLock()
+	defer outer.mu.RUnlock()
+	env.outer = outer
+	env.spawned = outer.spawned
+	env.trace = outer.trace
+	env.callTrace = outer.callTrace
+	env.branchTrace = outer.branchTrace
+}
+
 // NewTemporaryScope creates a temporary scope where some values
 // are ignored.
 //
@@ -73,17 +149,205 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 // keys from persisting.
 func NewTemporaryScope(outer *Environment, keys []string) *Environment {
 	env := NewEnvironment()
-	env.outer = outer
+	env.enclose(outer)
 	env.permit = keys
 	return env
 }
 
+// Spawned returns the count of the functions started by spawn in the
+// program running in this environment which haven't finished.
+// Programs run in environments made separately by NewEnvironment
+// have counts of their own, so don't wait for each other.
+func (e *Environment) Spawned() *sync.WaitGroup {
+	return e.spawned
+}
+
+// SetYield marks this environment as the body of a generator, with
+// `yield` handing its values to fn.
+func (e *Environment) SetYield(fn func(ObjectI) bool) {
+	e.mu.Lock()
+	e.yield = fn
+	e.mu.Unlock()
+}
+
+// Yield returns the yield function of the innermost generator whose
+// body encloses this environment, or nil outside of a generator.
+func (e *Environment) Yield() func(ObjectI) bool {
+	for ; e != nil; e = e.outer {
+		e.mu.RLock()
+		yield := e.yield
+		e.mu.RUnlock()
+		if yield != nil {
+			return yield
+		}
+	}
+	return nil
+}
+
+// SetClock makes time.now() in this environment, and those it
+// encloses, return the time fn gives rather than the real time.
+//
+// This allows hosts to make scripts which use the time deterministic.
+func (e *Environment) SetClock(fn func() time.Time) {
+	e.mu.Lock()
+	e.clock = fn
+	e.mu.Unlock()
+}
+
+// Now returns the current time, by the innermost clock set on this
+// environment or those enclosing it.
+func (e *Environment) Now() time.Time {
+	for ; e != nil; e = e.outer {
+		e.mu.RLock()
+		clock := e.clock
+		e.mu.RUnlock()
+		if clock != nil {
+			return clock()
+		}
+	}
+	return time.Now()
+}
+
+// SetErrorLog makes errors in this environment, and those it
+// encloses, be reported to w as they happen, rather than to os.Stderr.
+//
+// This allows hosts, such as the test runner, to keep them out of
+// their own output.
+func (e *Environment) SetErrorLog(w io.Writer) {
+	e.mu.Lock()
+	e.errorLog = w
+	e.mu.Unlock()
+}
+
+// ErrorLog returns where errors are reported as they happen, by the
+// innermost error log set on this environment or those enclosing it.
+func (e *Environment) ErrorLog() io.Writer {
+	for ; e != nil; e = e.outer {
+		e.mu.RLock()
+		w := e.errorLog
+		e.mu.RUnlock()
+		if w != nil {
+			return w
+		}
+	}
+	return os.Stderr
+}
+
+// SetFrame marks this environment as that of a function call.
+func (e *Environment) SetFrame(f *Frame) {
+	e.mu.Lock()
+	e.frame = f
+	e.mu.Unlock()
+}
+
+// Frame returns the innermost function call this environment is
+// part of, or nil at the top level.
+func (e *Environment) Frame() *Frame {
+	for ; e != nil; e = e.outer {
+		e.mu.RLock()
+		frame := e.frame
+		e.mu.RUnlock()
+		if frame != nil {
+			return frame
+		}
+	}
+	return nil
+}
+
+// Stack returns the function calls which led to this environment,
+// innermost first.
+func (e *Environment) Stack() []*Frame {
+	var stack []*Frame
+	for f := e.Frame(); f != nil; f = f.Caller.Frame() {
+		stack = append(stack, f)
+	}
+	return stack
+}
+
+// SetTrace makes the evaluator call fn before running each statement
+// in this environment, and in those later created within it.
+//
+// This allows hosts to follow a program as it runs, as a debugger
+// does.
+func (e *Environment) SetTrace(fn func(stmt asti.StatementI, env *Environment)) {
+	e.mu.Lock()
+	e.trace = fn
+	e.mu.Unlock()
+}
+
+// Trace returns the function set by SetTrace, if any.
+func (e *Environment) Trace() func(stmt asti.StatementI, env *Environment) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.trace
+}
+
+// SetCallTrace makes the evaluator call fn as each function call in
+// this environment, and in those later created within it, starts.
+// It's given the environment of the call, whose Frame describes it,
+// and the function it returns is called as the call finishes.
+//
+// This allows hosts to time calls, as a profiler does.
+func (e *Environment) SetCallTrace(fn func(env *Environment) func()) {
+	e.mu.Lock()
+	e.callTrace = fn
+	e.mu.Unlock()
+}
+
+// CallTrace returns the function set by SetCallTrace, if any.
+func (e *Environment) CallTrace() func(env *Environment) func() {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.callTrace
+}
+
+// SetBranchTrace makes the evaluator call fn as each `if` and
+// `switch` in this environment, and in those later created within
+// it, chooses a branch.  It's given the block chosen, or the `if` or
+// `switch` itself when it has no block for the case, as an `if`
+// without an `else` whose condition is false.
+//
+// This allows hosts to tell which branches ran, as coverage does.
+func (e *Environment) SetBranchTrace(fn func(branch asti.NodeI, env *Environment)) {
+	e.mu.Lock()
+	e.branchTrace = fn
+	e.mu.Unlock()
+}
+
+// BranchTrace returns the function set by SetBranchTrace, if any.
+func (e *Environment) BranchTrace() func(branch asti.NodeI, env *Environment) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.branchTrace
+}
+
+// Outer returns the enclosing environment, or nil for the outermost.
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+// Locals returns a copy of the variables stored in this environment,
+// leaving out those of the environments enclosing it.
+func (e *Environment) Locals() map[string]ObjectI {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	locals := make(map[string]ObjectI, len(e.store))
+	for name, val := range e.store {
+		locals[name] = val
+	}
+	return locals
+}
+
 // Names returns the names of every known-value with the
 // given prefix.
 //
 // This function is used by `invokeMethod` to get the methods
 // associated with a particular class-type.
 func (e *Environment) Names(prefix string) []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
 	var ret []string
 
 	for key := range e.store {
@@ -102,7 +366,9 @@ func (e *Environment) Names(prefix string) []string {
 
 // Get returns the value of a given variable, by name.
 func (e *Environment) Get(name string) (ObjectI, bool) {
+	e.mu.RLock()
 	obj, ok := e.store[name]
+	e.mu.RUnlock()
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.Get(name)
 	}
@@ -128,6 +394,7 @@ func (e *Environment) Set(name string, val ObjectI) ObjectI {
 	//
 	// The variable inside the function _should_ not be constant.
 	//
+	e.mu.Lock()
 	cur := e.store[name]
 	if cur != nil && e.readonly[name] {
 		fmt.Printf("Attempting to modify '%s' denied; it was defined as a constant.\n", name)
@@ -142,9 +409,11 @@ func (e *Environment) Set(name string, val ObjectI) ObjectI {
 			// we're permitted to store this variable
 			if v == name {
 				e.store[name] = val
+				e.mu.Unlock()
 				return val
 			}
 		}
+		e.mu.Unlock()
 		// ok we're not permitted, we must store in the parent
 		if e.outer != nil {
 			return e.outer.Set(name, val)
@@ -154,11 +423,14 @@ func (e *Environment) Set(name string, val ObjectI) ObjectI {
 		}
 	}
 	e.store[name] = val
+	e.mu.Unlock()
 	return val
 }
 
 // SetConst sets the value of a constant by name.
 func (e *Environment) SetConst(name string, val ObjectI) ObjectI {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 
 	// store the value
 	e.store[name] = val
@@ -39,11 +39,16 @@ type HashableI interface {
 // be generated instead.
 type IterableI interface {
 
-	// Reset the state of any previous iteration.
-	Reset()
+	// Iter returns a new iterator positioned before the first
+	// item.  Each call starts an independent pass, so the same
+	// object can be iterated by nested loops.
+	Iter() IteratorI
+}
+
+// Iterator walks over a sequence, one item at a time.
+type IteratorI interface {
 
-	// Get the next "thing" from the object being iterated
-	// over.
+	// Get the next "thing" from the sequence.
 	//
 	// The return values are the item which is to be returned
 	// next, the index of that object, and finally a boolean
@@ -51,6 +56,7 @@ type IterableI interface {
 	//
 	// If the boolean value returned is false then that
 	// means the iteration has completed and no further
-	// items are available.
+	// items are available.  An iterator which fails returns
+	// an *Error as the item, and then stops.
 	Next() (ObjectI, ObjectI, bool)
 }
@@ -2,20 +2,55 @@ package object
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
+	"hash/fnv"
 	"sort"
 	"strings"
 
 	"github.com/kasworld/nonkey/enum/objecttype"
 )
 
-// Array wraps ObjectI array and implements ObjectI interface.
+// Array wraps a persistent vector of ObjectI and implements ObjectI and
+// Hashable interfaces.
+//
+// Arrays are values: Set and Append return a new array which shares
+// storage with the original, leaving the original unchanged.  The zero
+// value is an empty array.
 type Array struct {
-	// Elements holds the individual members of the array we're wrapping.
-	Elements []ObjectI
+	// elements holds the individual members of the array we're wrapping.
+	elements vector
+}
+
+// NewArray creates an array holding the given elements.
+func NewArray(elements []ObjectI) *Array {
+	return &Array{elements: newVector(elements)}
+}
 
-	// offset holds our iteration-offset.
-	offset int
+// Len returns the number of elements in the array.
+func (ao *Array) Len() int {
+	return ao.elements.count
+}
+
+// Get returns the element at the given index, which must be in range.
+func (ao *Array) Get(i int) ObjectI {
+	return ao.elements.get(i)
+}
+
+// Set returns a copy of the array with the element at the given index,
+// which must be in range, replaced.
+func (ao *Array) Set(i int, val ObjectI) *Array {
+	return &Array{elements: ao.elements.set(i, val)}
+}
+
+// Append returns a copy of the array with val added at the end.
+func (ao *Array) Append(val ObjectI) *Array {
+	return &Array{elements: ao.elements.append(val)}
+}
+
+// Elements returns the members of the array as a new go slice.
+func (ao *Array) Elements() []ObjectI {
+	return ao.elements.slice()
 }
 
 // Type returns the type of this object.
@@ -26,19 +61,53 @@ func (ao *Array) Type() objecttype.ObjectType {
 // Inspect returns a string-representation of the given object.
 func (ao *Array) Inspect() string {
 	var out bytes.Buffer
-	elements := make([]string, 0)
-	for _, e := range ao.Elements {
+	elements := make([]string, 0, ao.Len())
+	for _, e := range ao.Elements() {
 		elements = append(elements, e.Inspect())
 	}
 	fmt.Fprintf(&out, "[%v]", strings.Join(elements, ", "))
 	return out.String()
 }
 
+// HashKey returns a hash key for the given object, built from the
+// hash keys of the elements, so arrays which are equal hash alike.
+func (ao *Array) HashKey() HashKey {
+	h := fnv.New64a()
+	buf := make([]byte, 9)
+	for _, e := range ao.Elements() {
+		k := elementKey(e)
+		buf[0] = byte(k.Type)
+		binary.LittleEndian.PutUint64(buf[1:], k.Value)
+		h.Write(buf)
+	}
+	return HashKey{Type: ao.Type(), Value: h.Sum64()}
+}
+
+// elementKey returns a key for an element of an array.  Hashes, which
+// aren't hashable themselves, are keyed by their pairs in any order,
+// and anything else by its string-representation.
+func elementKey(e ObjectI) HashKey {
+	switch e := e.(type) {
+	case HashableI:
+		return e.HashKey()
+	case *Hash:
+		var sum uint64
+		for _, pair := range e.Pairs() {
+			k := hamtHash(pair.Key.(HashableI).HashKey())
+			sum += hamtHash(HashKey{Type: e.Type(), Value: k*31 + hamtHash(elementKey(pair.Value))})
+		}
+		return HashKey{Type: e.Type(), Value: sum}
+	}
+	h := fnv.New64a()
+	h.Write([]byte(e.Inspect()))
+	return HashKey{Type: e.Type(), Value: h.Sum64()}
+}
+
 // InvokeMethod invokes a method against the object.
 // (Built-in methods only.)
 func (ao *Array) InvokeMethod(method string, env Environment, args ...ObjectI) ObjectI {
 	if method == "len" {
-		return &Integer{Value: int64(len(ao.Elements))}
+		return &Integer{Value: int64(ao.Len())}
 	}
 	if method == "methods" {
 		static := []string{"len", "methods"}
@@ -56,34 +125,34 @@ func (ao *Array) InvokeMethod(method string, env Environment, args ...ObjectI) O
 		for i, txt := range names {
 			result[i] = &String{Value: txt}
 		}
-		return &Array{Elements: result}
+		return NewArray(result)
 	}
 	return nil
 }
 
-// Reset implements the Iterable interface, and allows the contents
-// of the array to be reset to allow re-iteration.
-func (ao *Array) Reset() {
-	ao.offset = 0
-}
-
-// Next implements the Iterable interface, and allows the contents
-// of our array to be iterated over.
-func (ao *Array) Next() (ObjectI, ObjectI, bool) {
-	if ao.offset < len(ao.Elements) {
-		ao.offset++
-
-		element := ao.Elements[ao.offset-1]
-		return element, &Integer{Value: int64(ao.offset - 1)}, true
-	}
-
-	return nil, &Integer{Value: 0}, false
+// Iter implements the Iterable interface.  The iterator walks the
+// array as it was when Iter was called.
+func (ao *Array) Iter() IteratorI {
+	elements := ao.elements
+	i := 0
+	return NewIterator(func() (ObjectI, ObjectI, bool) {
+		if i >= elements.count {
+			return nil, &Integer{Value: 0}, false
+		}
+		i++
+		return elements.get(i - 1), &Integer{Value: int64(i - 1)}, true
+	})
 }
 
 // ToInterface converts this object to a go-interface, which will allow
 // it to be used naturally in our sprintf/printf primitives.
 //
-// It might also be helpful for embedded users.
+// It might also be helpful for embedded users.  Arrays become a
+// []interface{} of their converted elements.
 func (ao *Array) ToInterface() interface{} {
-	return "<ARRAY>"
+	out := make([]interface{}, 0, ao.Len())
+	for _, e := range ao.Elements() {
+		out = append(out, e.ToInterface())
+	}
+	return out
 }
@@ -54,7 +54,7 @@ func (b *Boolean) InvokeMethod(method string, env Environment, args ...ObjectI)
 		for i, txt := range names {
 			result[i] = &String{Value: txt}
 		}
-		return &Array{Elements: result}
+		return NewArray(result)
 	}
 	return nil
 }
@@ -34,7 +34,7 @@ func (b *Builtin) InvokeMethod(method string, env Environment, args ...ObjectI)
 		for i, txt := range names {
 			result[i] = &String{Value: txt}
 		}
-		return &Array{Elements: result}
+		return NewArray(result)
 	}
 	return nil
 }
@@ -0,0 +1,93 @@
+package object
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"github.com/kasworld/nonkey/enum/objecttype"
+)
+
+// Bytes wraps []byte and implements ObjectI, Hashable and Iterable
+// interfaces.
+//
+// Unlike String it may hold any data, so it's what binary file reads
+// return.  Bytes are never modified in place.
+type Bytes struct {
+	// Value holds the data this object wraps.
+	Value []byte
+}
+
+// Type returns the type of this object.
+func (b *Bytes) Type() objecttype.ObjectType {
+	return objecttype.BYTES
+}
+
+// Inspect returns a string-representation of the given object.
+func (b *Bytes) Inspect() string {
+	return fmt.Sprintf("b%q", b.Value)
+}
+
+// HashKey returns a hash key for the given object.
+func (b *Bytes) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write(b.Value)
+	return HashKey{Type: b.Type(), Value: h.Sum64()}
+}
+
+// Iter implements the Iterable interface, yielding each byte as an
+// integer.
+func (b *Bytes) Iter() IteratorI {
+	i := 0
+	return NewIterator(func() (ObjectI, ObjectI, bool) {
+		if i >= len(b.Value) {
+			return nil, &Integer{Value: 0}, false
+		}
+		i++
+		return &Integer{Value: int64(b.Value[i-1])}, &Integer{Value: int64(i - 1)}, true
+	})
+}
+
+// InvokeMethod invokes a method against the object.
+// (Built-in methods only.)
+func (b *Bytes) InvokeMethod(method string, env Environment, args ...ObjectI) ObjectI {
+	switch method {
+	case "len":
+		return &Integer{Value: int64(len(b.Value))}
+	case "to_string":
+		return &String{Value: string(b.Value)}
+	case "to_array":
+		result := make([]ObjectI, len(b.Value))
+		for i, c := range b.Value {
+			result[i] = &Integer{Value: int64(c)}
+		}
+		return NewArray(result)
+	case "methods":
+		static := []string{"len", "methods", "to_array", "to_string"}
+		dynamic := env.Names("bytes.")
+
+		var names []string
+		names = append(names, static...)
+		for _, e := range dynamic {
+			bits := strings.Split(e, ".")
+			names = append(names, bits[1])
+		}
+		sort.Strings(names)
+
+		result := make([]ObjectI, len(names))
+		for i, txt := range names {
+			result[i] = &String{Value: txt}
+		}
+		return NewArray(result)
+	}
+	return nil
+}
+
+// ToInterface converts this object to a go-interface, which will allow
+// it to be used naturally in our sprintf/printf primitives.
+//
+// It might also be helpful for embedded users.
+func (b *Bytes) ToInterface() interface{} {
+	return b.Value
+}
@@ -0,0 +1,161 @@
+package object
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kasworld/nonkey/enum/objecttype"
+)
+
+// Channel wraps a go channel of ObjectI and implements ObjectI and
+// Iterable interfaces.
+//
+// Channels connect spawned functions: send blocks until there is room
+// (or a receiver, for an unbuffered channel), and recv blocks until a
+// value arrives.  Once closed, recv drains what's left and then
+// returns null.
+type Channel struct {
+	// Value is the go channel we wrap.
+	Value chan ObjectI
+
+	// mu guards closed, so sending on or closing a closed channel
+	// is an error rather than a panic.
+	mu     sync.Mutex
+	closed bool
+
+	// done is closed first by Close, to release blocked senders,
+	// and sending counts them, so Value is only closed once they've
+	// gone.
+	done    chan struct{}
+	sending sync.WaitGroup
+}
+
+// NewChannel creates a channel which buffers size values.
+func NewChannel(size int) *Channel {
+	return &Channel{Value: make(chan ObjectI, size), done: make(chan struct{})}
+}
+
+// Send delivers a value, blocking until it's accepted.  Sending on a
+// closed channel, or one closed while the send is blocked, is an
+// error.
+func (c *Channel) Send(val ObjectI) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return fmt.Errorf("send on closed channel")
+	}
+	c.sending.Add(1)
+	c.mu.Unlock()
+	defer c.sending.Done()
+
+	select {
+	case c.Value <- val:
+		return nil
+	case <-c.done:
+		return fmt.Errorf("send on closed channel")
+	}
+}
+
+// Recv waits for a value.  The boolean is false once the channel is
+// closed and empty.
+func (c *Channel) Recv() (ObjectI, bool) {
+	val, ok := <-c.Value
+	if !ok {
+		return NULL, false
+	}
+	return val, true
+}
+
+// Close closes the channel; pending values can still be received,
+// and blocked senders fail.
+func (c *Channel) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return fmt.Errorf("close of closed channel")
+	}
+	c.closed = true
+	close(c.done)
+	c.mu.Unlock()
+
+	c.sending.Wait()
+	close(c.Value)
+	return nil
+}
+
+// Iter implements the Iterable interface, receiving values until the
+// channel is closed.
+func (c *Channel) Iter() IteratorI {
+	var count int64
+	return NewIterator(func() (ObjectI, ObjectI, bool) {
+		val, ok := c.Recv()
+		if !ok {
+			return nil, &Integer{Value: 0}, false
+		}
+		count++
+		return val, &Integer{Value: count - 1}, true
+	})
+}
+
+// Type returns the type of this object.
+func (c *Channel) Type() objecttype.ObjectType {
+	return objecttype.CHANNEL
+}
+
+// Inspect returns a string-representation of the given object.
+func (c *Channel) Inspect() string {
+	return fmt.Sprintf("<channel %d/%d>", len(c.Value), cap(c.Value))
+}
+
+// InvokeMethod invokes a method against the object.
+// (Built-in methods only.)
+func (c *Channel) InvokeMethod(method string, env Environment, args ...ObjectI) ObjectI {
+	switch method {
+	case "send":
+		if len(args) != 1 {
+			return &Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=1", len(args))}
+		}
+		if err := c.Send(args[0]); err != nil {
+			return &Error{Message: err.Error()}
+		}
+		return NULL
+	case "recv":
+		val, _ := c.Recv()
+		return val
+	case "close":
+		if err := c.Close(); err != nil {
+			return &Error{Message: err.Error()}
+		}
+		return NULL
+	case "len":
+		return &Integer{Value: int64(len(c.Value))}
+	case "methods":
+		static := []string{"close", "len", "methods", "recv", "send"}
+		dynamic := env.Names("channel.")
+
+		var names []string
+		names = append(names, static...)
+		for _, e := range dynamic {
+			bits := strings.Split(e, ".")
+			names = append(names, bits[1])
+		}
+		sort.Strings(names)
+
+		result := make([]ObjectI, len(names))
+		for i, txt := range names {
+			result[i] = &String{Value: txt}
+		}
+		return NewArray(result)
+	}
+	return nil
+}
+
+// ToInterface converts this object to a go-interface, which will allow
+// it to be used naturally in our sprintf/printf primitives.
+//
+// It might also be helpful for embedded users.
+func (c *Channel) ToInterface() interface{} {
+	return "<CHANNEL>"
+}
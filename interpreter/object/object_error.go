@@ -1,7 +1,9 @@
 package object
 
 import (
+	"bytes"
 	"fmt"
+	"strings"
 
 	"github.com/kasworld/nonkey/enum/objecttype"
 	"github.com/kasworld/nonkey/interpreter/asti"
@@ -26,6 +28,10 @@ type Error struct {
 	// Message contains the error-message we're wrapping
 	Message string
 	Node    asti.NodeI
+
+	// Stack holds the function calls which led to the error,
+	// innermost first.  It's empty for errors outside functions.
+	Stack []*Frame
 }
 
 // Type returns the type of this object.
@@ -38,6 +44,55 @@ func (e *Error) Inspect() string {
 	return fmt.Sprintf("object.Error %v, %v", e.Message, e.Node.GetToken())
 }
 
+// Traceback returns the error with the calls which led to it, most
+// recent last, showing each line with the source function gives for
+// lines counted from zero.
+func (e *Error) Traceback(source func(line int) string) string {
+	var out bytes.Buffer
+	out.WriteString("Traceback (most recent call last):\n")
+	e.walkStack(func(line int, name string) {
+		fmt.Fprintf(&out, "  line %d, in %s\n", line+1, name)
+		if src := strings.TrimSpace(source(line)); src != "" {
+			fmt.Fprintf(&out, "    %s\n", src)
+		}
+	})
+	fmt.Fprintf(&out, "error: %s\n", e.Message)
+	return out.String()
+}
+
+// StackArray returns the calls which led to the error, in the same
+// order as Traceback, as an array of hashes of function and line.
+func (e *Error) StackArray() *Array {
+	var frames []ObjectI
+	e.walkStack(func(line int, name string) {
+		frame := &Hash{}
+		for _, pair := range []HashPair{
+			{Key: &String{Value: "function"}, Value: &String{Value: name}},
+			{Key: &String{Value: "line"}, Value: &Integer{Value: int64(line + 1)}},
+		} {
+			frame = frame.Set(pair.Key.(*String).HashKey(), pair)
+		}
+		frames = append(frames, frame)
+	})
+	return NewArray(frames)
+}
+
+// walkStack calls fn with the line, counted from zero, and function
+// name of each call leading to the error, most recent last.
+func (e *Error) walkStack(fn func(line int, name string)) {
+	entry := func(node asti.NodeI, name string) {
+		if node != nil {
+			fn(node.GetToken().Line, name)
+		}
+	}
+	name := "main"
+	for i := len(e.Stack) - 1; i >= 0; i-- {
+		entry(e.Stack[i].Call, name)
+		name = e.Stack[i].Name
+	}
+	entry(e.Node, name)
+}
+
 // InvokeMethod invokes a method against the object.
 // (Built-in methods only.)
 func (e *Error) InvokeMethod(method string, env Environment, args ...ObjectI) ObjectI {
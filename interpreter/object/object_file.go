@@ -3,6 +3,8 @@ package object
 import (
 	"bufio"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"sort"
 	"strings"
@@ -21,8 +23,12 @@ type File struct {
 	// Writer is a helper for file-writing.
 	Writer *bufio.Writer
 
-	// Handle contains the filehandle we wrap.
+	// Handle contains the filehandle we wrap.  It's nil for the
+	// standard streams.
 	Handle *os.File
+
+	// closed is set once close() has been called.
+	closed bool
 }
 
 // Type returns the type of this object.
@@ -35,6 +41,24 @@ func (f *File) Inspect() string {
 	return fmt.Sprintf("<file:%s>", f.Filename)
 }
 
+// fileModes maps the modes accepted by open() to flags, as fopen
+// does.  "b" may be added to any of them and is ignored, since files
+// are always binary-safe.
+var fileModes = map[string]int{
+	"r":  os.O_RDONLY,
+	"r+": os.O_RDWR,
+	"w":  os.O_WRONLY | os.O_CREATE | os.O_TRUNC,
+	"w+": os.O_RDWR | os.O_CREATE | os.O_TRUNC,
+	"a":  os.O_WRONLY | os.O_CREATE | os.O_APPEND,
+	"a+": os.O_RDWR | os.O_CREATE | os.O_APPEND,
+	"x":  os.O_WRONLY | os.O_CREATE | os.O_EXCL,
+	"x+": os.O_RDWR | os.O_CREATE | os.O_EXCL,
+
+	// Older scripts use these for appending.
+	"wa": os.O_WRONLY | os.O_CREATE | os.O_APPEND,
+	"aw": os.O_WRONLY | os.O_CREATE | os.O_APPEND,
+}
+
 // Open opens the file - called only from the open-primitive where the
 // Filename will have been filled in for us.
 func (f *File) Open(mode string) error {
@@ -56,90 +80,339 @@ func (f *File) Open(mode string) error {
 		return nil
 	}
 
-	//
-	// Default mode is to read.
-	//
-	md := os.O_RDONLY
-
-	//
-	// Allow writing though ("w"), and write+append ( "wa"| "aw" )
-	//
-	if mode == "w" {
-		md = os.O_WRONLY
-
-		// If we're opening a file just for writing then
-		// we should truncate any existing file at the open-point
-		// too.
-		os.Remove(f.Filename)
-	} else {
-		// write and append
-		if strings.Contains(mode, "w") &&
-			strings.Contains(mode, "a") {
-			md = os.O_WRONLY
-			md |= os.O_APPEND
-		}
+	if mode == "" {
+		mode = "r"
+	}
+	md, ok := fileModes[strings.Replace(mode, "b", "", -1)]
+	if !ok {
+		return fmt.Errorf("invalid file mode %q", mode)
 	}
 
-	//
-	// Open the file.
-	//
-	file, err := os.OpenFile(f.Filename, os.O_CREATE|md, 0644)
+	file, err := os.OpenFile(f.Filename, md, 0644)
 	if err != nil {
 		return err
 	}
-
-	//
-	// Save the handle away
-	//
 	f.Handle = file
 
 	//
 	// Setup the reader/writer handles, as appropriate.
 	//
-	if md == os.O_RDONLY {
+	if md&(os.O_WRONLY|os.O_RDWR) != os.O_WRONLY {
 		f.Reader = bufio.NewReader(file)
-	} else {
+	}
+	if md&(os.O_WRONLY|os.O_RDWR) != os.O_RDONLY {
 		f.Writer = bufio.NewWriter(file)
 	}
+	return nil
+}
+
+// Close flushes anything written and closes the file.
+func (f *File) Close() error {
+	if f.closed {
+		return fmt.Errorf("file %s is already closed", f.Filename)
+	}
+	var err error
+	if f.Writer != nil {
+		err = f.Writer.Flush()
+	}
+	f.closed = true
+	if f.Handle != nil {
+		if cerr := f.Handle.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Read implements io.Reader.
+func (f *File) Read(p []byte) (int, error) {
+	r, err := f.reader()
+	if err != nil {
+		return 0, err
+	}
+	return r.Read(p)
+}
+
+// Write implements io.Writer.  Each write is flushed straight away,
+// so nothing is lost by a script which never closes the file.
+func (f *File) Write(p []byte) (int, error) {
+	if err := f.check(); err != nil {
+		return 0, err
+	}
+	if f.Writer == nil {
+		return 0, fmt.Errorf("file %s is not open for writing", f.Filename)
+	}
+	if err := f.dropReadBuffer(); err != nil {
+		return 0, err
+	}
+	n, err := f.Writer.Write(p)
+	if err == nil {
+		err = f.Writer.Flush()
+	}
+	return n, err
+}
+
+// Flush writes out anything buffered.
+func (f *File) Flush() error {
+	if err := f.check(); err != nil {
+		return err
+	}
+	return f.flush()
+}
+
+// Seek moves the file offset, as os.File.Seek does.
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	if err := f.check(); err != nil {
+		return 0, err
+	}
+	if f.Handle == nil {
+		return 0, fmt.Errorf("file %s is not seekable", f.Filename)
+	}
+	if err := f.flush(); err != nil {
+		return 0, err
+	}
+	if whence == io.SeekCurrent && f.Reader != nil {
+		offset -= int64(f.Reader.Buffered())
+	}
+	pos, err := f.Handle.Seek(offset, whence)
+	if err != nil {
+		return 0, err
+	}
+	if f.Reader != nil {
+		f.Reader.Reset(f.Handle)
+	}
+	return pos, nil
+}
+
+// check fails once the file has been closed.
+func (f *File) check() error {
+	if f.closed {
+		return fmt.Errorf("file %s is closed", f.Filename)
+	}
+	return nil
+}
 
-	// All good.
+// reader returns the reader, once anything written has been flushed so
+// reads see it.
+func (f *File) reader() (*bufio.Reader, error) {
+	if err := f.check(); err != nil {
+		return nil, err
+	}
+	if f.Reader == nil {
+		return nil, fmt.Errorf("file %s is not open for reading", f.Filename)
+	}
+	if err := f.flush(); err != nil {
+		return nil, err
+	}
+	return f.Reader, nil
+}
+
+func (f *File) flush() error {
+	if f.Writer == nil {
+		return nil
+	}
+	return f.Writer.Flush()
+}
+
+// dropReadBuffer moves the file offset back over data which has been
+// read ahead but not used, so a write lands where reading got to.
+func (f *File) dropReadBuffer() error {
+	if f.Reader == nil || f.Handle == nil || f.Reader.Buffered() == 0 {
+		return nil
+	}
+	if _, err := f.Handle.Seek(-int64(f.Reader.Buffered()), io.SeekCurrent); err != nil {
+		return err
+	}
+	f.Reader.Reset(f.Handle)
 	return nil
 }
 
+// readN reads up to n bytes, or everything left if n is negative.
+func (f *File) readN(n int64) ([]byte, error) {
+	r, err := f.reader()
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return ioutil.ReadAll(r)
+	}
+	data := make([]byte, n)
+	got, err := io.ReadFull(r, data)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	return data[:got], err
+}
+
+// readLine reads up to and including the next newline.  At the end of
+// the file it returns whatever is left, which may be "".
+func (f *File) readLine() (string, error) {
+	r, err := f.reader()
+	if err != nil {
+		return "", err
+	}
+	line, err := r.ReadString('\n')
+	if err == io.EOF {
+		err = nil
+	}
+	return line, err
+}
+
+// fileSize reads an optional size argument for read and friends;
+// -1 means no limit.
+func fileSize(method string, args []ObjectI) (int64, error) {
+	if len(args) == 0 {
+		return -1, nil
+	}
+	n, ok := args[0].(*Integer)
+	if !ok || n.Value < 0 {
+		return 0, fmt.Errorf("argument to `%s` must be a non-negative INTEGER, got=%s",
+			method, args[0].Inspect())
+	}
+	return n.Value, nil
+}
+
 // InvokeMethod invokes a method against the object.
 // (Built-in methods only.)
+//
+// Using a closed file, or reading or writing a file which wasn't
+// opened for it, is an error.
 func (f *File) InvokeMethod(method string, env Environment, args ...ObjectI) ObjectI {
-	if method == "close" {
-		f.Handle.Close()
-		return &Boolean{Value: true}
+	fail := func(err error) ObjectI {
+		return &Error{Message: err.Error()}
 	}
-	if method == "lines" {
-
-		// Do we not have a reader?
-		if f.Reader == nil {
-			return (&Null{})
+	switch method {
+	case "close":
+		if err := f.Close(); err != nil {
+			return fail(err)
 		}
-
-		// Result.
-		var lines []string
+		return TRUE
+	case "flush":
+		if err := f.Flush(); err != nil {
+			return fail(err)
+		}
+		return TRUE
+	case "lines":
+		var result []ObjectI
 		for {
-			line, err := f.Reader.ReadString('\n')
+			line, err := f.readLine()
 			if err != nil {
-				break
+				return fail(err)
 			}
-			lines = append(lines, line)
+			if line == "" {
+				return NewArray(result)
+			}
+			result = append(result, &String{Value: line})
 		}
-
-		// make results
-		l := len(lines)
-		result := make([]ObjectI, l)
-		for i, txt := range lines {
-			result[i] = &String{Value: txt}
+	case "read":
+		// With no size, read a line.
+		if len(args) == 0 {
+			line, err := f.readLine()
+			if err != nil {
+				return fail(err)
+			}
+			return &String{Value: line}
 		}
-		return &Array{Elements: result}
-	}
-	if method == "methods" {
-		static := []string{"methods"}
+		n, err := fileSize(method, args)
+		if err != nil {
+			return fail(err)
+		}
+		data, err := f.readN(n)
+		if err != nil {
+			return fail(err)
+		}
+		return &String{Value: string(data)}
+	case "read_all":
+		data, err := f.readN(-1)
+		if err != nil {
+			return fail(err)
+		}
+		return &String{Value: string(data)}
+	case "read_bytes":
+		n, err := fileSize(method, args)
+		if err != nil {
+			return fail(err)
+		}
+		data, err := f.readN(n)
+		if err != nil {
+			return fail(err)
+		}
+		return &Bytes{Value: data}
+	case "rewind":
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return fail(err)
+		}
+		return TRUE
+	case "seek":
+		if len(args) != 1 && len(args) != 2 {
+			return fail(fmt.Errorf("wrong number of arguments. got=%d, want=1|2", len(args)))
+		}
+		offset, ok := args[0].(*Integer)
+		if !ok {
+			return fail(fmt.Errorf("argument to `seek` must be INTEGER, got=%s", args[0].Type()))
+		}
+		whence := io.SeekStart
+		if len(args) == 2 {
+			w, ok := args[1].(*Integer)
+			if !ok || w.Value < 0 || w.Value > 2 {
+				return fail(fmt.Errorf("whence must be 0, 1 or 2, got=%s", args[1].Inspect()))
+			}
+			whence = int(w.Value)
+		}
+		pos, err := f.Seek(offset.Value, whence)
+		if err != nil {
+			return fail(err)
+		}
+		return &Integer{Value: pos}
+	case "tell":
+		pos, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return fail(err)
+		}
+		return &Integer{Value: pos}
+	case "truncate":
+		if err := f.check(); err != nil {
+			return fail(err)
+		}
+		if f.Handle == nil {
+			return fail(fmt.Errorf("file %s can't be truncated", f.Filename))
+		}
+		if err := f.flush(); err != nil {
+			return fail(err)
+		}
+		size, err := fileSize(method, args)
+		if err != nil {
+			return fail(err)
+		}
+		if size < 0 {
+			// Truncate at the current position.
+			if size, err = f.Seek(0, io.SeekCurrent); err != nil {
+				return fail(err)
+			}
+		}
+		if err := f.Handle.Truncate(size); err != nil {
+			return fail(err)
+		}
+		return TRUE
+	case "write":
+		if len(args) != 1 {
+			return fail(fmt.Errorf("wrong number of arguments. got=%d, want=1", len(args)))
+		}
+		var data []byte
+		switch arg := args[0].(type) {
+		case *Bytes:
+			data = arg.Value
+		default:
+			// Write anything else as it'd be printed.
+			data = []byte(arg.Inspect())
+		}
+		if _, err := f.Write(data); err != nil {
+			return fail(err)
+		}
+		return TRUE
+	case "methods":
+		static := []string{"close", "flush", "lines", "methods", "read", "read_all",
+			"read_bytes", "rewind", "seek", "tell", "truncate", "write"}
 		dynamic := env.Names("file.")
 
 		var names []string
@@ -154,47 +427,7 @@ func (f *File) InvokeMethod(method string, env Environment, args ...ObjectI) Obj
 		for i, txt := range names {
 			result[i] = &String{Value: txt}
 		}
-		return &Array{Elements: result}
-	}
-	if method == "read" {
-		// Check we have a reader.
-		if f.Reader == nil {
-			return (&String{Value: ""})
-		}
-
-		// Read and return a line.
-		line, err := f.Reader.ReadString('\n')
-		if err != nil {
-			return (&String{Value: ""})
-		}
-		return &String{Value: line}
-	}
-	if method == "rewind" {
-		// Rewind a handle by seeking to the beginning of the file.
-		f.Handle.Seek(0, 0)
-		return &Boolean{Value: true}
-	}
-	if method == "write" {
-
-		// check we have an argument to write.
-		if len(args) < 1 {
-			return &Error{Message: "Missing argument to write()!"}
-		}
-
-		// Ensure we have a writer.
-		if f.Writer == nil {
-			return (&Null{})
-		}
-
-		// Write the text - coorcing to a string first.
-		txt := args[0].Inspect()
-		_, err := f.Writer.Write([]byte(txt))
-		if err == nil {
-			f.Writer.Flush()
-			return &Boolean{Value: true}
-		}
-
-		return &Boolean{Value: false}
+		return NewArray(result)
 	}
 	return nil
 }
@@ -2,6 +2,7 @@ package object
 
 import (
 	"hash/fnv"
+	"math"
 	"sort"
 	"strconv"
 	"strings"
@@ -25,8 +26,12 @@ func (f *Float) Type() objecttype.ObjectType {
 	return objecttype.FLOAT
 }
 
-// HashKey returns a hash key for the given object.
+// HashKey returns a hash key for the given object.  Whole numbers hash
+// as the integers they equal, so 1.0 finds what's stored under 1.
 func (f *Float) HashKey() HashKey {
+	if f.Value == math.Trunc(f.Value) && f.Value >= math.MinInt64 && f.Value < math.MaxInt64 {
+		return (&Integer{Value: int64(f.Value)}).HashKey()
+	}
 	h := fnv.New64a()
 	h.Write([]byte(f.Inspect()))
 	return HashKey{Type: f.Type(), Value: h.Sum64()}
@@ -51,7 +56,7 @@ func (f *Float) InvokeMethod(method string, env Environment, args ...ObjectI) Ob
 		for i, txt := range names {
 			result[i] = &String{Value: txt}
 		}
-		return &Array{Elements: result}
+		return NewArray(result)
 	}
 	return nil
 }
@@ -13,10 +13,18 @@ import (
 
 // Function wraps ast.Identifier array, ast.BlockStatement and Environment and implements ObjectI interface.
 type Function struct {
+	// Name is the name the function was defined with, which is
+	// empty for an anonymous function.
+	Name string
+
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Defaults   map[string]asti.ExpressionI
 	Env        *Environment
+
+	// Generator is set for functions whose body contains `yield`;
+	// calling one returns an iterator over the values it yields.
+	Generator bool
 }
 
 // Type returns the type of this object.
@@ -54,7 +62,7 @@ func (f *Function) InvokeMethod(method string, env Environment, args ...ObjectI)
 		for i, txt := range names {
 			result[i] = &String{Value: txt}
 		}
-		return &Array{Elements: result}
+		return NewArray(result)
 	}
 	return nil
 }
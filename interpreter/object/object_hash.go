@@ -27,13 +27,45 @@ type HashPair struct {
 	Value ObjectI
 }
 
-// Hash wrap map[HashKey]HashPair and implements ObjectI interface.
+// Hash wraps a persistent map of HashKey to HashPair and implements
+// ObjectI interface.
+//
+// Hashes are values: Set and Delete return a new hash which shares
+// storage with the original, leaving the original unchanged.  The zero
+// value is an empty hash.
 type Hash struct {
-	// Pairs holds the key/value pairs of the hash we wrap
-	Pairs map[HashKey]HashPair
+	// pairs holds the key/value pairs of the hash we wrap
+	pairs hamt
+}
+
+// Len returns the number of pairs in the hash.
+func (h *Hash) Len() int {
+	return h.pairs.count
+}
+
+// Get returns the pair stored under the given key.
+func (h *Hash) Get(key HashKey) (HashPair, bool) {
+	return h.pairs.get(key)
+}
+
+// Set returns a copy of the hash with the key bound to the pair.
+func (h *Hash) Set(key HashKey, pair HashPair) *Hash {
+	return &Hash{pairs: h.pairs.set(key, pair)}
+}
 
-	// offset holds our iteration-offset.
-	offset int
+// Delete returns a copy of the hash without the given key.
+func (h *Hash) Delete(key HashKey) *Hash {
+	return &Hash{pairs: h.pairs.delete(key)}
+}
+
+// Pairs returns the pairs of the hash as a new go slice.
+func (h *Hash) Pairs() []HashPair {
+	out := make([]HashPair, 0, h.Len())
+	h.pairs.each(func(pair HashPair) bool {
+		out = append(out, pair)
+		return true
+	})
+	return out
 }
 
 // Type returns the type of this object.
@@ -44,8 +76,8 @@ func (h *Hash) Type() objecttype.ObjectType {
 // Inspect returns a string-representation of the given object.
 func (h *Hash) Inspect() string {
 	var out bytes.Buffer
-	pairs := make([]string, 0)
-	for _, pair := range h.Pairs {
+	pairs := make([]string, 0, h.Len())
+	for _, pair := range h.Pairs() {
 		pairs = append(pairs, fmt.Sprintf("%s: %s",
 			pair.Key.Inspect(), pair.Value.Inspect()))
 	}
@@ -57,17 +89,14 @@ func (h *Hash) Inspect() string {
 // (Built-in methods only.)
 func (h *Hash) InvokeMethod(method string, env Environment, args ...ObjectI) ObjectI {
 	if method == "keys" {
-		ents := len(h.Pairs)
-		array := make([]ObjectI, ents)
+		array := make([]ObjectI, 0, h.Len())
 
 		// Now copy the keys into it.
-		i := 0
-		for _, ent := range h.Pairs {
-			array[i] = ent.Key
-			i++
+		for _, ent := range h.Pairs() {
+			array = append(array, ent.Key)
 		}
 
-		return &Array{Elements: array}
+		return NewArray(array)
 	}
 	if method == "methods" {
 		static := []string{"keys", "methods"}
@@ -85,39 +114,36 @@ func (h *Hash) InvokeMethod(method string, env Environment, args ...ObjectI) Obj
 		for i, txt := range names {
 			result[i] = &String{Value: txt}
 		}
-		return &Array{Elements: result}
+		return NewArray(result)
 	}
 	return nil
 }
 
-// Reset implements the Iterable interface, and allows the contents
-// of the array to be reset to allow re-iteration.
-func (h *Hash) Reset() {
-	h.offset = 0
-}
-
-// Next implements the Iterable interface, and allows the contents
-// of our array to be iterated over.
-func (h *Hash) Next() (ObjectI, ObjectI, bool) {
-	if h.offset < len(h.Pairs) {
-		idx := 0
-
-		for _, pair := range h.Pairs {
-			if h.offset == idx {
-				h.offset++
-				return pair.Key, pair.Value, true
-			}
-			idx++
+// Iter implements the Iterable interface, yielding each key with its
+// value as the index.
+func (h *Hash) Iter() IteratorI {
+	pairs := h.Pairs()
+	i := 0
+	return NewIterator(func() (ObjectI, ObjectI, bool) {
+		if i >= len(pairs) {
+			return nil, &Integer{Value: 0}, false
 		}
-	}
-
-	return nil, &Integer{Value: 0}, false
+		i++
+		return pairs[i-1].Key, pairs[i-1].Value, true
+	})
 }
 
 // ToInterface converts this object to a go-interface, which will allow
 // it to be used naturally in our sprintf/printf primitives.
 //
 // It might also be helpful for embedded users.
+//
+// Hashes become a map[string]interface{} of their converted values,
+// keyed by the string form of each key.
 func (h *Hash) ToInterface() interface{} {
-	return "<HASH>"
+	out := make(map[string]interface{}, h.Len())
+	for _, pair := range h.Pairs() {
+		out[fmt.Sprint(pair.Key.ToInterface())] = pair.Value.ToInterface()
+	}
+	return out
 }
@@ -52,7 +52,7 @@ func (i *Integer) InvokeMethod(method string, env Environment, args ...ObjectI)
 		for i, txt := range names {
 			result[i] = &String{Value: txt}
 		}
-		return &Array{Elements: result}
+		return NewArray(result)
 	}
 	return nil
 }
@@ -0,0 +1,155 @@
+package object
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/kasworld/nonkey/enum/objecttype"
+)
+
+// Iterator wraps a single pass over a sequence and implements ObjectI,
+// Iterable and Iterator interfaces.
+//
+// Iterators are lazy: items are produced by calling next only when
+// they're asked for, so they can walk sequences which are too large,
+// or too expensive, to build up front.  Iterating an iterator
+// consumes it.
+type Iterator struct {
+	// next produces the following item, its index and whether
+	// there was one.
+	next func() (ObjectI, ObjectI, bool)
+
+	// done is set once next has reported the end, or the iterator
+	// was closed, so next isn't called again.
+	done bool
+
+	// stop, if set, releases what next holds once the iterator is
+	// done.
+	stop func()
+}
+
+// NewIterator creates an iterator which takes its items from next.
+func NewIterator(next func() (ObjectI, ObjectI, bool)) *Iterator {
+	return &Iterator{next: next}
+}
+
+// Next implements the Iterator interface.
+func (it *Iterator) Next() (ObjectI, ObjectI, bool) {
+	if it.done {
+		return nil, &Integer{Value: 0}, false
+	}
+	val, idx, ok := it.next()
+	if !ok || IsError(val) {
+		it.finish()
+	}
+	return val, idx, ok
+}
+
+// OnClose sets fn to be called once the iterator is done, whether it
+// reached its end, failed, or was closed early.
+func (it *Iterator) OnClose(fn func()) {
+	it.stop = fn
+}
+
+// Close ends the iterator early, so it yields nothing more, and
+// releases what it holds, such as the goroutine running a generator.
+func (it *Iterator) Close() {
+	it.finish()
+}
+
+func (it *Iterator) finish() {
+	if it.done {
+		return
+	}
+	it.done = true
+	if it.stop != nil {
+		it.stop()
+	}
+}
+
+// CloseIterator closes an iterator, if it's one which can be closed.
+func CloseIterator(it IteratorI) {
+	if c, ok := it.(interface{ Close() }); ok {
+		c.Close()
+	}
+}
+
+// Iter implements the Iterable interface.  An iterator is its own
+// iterator, so a second loop continues where the first stopped.
+func (it *Iterator) Iter() IteratorI {
+	return it
+}
+
+// Collect drains an iterator into a go slice, stopping at the first
+// error.
+func Collect(it IteratorI) ([]ObjectI, *Error) {
+	var out []ObjectI
+	for {
+		val, _, ok := it.Next()
+		if !ok {
+			return out, nil
+		}
+		if err, isErr := val.(*Error); isErr {
+			return nil, err
+		}
+		out = append(out, val)
+	}
+}
+
+// Type returns the type of this object.
+func (it *Iterator) Type() objecttype.ObjectType {
+	return objecttype.ITERATOR
+}
+
+// Inspect returns a string-representation of the given object.
+func (it *Iterator) Inspect() string {
+	return "<iterator>"
+}
+
+// InvokeMethod invokes a method against the object.
+// (Built-in methods only.)
+func (it *Iterator) InvokeMethod(method string, env Environment, args ...ObjectI) ObjectI {
+	switch method {
+	case "close":
+		it.Close()
+		return NULL
+	case "next":
+		val, _, ok := it.Next()
+		if !ok {
+			return NULL
+		}
+		return val
+	case "to_array":
+		elements, err := Collect(it)
+		if err != nil {
+			return err
+		}
+		return NewArray(elements)
+	case "methods":
+		static := []string{"close", "methods", "next", "to_array"}
+		dynamic := env.Names("iterator.")
+
+		var names []string
+		names = append(names, static...)
+		for _, e := range dynamic {
+			bits := strings.Split(e, ".")
+			names = append(names, bits[1])
+		}
+		sort.Strings(names)
+
+		result := make([]ObjectI, len(names))
+		for i, txt := range names {
+			result[i] = &String{Value: txt}
+		}
+		return NewArray(result)
+	}
+	return nil
+}
+
+// ToInterface converts this object to a go-interface, which will allow
+// it to be used naturally in our sprintf/printf primitives.
+//
+// It might also be helpful for embedded users.
+func (it *Iterator) ToInterface() interface{} {
+	return "<ITERATOR>"
+}
@@ -24,7 +24,8 @@ func (n *Null) InvokeMethod(method string, env Environment, args ...ObjectI) Obj
 // ToInterface converts this object to a go-interface, which will allow
 // it to be used naturally in our sprintf/printf primitives.
 //
-// It might also be helpful for embedded users.
+// It might also be helpful for embedded users.  Null becomes nil, as
+// JSON's null does; sprintf shows a null argument as "<NULL>" still.
 func (n *Null) ToInterface() interface{} {
-	return "<NULL>"
+	return nil
 }
@@ -0,0 +1,185 @@
+package object
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kasworld/nonkey/enum/objecttype"
+)
+
+// Process wraps a running command, started by exec.start, and
+// implements ObjectI interface.
+//
+// Its standard output is read a line at a time, and its standard
+// error is collected for wait() to return.
+type Process struct {
+	// Cmd is the command being run.
+	Cmd *exec.Cmd
+
+	stdin   io.WriteCloser
+	stdout  *bufio.Reader
+	stderr  bytes.Buffer
+	started time.Time
+
+	// mu guards result, which wait() fills in.
+	mu     sync.Mutex
+	result *Hash
+}
+
+// StartProcess starts cmd, connecting pipes to its standard input and
+// output.
+func StartProcess(cmd *exec.Cmd) (*Process, error) {
+	p := &Process{Cmd: cmd}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stderr = &p.stderr
+	p.started = time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	p.stdin = stdin
+	p.stdout = bufio.NewReader(stdout)
+	return p, nil
+}
+
+// ExitCode returns the exit code of a finished command, or -1 if it
+// was killed by a signal.  Errors other than a non-zero exit are
+// returned as they are.
+func ExitCode(cmd *exec.Cmd, err error) (int64, error) {
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		return 0, err
+	}
+	return int64(cmd.ProcessState.ExitCode()), nil
+}
+
+// Wait closes the command's input and waits for it to exit.  The
+// result is a hash of exit_code, stdout (whatever wasn't read with
+// read_line), stderr and duration in seconds.  Waiting again returns
+// the same result.
+func (p *Process) Wait() (*Hash, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.result != nil {
+		return p.result, nil
+	}
+
+	p.stdin.Close()
+	rest, _ := ioutil.ReadAll(p.stdout)
+	code, err := ExitCode(p.Cmd, p.Cmd.Wait())
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]ObjectI{
+		"exit_code": &Integer{Value: code},
+		"stdout":    &String{Value: string(rest)},
+		"stderr":    &String{Value: p.stderr.String()},
+		"duration":  &Float{Value: time.Since(p.started).Seconds()},
+	}
+	res := &Hash{}
+	for name, val := range fields {
+		key := &String{Value: name}
+		res = res.Set(key.HashKey(), HashPair{Key: key, Value: val})
+	}
+	p.result = res
+	return res, nil
+}
+
+// Type returns the type of this object.
+func (p *Process) Type() objecttype.ObjectType {
+	return objecttype.PROCESS
+}
+
+// Inspect returns a string-representation of the given object.
+func (p *Process) Inspect() string {
+	return fmt.Sprintf("<process:%d %s>", p.Cmd.Process.Pid, strings.Join(p.Cmd.Args, " "))
+}
+
+// InvokeMethod invokes a method against the object.
+// (Built-in methods only.)
+func (p *Process) InvokeMethod(method string, env Environment, args ...ObjectI) ObjectI {
+	fail := func(err error) ObjectI {
+		return &Error{Message: err.Error()}
+	}
+	switch method {
+	case "read_line":
+		// A line of output including its newline; "" once the
+		// output has ended.
+		line, err := p.stdout.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return fail(err)
+		}
+		return &String{Value: line}
+	case "write":
+		if len(args) != 1 {
+			return fail(fmt.Errorf("wrong number of arguments. got=%d, want=1", len(args)))
+		}
+		data := []byte(args[0].Inspect())
+		if b, ok := args[0].(*Bytes); ok {
+			data = b.Value
+		}
+		if _, err := p.stdin.Write(data); err != nil {
+			return fail(err)
+		}
+		return TRUE
+	case "close":
+		// Close the command's input, so it sees end-of-file.
+		if err := p.stdin.Close(); err != nil {
+			return fail(err)
+		}
+		return TRUE
+	case "wait":
+		res, err := p.Wait()
+		if err != nil {
+			return fail(err)
+		}
+		return res
+	case "kill":
+		if err := p.Cmd.Process.Kill(); err != nil {
+			return fail(err)
+		}
+		return TRUE
+	case "pid":
+		return &Integer{Value: int64(p.Cmd.Process.Pid)}
+	case "methods":
+		static := []string{"close", "kill", "methods", "pid", "read_line", "wait", "write"}
+		dynamic := env.Names("process.")
+
+		var names []string
+		names = append(names, static...)
+		for _, e := range dynamic {
+			bits := strings.Split(e, ".")
+			names = append(names, bits[1])
+		}
+		sort.Strings(names)
+
+		result := make([]ObjectI, len(names))
+		for i, txt := range names {
+			result[i] = &String{Value: txt}
+		}
+		return NewArray(result)
+	}
+	return nil
+}
+
+// ToInterface converts this object to a go-interface, which will allow
+// it to be used naturally in our sprintf/printf primitives.
+//
+// It might also be helpful for embedded users.
+func (p *Process) ToInterface() interface{} {
+	return "<PROCESS>"
+}
@@ -0,0 +1,102 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/kasworld/nonkey/enum/objecttype"
+)
+
+// Range is the inclusive sequence of integers Start..End and
+// implements ObjectI and Iterable interfaces.
+//
+// Ranges are lazy: `1..1000000000` costs the same as `1..3`, and the
+// integers are only made as they're iterated or indexed.  A range
+// whose End is before its Start is empty.
+type Range struct {
+	Start int64
+	End   int64
+}
+
+// Len returns the number of integers in the range, or math.MaxInt64
+// for ranges with more than that.
+func (r *Range) Len() int64 {
+	if r.End < r.Start {
+		return 0
+	}
+	if n := uint64(r.End) - uint64(r.Start); n < math.MaxInt64 {
+		return int64(n) + 1
+	}
+	return math.MaxInt64
+}
+
+// Get returns the integer at the given index, which must be in range.
+func (r *Range) Get(i int64) ObjectI {
+	return &Integer{Value: r.Start + i}
+}
+
+// Iter implements the Iterable interface.
+func (r *Range) Iter() IteratorI {
+	var i int64
+	n := r.Len()
+	return NewIterator(func() (ObjectI, ObjectI, bool) {
+		if i >= n {
+			return nil, &Integer{Value: 0}, false
+		}
+		i++
+		return r.Get(i - 1), &Integer{Value: i - 1}, true
+	})
+}
+
+// Type returns the type of this object.
+func (r *Range) Type() objecttype.ObjectType {
+	return objecttype.RANGE
+}
+
+// Inspect returns a string-representation of the given object.
+func (r *Range) Inspect() string {
+	return fmt.Sprintf("%d..%d", r.Start, r.End)
+}
+
+// InvokeMethod invokes a method against the object.
+// (Built-in methods only.)
+func (r *Range) InvokeMethod(method string, env Environment, args ...ObjectI) ObjectI {
+	switch method {
+	case "len":
+		return &Integer{Value: r.Len()}
+	case "to_array":
+		elements := make([]ObjectI, r.Len())
+		for i := range elements {
+			elements[i] = r.Get(int64(i))
+		}
+		return NewArray(elements)
+	case "methods":
+		static := []string{"len", "methods", "to_array"}
+		dynamic := env.Names("range.")
+
+		var names []string
+		names = append(names, static...)
+		for _, e := range dynamic {
+			bits := strings.Split(e, ".")
+			names = append(names, bits[1])
+		}
+		sort.Strings(names)
+
+		result := make([]ObjectI, len(names))
+		for i, txt := range names {
+			result[i] = &String{Value: txt}
+		}
+		return NewArray(result)
+	}
+	return nil
+}
+
+// ToInterface converts this object to a go-interface, which will allow
+// it to be used naturally in our sprintf/printf primitives.
+//
+// It might also be helpful for embedded users.
+func (r *Range) ToInterface() interface{} {
+	return "<RANGE>"
+}
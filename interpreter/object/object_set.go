@@ -0,0 +1,242 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kasworld/nonkey/enum/objecttype"
+)
+
+// Set wraps a persistent map of HashKey to element and implements
+// ObjectI, Hashable and Iterable interfaces.
+//
+// Sets are values: Add, Remove and the set algebra return a new set
+// which shares storage with the original, leaving the original
+// unchanged.  The zero value is an empty set.
+type Set struct {
+	// elements holds the members of the set, keyed by their HashKey.
+	elements hamt
+}
+
+// NewSet creates a set holding the given elements, which must all be
+// hashable.
+func NewSet(elements []ObjectI) *Set {
+	s := &Set{}
+	for _, e := range elements {
+		s = s.Add(e)
+	}
+	return s
+}
+
+// Len returns the number of elements in the set.
+func (s *Set) Len() int {
+	return s.elements.count
+}
+
+// Has reports whether the hashable element is a member of the set.
+func (s *Set) Has(e ObjectI) bool {
+	_, ok := s.elements.get(e.(HashableI).HashKey())
+	return ok
+}
+
+// Add returns a copy of the set with the hashable element added.
+func (s *Set) Add(e ObjectI) *Set {
+	key := e.(HashableI).HashKey()
+	return &Set{elements: s.elements.set(key, HashPair{Key: e, Value: e})}
+}
+
+// Remove returns a copy of the set without the hashable element.
+func (s *Set) Remove(e ObjectI) *Set {
+	return &Set{elements: s.elements.delete(e.(HashableI).HashKey())}
+}
+
+// Union returns the elements which are in either set.
+func (s *Set) Union(o *Set) *Set {
+	if s.Len() < o.Len() {
+		s, o = o, s
+	}
+	out := &Set{elements: s.elements}
+	o.elements.each(func(pair HashPair) bool {
+		out.elements = out.elements.set(pair.Key.(HashableI).HashKey(), pair)
+		return true
+	})
+	return out
+}
+
+// Intersection returns the elements which are in both sets.
+func (s *Set) Intersection(o *Set) *Set {
+	if s.Len() > o.Len() {
+		s, o = o, s
+	}
+	out := &Set{}
+	s.elements.each(func(pair HashPair) bool {
+		if o.Has(pair.Key) {
+			out.elements = out.elements.set(pair.Key.(HashableI).HashKey(), pair)
+		}
+		return true
+	})
+	return out
+}
+
+// Difference returns the elements of s which aren't in o.
+func (s *Set) Difference(o *Set) *Set {
+	out := &Set{elements: s.elements}
+	o.elements.each(func(pair HashPair) bool {
+		out.elements = out.elements.delete(pair.Key.(HashableI).HashKey())
+		return true
+	})
+	return out
+}
+
+// Elements returns the members of the set as a new go slice, in a
+// deterministic order: numbers first, then other values grouped by
+// type, each group sorted by Compare where possible.
+func (s *Set) Elements() []ObjectI {
+	out := make([]ObjectI, 0, s.Len())
+	s.elements.each(func(pair HashPair) bool {
+		out = append(out, pair.Key)
+		return true
+	})
+	sort.Slice(out, func(i, j int) bool {
+		return setOrder(out[i], out[j]) < 0
+	})
+	return out
+}
+
+// setOrder orders any two set members.
+func setOrder(a, b ObjectI) int {
+	ca, cb := setClass(a), setClass(b)
+	if ca != cb {
+		if ca < cb {
+			return -1
+		}
+		return 1
+	}
+	if c, ok := Compare(a, b); ok && c != 0 {
+		return c
+	}
+	return strings.Compare(a.Inspect(), b.Inspect())
+}
+
+// setClass groups integers and floats together so they sort by value.
+func setClass(o ObjectI) string {
+	switch o.Type() {
+	case objecttype.INTEGER, objecttype.FLOAT:
+		return ""
+	}
+	return o.Type().String()
+}
+
+// Type returns the type of this object.
+func (s *Set) Type() objecttype.ObjectType {
+	return objecttype.SET
+}
+
+// Inspect returns a string-representation of the given object.
+func (s *Set) Inspect() string {
+	if s.Len() == 0 {
+		return "new_set()"
+	}
+	var out bytes.Buffer
+	elements := make([]string, 0, s.Len())
+	for _, e := range s.Elements() {
+		elements = append(elements, e.Inspect())
+	}
+	fmt.Fprintf(&out, "{%v}", strings.Join(elements, ", "))
+	return out.String()
+}
+
+// HashKey returns a hash key for the given object.  It doesn't depend
+// on the order elements were added in.
+func (s *Set) HashKey() HashKey {
+	var sum uint64
+	s.elements.each(func(pair HashPair) bool {
+		sum += hamtHash(pair.Key.(HashableI).HashKey())
+		return true
+	})
+	return HashKey{Type: s.Type(), Value: sum}
+}
+
+// InvokeMethod invokes a method against the object.
+// (Built-in methods only.)
+func (s *Set) InvokeMethod(method string, env Environment, args ...ObjectI) ObjectI {
+	switch method {
+	case "add", "remove":
+		out := s
+		for _, arg := range args {
+			if _, ok := arg.(HashableI); !ok {
+				return &Error{Message: fmt.Sprintf("unusable as set element: %s", arg.Type())}
+			}
+			if method == "add" {
+				out = out.Add(arg)
+			} else {
+				out = out.Remove(arg)
+			}
+		}
+		return out
+	case "has":
+		if len(args) != 1 {
+			return &Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=1", len(args))}
+		}
+		if _, ok := args[0].(HashableI); !ok {
+			return FALSE
+		}
+		if s.Has(args[0]) {
+			return TRUE
+		}
+		return FALSE
+	case "len":
+		return &Integer{Value: int64(s.Len())}
+	case "to_array":
+		return NewArray(s.Elements())
+	case "methods":
+		static := []string{"add", "has", "len", "methods", "remove", "to_array"}
+		dynamic := env.Names("set.")
+
+		var names []string
+		names = append(names, static...)
+		for _, e := range dynamic {
+			bits := strings.Split(e, ".")
+			names = append(names, bits[1])
+		}
+		sort.Strings(names)
+
+		result := make([]ObjectI, len(names))
+		for i, txt := range names {
+			result[i] = &String{Value: txt}
+		}
+		return NewArray(result)
+	}
+	return nil
+}
+
+// Iter implements the Iterable interface, walking the members in the
+// same order Inspect shows them.
+func (s *Set) Iter() IteratorI {
+	elements := s.Elements()
+	i := 0
+	return NewIterator(func() (ObjectI, ObjectI, bool) {
+		if i >= len(elements) {
+			return nil, &Integer{Value: 0}, false
+		}
+		i++
+		return elements[i-1], &Integer{Value: int64(i - 1)}, true
+	})
+}
+
+// ToInterface converts this object to a go-interface, which will allow
+// it to be used naturally in our sprintf/printf primitives.
+//
+// It might also be helpful for embedded users.
+//
+// Sets become a []interface{} of their converted members, in the
+// same order Inspect shows them.
+func (s *Set) ToInterface() interface{} {
+	out := make([]interface{}, 0, s.Len())
+	for _, e := range s.Elements() {
+		out = append(out, e.ToInterface())
+	}
+	return out
+}
@@ -16,9 +16,6 @@ import (
 type String struct {
 	// Value holds the string value this object wraps.
 	Value string
-
-	// Offset holds our iteration-offset
-	offset int
 }
 
 // Type returns the type of this object.
@@ -61,7 +58,7 @@ func (s *String) InvokeMethod(method string, env Environment, args ...ObjectI) O
 		for i, txt := range names {
 			result[i] = &String{Value: txt}
 		}
-		return &Array{Elements: result}
+		return NewArray(result)
 	}
 	if method == "ord" {
 		return &Integer{Value: int64(s.Value[0])}
@@ -83,29 +80,18 @@ func (s *String) InvokeMethod(method string, env Environment, args ...ObjectI) O
 	return nil
 }
 
-// Reset implements the Iterable interface, and allows the contents
-// of the string to be reset to allow re-iteration.
-func (s *String) Reset() {
-	s.offset = 0
-}
-
-// Next implements the Iterable interface, and allows the contents
-// of our string to be iterated over.
-func (s *String) Next() (ObjectI, ObjectI, bool) {
-
-	if s.offset < utf8.RuneCountInString(s.Value) {
-		s.offset++
-
-		// Get the characters as an array of runes
-		chars := []rune(s.Value)
-
-		// Now index
-		val := &String{Value: string(chars[s.offset-1])}
-
-		return val, &Integer{Value: int64(s.offset - 1)}, true
-	}
-
-	return nil, &Integer{Value: 0}, false
+// Iter implements the Iterable interface, yielding the characters
+// of the string one at a time.
+func (s *String) Iter() IteratorI {
+	chars := []rune(s.Value)
+	i := 0
+	return NewIterator(func() (ObjectI, ObjectI, bool) {
+		if i >= len(chars) {
+			return nil, &Integer{Value: 0}, false
+		}
+		i++
+		return &String{Value: string(chars[i-1])}, &Integer{Value: int64(i - 1)}, true
+	})
 }
 
 // ToInterface converts this object to a go-interface, which will allow
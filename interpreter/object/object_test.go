@@ -1,6 +1,17 @@
 package object
 
-import "testing"
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/kasworld/nonkey/interpreter/asti"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -17,3 +28,412 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("string with different have same hash key")
 	}
 }
+
+func TestArrayPersistence(t *testing.T) {
+	// Cover a tail-only array, one level of trie, and two levels.
+	for _, size := range []int{0, 1, 31, 32, 33, 1024, 1056, 1057, 5000} {
+		ref := make([]ObjectI, size)
+		for i := range ref {
+			ref[i] = &Integer{Value: int64(i)}
+		}
+		built := NewArray(ref)
+		appended := &Array{}
+		for _, v := range ref {
+			appended = appended.Append(v)
+		}
+		for _, arr := range []*Array{built, appended} {
+			if arr.Len() != size {
+				t.Fatalf("size %d: wrong length %d", size, arr.Len())
+			}
+			for i := 0; i < size; i++ {
+				if arr.Get(i) != ref[i] {
+					t.Fatalf("size %d: wrong element at %d", size, i)
+				}
+			}
+		}
+		if size == 0 {
+			continue
+		}
+
+		// Updating must leave the original alone.
+		first := built.Set(0, FALSE)
+		last := built.Set(size-1, TRUE)
+		if built.Get(0) != ref[0] || built.Get(size-1) != ref[size-1] {
+			t.Fatalf("size %d: Set changed the original", size)
+		}
+		if first.Get(0) != FALSE || last.Get(size-1) != TRUE {
+			t.Fatalf("size %d: Set did not update", size)
+		}
+		grown := built.Append(NULL)
+		if built.Len() != size || grown.Len() != size+1 || grown.Get(size) != NULL {
+			t.Fatalf("size %d: Append changed the original", size)
+		}
+		if len(built.Elements()) != size {
+			t.Fatalf("size %d: Elements has wrong length", size)
+		}
+	}
+}
+
+func TestHashPersistence(t *testing.T) {
+	h := &Hash{}
+	versions := []*Hash{h}
+	for i := 0; i < 3000; i++ {
+		k := &Integer{Value: int64(i)}
+		h = h.Set(k.HashKey(), HashPair{Key: k, Value: k})
+		versions = append(versions, h)
+	}
+	for n, v := range versions {
+		if v.Len() != n {
+			t.Fatalf("version %d has %d pairs", n, v.Len())
+		}
+	}
+	for i := 0; i < 3000; i++ {
+		k := (&Integer{Value: int64(i)}).HashKey()
+		if _, ok := h.Get(k); !ok {
+			t.Fatalf("missing key %d", i)
+		}
+		if _, ok := versions[i].Get(k); ok {
+			t.Fatalf("old version has key %d", i)
+		}
+	}
+
+	// Keys of different types are different keys, but for numbers.
+	s := &String{Value: "1"}
+	f := &Float{Value: 1.5}
+	h2 := h.Set(s.HashKey(), HashPair{Key: s, Value: s}).Set(f.HashKey(), HashPair{Key: f, Value: f})
+	if h2.Len() != 3002 {
+		t.Fatalf("wrong length %d", h2.Len())
+	}
+
+	deleted := h
+	for i := 0; i < 3000; i += 2 {
+		deleted = deleted.Delete((&Integer{Value: int64(i)}).HashKey())
+	}
+	if deleted.Len() != 1500 || h.Len() != 3000 {
+		t.Fatalf("Delete gave %d, original %d", deleted.Len(), h.Len())
+	}
+	for i := 0; i < 3000; i++ {
+		_, ok := deleted.Get((&Integer{Value: int64(i)}).HashKey())
+		if ok != (i%2 == 1) {
+			t.Fatalf("key %d present=%v after delete", i, ok)
+		}
+	}
+	if len(deleted.Pairs()) != 1500 {
+		t.Fatalf("Pairs has wrong length")
+	}
+	if deleted.Delete(s.HashKey()).Len() != 1500 {
+		t.Fatalf("deleting a missing key changed the length")
+	}
+}
+
+// The cost per operation should grow only logarithmically, compare
+// the ns/op of the different sizes.
+func BenchmarkArrayAppend(b *testing.B) {
+	for _, size := range []int{1000, 100000} {
+		base := NewArray(make([]ObjectI, size))
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				base.Append(NULL)
+			}
+		})
+	}
+}
+
+func BenchmarkArraySet(b *testing.B) {
+	for _, size := range []int{1000, 100000} {
+		base := NewArray(make([]ObjectI, size))
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				base.Set(i%size, NULL)
+			}
+		})
+	}
+}
+
+func BenchmarkHashSet(b *testing.B) {
+	for _, size := range []int{1000, 100000} {
+		base := &Hash{}
+		for i := 0; i < size; i++ {
+			k := &Integer{Value: int64(i)}
+			base = base.Set(k.HashKey(), HashPair{Key: k, Value: k})
+		}
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				k := &Integer{Value: int64(i)}
+				base.Set(k.HashKey(), HashPair{Key: k, Value: k})
+			}
+		})
+	}
+}
+
+func BenchmarkHashDelete(b *testing.B) {
+	for _, size := range []int{1000, 100000} {
+		base := &Hash{}
+		for i := 0; i < size; i++ {
+			k := &Integer{Value: int64(i)}
+			base = base.Set(k.HashKey(), HashPair{Key: k, Value: k})
+		}
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				base.Delete((&Integer{Value: int64(i % size)}).HashKey())
+			}
+		})
+	}
+}
+
+func TestArrayHashKey(t *testing.T) {
+	one := NewArray([]ObjectI{&Integer{Value: 1}, &String{Value: "two"}})
+	two := NewArray([]ObjectI{&Integer{Value: 1}, &String{Value: "two"}})
+	diff := NewArray([]ObjectI{&String{Value: "two"}, &Integer{Value: 1}})
+	if one.HashKey() != two.HashKey() {
+		t.Errorf("arrays with same content have different keys")
+	}
+	if one.HashKey() == diff.HashKey() {
+		t.Errorf("arrays with different content have same hash key")
+	}
+	if !Equals(one, two) || Equals(one, diff) {
+		t.Errorf("Equals gave the wrong answer")
+	}
+
+	// Values which are equal hash alike, hashes in them included.
+	key := &String{Value: "a"}
+	hash := func(val ObjectI) ObjectI {
+		return (&Hash{}).Set(key.HashKey(), HashPair{Key: key, Value: val})
+	}
+	ints := NewArray([]ObjectI{&Integer{Value: 1}, hash(&Integer{Value: 2})})
+	floats := NewArray([]ObjectI{&Float{Value: 1}, hash(&Float{Value: 2})})
+	if !Equals(ints, floats) || ints.HashKey() != floats.HashKey() {
+		t.Errorf("equal arrays have different hash keys")
+	}
+	other := NewArray([]ObjectI{&Integer{Value: 1}, hash(&Integer{Value: 3})})
+	if ints.HashKey() == other.HashKey() {
+		t.Errorf("arrays holding different hashes have the same hash key")
+	}
+	later := NewArray([]ObjectI{&Integer{Value: 1}, &String{Value: "zzz"}})
+	if c, ok := Compare(one, later); !ok || c != -1 {
+		t.Errorf("Compare gave %d %v", c, ok)
+	}
+	if _, ok := Compare(one, diff); ok {
+		t.Errorf("Compare ordered INTEGER against STRING")
+	}
+}
+
+func TestSetOrder(t *testing.T) {
+	s := NewSet([]ObjectI{
+		&String{Value: "b"}, &Integer{Value: 3}, TRUE,
+		&Float{Value: 1.5}, &String{Value: "a"}, &Integer{Value: 3},
+	})
+	if s.Len() != 5 {
+		t.Fatalf("wrong length %d", s.Len())
+	}
+	if s.Inspect() != "{1.5, 3, true, a, b}" {
+		t.Fatalf("wrong order %s", s.Inspect())
+	}
+	removed := s.Remove(&Integer{Value: 3})
+	if removed.Len() != 4 || !s.Has(&Integer{Value: 3}) {
+		t.Fatalf("Remove changed the original set")
+	}
+	if s.HashKey() != NewSet(s.Elements()).HashKey() {
+		t.Fatalf("HashKey depends on insertion order")
+	}
+}
+
+func TestChannelCloseBlockedSend(t *testing.T) {
+	c := NewChannel(0)
+	sent := make(chan error)
+	go func() { sent <- c.Send(&Integer{Value: 1}) }()
+
+	// Give the send time to block, then close under it.
+	time.Sleep(10 * time.Millisecond)
+	if err := c.Close(); err != nil {
+		t.Fatalf("close failed: %s", err)
+	}
+	select {
+	case err := <-sent:
+		if err == nil || err.Error() != "send on closed channel" {
+			t.Errorf("expected the send to fail, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("close left the send blocked")
+	}
+	if err := c.Close(); err == nil {
+		t.Errorf("expected closing twice to fail")
+	}
+}
+
+func TestEnvironmentConcurrentAccess(t *testing.T) {
+	env := NewEnvironment()
+	inner := NewTemporaryScope(env, []string{"i"})
+	done := make(chan bool)
+	for g := 0; g < 8; g++ {
+		go func(g int) {
+			for i := 0; i < 1000; i++ {
+				name := "v" + strconv.Itoa(i%10)
+				inner.Set(name, &Integer{Value: int64(g)})
+				inner.Set("i", &Integer{Value: int64(i)})
+				inner.Get(name)
+				env.Names("v")
+			}
+			done <- true
+		}(g)
+	}
+	for g := 0; g < 8; g++ {
+		<-done
+	}
+	if len(env.Names("v")) != 10 {
+		t.Fatalf("wrong names %v", env.Names("v"))
+	}
+}
+
+func TestEnvironmentConcurrentHooks(t *testing.T) {
+	env := NewEnvironment()
+	inner := NewEnclosedEnvironment(env)
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 1000; i++ {
+			env.SetTrace(func(asti.StatementI, *Environment) {})
+			env.SetCallTrace(nil)
+			env.SetBranchTrace(nil)
+			env.SetClock(time.Now)
+			inner.SetFrame(&Frame{Name: "f"})
+			inner.SetYield(nil)
+		}
+		done <- true
+	}()
+	for i := 0; i < 1000; i++ {
+		scope := NewEnclosedEnvironment(inner)
+		scope.Trace()
+		scope.CallTrace()
+		scope.BranchTrace()
+		scope.Now()
+		scope.Frame()
+		scope.Yield()
+	}
+	<-done
+	if NewEnclosedEnvironment(inner).Spawned() != env.Spawned() {
+		t.Errorf("expected enclosed environments to share the count of spawned functions")
+	}
+	if NewEnvironment().Spawned() == env.Spawned() {
+		t.Errorf("expected separate environments to count spawned functions apart")
+	}
+}
+
+func TestToInterface(t *testing.T) {
+	key := &String{Value: "k"}
+	hash := (&Hash{}).Set(key.HashKey(), HashPair{Key: key, Value: NewArray([]ObjectI{
+		&Integer{Value: 1}, &Float{Value: 2.5}, TRUE, NULL, &String{Value: "s"},
+	})})
+	got := fmt.Sprintf("%v", hash.ToInterface())
+	if got != "map[k:[1 2.5 true <nil> s]]" {
+		t.Fatalf("wrong ToInterface %s", got)
+	}
+	set := NewSet([]ObjectI{&Integer{Value: 2}, &Integer{Value: 1}})
+	if got := fmt.Sprintf("%v", set.ToInterface()); got != "[1 2]" {
+		t.Fatalf("wrong ToInterface %s", got)
+	}
+}
+
+func TestBytes(t *testing.T) {
+	a := &Bytes{Value: []byte("a\x00b")}
+	b := &Bytes{Value: []byte("a\x00b")}
+	s := &String{Value: "a\x00b"}
+	if a.HashKey() != b.HashKey() || a.HashKey() == s.HashKey() {
+		t.Errorf("bytes hash keys should match each other but not strings")
+	}
+	if !Equals(a, b) || Equals(a, s) {
+		t.Errorf("bytes should equal bytes with the same content only")
+	}
+	if a.Inspect() != `b"a\x00b"` {
+		t.Errorf("unexpected Inspect %s", a.Inspect())
+	}
+	items, _ := Collect(a.Iter())
+	if len(items) != 3 || items[2].Inspect() != "98" {
+		t.Errorf("unexpected items %v", items)
+	}
+}
+
+func TestFileModes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "f")
+
+	r := &File{Filename: path}
+	if err := r.Open("r"); err == nil {
+		t.Errorf("opening a missing file for reading should fail")
+	}
+
+	w := &File{Filename: path}
+	if err := w.Open("x"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Read(make([]byte, 1)); err == nil {
+		t.Errorf("reading a write-only file should fail")
+	}
+	w.Write([]byte("one\ntwo"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("x")); err == nil {
+		t.Errorf("writing a closed file should fail")
+	}
+	if err := w.Close(); err == nil {
+		t.Errorf("closing twice should fail")
+	}
+	if err := (&File{Filename: path}).Open("x"); err == nil {
+		t.Errorf("exclusive open of an existing file should fail")
+	}
+	if err := (&File{Filename: path}).Open("rw"); err == nil {
+		t.Errorf("invalid mode should fail")
+	}
+
+	// Reads and writes on a read-write handle share one offset.
+	rw := &File{Filename: path}
+	if err := rw.Open("r+b"); err != nil {
+		t.Fatal(err)
+	}
+	line, _ := rw.readLine()
+	if line != "one\n" {
+		t.Errorf("unexpected line %q", line)
+	}
+	rw.Write([]byte("TWO"))
+	if pos, _ := rw.Seek(0, io.SeekCurrent); pos != 7 {
+		t.Errorf("expected offset 7, got %d", pos)
+	}
+	rw.Seek(0, io.SeekStart)
+	data, _ := rw.readN(-1)
+	if string(data) != "one\nTWO" {
+		t.Errorf("unexpected contents %q", data)
+	}
+	rw.Close()
+
+	a := &File{Filename: path}
+	if err := a.Open("a"); err != nil {
+		t.Fatal(err)
+	}
+	a.Write([]byte("!"))
+	a.Close()
+	w = &File{Filename: path}
+	if err := w.Open("w"); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	if info, err := os.Stat(path); err != nil || info.Size() != 0 {
+		t.Errorf("opening with w should truncate, got %v %v", info, err)
+	}
+}
+
+func TestRangeLen(t *testing.T) {
+	tests := []struct {
+		r        Range
+		expected int64
+	}{
+		{Range{Start: 1, End: 3}, 3},
+		{Range{Start: 3, End: 1}, 0},
+		{Range{Start: 1, End: math.MaxInt64}, math.MaxInt64},
+		{Range{Start: 0, End: math.MaxInt64}, math.MaxInt64},
+		{Range{Start: math.MinInt64, End: math.MaxInt64}, math.MaxInt64},
+	}
+	for _, tt := range tests {
+		if got := tt.r.Len(); got != tt.expected {
+			t.Errorf("%s: expected %d, got %d", tt.r.Inspect(), tt.expected, got)
+		}
+	}
+}
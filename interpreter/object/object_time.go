@@ -0,0 +1,282 @@
+package object
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kasworld/nonkey/enum/objecttype"
+)
+
+// layouts holds the named layouts format() and time.parse() accept in
+// place of a Go reference-time layout.
+var layouts = map[string]string{
+	"ANSIC":       time.ANSIC,
+	"Kitchen":     time.Kitchen,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"RFC822":      time.RFC822,
+	"RFC822Z":     time.RFC822Z,
+	"UnixDate":    time.UnixDate,
+	"DateTime":    "2006-01-02 15:04:05",
+	"DateOnly":    "2006-01-02",
+	"TimeOnly":    "15:04:05",
+}
+
+// Layout returns the layout a name such as "RFC3339" stands for, or
+// the layout itself if it isn't one of those names.
+func Layout(layout string) string {
+	if named, ok := layouts[layout]; ok {
+		return named
+	}
+	return layout
+}
+
+// LoadLocation returns the time zone with the given IANA name, "UTC",
+// "Local", or a fixed offset such as "+09:00" or "-0500".
+func LoadLocation(name string) (*time.Location, error) {
+	if len(name) > 0 && (name[0] == '+' || name[0] == '-') {
+		digits := strings.Replace(name[1:], ":", "", 1)
+		if len(digits) == 2 {
+			digits += "00"
+		}
+		hh, herr := strconv.Atoi(digits[:len(digits)/2])
+		mm, merr := strconv.Atoi(digits[len(digits)/2:])
+		if len(digits) != 4 || herr != nil || merr != nil || hh > 14 || mm >= 60 {
+			return nil, fmt.Errorf("invalid time zone offset %s", name)
+		}
+		offset := hh*3600 + mm*60
+		if name[0] == '-' {
+			offset = -offset
+		}
+		return time.FixedZone(name, offset), nil
+	}
+	return time.LoadLocation(name)
+}
+
+// Time wraps time.Time and implements ObjectI and HashableI interfaces.
+type Time struct {
+	// Value holds the time this object wraps.
+	Value time.Time
+}
+
+// Type returns the type of this object.
+func (t *Time) Type() objecttype.ObjectType {
+	return objecttype.TIME
+}
+
+// Inspect returns a string-representation of the given object.
+func (t *Time) Inspect() string {
+	return t.Value.Format(time.RFC3339Nano)
+}
+
+// HashKey returns a hash key for the given object.
+//
+// The same instant gives the same key whatever its time zone, as
+// such times are equal.
+func (t *Time) HashKey() HashKey {
+	return HashKey{Type: t.Type(), Value: uint64(t.Value.UnixNano())}
+}
+
+// InvokeMethod invokes a method against the object.
+// (Built-in methods only.)
+func (t *Time) InvokeMethod(method string, env Environment, args ...ObjectI) ObjectI {
+	switch method {
+	case "format":
+		layout := time.RFC3339
+		if len(args) > 1 {
+			return &Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=0|1", len(args))}
+		}
+		if len(args) == 1 {
+			str, ok := args[0].(*String)
+			if !ok {
+				return &Error{Message: fmt.Sprintf("argument to `format` must be STRING, got=%s", args[0].Type())}
+			}
+			layout = Layout(str.Value)
+		}
+		return &String{Value: t.Value.Format(layout)}
+	case "in":
+		if len(args) != 1 {
+			return &Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=1", len(args))}
+		}
+		str, ok := args[0].(*String)
+		if !ok {
+			return &Error{Message: fmt.Sprintf("argument to `in` must be STRING, got=%s", args[0].Type())}
+		}
+		loc, err := LoadLocation(str.Value)
+		if err != nil {
+			return &Error{Message: err.Error()}
+		}
+		return &Time{Value: t.Value.In(loc)}
+	case "utc":
+		return &Time{Value: t.Value.UTC()}
+	case "local":
+		return &Time{Value: t.Value.Local()}
+	case "zone":
+		name, _ := t.Value.Zone()
+		return &String{Value: name}
+	case "offset":
+		// Seconds east of UTC.
+		_, offset := t.Value.Zone()
+		return &Integer{Value: int64(offset)}
+	case "unix":
+		return &Integer{Value: t.Value.Unix()}
+	case "unix_ms":
+		return &Integer{Value: t.Value.UnixNano() / int64(time.Millisecond)}
+	case "unix_nano":
+		return &Integer{Value: t.Value.UnixNano()}
+	case "year":
+		return &Integer{Value: int64(t.Value.Year())}
+	case "month":
+		return &Integer{Value: int64(t.Value.Month())}
+	case "day":
+		return &Integer{Value: int64(t.Value.Day())}
+	case "hour":
+		return &Integer{Value: int64(t.Value.Hour())}
+	case "minute":
+		return &Integer{Value: int64(t.Value.Minute())}
+	case "second":
+		return &Integer{Value: int64(t.Value.Second())}
+	case "nanosecond":
+		return &Integer{Value: int64(t.Value.Nanosecond())}
+	case "weekday":
+		return &String{Value: t.Value.Weekday().String()}
+	case "yday":
+		return &Integer{Value: int64(t.Value.YearDay())}
+	case "add", "truncate", "round":
+		if len(args) != 1 {
+			return &Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=1", len(args))}
+		}
+		d, ok := args[0].(*Duration)
+		if !ok {
+			return &Error{Message: fmt.Sprintf("argument to `%s` must be DURATION, got=%s", method, args[0].Type())}
+		}
+		switch method {
+		case "add":
+			return &Time{Value: t.Value.Add(d.Value)}
+		case "truncate":
+			return &Time{Value: t.Value.Truncate(d.Value)}
+		}
+		return &Time{Value: t.Value.Round(d.Value)}
+	case "sub":
+		if len(args) != 1 {
+			return &Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=1", len(args))}
+		}
+		other, ok := args[0].(*Time)
+		if !ok {
+			return &Error{Message: fmt.Sprintf("argument to `sub` must be TIME, got=%s", args[0].Type())}
+		}
+		return &Duration{Value: t.Value.Sub(other.Value)}
+	case "methods":
+		static := []string{"add", "day", "format", "hour", "in", "local", "methods",
+			"minute", "month", "nanosecond", "offset", "round", "second", "sub",
+			"truncate", "unix", "unix_ms", "unix_nano", "utc", "weekday", "yday",
+			"year", "zone"}
+		dynamic := env.Names("time.")
+
+		var names []string
+		names = append(names, static...)
+		for _, e := range dynamic {
+			bits := strings.Split(e, ".")
+			names = append(names, bits[1])
+		}
+		sort.Strings(names)
+
+		result := make([]ObjectI, len(names))
+		for i, txt := range names {
+			result[i] = &String{Value: txt}
+		}
+		return NewArray(result)
+	}
+	return nil
+}
+
+// ToInterface converts this object to a go-interface, which will allow
+// it to be used naturally in our sprintf/printf primitives.
+//
+// It might also be helpful for embedded users.
+func (t *Time) ToInterface() interface{} {
+	return t.Value
+}
+
+// Duration wraps time.Duration and implements ObjectI and HashableI
+// interfaces.
+type Duration struct {
+	// Value holds the duration this object wraps.
+	Value time.Duration
+}
+
+// Type returns the type of this object.
+func (d *Duration) Type() objecttype.ObjectType {
+	return objecttype.DURATION
+}
+
+// Inspect returns a string-representation of the given object.
+func (d *Duration) Inspect() string {
+	return d.Value.String()
+}
+
+// HashKey returns a hash key for the given object.
+func (d *Duration) HashKey() HashKey {
+	return HashKey{Type: d.Type(), Value: uint64(d.Value)}
+}
+
+// InvokeMethod invokes a method against the object.
+// (Built-in methods only.)
+func (d *Duration) InvokeMethod(method string, env Environment, args ...ObjectI) ObjectI {
+	switch method {
+	case "hours":
+		return &Float{Value: d.Value.Hours()}
+	case "minutes":
+		return &Float{Value: d.Value.Minutes()}
+	case "seconds":
+		return &Float{Value: d.Value.Seconds()}
+	case "milliseconds":
+		return &Integer{Value: d.Value.Milliseconds()}
+	case "nanoseconds":
+		return &Integer{Value: d.Value.Nanoseconds()}
+	case "truncate", "round":
+		if len(args) != 1 {
+			return &Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=1", len(args))}
+		}
+		m, ok := args[0].(*Duration)
+		if !ok {
+			return &Error{Message: fmt.Sprintf("argument to `%s` must be DURATION, got=%s", method, args[0].Type())}
+		}
+		if method == "truncate" {
+			return &Duration{Value: d.Value.Truncate(m.Value)}
+		}
+		return &Duration{Value: d.Value.Round(m.Value)}
+	case "methods":
+		static := []string{"hours", "methods", "milliseconds", "minutes",
+			"nanoseconds", "round", "seconds", "truncate"}
+		dynamic := env.Names("duration.")
+
+		var names []string
+		names = append(names, static...)
+		for _, e := range dynamic {
+			bits := strings.Split(e, ".")
+			names = append(names, bits[1])
+		}
+		sort.Strings(names)
+
+		result := make([]ObjectI, len(names))
+		for i, txt := range names {
+			result[i] = &String{Value: txt}
+		}
+		return NewArray(result)
+	}
+	return nil
+}
+
+// ToInterface converts this object to a go-interface, which will allow
+// it to be used naturally in our sprintf/printf primitives.
+//
+// It might also be helpful for embedded users.
+func (d *Duration) ToInterface() interface{} {
+	return d.Value
+}
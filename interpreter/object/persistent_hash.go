@@ -0,0 +1,227 @@
+package object
+
+import "math/bits"
+
+// hamt is a persistent hash array mapped trie keyed by HashKey.
+//
+// Each level consumes 5 bits of the (mixed) hash to pick one of 32
+// slots, and a bitmap records which slots are in use so nodes stay
+// small.  Updates copy only the path from the root to the changed
+// entry, so set/delete cost O(log32 n) and older versions are
+// never changed.
+type hamt struct {
+	count int
+	root  *hamtNode
+}
+
+// hamtNode holds the used slots of one level.  Below the last level
+// (shift >= 64) the bitmap is unused and entries is a plain list of
+// keys whose hashes collide.
+type hamtNode struct {
+	bitmap  uint32
+	entries []*hamtEntry
+}
+
+// hamtEntry is either a key/value leaf or a child node.  Entries are
+// never changed once created, so they are shared between versions.
+type hamtEntry struct {
+	key   HashKey
+	pair  HashPair
+	child *hamtNode
+}
+
+const (
+	hamtBits = 5
+	hamtMask = 1<<hamtBits - 1
+)
+
+// hamtHash spreads the key over 64 bits, so small integers and the
+// object-type both contribute to every level.
+func hamtHash(k HashKey) uint64 {
+	h := k.Value ^ uint64(k.Type)<<56
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// get returns the pair stored under key.
+func (m hamt) get(key HashKey) (HashPair, bool) {
+	if m.root == nil {
+		return HashPair{}, false
+	}
+	return m.root.get(hamtHash(key), 0, key)
+}
+
+// set returns a hamt with key bound to pair.
+func (m hamt) set(key HashKey, pair HashPair) hamt {
+	root := m.root
+	if root == nil {
+		root = &hamtNode{}
+	}
+	var added bool
+	m.root, added = root.set(hamtHash(key), 0, key, pair)
+	if added {
+		m.count++
+	}
+	return m
+}
+
+// delete returns a hamt without key.
+func (m hamt) delete(key HashKey) hamt {
+	if m.root == nil {
+		return m
+	}
+	root, removed := m.root.delete(hamtHash(key), 0, key)
+	if removed {
+		m.root = root
+		m.count--
+	}
+	return m
+}
+
+// each calls fn for every pair until fn returns false.
+func (m hamt) each(fn func(HashPair) bool) {
+	if m.root != nil {
+		m.root.each(fn)
+	}
+}
+
+// slot returns the bit for the hash at this level, and the position
+// of that slot within entries.
+func (n *hamtNode) slot(h uint64, shift uint) (uint32, int) {
+	bit := uint32(1) << ((h >> shift) & hamtMask)
+	return bit, bits.OnesCount32(n.bitmap & (bit - 1))
+}
+
+func (n *hamtNode) get(h uint64, shift uint, key HashKey) (HashPair, bool) {
+	if shift >= 64 {
+		for _, e := range n.entries {
+			if e.key == key {
+				return e.pair, true
+			}
+		}
+		return HashPair{}, false
+	}
+	bit, pos := n.slot(h, shift)
+	if n.bitmap&bit == 0 {
+		return HashPair{}, false
+	}
+	e := n.entries[pos]
+	if e.child != nil {
+		return e.child.get(h, shift+hamtBits, key)
+	}
+	if e.key == key {
+		return e.pair, true
+	}
+	return HashPair{}, false
+}
+
+func (n *hamtNode) set(h uint64, shift uint, key HashKey, pair HashPair) (*hamtNode, bool) {
+	leaf := &hamtEntry{key: key, pair: pair}
+
+	if shift >= 64 {
+		for i, e := range n.entries {
+			if e.key == key {
+				return n.replace(i, leaf), false
+			}
+		}
+		return n.insert(0, len(n.entries), leaf), true
+	}
+
+	bit, pos := n.slot(h, shift)
+	if n.bitmap&bit == 0 {
+		return n.insert(bit, pos, leaf), true
+	}
+
+	e := n.entries[pos]
+	if e.child != nil {
+		child, added := e.child.set(h, shift+hamtBits, key, pair)
+		return n.replace(pos, &hamtEntry{child: child}), added
+	}
+	if e.key == key {
+		return n.replace(pos, leaf), false
+	}
+
+	// Two different keys share this slot; push both down a level.
+	child, _ := (&hamtNode{}).set(hamtHash(e.key), shift+hamtBits, e.key, e.pair)
+	child, _ = child.set(h, shift+hamtBits, key, pair)
+	return n.replace(pos, &hamtEntry{child: child}), true
+}
+
+func (n *hamtNode) delete(h uint64, shift uint, key HashKey) (*hamtNode, bool) {
+	if shift >= 64 {
+		for i, e := range n.entries {
+			if e.key == key {
+				return n.remove(0, i), true
+			}
+		}
+		return n, false
+	}
+
+	bit, pos := n.slot(h, shift)
+	if n.bitmap&bit == 0 {
+		return n, false
+	}
+	e := n.entries[pos]
+	if e.child == nil {
+		if e.key != key {
+			return n, false
+		}
+		return n.remove(bit, pos), true
+	}
+
+	child, removed := e.child.delete(h, shift+hamtBits, key)
+	if !removed {
+		return n, false
+	}
+	switch {
+	case len(child.entries) == 0:
+		return n.remove(bit, pos), true
+	case len(child.entries) == 1 && child.entries[0].child == nil:
+		// A lone leaf can live directly in this level.
+		return n.replace(pos, child.entries[0]), true
+	default:
+		return n.replace(pos, &hamtEntry{child: child}), true
+	}
+}
+
+func (n *hamtNode) each(fn func(HashPair) bool) bool {
+	for _, e := range n.entries {
+		if e.child != nil {
+			if !e.child.each(fn) {
+				return false
+			}
+		} else if !fn(e.pair) {
+			return false
+		}
+	}
+	return true
+}
+
+// replace returns a copy of the node with entries[pos] replaced.
+func (n *hamtNode) replace(pos int, e *hamtEntry) *hamtNode {
+	entries := make([]*hamtEntry, len(n.entries))
+	copy(entries, n.entries)
+	entries[pos] = e
+	return &hamtNode{bitmap: n.bitmap, entries: entries}
+}
+
+// insert returns a copy of the node with e inserted at pos.
+func (n *hamtNode) insert(bit uint32, pos int, e *hamtEntry) *hamtNode {
+	entries := make([]*hamtEntry, len(n.entries)+1)
+	copy(entries, n.entries[:pos])
+	entries[pos] = e
+	copy(entries[pos+1:], n.entries[pos:])
+	return &hamtNode{bitmap: n.bitmap | bit, entries: entries}
+}
+
+// remove returns a copy of the node without entries[pos].
+func (n *hamtNode) remove(bit uint32, pos int) *hamtNode {
+	entries := make([]*hamtEntry, len(n.entries)-1)
+	copy(entries, n.entries[:pos])
+	copy(entries[pos:], n.entries[pos+1:])
+	return &hamtNode{bitmap: n.bitmap &^ bit, entries: entries}
+}
@@ -0,0 +1,167 @@
+package object
+
+// vector is a persistent vector: a 32-way trie of ObjectI plus a tail
+// holding the last (up to) 32 elements.
+//
+// Every update returns a new vector which shares all untouched nodes
+// with the old one, so an append or a set costs O(log32 n) rather than
+// a copy of the whole array, and older versions are never changed.
+type vector struct {
+	count int
+	shift uint
+	root  *vectorNode
+	tail  []ObjectI
+}
+
+// vectorNode is an interior node (children) or a leaf (values).
+type vectorNode struct {
+	children []*vectorNode
+	values   []ObjectI
+}
+
+const (
+	vectorBits  = 5
+	vectorWidth = 1 << vectorBits
+	vectorMask  = vectorWidth - 1
+)
+
+var emptyVectorNode = &vectorNode{}
+
+// newVector builds a vector holding the given elements.
+func newVector(elements []ObjectI) vector {
+	v := vector{shift: vectorBits, root: emptyVectorNode}
+
+	// Push whole leaves straight into the trie, leaving the remainder
+	// (which may be a full leaf) as the tail.
+	full := 0
+	if len(elements) > 0 {
+		full = (len(elements) - 1) / vectorWidth * vectorWidth
+	}
+	for full > v.count {
+		leaf := make([]ObjectI, vectorWidth)
+		copy(leaf, elements[v.count:v.count+vectorWidth])
+		v.tail = leaf
+		v.count += vectorWidth
+		v = v.pushTail()
+	}
+	v.tail = make([]ObjectI, len(elements)-full)
+	copy(v.tail, elements[full:])
+	v.count = len(elements)
+	return v
+}
+
+// tailOffset is the index of the first element held in the tail.
+func (v vector) tailOffset() int {
+	return v.count - len(v.tail)
+}
+
+// get returns the element at index i, which must be in range.
+func (v vector) get(i int) ObjectI {
+	if off := v.tailOffset(); i >= off {
+		return v.tail[i-off]
+	}
+	node := v.root
+	for level := v.shift; level > 0; level -= vectorBits {
+		node = node.children[(i>>level)&vectorMask]
+	}
+	return node.values[i&vectorMask]
+}
+
+// set returns a vector with index i, which must be in range, replaced.
+func (v vector) set(i int, val ObjectI) vector {
+	if off := v.tailOffset(); i >= off {
+		tail := make([]ObjectI, len(v.tail))
+		copy(tail, v.tail)
+		tail[i-off] = val
+		v.tail = tail
+		return v
+	}
+	v.root = v.root.set(v.shift, i, val)
+	return v
+}
+
+func (n *vectorNode) set(level uint, i int, val ObjectI) *vectorNode {
+	if level == 0 {
+		values := make([]ObjectI, len(n.values))
+		copy(values, n.values)
+		values[i&vectorMask] = val
+		return &vectorNode{values: values}
+	}
+	children := make([]*vectorNode, len(n.children))
+	copy(children, n.children)
+	idx := (i >> level) & vectorMask
+	children[idx] = children[idx].set(level-vectorBits, i, val)
+	return &vectorNode{children: children}
+}
+
+// append returns a vector with val added at the end.
+func (v vector) append(val ObjectI) vector {
+	if len(v.tail) == vectorWidth {
+		v = v.pushTail()
+		v.tail = nil
+	}
+	tail := make([]ObjectI, len(v.tail)+1)
+	copy(tail, v.tail)
+	tail[len(v.tail)] = val
+	v.tail = tail
+	v.count++
+	return v
+}
+
+// pushTail moves a full tail into the trie, growing the trie by a
+// level when the root is full.  The tail is left for the caller to
+// replace.
+func (v vector) pushTail() vector {
+	if v.root == nil {
+		v.root, v.shift = emptyVectorNode, vectorBits
+	}
+	leaf := &vectorNode{values: v.tail}
+	if (v.count >> vectorBits) > (1 << v.shift) {
+		v.root = &vectorNode{children: []*vectorNode{v.root, newVectorPath(v.shift, leaf)}}
+		v.shift += vectorBits
+		return v
+	}
+	v.root = v.root.pushTail(v.count, v.shift, leaf)
+	return v
+}
+
+func (n *vectorNode) pushTail(count int, level uint, leaf *vectorNode) *vectorNode {
+	idx := ((count - 1) >> level) & vectorMask
+	children := make([]*vectorNode, idx+1)
+	copy(children, n.children)
+	if level == vectorBits {
+		children[idx] = leaf
+	} else if children[idx] != nil {
+		children[idx] = children[idx].pushTail(count, level-vectorBits, leaf)
+	} else {
+		children[idx] = newVectorPath(level-vectorBits, leaf)
+	}
+	return &vectorNode{children: children}
+}
+
+// newVectorPath wraps the leaf in enough single-child nodes to reach level.
+func newVectorPath(level uint, leaf *vectorNode) *vectorNode {
+	if level == 0 {
+		return leaf
+	}
+	return &vectorNode{children: []*vectorNode{newVectorPath(level-vectorBits, leaf)}}
+}
+
+// slice returns the elements as a newly allocated go slice.
+func (v vector) slice() []ObjectI {
+	out := make([]ObjectI, 0, v.count)
+	var walk func(n *vectorNode, level uint)
+	walk = func(n *vectorNode, level uint) {
+		if level == 0 {
+			out = append(out, n.values...)
+			return
+		}
+		for _, c := range n.children {
+			walk(c, level-vectorBits)
+		}
+	}
+	if v.root != nil {
+		walk(v.root, v.shift)
+	}
+	return append(out, v.tail...)
+}
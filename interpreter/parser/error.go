@@ -1,31 +1,207 @@
 package parser
 
 import (
+	"bytes"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kasworld/nonkey/config/builtinfunctions"
+	"github.com/kasworld/nonkey/enum/tokentype"
+	"github.com/kasworld/nonkey/interpreter/lexer"
+	"github.com/kasworld/nonkey/interpreter/token"
 )
 
+// Error is a problem found parsing a program, at the token which
+// shows it.
 type Error struct {
 	Msg  string
 	Line int // token pos line in source code
 	Pos  int // token pos of source code
+	End  int // pos just after the token
+
+	// Hint suggests a fix, such as the keyword a misspelt name was
+	// meant to be.
+	Hint string
 }
 
 func (err Error) String() string {
-	return fmt.Sprintf("%s at Line:%v Pos:%v",
+	s := fmt.Sprintf("%s at Line:%v Pos:%v",
 		err.Msg, err.Line, err.Pos,
 	)
+	if err.Hint != "" {
+		s += "; " + err.Hint
+	}
+	return s
 }
 
+// AddError records an error at the current token.
 func (p *Parser) AddError(format string, args ...interface{}) {
+	p.errorAt(p.curToken, format, args...)
+}
+
+// errorAt records an error at a token, unless the statement being
+// parsed already has one, which this is likely to follow from.
+func (p *Parser) errorAt(tok token.Token, format string, args ...interface{}) {
+	if p.panicking {
+		return
+	}
+	p.panicking = true
+
+	length := utf8.RuneCountInString(tok.Literal)
+	if length == 0 || tok.Type == tokentype.ILLEGAL && tok.Literal == lexer.UnterminatedString {
+		// Mark the quote an unterminated string starts with.
+		length = 1
+	}
 	err := Error{
 		Msg:  fmt.Sprintf(format, args...),
-		Line: p.l.CurrentLine(),
-		Pos:  p.l.CurrentPosInLine(),
+		Line: tok.Line,
+		Pos:  tok.Pos,
+		End:  tok.Pos + length,
+	}
+	// A misspelt keyword often parses as a call, as in
+	// `swicth (x) { ... }`, and the error comes in the next statement.
+	candidates := []token.Token{tok, p.curToken, p.start}
+	for i := len(p.previous) - 1; i >= 0; i-- {
+		candidates = append(candidates, p.previous[i])
+	}
+	for _, t := range candidates {
+		if t.Type != tokentype.IDENT {
+			continue
+		}
+		if name := closest(t.Literal); name != "" {
+			err.Hint = fmt.Sprintf("did you mean `%s`?", name)
+			break
+		}
 	}
 	p.errors = append(p.errors, err)
 }
 
+// describe returns how errors refer to a token.
+func describe(tok token.Token) string {
+	if tok.Type == tokentype.EOF {
+		return "end of input"
+	}
+	return tok.Literal
+}
+
 // Errors return stored errors
 func (p *Parser) Errors() []Error {
 	return p.errors
 }
+
+// closest returns the keyword or builtin a name is most likely a
+// misspelling of, or nothing if it's not close to any, or is itself
+// a builtin.
+func closest(name string) string {
+	if _, ok := builtinfunctions.BuiltinFunctions[name]; ok {
+		return ""
+	}
+	if len(name) < 2 {
+		return ""
+	}
+	limit := 1
+	if len(name) > 5 {
+		limit = 2
+	}
+
+	var names []string
+	for word := range tokentype.Keywords {
+		names = append(names, word)
+	}
+	for word := range builtinfunctions.BuiltinFunctions {
+		names = append(names, word)
+	}
+	sort.Strings(names)
+
+	best, bestDist := "", limit+1
+	for _, word := range names {
+		if d := distance(name, word); d < bestDist {
+			best, bestDist = word, d
+		}
+	}
+	return best
+}
+
+// distance returns the number of insertions, deletions, substitutions
+// and transpositions of adjacent characters which turn a into b.
+func distance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	d := make([][]int, len(s)+1)
+	for i := range d {
+		d[i] = make([]int, len(t)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(s)][len(t)]
+}
+
+func min(first int, rest ...int) int {
+	for _, n := range rest {
+		if n < first {
+			first = n
+		}
+	}
+	return first
+}
+
+// Render returns errors found parsing src, from the file name, each
+// with the line it's on and the offending token underlined:
+//
+//	error: expected next token to be ), got {
+//	 --> demo.mon:1:7
+//	  |
+//	1 | if (x { puts(x); }
+//	  |       ^
+func Render(name, src string, errs []Error) string {
+	lines := strings.Split(src, "\n")
+	var out bytes.Buffer
+	for _, err := range errs {
+		fmt.Fprintf(&out, "error: %s\n", err.Msg)
+
+		number := strconv.Itoa(err.Line + 1)
+		gutter := strings.Repeat(" ", len(number))
+		fmt.Fprintf(&out, "%s--> %s:%d:%d\n", gutter, name, err.Line+1, err.Pos)
+		if err.Line >= 0 && err.Line < len(lines) {
+			line := []rune(strings.TrimRight(lines[err.Line], "\r"))
+
+			// Indent the carets as the line is, keeping tabs.
+			var indent []rune
+			for i := 0; i < err.Pos-1 && i < len(line); i++ {
+				if line[i] == '\t' {
+					indent = append(indent, '\t')
+				} else {
+					indent = append(indent, ' ')
+				}
+			}
+			carets := err.End - err.Pos
+			if carets < 1 {
+				carets = 1
+			}
+
+			fmt.Fprintf(&out, "%s |\n", gutter)
+			fmt.Fprintf(&out, "%s | %s\n", number, string(line))
+			fmt.Fprintf(&out, "%s | %s%s\n", gutter, string(indent), strings.Repeat("^", carets))
+		}
+		if err.Hint != "" {
+			fmt.Fprintf(&out, "%s = help: %s\n", gutter, err.Hint)
+		}
+	}
+	return out.String()
+}
@@ -3,7 +3,6 @@
 package parser
 
 import (
-	"fmt"
 	"strconv"
 	"strings"
 
@@ -58,6 +57,23 @@ type Parser struct {
 	//
 	// Nested ternary expressions are illegal :)
 	tern bool
+
+	// functions counts the function bodies we're inside, and
+	// yielded records whether the innermost one contains a yield.
+	functions int
+	yielded   bool
+
+	// panicking is set once the statement being parsed has an
+	// error, so those which follow from it aren't reported.
+	panicking bool
+
+	// start is the first token of the statement being parsed, and
+	// previous those of the statements before it on the same line.
+	start    token.Token
+	previous []token.Token
+
+	// depth counts the braces we're inside.
+	depth int
 }
 
 // New returns our new parser-object.
@@ -89,12 +105,15 @@ func New(l *lexer.Lexer) *Parser {
 		tokentype.MINUS:           p.parsePrefixExpression,
 		tokentype.REGEXP:          p.parseRegexpLiteral,
 		tokentype.STRING:          p.parseStringLiteral,
+		tokentype.SELECT:          p.parseSelectStatement,
+		tokentype.SPAWN:           p.parseSpawnExpression,
 		tokentype.SWITCH:          p.parseSwitchStatement,
 		tokentype.TRUE:            p.parseBoolean,
 	}
 
 	// Register infix functions
 	p.infixParseFns = [tokentype.TokenType_Count]infixParseFn{
+		tokentype.AMPERSAND:       p.parseInfixExpression,
 		tokentype.AND:             p.parseInfixExpression,
 		tokentype.ASSIGN:          p.parseAssignExpression,
 		tokentype.ASTERISK:        p.parseInfixExpression,
@@ -115,6 +134,7 @@ func New(l *lexer.Lexer) *Parser {
 		tokentype.NOT_EQ:          p.parseInfixExpression,
 		tokentype.OR:              p.parseInfixExpression,
 		tokentype.PERIOD:          p.parseMethodCallExpression,
+		tokentype.PIPE:            p.parseInfixExpression,
 		tokentype.PLUS:            p.parseInfixExpression,
 		tokentype.PLUS_EQUALS:     p.parseAssignExpression,
 		tokentype.POW:             p.parseInfixExpression,
@@ -138,14 +158,22 @@ func (p *Parser) nextToken() {
 	p.prevToken = p.curToken
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+	switch p.curToken.Type {
+	case tokentype.LBRACE:
+		p.depth++
+	case tokentype.RBRACE:
+		p.depth--
+	}
 }
 
 // ParseProgram used to parse the whole program
 func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{}
 	program.Statements = []asti.StatementI{}
+	var previous []token.Token
 	for p.curToken.Type != tokentype.EOF {
-		stmt := p.parseStatement()
+		previous = sameLine(previous, p.curToken)
+		stmt := p.statement(previous)
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
@@ -154,6 +182,64 @@ func (p *Parser) ParseProgram() *ast.Program {
 	return program
 }
 
+// statement parses a statement of a program or block.  After an
+// error it skips the rest of the statement, so parsing can carry on
+// with the next and report any errors there too.
+func (p *Parser) statement(previous []token.Token) asti.StatementI {
+	panicking, start, before := p.panicking, p.start, p.previous
+	p.start, p.previous = p.curToken, previous[:len(previous)-1]
+	depth := p.depth
+	if p.curTokenIs(tokentype.LBRACE) {
+		depth--
+	}
+
+	stmt := p.parseStatement()
+	if p.panicking && !panicking {
+		p.synchronize(p.depth - depth)
+	}
+	p.panicking, p.start, p.previous = panicking, start, before
+	return stmt
+}
+
+// sameLine adds the start of a statement to those of the statements
+// before it, forgetting them if it's on a new line.
+func sameLine(previous []token.Token, start token.Token) []token.Token {
+	if len(previous) > 0 && previous[0].Line != start.Line {
+		previous = previous[:0]
+	}
+	return append(previous, start)
+}
+
+// synchronize skips to the end of a statement which failed to parse,
+// inside depth braces: its semicolon, the closing brace of its last
+// block, or the token before the closing brace of the block it's in.
+func (p *Parser) synchronize(depth int) {
+	for {
+		if depth == 0 {
+			switch {
+			case p.curTokenIs(tokentype.SEMICOLON):
+				return
+			case p.curTokenIs(tokentype.RBRACE):
+				if !p.peekTokenIs(tokentype.ELSE) && !p.peekTokenIs(tokentype.SEMICOLON) {
+					return
+				}
+			case p.peekTokenIs(tokentype.RBRACE):
+				return
+			}
+		}
+		if p.peekTokenIs(tokentype.EOF) {
+			return
+		}
+		p.nextToken()
+		switch p.curToken.Type {
+		case tokentype.LBRACE:
+			depth++
+		case tokentype.RBRACE:
+			depth--
+		}
+	}
+}
+
 // parseStatement parses a single statement.
 func (p *Parser) parseStatement() asti.StatementI {
 	switch p.curToken.Type {
@@ -163,6 +249,8 @@ func (p *Parser) parseStatement() asti.StatementI {
 		return p.parseConstStatement()
 	case tokentype.RETURN:
 		return p.parseReturnStatement()
+	case tokentype.YIELD:
+		return p.parseYieldStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -183,7 +271,7 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	for !p.curTokenIs(tokentype.SEMICOLON) {
 
 		if p.curTokenIs(tokentype.EOF) {
-			p.AddError("unterminated let statement")
+			p.errorAt(stmt.Token, "unterminated let statement")
 			return nil
 		}
 
@@ -207,7 +295,7 @@ func (p *Parser) parseConstStatement() *ast.ConstStatement {
 	for !p.curTokenIs(tokentype.SEMICOLON) {
 
 		if p.curTokenIs(tokentype.EOF) {
-			p.AddError("unterminated const statement")
+			p.errorAt(stmt.Token, "unterminated const statement")
 			return nil
 		}
 
@@ -224,7 +312,33 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	for !p.curTokenIs(tokentype.SEMICOLON) {
 
 		if p.curTokenIs(tokentype.EOF) {
-			p.AddError("unterminated return statement")
+			p.errorAt(stmt.Token, "unterminated return statement")
+			return nil
+		}
+
+		p.nextToken()
+	}
+	return stmt
+}
+
+// parseYieldStatement parses a yield-statement, which turns the
+// enclosing function into a generator.
+func (p *Parser) parseYieldStatement() *ast.YieldStatement {
+	stmt := &ast.YieldStatement{Token: p.curToken}
+	if p.functions == 0 {
+		p.AddError("yield outside of a function")
+	}
+	p.yielded = true
+	if p.peekTokenIs(tokentype.SEMICOLON) {
+		p.nextToken()
+		return stmt
+	}
+	p.nextToken()
+	stmt.Value = p.parseExpression(precedence.LOWEST)
+	for !p.curTokenIs(tokentype.SEMICOLON) {
+
+		if p.curTokenIs(tokentype.EOF) {
+			p.errorAt(stmt.Token, "unterminated yield statement")
 			return nil
 		}
 
@@ -270,9 +384,18 @@ func (p *Parser) parseExpression(precedence1 precedence.Precedence) asti.Express
 	return leftExp
 }
 
-// parsingBroken is hit if we see an EOF in our input-stream
-// this means we're screwed
+// parsingBroken is hit if we see an EOF in our input-stream, which
+// the statement reports as unterminated, or an illegal token: a
+// character no token starts with, or a string missing its closing
+// quote.
 func (p *Parser) parsingBroken() asti.ExpressionI {
+	if p.curTokenIs(tokentype.ILLEGAL) {
+		if p.curToken.Literal == lexer.UnterminatedString {
+			p.AddError("%s", lexer.UnterminatedString)
+		} else {
+			p.AddError("illegal character %q", p.curToken.Literal)
+		}
+	}
 	return nil
 }
 
@@ -346,7 +469,7 @@ func (p *Parser) parseSwitchStatement() asti.ExpressionI {
 	for !p.curTokenIs(tokentype.RBRACE) {
 
 		if p.curTokenIs(tokentype.EOF) {
-			p.AddError("unterminated switch statement")
+			p.errorAt(expression.Token, "unterminated switch statement")
 			return nil
 		}
 		tmp := &ast.CaseExpression{Token: p.curToken}
@@ -385,9 +508,6 @@ func (p *Parser) parseSwitchStatement() asti.ExpressionI {
 		}
 
 		if !p.expectPeek(tokentype.LBRACE) {
-
-			p.AddError("expected token to be '{', got %s instead", p.curToken.Type)
-			fmt.Printf("error\n")
 			return nil
 		}
 
@@ -395,10 +515,8 @@ func (p *Parser) parseSwitchStatement() asti.ExpressionI {
 		tmp.Block = p.parseBlockStatement()
 
 		if !p.curTokenIs(tokentype.RBRACE) {
-			p.AddError("Syntax Error: expected token to be '}', got %s instead", p.curToken.Type)
-			fmt.Printf("error\n")
+			p.AddError("expected token to be }, got %s instead", describe(p.curToken))
 			return nil
-
 		}
 		p.nextToken()
 
@@ -420,7 +538,7 @@ func (p *Parser) parseSwitchStatement() asti.ExpressionI {
 		}
 	}
 	if count > 1 {
-		p.AddError("A switch-statement should only have one default block")
+		p.errorAt(expression.Token, "A switch-statement should only have one default block")
 		return nil
 
 	}
@@ -428,6 +546,83 @@ func (p *Parser) parseSwitchStatement() asti.ExpressionI {
 
 }
 
+// parseSpawnExpression parses `spawn f(args)`, which runs the call
+// in a goroutine.
+func (p *Parser) parseSpawnExpression() asti.ExpressionI {
+	expression := &ast.SpawnExpression{Token: p.curToken}
+	p.nextToken()
+	expression.Call = p.parseExpression(precedence.PREFIX)
+	switch expression.Call.(type) {
+	case *ast.CallExpression, *ast.ObjectCallExpression:
+		return expression
+	}
+	p.errorAt(expression.Token, "spawn requires a function call, got %v", expression.Call)
+	return nil
+}
+
+// parseSelectStatement parses a select statement, whose cases wait
+// to receive from channels:
+//
+//	select {
+//	    case v in results { puts(v); }
+//	    case done { puts("done"); }
+//	    default { puts("nothing ready"); }
+//	}
+func (p *Parser) parseSelectStatement() asti.ExpressionI {
+	expression := &ast.SelectExpression{Token: p.curToken}
+	if !p.expectPeek(tokentype.LBRACE) {
+		return nil
+	}
+	p.nextToken()
+
+	defaults := 0
+	for !p.curTokenIs(tokentype.RBRACE) {
+
+		if p.curTokenIs(tokentype.EOF) {
+			p.errorAt(expression.Token, "unterminated select statement")
+			return nil
+		}
+		tmp := &ast.SelectCase{Token: p.curToken}
+
+		switch {
+		case p.curTokenIs(tokentype.DEFAULT):
+			tmp.Default = true
+			defaults++
+		case p.curTokenIs(tokentype.CASE):
+			p.nextToken()
+			if p.curTokenIs(tokentype.IDENT) && p.peekTokenIs(tokentype.IN) {
+				tmp.Ident = p.curToken.Literal
+				p.nextToken()
+				p.nextToken()
+			}
+			tmp.Channel = p.parseExpression(precedence.LOWEST)
+			if tmp.Channel == nil {
+				return nil
+			}
+		default:
+			p.AddError("expected case or default in select, got %s", describe(p.curToken))
+			return nil
+		}
+
+		if !p.expectPeek(tokentype.LBRACE) {
+			return nil
+		}
+		tmp.Block = p.parseBlockStatement()
+		if tmp.Block == nil {
+			return nil
+		}
+		p.nextToken()
+
+		expression.Cases = append(expression.Cases, tmp)
+	}
+
+	if defaults > 1 {
+		p.errorAt(expression.Token, "A select-statement should only have one default block")
+		return nil
+	}
+	return expression
+}
+
 // parseBoolean parses a boolean token.
 func (p *Parser) parseBoolean() asti.ExpressionI {
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(tokentype.TRUE)}
@@ -576,7 +771,7 @@ func (p *Parser) parseForEach() asti.ExpressionI {
 		p.nextToken()
 
 		if !p.peekTokenIs(tokentype.IDENT) {
-			p.AddError(fmt.Sprintf("second argument to foreach must be ident, got %v", p.peekToken))
+			p.errorAt(p.peekToken, "second argument to foreach must be ident, got %s", describe(p.peekToken))
 			return nil
 		}
 		p.nextToken()
@@ -613,16 +808,17 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []asti.StatementI{}
 	p.nextToken()
+	var previous []token.Token
 	for !p.curTokenIs(tokentype.RBRACE) {
 
 		// Don't loop forever
 		if p.curTokenIs(tokentype.EOF) {
-			p.AddError(
-				"unterminated block statement")
+			p.errorAt(block.Token, "unterminated block statement")
 			return nil
 		}
 
-		stmt := p.parseStatement()
+		previous = sameLine(previous, p.curToken)
+		stmt := p.statement(previous)
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
@@ -641,7 +837,7 @@ func (p *Parser) parseFunctionLiteral() asti.ExpressionI {
 	if !p.expectPeek(tokentype.LBRACE) {
 		return nil
 	}
-	lit.Body = p.parseBlockStatement()
+	lit.Body, lit.Generator = p.parseFunctionBody()
 	return lit
 }
 
@@ -656,10 +852,24 @@ func (p *Parser) parseFunctionDefinition() asti.ExpressionI {
 	if !p.expectPeek(tokentype.LBRACE) {
 		return nil
 	}
-	lit.Body = p.parseBlockStatement()
+	lit.Body, lit.Generator = p.parseFunctionBody()
 	return lit
 }
 
+// parseFunctionBody parses the body of a function, and reports
+// whether it yields and so is a generator.
+func (p *Parser) parseFunctionBody() (*ast.BlockStatement, bool) {
+	yielded := p.yielded
+	p.functions++
+	p.yielded = false
+	defer func() {
+		p.functions--
+		p.yielded = yielded
+	}()
+	body := p.parseBlockStatement()
+	return body, p.yielded
+}
+
 // parseFunctionParameters parses the parameters used for a function.
 func (p *Parser) parseFunctionParameters() (map[string]asti.ExpressionI, []*ast.Identifier) {
 
@@ -771,10 +981,44 @@ func (p *Parser) parseExpressionList(end tokentype.TokenType) []asti.ExpressionI
 }
 
 // parseInfixExpression parsea an array index expression.
+//
+// If a ":" follows the (optional) first index then we have a
+// slice-expression instead, which is handled by parseSliceExpression.
 func (p *Parser) parseIndexExpression(left asti.ExpressionI) asti.ExpressionI {
 	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	if !p.peekTokenIs(tokentype.COLON) {
+		p.nextToken()
+		exp.Index = p.parseExpression(precedence.LOWEST)
+	}
+	if p.peekTokenIs(tokentype.COLON) {
+		return p.parseSliceExpression(exp.Token, left, exp.Index)
+	}
+	if !p.expectPeek(tokentype.RBRACKET) {
+		return nil
+	}
+	return exp
+}
+
+// parseSliceExpression parses the remainder of `x[start:end:step]`,
+// every part of which is optional.
+func (p *Parser) parseSliceExpression(tok token.Token, left, start asti.ExpressionI) asti.ExpressionI {
+	exp := &ast.SliceExpression{Token: tok, Left: left, Start: start}
+
+	// skip the first ":"
 	p.nextToken()
-	exp.Index = p.parseExpression(precedence.LOWEST)
+	if !p.peekTokenIs(tokentype.COLON) && !p.peekTokenIs(tokentype.RBRACKET) {
+		p.nextToken()
+		exp.End = p.parseExpression(precedence.LOWEST)
+	}
+
+	// An optional step follows a second ":".
+	if p.peekTokenIs(tokentype.COLON) {
+		p.nextToken()
+		if !p.peekTokenIs(tokentype.RBRACKET) {
+			p.nextToken()
+			exp.Step = p.parseExpression(precedence.LOWEST)
+		}
+	}
 	if !p.expectPeek(tokentype.RBRACKET) {
 		return nil
 	}
@@ -784,10 +1028,13 @@ func (p *Parser) parseIndexExpression(left asti.ExpressionI) asti.ExpressionI {
 // parseAssignExpression parses a bare assignment, without a `let`.
 func (p *Parser) parseAssignExpression(name asti.ExpressionI) asti.ExpressionI {
 	stmt := &ast.AssignStatement{Token: p.curToken}
-	if n, ok := name.(*ast.Identifier); ok {
+	switch n := name.(type) {
+	case *ast.Identifier:
 		stmt.Name = n
-	} else {
-		p.AddError("expected assign token to be IDENT, got %s instead",
+	case *ast.IndexExpression:
+		stmt.Target = n
+	default:
+		p.errorAt(name.GetToken(), "expected assign token to be IDENT or index, got %s instead",
 			name.GetToken().Literal)
 	}
 
@@ -807,18 +1054,25 @@ func (p *Parser) parseCallExpression(function asti.ExpressionI) asti.ExpressionI
 }
 
 // parseHashLiteral parses a hash literal.
+//
+// A brace whose first element isn't followed by a colon starts a set
+// literal instead, so `{1, 2}` is a set while `{}` stays an empty hash.
 func (p *Parser) parseHashLiteral() asti.ExpressionI {
 	hash := &ast.HashLiteral{Token: p.curToken}
 	hash.Pairs = make(map[asti.ExpressionI]asti.ExpressionI)
 	for !p.peekTokenIs(tokentype.RBRACE) {
 		p.nextToken()
 		key := p.parseExpression(precedence.LOWEST)
+		if len(hash.Pairs) == 0 && !p.peekTokenIs(tokentype.COLON) {
+			return p.parseSetLiteral(hash.Token, key)
+		}
 		if !p.expectPeek(tokentype.COLON) {
 			return nil
 		}
 		p.nextToken()
 		value := p.parseExpression(precedence.LOWEST)
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 		if !p.peekTokenIs(tokentype.RBRACE) && !p.expectPeek(tokentype.COMMA) {
 			return nil
 		}
@@ -829,11 +1083,39 @@ func (p *Parser) parseHashLiteral() asti.ExpressionI {
 	return hash
 }
 
+// parseSetLiteral parses the rest of a set literal, whose first element
+// has already been read.
+func (p *Parser) parseSetLiteral(tok token.Token, first asti.ExpressionI) asti.ExpressionI {
+	set := &ast.SetLiteral{Token: tok}
+	set.Elements = []asti.ExpressionI{first}
+	for p.peekTokenIs(tokentype.COMMA) {
+		p.nextToken()
+		if p.peekTokenIs(tokentype.RBRACE) {
+			break
+		}
+		p.nextToken()
+		set.Elements = append(set.Elements, p.parseExpression(precedence.LOWEST))
+	}
+	if !p.expectPeek(tokentype.RBRACE) {
+		return nil
+	}
+	return set
+}
+
 // parseMethodCallExpression parses an object-based method-call.
 func (p *Parser) parseMethodCallExpression(obj asti.ExpressionI) asti.ExpressionI {
 	methodCall := &ast.ObjectCallExpression{Token: p.curToken, Object: obj}
 	p.nextToken()
 	name := p.parseIdentifier()
+
+	// Without a call `obj.field` is a shorthand for `obj["field"]`.
+	if !p.peekTokenIs(tokentype.LPAREN) {
+		return &ast.IndexExpression{
+			Token: methodCall.Token,
+			Left:  obj,
+			Index: &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal},
+		}
+	}
 	p.nextToken()
 	methodCall.Call = p.parseCallExpression(name)
 	return methodCall
@@ -856,8 +1138,8 @@ func (p *Parser) expectPeek(t tokentype.TokenType) bool {
 		p.nextToken()
 		return true
 	}
-	p.AddError("expected next token to be %s, got %v",
-		t.Literal(), p.curToken.Literal)
+	p.errorAt(p.peekToken, "expected next token to be %s, got %s",
+		t.Literal(), describe(p.peekToken))
 	return false
 }
 
@@ -5,6 +5,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/kasworld/nonkey/config/builtinfunctions"
 	"github.com/kasworld/nonkey/enum/tokentype"
 	"github.com/kasworld/nonkey/interpreter/ast"
 	"github.com/kasworld/nonkey/interpreter/asti"
@@ -753,6 +754,33 @@ func TestParsingIndexExpression(t *testing.T) {
 	}
 }
 
+func TestParsingSliceExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a[1:2]", "(a[1:2])"},
+		{"a[:2]", "(a[:2])"},
+		{"a[1:]", "(a[1:])"},
+		{"a[:]", "(a[:])"},
+		{"a[::-1]", "(a[::(-1)])"},
+		{"a[1+1:-1:2]", "(a[(1 + 1):(-1):2])"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		stmt, _ := program.Statements[0].(*ast.ExpressionStatement)
+		if _, ok := stmt.Expression.(*ast.SliceExpression); !ok {
+			t.Fatalf("exp not *ast.SliceExpression. got=%T", stmt.Expression)
+		}
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
 func TestParsingHashLiteral(t *testing.T) {
 	input := `{"one":1, "two":2, "three":3}`
 	l := lexer.New(input)
@@ -799,6 +827,28 @@ func TestParsingEmptyHashLiteral(t *testing.T) {
 	}
 }
 
+func TestParsingSetLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"{1}", "{1}"},
+		{"{1, 2, 3}", "{1, 2, 3}"},
+		{"{1, 2,}", "{1, 2}"},
+		{`{"a", 1 + 2}`, "{a, (1 + 2)}"},
+		{"{1} | {2} & {3}", "({1} | ({2} & {3}))"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
 func TestParsingHashLiteralWithExpression(t *testing.T) {
 	input := `{"one":0+1, "two":10 - 8, "three": 15/5}`
 	l := lexer.New(input)
@@ -849,7 +899,10 @@ func TestMutators(t *testing.T) {
 		"let z = 10; y -= 2;",
 		"let z = 1; z++;",
 		"let z = 1; z--;",
-		"let z = 10; let a = 3; y = a;"}
+		"let z = 10; let a = 3; y = a;",
+		"let a = [1]; a[0] = 2;",
+		"let h = {}; h[\"k\"] += 2;",
+		"let h = {}; h.k.j = 2;"}
 
 	for _, txt := range input {
 		l := lexer.New(txt)
@@ -901,6 +954,72 @@ func TestIncompleThings(t *testing.T) {
 	}
 }
 
+func TestYieldStatement(t *testing.T) {
+	input := `let g = fn(n) { let f = fn() { return 1; }; yield n; yield; };`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.LetStatement)
+	gen := stmt.Value.(*ast.FunctionLiteral)
+	if !gen.Generator {
+		t.Fatalf("function with yield isn't a generator")
+	}
+	inner := gen.Body.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	if inner.Generator {
+		t.Fatalf("function without yield is a generator")
+	}
+	if gen.Body.Statements[1].String() != "yield n;" || gen.Body.Statements[2].String() != "yield;" {
+		t.Fatalf("wrong yield statements %q %q",
+			gen.Body.Statements[1], gen.Body.Statements[2])
+	}
+
+	l = lexer.New(`yield 1;`)
+	p = New(l)
+	p.ParseProgram()
+	if len(p.errors) != 1 || !strings.Contains(p.errors[0].Msg, "outside") {
+		t.Fatalf("expected yield outside function error, got %v", p.errors)
+	}
+}
+
+func TestSpawnAndSelect(t *testing.T) {
+	input := `spawn f(1, 2); spawn a.b(); select { case v in c { v; } case d { 1; } default { 2; } }`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if len(program.Statements) != 3 {
+		t.Fatalf("wrong statement count %d", len(program.Statements))
+	}
+	spawn := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.SpawnExpression)
+	if spawn.String() != "spawn f(1, 2)" {
+		t.Errorf("wrong spawn %q", spawn.String())
+	}
+	sel := program.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.SelectExpression)
+	if len(sel.Cases) != 3 {
+		t.Fatalf("wrong case count %d", len(sel.Cases))
+	}
+	if sel.Cases[0].Ident != "v" || sel.Cases[0].Channel.String() != "c" {
+		t.Errorf("wrong receive case %q", sel.Cases[0])
+	}
+	if sel.Cases[1].Ident != "" || sel.Cases[1].Channel.String() != "d" {
+		t.Errorf("wrong receive case %q", sel.Cases[1])
+	}
+	if !sel.Cases[2].Default {
+		t.Errorf("expected default case")
+	}
+
+	for _, bad := range []string{"spawn 1;", "spawn f;", "select { default { 1; } default { 2; } }", "select { 1 { 2; } }"} {
+		l := lexer.New(bad)
+		p := New(l)
+		p.ParseProgram()
+		if len(p.errors) == 0 {
+			t.Errorf("%s: expected parse error", bad)
+		}
+	}
+}
+
 func TestMultiDefault(t *testing.T) {
 	input := `
 switch( val ) {
@@ -930,3 +1049,92 @@ switch( val ) {
 		t.Errorf("Unexpected error-message %s\n", p.errors[0])
 	}
 }
+
+func TestErrorRecovery(t *testing.T) {
+	builtinfunctions.Register("puts", nil)
+
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let a = ;\nlet b = 2;\nlet c = (1;\nputs(a b);", []string{
+			"1:9-10: no prefix parse function for ;",
+			"3:11-12: expected next token to be ), got ;",
+			"4:8-9: expected next token to be ), got b",
+		}},
+		{"if (x) { let a = ; puts(a b); } else { puts(1 2); }\nlet b = 1;", []string{
+			"1:18-19: no prefix parse function for ;",
+			"1:27-28: expected next token to be ), got b",
+			"1:47-48: expected next token to be ), got 2",
+		}},
+		{"if (x { puts(x); }\nlet y = @;", []string{
+			"1:7-8: expected next token to be ), got {",
+			"2:9-10: illegal character \"@\"",
+		}},
+		{"let a = 1;\nlet b = ", []string{"2:1-4: unterminated let statement"}},
+		{"foreach x ni [1] { puts(x); }", []string{"1:11-13: expected next token to be in, got ni; did you mean `in`?"}},
+		{"swicth (y) { case 1 { puts(1); } }\nputs(1);", []string{
+			"1:14-18: no prefix parse function for case; did you mean `switch`?",
+		}},
+		{"fucntion f(a) { return a; }", []string{
+			"1:17-23: no prefix parse function for return; did you mean `function`?",
+		}},
+		{"pust(1 2);", []string{"1:8-9: expected next token to be ), got 2; did you mean `puts`?"}},
+		{"puts(1 2);", []string{"1:8-9: expected next token to be ), got 2"}},
+		{"let s = 1;\nputs(\"abc);\n", []string{"2:6-7: unterminated string"}},
+		{"let s = `ls", []string{"1:9-10: unterminated string"}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		var got []string
+		for _, err := range p.Errors() {
+			s := fmt.Sprintf("%d:%d-%d: %s", err.Line+1, err.Pos, err.End, err.Msg)
+			if err.Hint != "" {
+				s += "; " + err.Hint
+			}
+			got = append(got, s)
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	input := "let ok = 1;\n\tif (ok { puts(ok); }\nforeach x ni [1] { puts(x); }\n"
+	p := New(lexer.New(input))
+	p.ParseProgram()
+
+	expected := `error: expected next token to be ), got {
+ --> demo.mon:2:9
+  |
+2 | 	if (ok { puts(ok); }
+  | 	       ^
+error: expected next token to be in, got ni
+ --> demo.mon:3:11
+  |
+3 | foreach x ni [1] { puts(x); }
+  |           ^^
+  = help: did you mean ` + "`in`" + `?
+`
+	if got := Render("demo.mon", input, p.Errors()); got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+
+	// A method call earlier on the line must not push the caret right.
+	input = "let x = [1];\nputs(x.len(), );\n"
+	p = New(lexer.New(input))
+	p.ParseProgram()
+
+	expected = `error: no prefix parse function for )
+ --> demo.mon:2:15
+  |
+2 | puts(x.len(), );
+  |               ^
+`
+	if got := Render("demo.mon", input, p.Errors()); got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
@@ -0,0 +1,188 @@
+package profile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sort"
+)
+
+// The fields of the messages of profile.proto, from
+// github.com/google/pprof, which are written.
+const (
+	profileSampleType    = 1
+	profileSample        = 2
+	profileLocation      = 4
+	profileFunction      = 5
+	profileStringTable   = 6
+	profileTimeNanos     = 9
+	profileDurationNanos = 10
+	profilePeriodType    = 11
+	profilePeriod        = 12
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	locationID   = 1
+	locationLine = 4
+
+	lineFunctionID = 1
+	lineLine       = 2
+
+	functionID        = 1
+	functionName      = 2
+	functionFilename  = 4
+	functionStartLine = 5
+)
+
+// buffer builds a protocol buffer message.
+type buffer struct {
+	bytes.Buffer
+}
+
+func (b *buffer) varint(n uint64) {
+	for n >= 0x80 {
+		b.WriteByte(byte(n) | 0x80)
+		n >>= 7
+	}
+	b.WriteByte(byte(n))
+}
+
+// uint64 writes a varint field, skipping zero as protocol buffers do.
+func (b *buffer) uint64(field int, n uint64) {
+	if n == 0 {
+		return
+	}
+	b.varint(uint64(field) << 3)
+	b.varint(n)
+}
+
+func (b *buffer) int64(field int, n int64) {
+	b.uint64(field, uint64(n))
+}
+
+// bytes writes a length delimited field.
+func (b *buffer) bytes(field int, data []byte) {
+	b.varint(uint64(field)<<3 | 2)
+	b.varint(uint64(len(data)))
+	b.Write(data)
+}
+
+func (b *buffer) message(field int, m *buffer) {
+	b.bytes(field, m.Bytes())
+}
+
+// packed writes repeated varints as one field.
+func (b *buffer) packed(field int, list []uint64) {
+	var m buffer
+	for _, n := range list {
+		m.varint(n)
+	}
+	b.message(field, &m)
+}
+
+// WritePprof writes what was recorded as a profile for pprof, with a
+// sample for each stack of monkey calls giving the calls made and
+// the time spent in the innermost.
+func (p *Profiler) WritePprof(w io.Writer) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var out buffer
+	index := map[string]int{}
+	var table []string
+	str := func(s string) uint64 {
+		i, ok := index[s]
+		if !ok {
+			i = len(table)
+			index[s] = i
+			table = append(table, s)
+		}
+		return uint64(i)
+	}
+	str("")
+
+	valueType := func(field int, typ, unit string) {
+		var m buffer
+		m.uint64(valueTypeType, str(typ))
+		m.uint64(valueTypeUnit, str(unit))
+		out.message(field, &m)
+	}
+	valueType(profileSampleType, "calls", "count")
+	valueType(profileSampleType, "time", "nanoseconds")
+
+	// Write the samples in a fixed order, so the same profile gives
+	// the same output.
+	var keys []string
+	for key := range p.samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	functions := map[string]uint64{}
+	locations := map[location]uint64{}
+	var functionMessages, locationMessages []*buffer
+	for _, key := range keys {
+		s := p.samples[key]
+		var ids []uint64
+		for _, l := range s.stack {
+			id, ok := locations[l]
+			if !ok {
+				fid, ok := functions[l.function]
+				if !ok {
+					fid = uint64(len(functions) + 1)
+					functions[l.function] = fid
+					var f buffer
+					f.uint64(functionID, fid)
+					f.uint64(functionName, str(l.function))
+					f.uint64(functionFilename, str(p.name))
+					if record, ok := p.functions[l.function]; ok {
+						f.int64(functionStartLine, int64(record.Line))
+					}
+					functionMessages = append(functionMessages, &f)
+				}
+
+				id = uint64(len(locations) + 1)
+				locations[l] = id
+				var line buffer
+				line.uint64(lineFunctionID, fid)
+				line.int64(lineLine, int64(l.line))
+				var m buffer
+				m.uint64(locationID, id)
+				m.message(locationLine, &line)
+				locationMessages = append(locationMessages, &m)
+			}
+			ids = append(ids, id)
+		}
+
+		var m buffer
+		m.packed(sampleLocationID, ids)
+		m.packed(sampleValue, []uint64{uint64(s.calls), uint64(s.self)})
+		out.message(profileSample, &m)
+	}
+	for _, m := range locationMessages {
+		out.message(profileLocation, m)
+	}
+	for _, m := range functionMessages {
+		out.message(profileFunction, m)
+	}
+
+	out.int64(profileTimeNanos, p.start.UnixNano())
+	out.int64(profileDurationNanos, int64(p.duration))
+	valueType(profilePeriodType, "time", "nanoseconds")
+	out.int64(profilePeriod, 1)
+
+	// The string table comes last, once everything has added to it.
+	for _, s := range table {
+		out.bytes(profileStringTable, []byte(s))
+	}
+
+	z := gzip.NewWriter(w)
+	if _, err := z.Write(out.Bytes()); err != nil {
+		return err
+	}
+	return z.Close()
+}
@@ -0,0 +1,284 @@
+// Package profile records where monkey programs spend their time: the
+// calls of each function with the time spent in them, and how often
+// each line runs.
+//
+// It follows a parsed program through the trace hooks of the
+// environment the same program runs in, and reports what it found as text, or in the format of
+// pprof so `go tool pprof` can show monkey functions.
+package profile
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kasworld/nonkey/interpreter/ast"
+	"github.com/kasworld/nonkey/interpreter/asti"
+	"github.com/kasworld/nonkey/interpreter/cover"
+	"github.com/kasworld/nonkey/interpreter/object"
+)
+
+// mainName names the top level of the program.
+const mainName = "main"
+
+// Function holds what was recorded for one function.
+type Function struct {
+	Name  string
+	Line  int // where it's defined, counted from one; zero for main
+	Calls int
+
+	// Self is the time spent running the function itself, and
+	// Cumulative that including the functions it called.
+	Self       time.Duration
+	Cumulative time.Duration
+}
+
+// call is a call in progress.
+type call struct {
+	start    time.Time
+	children time.Duration // spent in the calls it made
+}
+
+// Profiler records a program as it runs.
+type Profiler struct {
+	name  string
+	lines []string
+	now   func() time.Time
+
+	// stmts holds the statements of the program, the only ones
+	// whose lines are counted.
+	stmts map[asti.StatementI]bool
+
+	mu        sync.Mutex
+	start     time.Time
+	duration  time.Duration
+	functions map[string]*Function
+	active    map[*object.Frame]*call
+	top       time.Duration // spent in calls made from the top level
+	hits      map[int]int
+
+	// samples holds the time spent in each function by the stack
+	// of calls leading to it, keyed by the stack's frames joined.
+	samples map[string]*sample
+}
+
+// sample is the time spent in a function by one stack of calls.
+type sample struct {
+	stack []location // innermost first
+	calls int64
+	self  time.Duration
+}
+
+// location is a line in a function, where the function is defined or
+// where it calls another.
+type location struct {
+	function string
+	line     int
+}
+
+// New returns a profiler for program, parsed from src from the file
+// name.  Lines are counted only for what program itself runs, so the
+// program run must be this one, not the source parsed again.
+func New(name, src string, program *ast.Program) *Profiler {
+	stmts := make(map[asti.StatementI]bool)
+	if program != nil {
+		for _, stmt := range cover.Statements(program) {
+			stmts[stmt] = true
+		}
+	}
+	return &Profiler{
+		name:      name,
+		lines:     strings.Split(src, "\n"),
+		now:       time.Now,
+		stmts:     stmts,
+		functions: make(map[string]*Function),
+		active:    make(map[*object.Frame]*call),
+		hits:      make(map[int]int),
+		samples:   make(map[string]*sample),
+	}
+}
+
+// Start starts recording the programs run in env.
+func (p *Profiler) Start(env *object.Environment) {
+	p.start = p.now()
+	env.SetTrace(p.statement)
+	env.SetCallTrace(p.call)
+}
+
+// Stop stops recording the programs run in env.
+func (p *Profiler) Stop(env *object.Environment) {
+	env.SetTrace(nil)
+	env.SetCallTrace(nil)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.duration = p.now().Sub(p.start)
+	self := p.duration - p.top
+	p.functions[mainName] = &Function{Name: mainName, Calls: 1, Self: self, Cumulative: p.duration}
+	p.add([]location{{mainName, 0}}, 0, self)
+}
+
+// statement counts a line being run.  Statements of other programs,
+// such as code given to eval or loaded before the program, have lines
+// of their own, and aren't counted.
+func (p *Profiler) statement(stmt asti.StatementI, env *object.Environment) {
+	if !p.stmts[stmt] {
+		return
+	}
+	p.mu.Lock()
+	p.hits[stmt.GetToken().Line+1]++
+	p.mu.Unlock()
+}
+
+// call records a call starting, and returns the function which
+// records it finishing.
+func (p *Profiler) call(env *object.Environment) func() {
+	frame := env.Frame()
+	p.mu.Lock()
+	c := &call{start: p.now()}
+	p.active[frame] = c
+	p.mu.Unlock()
+
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		delete(p.active, frame)
+		elapsed := p.now().Sub(c.start)
+		self := elapsed - c.children
+
+		f := p.function(frame)
+		f.Calls++
+		f.Self += self
+
+		// Time in a recursive call is already counted by the
+		// outermost call of the function.
+		stack := frame.Caller.Stack()
+		recursive := false
+		for _, caller := range stack {
+			if caller.Name == frame.Name {
+				recursive = true
+			}
+		}
+		if !recursive {
+			f.Cumulative += elapsed
+		}
+		if len(stack) > 0 {
+			if parent, ok := p.active[stack[0]]; ok {
+				parent.children += elapsed
+			}
+		} else {
+			p.top += elapsed
+		}
+
+		// The stack of locations: the function, then where each
+		// caller called it.
+		locations := []location{{frame.Name, f.Line}}
+		callee := frame
+		for _, caller := range stack {
+			locations = append(locations, location{caller.Name, callee.Call.GetToken().Line + 1})
+			callee = caller
+		}
+		locations = append(locations, location{mainName, callee.Call.GetToken().Line + 1})
+		p.add(locations, 1, self)
+	}
+}
+
+// function returns the record of the function a frame calls.
+func (p *Profiler) function(frame *object.Frame) *Function {
+	f, ok := p.functions[frame.Name]
+	if !ok {
+		f = &Function{Name: frame.Name}
+		if frame.Function != nil && frame.Function.Body != nil {
+			f.Line = frame.Function.Body.Token.Line + 1
+		}
+		p.functions[frame.Name] = f
+	}
+	return f
+}
+
+// add adds to the sample for a stack.
+func (p *Profiler) add(stack []location, calls int64, self time.Duration) {
+	var key []string
+	for _, l := range stack {
+		key = append(key, fmt.Sprintf("%s:%d", l.function, l.line))
+	}
+	s, ok := p.samples[strings.Join(key, ";")]
+	if !ok {
+		s = &sample{stack: stack}
+		p.samples[strings.Join(key, ";")] = s
+	}
+	s.calls += calls
+	s.self += self
+}
+
+// Functions returns what was recorded for each function, including
+// main for the top level, by the time spent in them.
+func (p *Profiler) Functions() []*Function {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var list []*Function
+	for _, f := range p.functions {
+		copied := *f
+		list = append(list, &copied)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Self != list[j].Self {
+			return list[i].Self > list[j].Self
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// Hits returns how often each line ran, by line counted from one.
+func (p *Profiler) Hits() map[int]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	hits := make(map[int]int, len(p.hits))
+	for line, n := range p.hits {
+		hits[line] = n
+	}
+	return hits
+}
+
+// Report writes the n functions the program spent most time in, and
+// the n lines run most often.
+func (p *Profiler) Report(w io.Writer, n int) {
+	fmt.Fprintf(w, "%s: %v total\n\n", p.name, p.duration)
+
+	fmt.Fprintf(w, "%10s %12s %12s  %s\n", "calls", "self", "cumulative", "function")
+	for i, f := range p.Functions() {
+		if i == n {
+			break
+		}
+		fmt.Fprintf(w, "%10d %12v %12v  %s\n", f.Calls, f.Self, f.Cumulative, f.Name)
+	}
+
+	type hit struct{ line, n int }
+	var hits []hit
+	for line, n := range p.Hits() {
+		hits = append(hits, hit{line, n})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].n != hits[j].n {
+			return hits[i].n > hits[j].n
+		}
+		return hits[i].line < hits[j].line
+	})
+	fmt.Fprintf(w, "\n%10s %6s  %s\n", "hits", "line", "source")
+	for i, h := range hits {
+		if i == n {
+			break
+		}
+		src := ""
+		if h.line <= len(p.lines) {
+			src = strings.TrimSpace(p.lines[h.line-1])
+		}
+		fmt.Fprintf(w, "%10d %6d  %s\n", h.n, h.line, src)
+	}
+}
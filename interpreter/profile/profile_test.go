@@ -0,0 +1,158 @@
+package profile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kasworld/nonkey/interpreter/evaluator"
+	"github.com/kasworld/nonkey/interpreter/lexer"
+	"github.com/kasworld/nonkey/interpreter/object"
+	"github.com/kasworld/nonkey/interpreter/parser"
+)
+
+const script = `function fib(n) {
+    if (n < 2) {
+        return n;
+    }
+    return fib(n - 1) + fib(n - 2);
+}
+let total = 0;
+foreach i in 1..3 {
+    total = total + fib(i);
+}
+`
+
+// profile runs the script under a profiler whose clock ticks a
+// millisecond each time it's read.
+func profile(t *testing.T) *Profiler {
+	program := parser.New(lexer.New(script)).ParseProgram()
+	p := New("t.mon", script, program)
+	clock := time.Unix(0, 0)
+	p.now = func() time.Time {
+		clock = clock.Add(time.Millisecond)
+		return clock
+	}
+
+	env := object.NewEnvironment()
+	p.Start(env)
+	evaluator.Eval(program, env)
+	p.Stop(env)
+
+	if total, _ := env.Get("total"); total == nil || total.Inspect() != "4" {
+		t.Fatalf("expected total 4, got %v", total)
+	}
+	return p
+}
+
+func TestFunctions(t *testing.T) {
+	functions := map[string]*Function{}
+	for _, f := range profile(t).Functions() {
+		functions[f.Name] = f
+	}
+
+	// fib(1) makes one call, fib(2) three and fib(3) five.
+	fib := functions["fib"]
+	if fib == nil || fib.Calls != 9 || fib.Line != 1 {
+		t.Fatalf("expected 9 calls of fib from line 1, got %+v", fib)
+	}
+	// Each call reads the clock as it starts and finishes, which
+	// counts to the caller as the time between its own readings.
+	if fib.Self != 15*time.Millisecond {
+		t.Errorf("expected 15ms in fib, got %v", fib.Self)
+	}
+	// fib(3) took 9ms, counting its recursive calls only once.
+	if fib.Cumulative != (1+5+9)*time.Millisecond {
+		t.Errorf("expected 15ms in fib and its calls, got %v", fib.Cumulative)
+	}
+
+	main := functions["main"]
+	if main == nil || main.Calls != 1 || main.Self+fib.Cumulative != main.Cumulative {
+		t.Errorf("expected the rest of the time in main, got %+v", main)
+	}
+}
+
+func TestHits(t *testing.T) {
+	hits := profile(t).Hits()
+	for line, expected := range map[int]int{1: 1, 2: 9, 3: 6, 5: 3, 9: 3} {
+		if hits[line] != expected {
+			t.Errorf("expected line %d to run %d times, got %d", line, expected, hits[line])
+		}
+	}
+}
+
+func TestOtherPrograms(t *testing.T) {
+	// The function loaded first, and the code given to eval, have
+	// statements on lines the program doesn't run.
+	lib := `let helper = fn() {
+    let a = 1;
+    return a;
+};
+`
+	main := `let r = helper();
+if (r > 5) {
+    puts(r);
+}
+eval("1;\n2;");
+`
+	env := object.NewEnvironment()
+	evaluator.Eval(parser.New(lexer.New(lib)).ParseProgram(), env)
+
+	program := parser.New(lexer.New(main)).ParseProgram()
+	p := New("t.mon", main, program)
+	p.Start(env)
+	evaluator.Eval(program, env)
+	p.Stop(env)
+
+	hits := p.Hits()
+	expected := map[int]int{1: 1, 2: 1, 5: 1}
+	if len(hits) != len(expected) {
+		t.Errorf("expected hits %v, got %v", expected, hits)
+	}
+	for line, n := range expected {
+		if hits[line] != n {
+			t.Errorf("expected line %d to run %d times, got %d", line, n, hits[line])
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	var out bytes.Buffer
+	profile(t).Report(&out, 2)
+	for _, expected := range []string{
+		"         9         15ms         15ms  fib\n",
+		"         9      2  if (n < 2) {\n",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in:\n%s", expected, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "function fib(n)") {
+		t.Errorf("expected only the top two lines in:\n%s", out.String())
+	}
+}
+
+func TestWritePprof(t *testing.T) {
+	var out bytes.Buffer
+	if err := profile(t).WritePprof(&out); err != nil {
+		t.Fatalf("%s", err)
+	}
+	z, err := gzip.NewReader(&out)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	data, err := ioutil.ReadAll(z)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// The string table is written last, as length delimited fields.
+	for _, s := range []string{"calls", "nanoseconds", "fib", "main", "t.mon"} {
+		if !bytes.Contains(data, append([]byte{profileStringTable<<3 | 2, byte(len(s))}, s...)) {
+			t.Errorf("expected %q in the string table", s)
+		}
+	}
+}
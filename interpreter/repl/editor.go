@@ -0,0 +1,276 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// errInterrupt is returned for a line abandoned with ctrl-c.
+var errInterrupt = errors.New("interrupt")
+
+// maxHistory is how many lines the history keeps.
+const maxHistory = 1000
+
+// loadHistory reads the history kept by earlier sessions.
+func (r *REPL) loadHistory() {
+	if r.HistoryFile == "" || !r.editing {
+		return
+	}
+	f, err := os.Open(r.HistoryFile)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		r.history = append(r.history, scanner.Text())
+	}
+	if len(r.history) > maxHistory {
+		r.history = r.history[len(r.history)-maxHistory:]
+	}
+}
+
+// addHistory adds a line entered on a terminal to the history, unless
+// it's blank or repeats the last.
+func (r *REPL) addHistory(line string) {
+	if !r.editing || strings.TrimSpace(line) == "" {
+		return
+	}
+	if n := len(r.history); n > 0 && r.history[n-1] == line {
+		return
+	}
+	r.history = append(r.history, line)
+	if r.HistoryFile == "" {
+		return
+	}
+	f, err := os.OpenFile(r.HistoryFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(f, line)
+	f.Close()
+}
+
+// readLine reads a line after showing prompt, editing it on a
+// terminal.
+func (r *REPL) readLine(prompt string) (string, error) {
+	if r.editing {
+		if restore, err := makeRaw(r.fd); err == nil {
+			defer restore()
+		}
+		return r.edit(prompt)
+	}
+
+	fmt.Fprint(r.out, prompt)
+	line, err := r.in.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// editor holds a line being edited.
+type editor struct {
+	out    io.Writer
+	prompt string
+	buf    []rune
+	pos    int
+}
+
+// refresh redraws the line, leaving the cursor at pos.
+func (e *editor) refresh() {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", e.prompt, string(e.buf))
+	if back := len(e.buf) - e.pos; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
+
+func (e *editor) insert(s []rune) {
+	buf := append([]rune{}, e.buf[:e.pos]...)
+	buf = append(buf, s...)
+	e.buf = append(buf, e.buf[e.pos:]...)
+	e.pos += len(s)
+}
+
+func (e *editor) set(line string) {
+	e.buf = []rune(line)
+	e.pos = len(e.buf)
+}
+
+// The keys the editor handles.
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyBackspace = 8
+	keyTab       = 9
+	keyLF        = 10
+	keyCtrlK     = 11
+	keyCR        = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlU     = 21
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+// edit reads a line a key at a time, as a terminal in raw mode gives
+// them.
+func (r *REPL) edit(prompt string) (string, error) {
+	e := &editor{out: r.out, prompt: prompt}
+	fmt.Fprint(r.out, prompt)
+
+	// recall is the position in the history being shown, and saved
+	// the line being entered before moving into it.
+	recall, saved := len(r.history), ""
+	move := func(to int) {
+		if to < 0 || to > len(r.history) || to == recall {
+			return
+		}
+		if recall == len(r.history) {
+			saved = string(e.buf)
+		}
+		recall = to
+		if recall == len(r.history) {
+			e.set(saved)
+		} else {
+			e.set(r.history[recall])
+		}
+	}
+
+	for {
+		key, _, err := r.in.ReadRune()
+		if err != nil {
+			if len(e.buf) > 0 {
+				fmt.Fprint(r.out, "\n")
+				return string(e.buf), nil
+			}
+			return "", err
+		}
+
+		switch key {
+		case keyCR, keyLF:
+			fmt.Fprint(r.out, "\n")
+			return string(e.buf), nil
+		case keyCtrlC:
+			fmt.Fprint(r.out, "^C\n")
+			return "", errInterrupt
+		case keyCtrlD:
+			if len(e.buf) == 0 {
+				fmt.Fprint(r.out, "\n")
+				return "", io.EOF
+			}
+			if e.pos < len(e.buf) {
+				e.buf = append(e.buf[:e.pos], e.buf[e.pos+1:]...)
+			}
+		case keyBackspace, keyDelete:
+			if e.pos > 0 {
+				e.buf = append(e.buf[:e.pos-1], e.buf[e.pos:]...)
+				e.pos--
+			}
+		case keyCtrlA:
+			e.pos = 0
+		case keyCtrlE:
+			e.pos = len(e.buf)
+		case keyCtrlB:
+			if e.pos > 0 {
+				e.pos--
+			}
+		case keyCtrlF:
+			if e.pos < len(e.buf) {
+				e.pos++
+			}
+		case keyCtrlK:
+			e.buf = e.buf[:e.pos]
+		case keyCtrlU:
+			e.buf = e.buf[e.pos:]
+			e.pos = 0
+		case keyCtrlP:
+			move(recall - 1)
+		case keyCtrlN:
+			move(recall + 1)
+		case keyTab:
+			r.complete(e)
+		case keyEscape:
+			// Arrows and the like come as ESC [ code, with the
+			// delete key ESC [ 3 ~.
+			if b, _, _ := r.in.ReadRune(); b != '[' && b != 'O' {
+				break
+			}
+			code, _, _ := r.in.ReadRune()
+			switch code {
+			case 'A':
+				move(recall - 1)
+			case 'B':
+				move(recall + 1)
+			case 'C':
+				if e.pos < len(e.buf) {
+					e.pos++
+				}
+			case 'D':
+				if e.pos > 0 {
+					e.pos--
+				}
+			case 'H':
+				e.pos = 0
+			case 'F':
+				e.pos = len(e.buf)
+			case '3':
+				r.in.ReadRune() // ~
+				if e.pos < len(e.buf) {
+					e.buf = append(e.buf[:e.pos], e.buf[e.pos+1:]...)
+				}
+			}
+		default:
+			if unicode.IsPrint(key) {
+				e.insert([]rune{key})
+			}
+		}
+		e.refresh()
+	}
+}
+
+// complete extends the name before the cursor by what all the names
+// it could be have in common, listing them if that adds nothing.
+func (r *REPL) complete(e *editor) {
+	start := e.pos
+	for start > 0 && isNameRune(e.buf[start-1]) {
+		start--
+	}
+	prefix := string(e.buf[start:e.pos])
+	if prefix == "" {
+		return
+	}
+	names := r.Complete(prefix)
+	if len(names) == 0 {
+		return
+	}
+
+	common := names[0]
+	for _, name := range names[1:] {
+		for !strings.HasPrefix(name, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	if len(common) > len(prefix) {
+		e.insert([]rune(common[len(prefix):]))
+		return
+	}
+	if len(names) > 1 {
+		fmt.Fprintf(r.out, "\n%s\n", strings.Join(names, "  "))
+	}
+}
+
+// isNameRune reports whether r may be part of a name, as the lexer
+// reads them.
+func isNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '?' || r == '$' || r == '_'
+}
@@ -0,0 +1,145 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kasworld/nonkey/interpreter/asti"
+	"github.com/kasworld/nonkey/interpreter/object"
+)
+
+// width is how long a value may be shown on one line.
+const width = 72
+
+// pretty returns how the REPL shows a value: strings quoted, and
+// arrays and hashes too long for a line split over several, with
+// each of their items on its own line after indent.
+func pretty(obj object.ObjectI, indent string) string {
+	if s := compact(obj); len(indent)+len(s) <= width {
+		return s
+	}
+	inner := indent + "  "
+	var out strings.Builder
+	switch obj := obj.(type) {
+	case *object.Array:
+		out.WriteString("[\n")
+		for _, elem := range obj.Elements() {
+			fmt.Fprintf(&out, "%s%s,\n", inner, pretty(elem, inner))
+		}
+		fmt.Fprintf(&out, "%s]", indent)
+	case *object.Hash:
+		out.WriteString("{\n")
+		for _, pair := range obj.Pairs() {
+			fmt.Fprintf(&out, "%s%s: %s,\n", inner, compact(pair.Key), pretty(pair.Value, inner))
+		}
+		fmt.Fprintf(&out, "%s}", indent)
+	default:
+		return compact(obj)
+	}
+	return out.String()
+}
+
+// compact returns a value on one line, with strings quoted, and
+// functions without their bodies.
+func compact(obj object.ObjectI) string {
+	switch obj := obj.(type) {
+	case *object.String:
+		return strconv.Quote(obj.Value)
+	case *object.Function:
+		var params []string
+		for _, p := range obj.Parameters {
+			params = append(params, p.String())
+		}
+		return "fn(" + strings.Join(params, ", ") + ") {...}"
+	case *object.Array:
+		var elems []string
+		for _, elem := range obj.Elements() {
+			elems = append(elems, compact(elem))
+		}
+		return "[" + strings.Join(elems, ", ") + "]"
+	case *object.Hash:
+		var pairs []string
+		for _, pair := range obj.Pairs() {
+			pairs = append(pairs, compact(pair.Key)+": "+compact(pair.Value))
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+	}
+	return obj.Inspect()
+}
+
+var nodeType = reflect.TypeOf((*asti.NodeI)(nil)).Elem()
+
+// dump writes the tree of nodes a statement parses to, a line for
+// each giving its type and token, followed by its other values, with
+// the nodes it holds indented below it by the fields holding them.
+func dump(w io.Writer, stmt asti.StatementI) {
+	dumpNode(w, "", reflect.ValueOf(stmt), "")
+}
+
+func dumpNode(w io.Writer, label string, v reflect.Value, indent string) {
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	node := v.Interface().(asti.NodeI)
+	s := v.Elem()
+	fmt.Fprintf(w, "%s%s%s %q", indent, label, s.Type().Name(), node.GetToken().Literal)
+
+	// The values first, on the node's line, then the nodes below it.
+	type child struct {
+		label string
+		v     reflect.Value
+	}
+	var children []child
+	for i := 0; i < s.NumField(); i++ {
+		field, f := s.Type().Field(i), s.Field(i)
+		if field.PkgPath != "" || field.Name == "Token" {
+			continue
+		}
+		switch {
+		case isNode(f):
+			children = append(children, child{field.Name + ": ", f})
+		case f.Kind() == reflect.Slice:
+			for j := 0; j < f.Len(); j++ {
+				if isNode(f.Index(j)) {
+					children = append(children, child{fmt.Sprintf("%s[%d]: ", field.Name, j), f.Index(j)})
+				} else {
+					fmt.Fprintf(w, " %s[%d]=%v", field.Name, j, f.Index(j))
+				}
+			}
+		case f.Kind() == reflect.Map:
+			var entries []child
+			for _, key := range f.MapKeys() {
+				name := fmt.Sprint(key)
+				if isNode(key) {
+					name = key.Interface().(asti.NodeI).String()
+				}
+				if isNode(f.MapIndex(key)) {
+					entries = append(entries, child{fmt.Sprintf("%s[%s]: ", field.Name, name), f.MapIndex(key)})
+				}
+			}
+			sort.Slice(entries, func(i, j int) bool { return entries[i].label < entries[j].label })
+			children = append(children, entries...)
+		case !f.IsZero() && f.Kind() != reflect.Ptr && f.Kind() != reflect.Interface:
+			fmt.Fprintf(w, " %s=%v", field.Name, f)
+		}
+	}
+	fmt.Fprintf(w, "\n")
+	for _, c := range children {
+		dumpNode(w, c.label, c.v, indent+"  ")
+	}
+}
+
+// isNode reports whether v holds a node.
+func isNode(v reflect.Value) bool {
+	if v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return false
+		}
+		v = v.Elem()
+	}
+	return v.Kind() == reflect.Ptr && !v.IsNil() && v.Type().Implements(nodeType)
+}
@@ -1,10 +1,25 @@
+// Package repl reads programs from a user a line at a time, and runs
+// each as soon as it's complete, showing its result.
+//
+// Input continues over lines while brackets are left open, so
+// functions and blocks can be typed as they'd be written in a file.
+// On a terminal, lines can be edited, recalled from the history kept
+// between sessions, and completed with tab.  Lines starting with a
+// colon are commands to the REPL itself; see :help.
 package repl
 
 import (
 	"bufio"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/kasworld/nonkey/config/builtinfunctions"
+	"github.com/kasworld/nonkey/enum/tokentype"
 	"github.com/kasworld/nonkey/interpreter/evaluator"
 	"github.com/kasworld/nonkey/interpreter/lexer"
 	"github.com/kasworld/nonkey/interpreter/object"
@@ -14,43 +29,247 @@ import (
 
 const PROMPT = ">> "
 
-func Start(in io.Reader, out io.Writer, env *object.Environment) {
+// CONTINUE is the prompt for the lines of input after the first.
+const CONTINUE = ".. "
+
+const help = `Enter code to run it, continuing over lines while brackets are open.
+commands:
+  :help         show this help
+  :env          show the variables defined
+  :type expr    show the type of an expression
+  :load file    run a file
+  :reset        forget all variables
+  :ast expr     show how an expression parses
+  :quit         leave
+On a terminal, tab completes names, up and down recall earlier lines,
+and ctrl-c abandons the input.
+`
 
+// Start runs a REPL in env, keeping its history in the user's home
+// directory.
+func Start(in io.Reader, out io.Writer, env *object.Environment) {
 	fmt.Fprintf(out, "welcome to nonkey version:%v\n", version.GetVersion())
 
-	scanner := bufio.NewScanner(in)
+	r := New(in, out, env)
+	if home, err := os.UserHomeDir(); err == nil {
+		r.HistoryFile = filepath.Join(home, ".nonkey_history")
+	}
+	r.Run()
+}
+
+// REPL reads programs from in, writing their results to out.
+type REPL struct {
+	// HistoryFile, if set, keeps the lines entered on a terminal
+	// between sessions.
+	HistoryFile string
+
+	in  *bufio.Reader
+	out io.Writer
+	env *object.Environment
+
+	// fd is the terminal in is, if editing.
+	fd      uintptr
+	editing bool
+
+	history []string
+}
+
+// New returns a REPL reading from in and writing to out, which runs
+// programs in env.  Lines are edited if in is a terminal.
+func New(in io.Reader, out io.Writer, env *object.Environment) *REPL {
+	r := &REPL{in: bufio.NewReader(in), out: out, env: env}
+	if f, ok := in.(*os.File); ok && isTerminal(f.Fd()) {
+		r.fd, r.editing = f.Fd(), true
+	}
+	return r
+}
+
+// Run reads and runs programs until the input ends or the user quits.
+func (r *REPL) Run() {
+	r.loadHistory()
+
+	var pending []string
 	for {
-		fmt.Fprintf(out, PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		prompt := PROMPT
+		if len(pending) > 0 {
+			prompt = CONTINUE
+		}
+		line, err := r.readLine(prompt)
+		if err == errInterrupt {
+			pending = nil
+			continue
+		}
+		if err != nil {
+			// Run what's left, which reports what it lacks.
+			if len(pending) > 0 {
+				r.run("<stdin>", strings.Join(pending, "\n"))
+			}
 			return
 		}
+		r.addHistory(line)
 
-		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
+		if len(pending) == 0 && strings.HasPrefix(strings.TrimSpace(line), ":") {
+			if !r.command(strings.TrimSpace(line)) {
+				return
+			}
+			continue
+		}
 
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+		pending = append(pending, line)
+		src := strings.Join(pending, "\n")
+		if depth(src) > 0 {
 			continue
 		}
+		pending = nil
+		if strings.TrimSpace(src) != "" {
+			r.run("<stdin>", src)
+		}
+	}
+}
 
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
-			if erro, ok := evaluated.(*object.Error); ok {
-				fmt.Fprintf(out, "%v\n", evaluated.Inspect())
-				fmt.Fprintf(out, "%v\n", l.GetLineStr(erro.Node.GetToken().Line))
-			} else {
-				fmt.Fprintf(out, "%v\n", evaluated.Inspect())
+// depth returns how many more brackets src opens than it closes.  A
+// string left open counts as one more, as it goes on to the next line.
+func depth(src string) int {
+	n := 0
+	l := lexer.New(src)
+	for tok := l.NextToken(); tok.Type != tokentype.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case tokentype.LBRACE, tokentype.LPAREN, tokentype.LBRACKET:
+			n++
+		case tokentype.RBRACE, tokentype.RPAREN, tokentype.RBRACKET:
+			n--
+		case tokentype.ILLEGAL:
+			if tok.Literal == lexer.UnterminatedString {
+				if n < 0 {
+					n = 0
+				}
+				return n + 1
 			}
 		}
 	}
+	return n
 }
 
-func printParserErrors(out io.Writer, errors []parser.Error) {
-	io.WriteString(out, "parser errors\n")
-	for _, msg := range errors {
-		fmt.Fprintf(out, "\t%v\n", msg)
+// run runs the program src, from the file name, showing its result.
+func (r *REPL) run(name, src string) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		fmt.Fprintf(r.out, "%s", parser.Render(name, src, errs))
+		return
+	}
+
+	switch result := evaluator.Eval(program, r.env).(type) {
+	case nil:
+	case *object.Error:
+		lines := strings.Split(src, "\n")
+		fmt.Fprintf(r.out, "%s", result.Traceback(func(line int) string {
+			if line < 0 || line >= len(lines) {
+				return ""
+			}
+			return lines[line]
+		}))
+	case *object.Null:
+		// as statements such as calls of puts give
+	default:
+		fmt.Fprintf(r.out, "%s\n", pretty(result, ""))
+	}
+}
+
+// command runs a command to the REPL, reporting false if it's to quit.
+func (r *REPL) command(line string) bool {
+	cmd, arg := line, ""
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		cmd, arg = line[:i], strings.TrimSpace(line[i+1:])
+	}
+
+	switch cmd {
+	case ":help", ":h":
+		fmt.Fprint(r.out, help)
+	case ":env":
+		r.showEnv()
+	case ":type":
+		r.showType(arg)
+	case ":load":
+		src, err := ioutil.ReadFile(arg)
+		if err != nil {
+			fmt.Fprintf(r.out, "%s\n", err)
+			break
+		}
+		r.run(arg, string(src))
+	case ":reset":
+		r.env = object.NewEnvironment()
+	case ":ast":
+		p := parser.New(lexer.New(arg))
+		program := p.ParseProgram()
+		if errs := p.Errors(); len(errs) > 0 {
+			fmt.Fprintf(r.out, "%s", parser.Render("<stdin>", arg, errs))
+			break
+		}
+		for _, stmt := range program.Statements {
+			dump(r.out, stmt)
+		}
+	case ":quit", ":q":
+		return false
+	default:
+		fmt.Fprintf(r.out, "unknown command %s; try :help\n", cmd)
+	}
+	return true
+}
+
+// showEnv prints the variables of the environment.
+func (r *REPL) showEnv() {
+	vars := r.env.Locals()
+	var names []string
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(r.out, "%s = %s\n", name, pretty(vars[name], ""))
+	}
+}
+
+// showType prints the type of an expression.
+func (r *REPL) showType(src string) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		fmt.Fprintf(r.out, "%s", parser.Render("<stdin>", src, errs))
+		return
+	}
+	switch result := evaluator.Eval(program, r.env).(type) {
+	case nil:
+		fmt.Fprintf(r.out, "no value\n")
+	case *object.Error:
+		fmt.Fprintf(r.out, "error: %s\n", result.Message)
+	default:
+		fmt.Fprintf(r.out, "%s\n", result.Type())
+	}
+}
+
+// Complete returns the names of variables, builtins and keywords which
+// start with prefix, in order.
+func (r *REPL) Complete(prefix string) []string {
+	seen := make(map[string]bool)
+	for env := r.env; env != nil; env = env.Outer() {
+		for name := range env.Locals() {
+			seen[name] = true
+		}
+	}
+	for name := range builtinfunctions.BuiltinFunctions {
+		seen[name] = true
+	}
+	for name := range tokentype.Keywords {
+		seen[name] = true
+	}
+
+	var names []string
+	for name := range seen {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
 	}
+	sort.Strings(names)
+	return names
 }
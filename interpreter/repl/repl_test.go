@@ -0,0 +1,194 @@
+package repl
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/kasworld/nonkey/interpreter/evaluator"
+	"github.com/kasworld/nonkey/interpreter/object"
+)
+
+// session runs a REPL on input, returning what it wrote.
+func session(input string) string {
+	var out bytes.Buffer
+	New(strings.NewReader(input), &out, object.NewEnvironment()).Run()
+	return out.String()
+}
+
+func TestMultiLine(t *testing.T) {
+	out := session(`function add(a, b) {
+    return a + b;
+}
+add(1,
+    2)
+let s = "{";
+[s, "]"]
+if (true) {
+`)
+	expected := `>> .. .. >> .. 3
+>> "{"
+>> ["{", "]"]
+>> .. error: unterminated block statement
+ --> <stdin>:1:11
+  |
+1 | if (true) {
+  |           ^
+`
+	if out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+
+	// A string goes on until its closing quote.
+	out = session("let s = \"one\ntwo\";\nlen(s)\nputs(\"open\n")
+	expected = `>> .. "one\ntwo"
+>> 7
+>> .. error: unterminated string
+ --> <stdin>:1:6
+  |
+1 | puts("open
+  |      ^
+`
+	if out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+}
+
+func TestPretty(t *testing.T) {
+	out := session(`["a", 1.5, {"k": [1, 2]}, fn(x) { x }]
+let long = ["aaaaaaaaaaaaaaaaaaaaaaaaa", {"b": "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"}];
+`)
+	expected := `>> ["a", 1.5, {"k": [1, 2]}, fn(x) {...}]
+>> [
+  "aaaaaaaaaaaaaaaaaaaaaaaaa",
+  {
+    "b": "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb",
+  },
+]
+>> `
+	if out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repl")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer os.RemoveAll(dir)
+	lib := filepath.Join(dir, "lib.mon")
+	ioutil.WriteFile(lib, []byte("let loaded = 42;\nlet broken = fn() { return nosuch; };\n"), 0644)
+
+	out := session(`let b = 2;
+let a = "x";
+:env
+:type a
+:type [1]
+:load ` + lib + `
+loaded
+:ast -x + 1
+:reset
+:env
+:nosuch
+:quit
+1
+`)
+	for _, expected := range []string{
+		">> a = \"x\"\nb = 2\n",
+		">> STRING\n>> ARRAY\n",
+		">> 42\n",
+		`>> ExpressionStatement "-"
+  Expression: InfixExpression "+" Operator=PLUS
+    Left: PrefixExpression "-" Operator=MINUS
+      Right: Identifier "x" Value=x
+    Right: IntegerLiteral "1" Value=1
+`,
+		">> >> >> unknown command :nosuch; try :help\n>> ",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in:\n%s", expected, out)
+		}
+	}
+	if strings.Contains(out, ">> 1\n") {
+		t.Errorf("expected :quit to stop the REPL:\n%s", out)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	env := object.NewEnvironment()
+	env.Set("total", &object.Integer{Value: 1})
+	env.Set("tally", &object.Integer{Value: 2})
+	r := New(strings.NewReader(""), ioutil.Discard, env)
+
+	if got := strings.Join(r.Complete("ta"), " "); got != "take tally" {
+		t.Errorf("expected take and tally, got %q", got)
+	}
+	if got := strings.Join(r.Complete("time.n"), " "); got != "time.now" {
+		t.Errorf("expected time.now, got %q", got)
+	}
+	if got := strings.Join(r.Complete("func"), " "); got != "function" {
+		t.Errorf("expected function, got %q", got)
+	}
+}
+
+// editing runs a REPL as if on a terminal, with keys as input.
+func editing(keys, history string) (*REPL, string) {
+	var out bytes.Buffer
+	r := New(strings.NewReader(keys), &out, object.NewEnvironment())
+	r.editing, r.fd = true, ^uintptr(0)
+	r.HistoryFile = history
+	r.Run()
+	return r, out.String()
+}
+
+func TestEditing(t *testing.T) {
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		// Editing within the line.
+		{"1 + 3\x7f2\r", "3"},
+		{"2 * 3\x01\x1b[C\x1b[C\x1b[3~+\r", "5"},
+		{"9\x15len(\"ab\")\r", "2"},
+		{"len(1)\x01\x0b9\r", "9"},
+		// Completion, and recalling a line.
+		{"let alpha = 7;\ralp\t * 2\r", "14"},
+		{"let alpha = 7;\r\x1b[A\x1b[D\x7f8\r", "8"},
+		{"(3 +\r\x03 4\r", "4"},
+	}
+	for _, tt := range tests {
+		_, out := editing(tt.keys, "")
+		lines := strings.Split(strings.TrimSuffix(out, "\n"+PROMPT), "\n")
+		if got := lines[len(lines)-1]; got != tt.expected {
+			t.Errorf("%q: expected result %q, got %q in:\n%s", tt.keys, tt.expected, got, out)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repl")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "history")
+
+	editing("let x = 1;\r\rlet x = 1;\rx + 1\r\x04", file)
+	data, _ := ioutil.ReadFile(file)
+	if string(data) != "let x = 1;\nx + 1\n" {
+		t.Errorf("unexpected history %q", data)
+	}
+
+	// A new session recalls the lines of the last.
+	r, out := editing("\x1b[A\x1b[A\r\x1b[A\x1b[A\r\x04", file)
+	if !strings.Contains(out, "\n2\n") {
+		t.Errorf("expected the recalled line to run, got:\n%s", out)
+	}
+	if strings.Join(r.history, "|") != "let x = 1;|x + 1|let x = 1;|x + 1" {
+		t.Errorf("unexpected history %q", r.history)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build darwin || freebsd || netbsd || openbsd
+// +build darwin freebsd netbsd openbsd
+
+package repl
+
+import "syscall"
+
+const (
+	ioctlGet = syscall.TIOCGETA
+	ioctlSet = syscall.TIOCSETA
+)
@@ -0,0 +1,16 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd
+
+package repl
+
+import "errors"
+
+// isTerminal reports whether fd is a terminal, which it's never taken
+// to be here, so lines are read without editing.
+func isTerminal(fd uintptr) bool {
+	return false
+}
+
+func makeRaw(fd uintptr) (func(), error) {
+	return nil, errors.New("terminal not supported")
+}
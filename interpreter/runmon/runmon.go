@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 
+	"github.com/kasworld/nonkey/interpreter/ast"
 	"github.com/kasworld/nonkey/interpreter/evaluator"
 	"github.com/kasworld/nonkey/interpreter/lexer"
 	"github.com/kasworld/nonkey/interpreter/object"
@@ -17,26 +19,48 @@ func RunFile(filename string, env *object.Environment) *object.Environment {
 		fmt.Fprintf(os.Stderr, "fail to load %v %v\n", filename, err)
 		return env
 	}
-	return RunString(string(input), env)
+	return run(filename, string(input), env)
 }
 
 func RunString(input string, env *object.Environment) *object.Environment {
-	l := lexer.New(input)
-	p := parser.New(l)
-	prg := p.ParseProgram()
+	return run("<string>", input, env)
+}
 
-	if len(p.Errors()) != 0 {
-		for _, v := range p.Errors() {
-			fmt.Fprintf(os.Stderr, "%v\n", v)
-		}
+// run runs the program input, from the file name.
+func run(name, input string, env *object.Environment) *object.Environment {
+	prg := Parse(name, input)
+	if prg == nil {
 		return env
 	}
+	return RunProgram(name, input, prg, env)
+}
 
+// Parse parses the program input, from the file name.  If it doesn't
+// parse, the errors are written to stderr and it returns nil.
+func Parse(name, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	prg := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		fmt.Fprintf(os.Stderr, "%s", parser.Render(name, input, p.Errors()))
+		return nil
+	}
+	return prg
+}
+
+// RunProgram runs prg, parsed from input from the file name, in env.
+// Hosts which need the program before it runs, to find what to trace
+// in it, parse it with Parse and run it with this.
+func RunProgram(name, input string, prg *ast.Program, env *object.Environment) *object.Environment {
 	evaluated := evaluator.Eval(prg, env)
 	if evaluated != nil {
 		if erro, ok := evaluated.(*object.Error); ok {
-			fmt.Fprintf(os.Stderr, "%v\n", evaluated.Inspect())
-			fmt.Fprintf(os.Stderr, "%v\n", l.GetLineStr(erro.Node.GetToken().Line))
+			lines := strings.Split(input, "\n")
+			fmt.Fprintf(os.Stderr, "%s", erro.Traceback(func(line int) string {
+				if line < 0 || line >= len(lines) {
+					return ""
+				}
+				return lines[line]
+			}))
 		} else {
 			fmt.Fprintf(os.Stderr, "%v\n", evaluated.Inspect())
 		}
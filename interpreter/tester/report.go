@@ -0,0 +1,151 @@
+package tester
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// title returns how reports name the test of a result.
+func (r Result) title() string {
+	if r.Name == "" {
+		return r.File
+	}
+	return r.File + ": " + r.Name
+}
+
+// indent indents each line of s.
+func indent(s, prefix string) string {
+	s = strings.TrimSuffix(s, "\n")
+	return prefix + strings.Replace(s, "\n", "\n"+prefix, -1) + "\n"
+}
+
+// count returns how many of the results passed and failed.
+func count(results []Result) (passed, failed int) {
+	for _, r := range results {
+		if r.Passed() {
+			passed++
+		} else {
+			failed++
+		}
+	}
+	return passed, failed
+}
+
+// WriteText writes a line for each test, followed by why it failed if
+// it did, and then the number of tests which passed and failed.
+func WriteText(w io.Writer, results []Result) error {
+	out := bufio.NewWriter(w)
+	for _, r := range results {
+		if r.Passed() {
+			fmt.Fprintf(out, "PASS %s (%.3fs)\n", r.title(), r.Duration.Seconds())
+		} else {
+			fmt.Fprintf(out, "FAIL %s (%.3fs)\n", r.title(), r.Duration.Seconds())
+			out.WriteString(indent(r.Failure, "    "))
+		}
+	}
+	passed, failed := count(results)
+	fmt.Fprintf(out, "%d passed, %d failed\n", passed, failed)
+	return out.Flush()
+}
+
+// WriteTAP writes the results in the Test Anything Protocol, with why
+// each test failed as YAML after it.
+func WriteTAP(w io.Writer, results []Result) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "TAP version 13\n1..%d\n", len(results))
+	for i, r := range results {
+		if r.Passed() {
+			fmt.Fprintf(out, "ok %d - %s\n", i+1, r.title())
+			continue
+		}
+		fmt.Fprintf(out, "not ok %d - %s\n", i+1, r.title())
+		fmt.Fprintf(out, "  ---\n  message: |\n")
+		out.WriteString(indent(r.Failure, "    "))
+		fmt.Fprintf(out, "  ...\n")
+	}
+	return out.Flush()
+}
+
+// The elements of JUnit XML reports.
+type (
+	junitSuites struct {
+		XMLName  xml.Name     `xml:"testsuites"`
+		Tests    int          `xml:"tests,attr"`
+		Failures int          `xml:"failures,attr"`
+		Time     string       `xml:"time,attr"`
+		Suites   []junitSuite `xml:"testsuite"`
+	}
+	junitSuite struct {
+		Name     string      `xml:"name,attr"`
+		Tests    int         `xml:"tests,attr"`
+		Failures int         `xml:"failures,attr"`
+		Time     string      `xml:"time,attr"`
+		Cases    []junitCase `xml:"testcase"`
+
+		seconds float64
+	}
+	junitCase struct {
+		Name      string        `xml:"name,attr"`
+		ClassName string        `xml:"classname,attr"`
+		Time      string        `xml:"time,attr"`
+		Failure   *junitFailure `xml:"failure,omitempty"`
+	}
+	junitFailure struct {
+		Message string `xml:"message,attr"`
+		Text    string `xml:",chardata"`
+	}
+)
+
+// message returns the error a failure reports, which the traceback of
+// a failed test ends with, and a file which didn't parse starts with.
+func message(failure string) string {
+	lines := strings.Split(failure, "\n")
+	for _, line := range lines {
+		if strings.HasPrefix(line, "error: ") {
+			return strings.TrimPrefix(line, "error: ")
+		}
+	}
+	return lines[0]
+}
+
+// WriteJUnit writes the results as JUnit XML, with a suite for each
+// file.
+func WriteJUnit(w io.Writer, results []Result) error {
+	var report junitSuites
+	var total float64
+	for _, r := range results {
+		if len(report.Suites) == 0 || report.Suites[len(report.Suites)-1].Name != r.File {
+			report.Suites = append(report.Suites, junitSuite{Name: r.File})
+		}
+		suite := &report.Suites[len(report.Suites)-1]
+
+		c := junitCase{Name: r.Name, ClassName: r.File, Time: fmt.Sprintf("%.3f", r.Duration.Seconds())}
+		if c.Name == "" {
+			c.Name = r.File
+		}
+		if !r.Passed() {
+			c.Failure = &junitFailure{Message: message(r.Failure), Text: r.Failure}
+			suite.Failures++
+			report.Failures++
+		}
+		suite.Cases = append(suite.Cases, c)
+		suite.Tests++
+		report.Tests++
+		suite.seconds += r.Duration.Seconds()
+		total += r.Duration.Seconds()
+	}
+	report.Time = fmt.Sprintf("%.3f", total)
+	for i := range report.Suites {
+		report.Suites[i].Time = fmt.Sprintf("%.3f", report.Suites[i].seconds)
+	}
+
+	out, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s%s\n", xml.Header, out)
+	return err
+}
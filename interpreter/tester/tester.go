@@ -0,0 +1,141 @@
+// Package tester runs the tests of monkey programs: the functions
+// named test_* in files named *_test.mon.
+//
+// Each test runs in a fresh environment, in which the rest of its file
+// has run first, so tests can't affect each other.  A test fails if it
+// returns an error, as the assert builtins do when they fail.
+package tester
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kasworld/nonkey/interpreter/ast"
+	"github.com/kasworld/nonkey/interpreter/asti"
+	"github.com/kasworld/nonkey/interpreter/evaluator"
+	"github.com/kasworld/nonkey/interpreter/lexer"
+	"github.com/kasworld/nonkey/interpreter/object"
+	"github.com/kasworld/nonkey/interpreter/parser"
+)
+
+// Result is the outcome of a test.
+type Result struct {
+	File string
+	Name string // empty for a file which couldn't be run
+
+	// Failure tells why the test failed, and is empty if it passed.
+	Failure string
+
+	Duration time.Duration
+}
+
+// Passed reports whether the test passed.
+func (r Result) Passed() bool {
+	return r.Failure == ""
+}
+
+// Find returns the test files in dir and the directories below it.
+func Find(dir string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.HasSuffix(info.Name(), "_test.mon") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// test is a test function, and the statement defining it.
+type test struct {
+	name string
+	node asti.NodeI
+}
+
+// tests returns the tests a program defines, in order.
+func tests(program *ast.Program) []test {
+	var list []test
+	for _, stmt := range program.Statements {
+		var name string
+		switch n := stmt.(type) {
+		case *ast.ExpressionStatement:
+			if fn, ok := n.Expression.(*ast.FunctionDefineLiteral); ok {
+				name = fn.Token.Literal
+			}
+		case *ast.LetStatement:
+			if _, ok := n.Value.(*ast.FunctionLiteral); ok {
+				name = n.Name.Value
+			}
+		case *ast.ConstStatement:
+			if _, ok := n.Value.(*ast.FunctionLiteral); ok {
+				name = n.Name.Value
+			}
+		}
+		if strings.HasPrefix(name, "test_") {
+			list = append(list, test{name, stmt})
+		}
+	}
+	return list
+}
+
+// Run runs the tests of the program src, from the file name.  setup,
+// if given, prepares the environment of each before the program runs
+// in it.
+func Run(name, src string, setup func(env *object.Environment)) []Result {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return []Result{{File: name, Failure: parser.Render(name, src, errs)}}
+	}
+
+	lines := strings.Split(src, "\n")
+	source := func(line int) string {
+		if line < 0 || line >= len(lines) {
+			return ""
+		}
+		return lines[line]
+	}
+
+	var results []Result
+	for _, t := range tests(program) {
+		start := time.Now()
+		result := Result{File: name, Name: t.name}
+		if err := run(program, t, setup); err != nil {
+			result.Failure = err.Traceback(source)
+		}
+		result.Duration = time.Since(start)
+		results = append(results, result)
+	}
+	return results
+}
+
+// run runs a test in a fresh environment, returning its error if it
+// fails.
+func run(program *ast.Program, t test, setup func(env *object.Environment)) *object.Error {
+	env := object.NewEnvironment()
+	// Failures are reported with their tracebacks, and the errors
+	// passing tests expect aren't worth reporting at all.
+	env.SetErrorLog(ioutil.Discard)
+	if setup != nil {
+		setup(env)
+	}
+	if err, ok := evaluator.Eval(program, env).(*object.Error); ok {
+		return err
+	}
+	fn, ok := env.Get(t.name)
+	if !ok {
+		return object.NewError(t.node, "test %s isn't defined", t.name)
+	}
+	if err, ok := evaluator.Call(t.node, env, fn).(*object.Error); ok {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package tester
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kasworld/nonkey/interpreter/object"
+)
+
+const script = `let counter = 0;
+function add(a, b) { return a + b; }
+
+function test_add() {
+    counter = counter + 1;
+    assert_eq(add(1, 2), 3);
+}
+
+function test_fresh() {
+    counter = counter + 1;
+    assert_eq(counter, 1, "counter");
+    assert_eq(add(2, 2), 5, "sum");
+}
+
+let test_raises = fn() {
+    assert_raises(fn() { return add(1); });
+};
+
+function helper() { assert(false); }
+`
+
+func TestRun(t *testing.T) {
+	results := Run("t_test.mon", script, nil)
+
+	var names []string
+	for _, r := range results {
+		names = append(names, r.Name)
+	}
+	if strings.Join(names, " ") != "test_add test_fresh test_raises" {
+		t.Fatalf("expected three tests, got %q", names)
+	}
+
+	if !results[0].Passed() || !results[2].Passed() {
+		t.Errorf("expected test_add and test_raises to pass, got %+v", results)
+	}
+	// The counter starts again for each test, so only the sum fails.
+	expected := `Traceback (most recent call last):
+  line 9, in main
+    function test_fresh() {
+  line 12, in test_fresh
+    assert_eq(add(2, 2), 5, "sum");
+error: assert_eq failed: sum: got 4, want 5
+`
+	if results[1].Failure != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, results[1].Failure)
+	}
+}
+
+// TestRunQuiet checks that failing assertions, and the errors passing
+// tests expect, don't reach stderr around the report.
+func TestRunQuiet(t *testing.T) {
+	f, err := ioutil.TempFile("", "stderr")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	stderr := os.Stderr
+	os.Stderr = f
+	Run("t_test.mon", script, nil)
+	os.Stderr = stderr
+
+	if out, _ := ioutil.ReadFile(f.Name()); len(out) != 0 {
+		t.Errorf("expected nothing on stderr, got %q", out)
+	}
+}
+
+func TestRunSetup(t *testing.T) {
+	setup := func(env *object.Environment) {
+		env.Set("counter", &object.Integer{Value: 7})
+	}
+	results := Run("t_test.mon", "function test_setup() { assert_eq(counter, 7); }", setup)
+	if len(results) != 1 || !results[0].Passed() {
+		t.Errorf("expected the test to see the setup, got %+v", results)
+	}
+
+	results = Run("bad_test.mon", "let x = (;", nil)
+	if len(results) != 1 || results[0].Name != "" || !strings.Contains(results[0].Failure, "bad_test.mon:1:10") {
+		t.Errorf("expected the file to fail to parse, got %+v", results)
+	}
+}
+
+func TestFind(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tester")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"b_test.mon", "a.mon", "sub/a_test.mon", "test.mon"} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+
+	files, err := Find(dir)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	for i := range files {
+		files[i], _ = filepath.Rel(dir, files[i])
+	}
+	if strings.Join(files, " ") != "b_test.mon "+filepath.Join("sub", "a_test.mon") {
+		t.Errorf("unexpected files %q", files)
+	}
+}
+
+func TestReports(t *testing.T) {
+	results := []Result{
+		{File: "a_test.mon", Name: "test_ok"},
+		{File: "a_test.mon", Name: "test_bad", Failure: "Traceback (most recent call last):\n  line 3, in main\nerror: it broke\n"},
+		{File: "b_test.mon", Failure: "error: no prefix parse function for ;\n --> b_test.mon:1:10\n"},
+	}
+
+	var out bytes.Buffer
+	WriteText(&out, results)
+	if expected := `PASS a_test.mon: test_ok (0.000s)
+FAIL a_test.mon: test_bad (0.000s)
+    Traceback (most recent call last):
+      line 3, in main
+    error: it broke
+FAIL b_test.mon (0.000s)
+    error: no prefix parse function for ;
+     --> b_test.mon:1:10
+1 passed, 2 failed
+`; out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+
+	out.Reset()
+	WriteTAP(&out, results[:2])
+	if expected := `TAP version 13
+1..2
+ok 1 - a_test.mon: test_ok
+not ok 2 - a_test.mon: test_bad
+  ---
+  message: |
+    Traceback (most recent call last):
+      line 3, in main
+    error: it broke
+  ...
+`; out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+
+	out.Reset()
+	WriteJUnit(&out, results)
+	for _, expected := range []string{
+		`<testsuites tests="3" failures="2" time="0.000">`,
+		`<testsuite name="a_test.mon" tests="2" failures="1" time="0.000">`,
+		`<testcase name="test_ok" classname="a_test.mon" time="0.000"></testcase>`,
+		`<failure message="it broke">Traceback`,
+		`<testcase name="b_test.mon" classname="b_test.mon" time="0.000">`,
+		`<failure message="no prefix parse function for ;">`,
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in:\n%s", expected, out.String())
+		}
+	}
+}
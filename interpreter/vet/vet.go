@@ -0,0 +1,506 @@
+// Package vet reports code which parses, but is likely to go wrong when
+// it runs: unused variables, names which were never declared, calls to
+// functions with the wrong number of arguments, code which can't be
+// reached, and constants which are changed.
+//
+// Names are resolved as the evaluator resolves them, so the bodies of
+// functions see everything their enclosing scope declares, wherever it
+// comes, while other code only sees what came before it.
+package vet
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kasworld/nonkey/config/builtinfunctions"
+	"github.com/kasworld/nonkey/enum/tokentype"
+	"github.com/kasworld/nonkey/interpreter/ast"
+	"github.com/kasworld/nonkey/interpreter/asti"
+	"github.com/kasworld/nonkey/interpreter/lexer"
+	"github.com/kasworld/nonkey/interpreter/parser"
+	"github.com/kasworld/nonkey/interpreter/token"
+)
+
+// The checks which diagnostics come from.
+const (
+	Syntax      = "syntax"
+	Unused      = "unused"
+	Undeclared  = "undeclared"
+	Unknown     = "unknown"
+	Arity       = "arity"
+	Unreachable = "unreachable"
+	Const       = "const"
+)
+
+// Diagnostic is a problem found in a program.
+type Diagnostic struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Check   string `json:"check"`
+	Message string `json:"message"`
+}
+
+// String returns the diagnostic as file:line:col: message.
+func (d Diagnostic) String() string {
+	pos := fmt.Sprintf("%d:%d", d.Line, d.Column)
+	if d.File != "" {
+		pos = d.File + ":" + pos
+	}
+	return pos + ": " + d.Message
+}
+
+// Source parses and vets the program src, from the file name.  Errors
+// parsing it are returned as Syntax diagnostics.
+//
+// Names declared by the preludes, such as a file given to -autoload,
+// are taken as declared.
+func Source(name, src string, preludes ...*ast.Program) []Diagnostic {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	var diags []Diagnostic
+	if errs := p.Errors(); len(errs) > 0 {
+		for _, err := range errs {
+			diags = append(diags, Diagnostic{
+				Line:    err.Line + 1,
+				Column:  err.Pos,
+				Check:   Syntax,
+				Message: err.Msg,
+			})
+		}
+	} else {
+		diags = Program(program, preludes...)
+	}
+	for i := range diags {
+		diags[i].File = name
+	}
+	return diags
+}
+
+// Program vets program, returning what it finds in source order.
+func Program(program *ast.Program, preludes ...*ast.Program) []Diagnostic {
+	c := &checker{}
+
+	global := newScope(nil, false)
+	for _, prelude := range preludes {
+		for _, stmt := range prelude.Statements {
+			c.predeclare(global, stmt)
+		}
+	}
+	c.block(global, program.Statements)
+	c.close(global)
+
+	sort.SliceStable(c.diags, func(i, j int) bool {
+		a, b := c.diags[i], c.diags[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return c.diags
+}
+
+// kind is how a name was declared.
+type kind int
+
+const (
+	declLet kind = iota
+	declConst
+	declFunction
+	declParam
+	declLoop
+	declImplicit
+)
+
+// binding is a declared name.
+type binding struct {
+	tok  token.Token
+	kind kind
+	used bool
+
+	// params is set when the name is a function, which wasn't
+	// assigned to since.
+	params *params
+}
+
+// params holds the number of arguments a function takes.
+type params struct {
+	min, max int
+}
+
+func newParams(list []*ast.Identifier, defaults map[string]asti.ExpressionI) *params {
+	p := &params{max: len(list)}
+	for _, param := range list {
+		if _, ok := defaults[param.Value]; !ok {
+			p.min++
+		}
+	}
+	return p
+}
+
+// scope holds the names declared by a function, or the program.
+//
+// A loop scope holds only the variables of a foreach or a select case,
+// as anything else declared in their body goes to the scope outside,
+// just as the evaluator stores it.
+type scope struct {
+	outer *scope
+	loop  bool
+
+	names map[string]*binding
+	order []*binding
+
+	// deferred holds the bodies of the functions defined here, to
+	// be checked once every name here is known.
+	deferred []func()
+}
+
+func newScope(outer *scope, loop bool) *scope {
+	return &scope{outer: outer, loop: loop, names: make(map[string]*binding)}
+}
+
+// function returns the scope which holds what s declares.
+func (s *scope) function() *scope {
+	for s.loop {
+		s = s.outer
+	}
+	return s
+}
+
+// lookup finds a name, returning the scope it's in as well.
+func (s *scope) lookup(name string) (*binding, *scope) {
+	for ; s != nil; s = s.outer {
+		if b, ok := s.names[name]; ok {
+			return b, s
+		}
+	}
+	return nil, nil
+}
+
+// checker holds the diagnostics found so far.
+type checker struct {
+	diags []Diagnostic
+}
+
+func (c *checker) report(tok token.Token, check, format string, args ...interface{}) {
+	c.diags = append(c.diags, Diagnostic{
+		Line:    tok.Line + 1,
+		Column:  tok.Pos,
+		Check:   check,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// declare binds name in s, or the scope outside it if s is a loop
+// scope and this isn't a loop variable.
+func (c *checker) declare(s *scope, name string, tok token.Token, k kind, p *params) *binding {
+	if k != declLoop {
+		s = s.function()
+	}
+	old, ok := s.names[name]
+	if ok && old.kind == declConst {
+		c.report(tok, Const, "assignment to constant %s", name)
+	}
+	// Methods, such as string.len, are given the object they're
+	// invoked on besides their arguments.
+	if strings.Contains(name, ".") {
+		p = nil
+	}
+	if ok && k == declLet && old.kind == declLet {
+		// Declaring a variable again just sets it.
+		old.params = p
+		return old
+	}
+	b := &binding{tok: tok, kind: k, params: p}
+	s.names[name] = b
+	s.order = append(s.order, b)
+	return b
+}
+
+// predeclare declares the names a prelude defines at its top level.
+func (c *checker) predeclare(s *scope, stmt asti.StatementI) {
+	switch n := stmt.(type) {
+	case *ast.LetStatement:
+		c.declare(s, n.Name.Value, n.Name.Token, declImplicit, nil)
+	case *ast.ConstStatement:
+		c.declare(s, n.Name.Value, n.Name.Token, declConst, nil)
+	case *ast.ExpressionStatement:
+		if fn, ok := n.Expression.(*ast.FunctionDefineLiteral); ok {
+			c.declare(s, fn.Token.Literal, fn.Token, declFunction, newParams(fn.Parameters, fn.Defaults))
+		}
+	}
+}
+
+// close checks the bodies of the functions defined in s, then reports
+// the variables it declared which were never used.
+func (c *checker) close(s *scope) {
+	for len(s.deferred) > 0 {
+		fn := s.deferred[0]
+		s.deferred = s.deferred[1:]
+		fn()
+	}
+	for _, b := range s.order {
+		if b.kind == declLet && !b.used && !strings.HasPrefix(b.tok.Literal, "_") {
+			c.report(b.tok, Unused, "%s declared but not used", b.tok.Literal)
+		}
+	}
+}
+
+// block checks a list of statements.
+func (c *checker) block(s *scope, stmts []asti.StatementI) {
+	returned := false
+	for _, stmt := range stmts {
+		if returned {
+			c.report(start(stmt), Unreachable, "unreachable code")
+			returned = false
+		}
+		c.statement(s, stmt)
+		if _, ok := stmt.(*ast.ReturnStatement); ok {
+			returned = true
+		}
+	}
+}
+
+func (c *checker) statement(s *scope, stmt asti.StatementI) {
+	switch n := stmt.(type) {
+	case *ast.LetStatement:
+		c.expr(s, n.Value)
+		c.declare(s, n.Name.Value, n.Name.Token, declLet, funcParams(n.Value))
+	case *ast.ConstStatement:
+		c.expr(s, n.Value)
+		c.declare(s, n.Name.Value, n.Name.Token, declConst, funcParams(n.Value))
+	case *ast.ReturnStatement:
+		c.expr(s, n.ReturnValue)
+	case *ast.YieldStatement:
+		c.expr(s, n.Value)
+	case *ast.ExpressionStatement:
+		c.expr(s, n.Expression)
+	case *ast.BlockStatement:
+		c.block(s, n.Statements)
+	}
+}
+
+// funcParams returns the parameters of e, if it's a function.
+func funcParams(e asti.ExpressionI) *params {
+	if fn, ok := e.(*ast.FunctionLiteral); ok {
+		return newParams(fn.Parameters, fn.Defaults)
+	}
+	return nil
+}
+
+func (c *checker) body(s *scope, b *ast.BlockStatement) {
+	if b != nil {
+		c.block(s, b.Statements)
+	}
+}
+
+func (c *checker) exprs(s *scope, list []asti.ExpressionI) {
+	for _, e := range list {
+		c.expr(s, e)
+	}
+}
+
+func (c *checker) expr(s *scope, e asti.ExpressionI) {
+	switch n := e.(type) {
+	case nil:
+	case *ast.Identifier:
+		if b := c.use(s, n.Value); b == nil {
+			c.report(n.Token, Unknown, "identifier not found: %s", n.Value)
+		}
+	case *ast.PrefixExpression:
+		c.expr(s, n.Right)
+	case *ast.InfixExpression:
+		c.expr(s, n.Left)
+		c.expr(s, n.Right)
+	case *ast.PostfixExpression:
+		c.assign(s, n.Token.Literal, n.Token, n.Operator)
+	case *ast.TernaryExpression:
+		c.expr(s, n.Condition)
+		c.expr(s, n.IfTrue)
+		c.expr(s, n.IfFalse)
+	case *ast.IfExpression:
+		c.expr(s, n.Condition)
+		c.body(s, n.Consequence)
+		c.body(s, n.Alternative)
+	case *ast.ForLoopExpression:
+		c.expr(s, n.Condition)
+		c.body(s, n.Consequence)
+	case *ast.ForeachStatement:
+		c.expr(s, n.Value)
+		loop := newScope(s, true)
+		if n.Index != "" {
+			c.declare(loop, n.Index, n.Token, declLoop, nil)
+		}
+		c.declare(loop, n.Ident, n.Token, declLoop, nil)
+		c.body(loop, n.Body)
+	case *ast.FunctionLiteral:
+		c.function(s, n.Token, n.Parameters, n.Defaults, n.Body, false)
+	case *ast.FunctionDefineLiteral:
+		c.declare(s, n.Token.Literal, n.Token, declFunction, newParams(n.Parameters, n.Defaults))
+		c.function(s, n.Token, n.Parameters, n.Defaults, n.Body, strings.Contains(n.Token.Literal, "."))
+	case *ast.CallExpression:
+		if id, ok := n.Function.(*ast.Identifier); ok {
+			c.call(s, id, len(n.Arguments))
+		} else {
+			c.expr(s, n.Function)
+		}
+		c.exprs(s, n.Arguments)
+	case *ast.ObjectCallExpression:
+		c.expr(s, n.Object)
+		// The method is named, not looked up.
+		if call, ok := n.Call.(*ast.CallExpression); ok {
+			c.exprs(s, call.Arguments)
+		}
+	case *ast.IndexExpression:
+		c.expr(s, n.Left)
+		c.expr(s, n.Index)
+	case *ast.SliceExpression:
+		c.expr(s, n.Left)
+		c.expr(s, n.Start)
+		c.expr(s, n.End)
+		c.expr(s, n.Step)
+	case *ast.ArrayLiteral:
+		c.exprs(s, n.Elements)
+	case *ast.SetLiteral:
+		c.exprs(s, n.Elements)
+	case *ast.HashLiteral:
+		for _, key := range n.Keys {
+			c.expr(s, key)
+			c.expr(s, n.Pairs[key])
+		}
+	case *ast.SwitchExpression:
+		c.expr(s, n.Value)
+		for _, choice := range n.Choices {
+			c.exprs(s, choice.Expr)
+			c.body(s, choice.Block)
+		}
+	case *ast.SelectExpression:
+		for _, sc := range n.Cases {
+			c.expr(s, sc.Channel)
+			inner := s
+			if sc.Ident != "" {
+				inner = newScope(s, true)
+				c.declare(inner, sc.Ident, sc.Token, declLoop, nil)
+			}
+			c.body(inner, sc.Block)
+		}
+	case *ast.SpawnExpression:
+		c.expr(s, n.Call)
+	case *ast.AssignStatement:
+		c.expr(s, n.Value)
+		if n.Target != nil {
+			c.expr(s, n.Target)
+			break
+		}
+		if b := c.assign(s, n.Name.Value, n.Name.Token, n.Operator); b != nil {
+			// It's no longer known which function this is.
+			b.params = funcParams(n.Value)
+		}
+	}
+}
+
+// use marks a name as used, returning its binding.  Builtins and the
+// names the evaluator sets itself return a binding which isn't kept.
+func (c *checker) use(s *scope, name string) *binding {
+	if b, _ := s.lookup(name); b != nil {
+		b.used = true
+		return b
+	}
+	if _, ok := builtinfunctions.BuiltinFunctions[name]; ok {
+		return &binding{kind: declImplicit}
+	}
+	// Regexp captures.
+	if strings.HasPrefix(name, "$") {
+		return &binding{kind: declImplicit}
+	}
+	return nil
+}
+
+// call checks a call of the function name.
+func (c *checker) call(s *scope, id *ast.Identifier, args int) {
+	b := c.use(s, id.Value)
+	if b == nil {
+		c.report(id.Token, Unknown, "call of unknown function %s", id.Value)
+		return
+	}
+	if p := b.params; p != nil && (args < p.min || args > p.max) {
+		want := fmt.Sprintf("%d", p.max)
+		if p.min != p.max {
+			want = fmt.Sprintf("%d to %d", p.min, p.max)
+		}
+		c.report(id.Token, Arity, "%s takes %s arguments, called with %d", id.Value, want, args)
+	}
+}
+
+// assign checks an assignment to name, returning its binding.
+func (c *checker) assign(s *scope, name string, tok token.Token, op tokentype.TokenType) *binding {
+	b, where := s.lookup(name)
+	if b == nil {
+		if op != tokentype.ASSIGN {
+			c.report(tok, Unknown, "identifier not found: %s", name)
+			return nil
+		}
+		c.report(tok, Undeclared, "assignment to undeclared %s", name)
+		return c.declare(s, name, tok, declImplicit, nil)
+	}
+	// Constants are only protected in the scope which declared
+	// them; elsewhere the assignment makes a new variable.
+	if b.kind == declConst && where == s.function() {
+		c.report(tok, Const, "assignment to constant %s", name)
+	}
+	return b
+}
+
+// function checks the body of a function defined in s, once the rest
+// of s has been.  Methods have self set to the object they're invoked
+// on.
+func (c *checker) function(s *scope, tok token.Token, list []*ast.Identifier, defaults map[string]asti.ExpressionI, body *ast.BlockStatement, method bool) {
+	outer := s.function()
+	outer.deferred = append(outer.deferred, func() {
+		fn := newScope(s, false)
+		if method {
+			c.declare(fn, "self", tok, declImplicit, nil)
+		}
+		for _, param := range list {
+			c.declare(fn, param.Value, param.Token, declParam, nil)
+		}
+		for _, param := range list {
+			c.expr(fn, defaults[param.Value])
+		}
+		c.body(fn, body)
+		c.close(fn)
+	})
+}
+
+// start returns the first token of a statement.
+func start(stmt asti.StatementI) token.Token {
+	var e asti.ExpressionI
+	if es, ok := stmt.(*ast.ExpressionStatement); ok {
+		e = es.Expression
+	}
+	for e != nil {
+		switch n := e.(type) {
+		case *ast.InfixExpression:
+			e = n.Left
+		case *ast.CallExpression:
+			e = n.Function
+		case *ast.ObjectCallExpression:
+			e = n.Object
+		case *ast.IndexExpression:
+			e = n.Left
+		case *ast.SliceExpression:
+			e = n.Left
+		case *ast.TernaryExpression:
+			e = n.Condition
+		case *ast.AssignStatement:
+			if n.Target == nil {
+				return n.Name.Token
+			}
+			e = n.Target
+		default:
+			return e.GetToken()
+		}
+	}
+	return stmt.GetToken()
+}
@@ -0,0 +1,87 @@
+package vet
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/kasworld/nonkey/interpreter/lexer"
+	"github.com/kasworld/nonkey/interpreter/parser"
+
+	// The evaluator registers the builtins.
+	_ "github.com/kasworld/nonkey/interpreter/evaluator"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		// Clean programs.
+		{`let a = 1; puts(a);`, nil},
+		{`function f(a, b = 2) { return a + b; } f(1); f(1, 2);`, nil},
+		{`function f() { return g(); } function g() { return 1; } f();`, nil},
+		{`foreach i, x in [1] { let y = x; puts(i, y); }`, nil},
+		{`if ("a" ~= /(a)/) { puts($1); }`, nil},
+		{`function string.twice() { return self + self; } puts("a".twice());`, nil},
+		{`let c = chan(1); select { case v in c { puts(v); } }`, nil},
+		{`let _ignored = 1;`, nil},
+		{`let a = 1; let a = a + 1; puts(a);`, nil},
+		{`const A = 1; function f() { A = 2; return A; } f();`, nil},
+		{`let h = {}; h.a = 1; h["b"] = 2;`, nil},
+		{`puts(math.sqrt(4), os.getenv("HOME"));`, nil},
+
+		{`let a = 1;`, []string{"1:5: unused: a declared but not used"}},
+		{`function f() { let a = 1; return 2; } f();`, []string{"1:20: unused: a declared but not used"}},
+		{`let x = [1]; puts(x.len()); let y = 2;`, []string{"1:33: unused: y declared but not used"}},
+		{"let x = [1];\nfunction f() { x.len(); let y = 2; } f();", []string{"2:29: unused: y declared but not used"}},
+		{`a = 1; puts(a);`, []string{"1:1: undeclared: assignment to undeclared a"}},
+		{`puts(b);`, []string{"1:6: unknown: identifier not found: b"}},
+		{`b += 1;`, []string{"1:1: unknown: identifier not found: b"}},
+		{`nosuch(1);`, []string{"1:1: unknown: call of unknown function nosuch"}},
+		{`math.nosuch(1);`, []string{"1:1: unknown: identifier not found: math"}},
+		{`function f(a) { return a; } f(1, 2);`, []string{"1:29: arity: f takes 1 arguments, called with 2"}},
+		{`let f = fn(a, b = 1) { return a; }; f();`, []string{"1:37: arity: f takes 1 to 2 arguments, called with 0"}},
+		{`function f() { return 1; puts(2); } f();`, []string{"1:26: unreachable: unreachable code"}},
+		{`const A = 1; A = 2;`, []string{"1:14: const: assignment to constant A"}},
+		{`const A = 1; let A = 2; puts(A);`, []string{"1:18: const: assignment to constant A"}},
+		{`const A = 1; foreach x in [1] { A++; }`, []string{"1:33: const: assignment to constant A"}},
+
+		// Functions see names declared after them.
+		{`function f() { return later; } puts(f());`, []string{"1:23: unknown: identifier not found: later"}},
+		{`function f() { return later; } let later = 1; puts(f());`, nil},
+
+		{`let a = ;`, []string{"1:9: syntax: no prefix parse function for ;"}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, d := range Source("", tt.input) {
+			got = append(got, fmt.Sprintf("%d:%d: %s: %s", d.Line, d.Column, d.Check, d.Message))
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestPrelude(t *testing.T) {
+	prelude := parser.New(lexer.New(`function helper(a) { return a; } const PI = 3;`)).ParseProgram()
+
+	diags := Source("main.mon", `puts(helper(PI)); helper();`, prelude)
+	if len(diags) != 1 {
+		t.Fatalf("expected one diagnostic, got %v", diags)
+	}
+	out, err := json.Marshal(diags[0])
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	expected := `{"file":"main.mon","line":1,"column":19,"check":"arity","message":"helper takes 1 arguments, called with 0"}`
+	if string(out) != expected {
+		t.Errorf("expected %s, got %s", expected, out)
+	}
+	if diags[0].String() != "main.mon:1:19: helper takes 1 arguments, called with 0" {
+		t.Errorf("wrong text: %s", diags[0])
+	}
+}
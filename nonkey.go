@@ -25,10 +25,29 @@ func init() {
 	version.Set(Ver)
 }
 
+// commands holds the subcommands, which are given in place of a file
+// to run, each taking the arguments after its name and returning the
+// status to exit with.
+var commands = map[string]func(args []string) int{
+	"debug": runDebug,
+	"fmt":   runFmt,
+	"lsp":   runLsp,
+	"test":  runTest,
+	"vet":   runVet,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			os.Exit(cmd(os.Args[2:]))
+		}
+	}
+
 	eval := flag.String("eval", "", "Code to execute.")
 	vers := flag.Bool("version", false, "Show our version and exit.")
 	autoload := flag.String("autoload", "", "autoload filename")
+	profile := flag.String("profile", "", "write a pprof profile of the script to this file, and a report to stderr")
+	cover := flag.String("cover", "", "write a coverage profile of the script to this file, and an HTML report beside it")
 	flag.Parse()
 
 	// show version
@@ -48,7 +67,17 @@ func main() {
 		os.Exit(1)
 	} else {
 		if len(flag.Args()) > 0 { // run file
-			runmon.RunFile(os.Args[1], env)
+			if *profile != "" && *cover != "" {
+				fmt.Fprintf(os.Stderr, "-profile and -cover can't be used together\n")
+				os.Exit(2)
+			}
+			if *profile != "" {
+				os.Exit(runProfiled(flag.Arg(0), *profile, env))
+			}
+			if *cover != "" {
+				os.Exit(runCovered(flag.Arg(0), *cover, env))
+			}
+			runmon.RunFile(flag.Arg(0), env)
 		} else { // repl line by line
 			repl.Start(os.Stdin, os.Stdout, env)
 		}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/kasworld/nonkey/interpreter/object"
+	"github.com/kasworld/nonkey/interpreter/profile"
+	"github.com/kasworld/nonkey/interpreter/runmon"
+)
+
+// reportTop is how many functions and lines the profile report lists.
+const reportTop = 20
+
+// runProfiled implements `nonkey -profile out.prof script`, which
+// runs the script in env writing a profile of it for `go tool pprof`
+// to out, and a report of where it spent its time to stderr.
+func runProfiled(name, out string, env *object.Environment) int {
+	src, err := ioutil.ReadFile(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "profile: %s\n", err)
+		return 2
+	}
+
+	program := runmon.Parse(name, string(src))
+	p := profile.New(name, string(src), program)
+	p.Start(env)
+	if program != nil {
+		runmon.RunProgram(name, string(src), program, env)
+	}
+	p.Stop(env)
+
+	p.Report(os.Stderr, reportTop)
+
+	if err := writeFile(out, p.WritePprof); err != nil {
+		fmt.Fprintf(os.Stderr, "profile: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+// writeFile creates the file name, with write writing its contents.
+func writeFile(name string, write func(w io.Writer) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}